@@ -0,0 +1,260 @@
+// Package config resolves the configuration of the application from the defaults, the configuration file, the
+// environment variables and the command line flags.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/caarlos0/env"
+)
+
+// Config contains the settings of the whole application.
+// The tags define the key in the JSON configuration file, the environment variable and the command line flag.
+type Config struct {
+	// Postgresql is the connection string of the database engine.
+	Postgresql string `json:"postgresql" env:"PGSQL" flag:"postgresql"`
+	// Tenant is the tenant of the documents in the database engine.
+	Tenant string `json:"tenant" env:"TENANT" flag:"tenant"`
+	// FlushWorkers is the number of the workers inserting the occurrences to the database in parallel, default 1.
+	FlushWorkers int `json:"flush_workers" env:"FLUSH_WORKERS" flag:"flushWorkers"`
+	// FlushMemory is the estimated size in bytes of the batch of the occurrences inserted to the database without
+	// waiting for the periodic insert, 0 disables it.
+	FlushMemory int `json:"flush_memory" env:"FLUSH_MEMORY" flag:"flushMemory"`
+	// OccurrenceConflict is the handling of the occurrences already stored in the database: ignore (default) or fail.
+	OccurrenceConflict string `json:"occurrence_conflict" env:"OCCURRENCE_CONFLICT" flag:"occurrenceConflict"`
+	// Listen is the interface of the web server, the interactive CLI is used if it is empty.
+	Listen string `json:"listen" env:"LISTEN" flag:"listen"`
+	// Prompt is written before every query read by the interactive CLI from the terminal.
+	Prompt string `json:"prompt" env:"PROMPT" flag:"prompt"`
+	// Strict stops the interactive CLI on the first failed search instead of printing the error.
+	Strict bool `json:"strict" env:"STRICT" flag:"strict"`
+	// Timeout is the read and write timeout of the web server.
+	Timeout time.Duration `json:"timeout" env:"TIMEOUT" flag:"timeout"`
+	// Progress is the interval of the build progress messages, 0 disables them.
+	Progress time.Duration `json:"progress" env:"PROGRESS" flag:"progress"`
+	// Static is the directory with the static files of the web UI overriding the embedded ones, e.g. style.css.
+	Static string `json:"static" env:"STATIC" flag:"static"`
+	// Gzip enables the gzip compression of the web server responses.
+	Gzip bool `json:"gzip" env:"GZIP" flag:"gzip"`
+	// GzipMinSize is the minimal size of the compressed response in bytes.
+	GzipMinSize int `json:"gzip_min_size" env:"GZIP_MIN_SIZE" flag:"gzipMinSize"`
+	// LogLevel is the minimal level of the log messages.
+	LogLevel string `json:"log_level" env:"LOG_LEVEL" flag:"logLevel"`
+	// LogFormat is the format of the log messages: json or console.
+	LogFormat string `json:"log_format" env:"LOG_FORMAT" flag:"logFormat"`
+	// Ranker is the name of the range algorithm.
+	Ranker string `json:"ranker" env:"RANKER" flag:"ranker"`
+	// Stemmer is the name of the stemmer. The same stemmer must be used to build and to search.
+	Stemmer string `json:"stemmer" env:"STEMMER" flag:"stemmer"`
+	// Operator is the operator between the bare query terms: AND (default) or OR.
+	Operator string `json:"operator" env:"OPERATOR" flag:"operator"`
+	// HalfLife is the age of the document halving its score, 0 means the score does not depend on the age.
+	HalfLife time.Duration `json:"half_life" env:"HALF_LIFE" flag:"halfLife"`
+	// Stopwords is the path to the file or the language code of the additional stopwords.
+	Stopwords string `json:"stopwords" env:"STOPWORDS" flag:"stopwords"`
+	// StopwordsOnly ignores only the Stopwords instead of adding them to the built-in English ones.
+	StopwordsOnly bool `json:"stopwords_only" env:"STOPWORDS_ONLY" flag:"stopwordsOnly"`
+	// SplitIdentifiers indexes the whole words joined by underscores, dots and slashes in addition to their parts.
+	// The same setting must be used to build and to search.
+	SplitIdentifiers bool `json:"split_identifiers" env:"SPLIT_IDENTIFIERS" flag:"splitIdentifiers"`
+	// Apostrophes is the handling of the apostrophes inside the words: split (default) or strip. The same setting must
+	// be used to build and to search.
+	Apostrophes string `json:"apostrophes" env:"APOSTROPHES" flag:"apostrophes"`
+	// MinTokenLength drops the words shorter than the number of letters from the documents and the queries, default 2.
+	// 0 keeps all words. The same setting should be used to build and to search.
+	MinTokenLength int `json:"min_token_length" env:"MIN_TOKEN_LENGTH" flag:"minTokenLength"`
+	// ExactBoost multiplies the score of the documents containing the original form of the query term, 1 or less
+	// disables the boost. The original forms are indexed only if it is set, so the same setting must be used to build
+	// and to search.
+	ExactBoost float64 `json:"exact_boost" env:"EXACT_BOOST" flag:"exactBoost"`
+	// Language is the code of the language of the built documents, they are analyzed with the stemmer and the
+	// stopwords of the language.
+	Language string `json:"language" env:"LANGUAGE" flag:"language"`
+	// MaxFiles is the maximal number of the files indexed by the build in the order of their names, 0 means no limit.
+	MaxFiles int `json:"max_files" env:"MAX_FILES" flag:"maxFiles"`
+	// Workers is the number of the files read by the build in parallel, 0 means the number of CPUs.
+	Workers int `json:"workers" env:"WORKERS" flag:"workers"`
+	// ReadErrors is the handling of the files which can not be read by the build: skip, fail or strict.
+	ReadErrors string `json:"read_errors" env:"READ_ERRORS" flag:"readErrors"`
+	// Records is the format of the files whose records are indexed as separate documents: csv or jsonl. Empty indexes
+	// every file as one document.
+	Records string `json:"records" env:"RECORDS" flag:"records"`
+	// RetainContent keeps the raw texts of the documents in the index file to return them with the API.
+	RetainContent bool `json:"retain_content" env:"RETAIN_CONTENT" flag:"retainContent"`
+	// Snippets keeps the words of the documents in the index file and returns the excerpts of the texts around the
+	// matched words with the search results. The same setting must be used to build and to search.
+	Snippets bool `json:"snippets" env:"SNIPPETS" flag:"snippets"`
+	// RecordName is the field of the record used as the document name.
+	RecordName string `json:"record_name" env:"RECORD_NAME" flag:"recordName"`
+	// RecordContent is the field of the record used as the document content.
+	RecordContent string `json:"record_content" env:"RECORD_CONTENT" flag:"recordContent"`
+	// RecordMetadata are the comma-separated fields of the record kept as the document metadata, e.g. to order the
+	// results by the field. The metadata are kept by the index file only.
+	RecordMetadata string `json:"record_metadata" env:"RECORD_METADATA" flag:"recordMetadata"`
+	// Dedup skips the documents with the same content as the documents already indexed by the build.
+	Dedup bool `json:"dedup" env:"DEDUP" flag:"dedup"`
+	// Incremental skips the files with the same content as the documents already indexed and replaces the changed ones
+	// instead of building the index from scratch.
+	Incremental bool `json:"incremental" env:"INCREMENTAL" flag:"incremental"`
+	// NameCollision is the handling of the documents built with the same name: serialize or reject, empty indexes them
+	// concurrently.
+	NameCollision string `json:"name_collision" env:"NAME_COLLISION" flag:"nameCollision"`
+	// Counts makes the database engine fetch only the number of occurrences.
+	Counts bool `json:"counts" env:"COUNTS" flag:"counts"`
+	// QueryCache is the number of recent queries with cached results, 0 disables the cache.
+	QueryCache int `json:"query_cache" env:"QUERY_CACHE" flag:"queryCache"`
+	// QueryCacheFile is the file the query cache is saved to on shutdown and loaded from on start. The saved cache is
+	// discarded if the index file or the search settings have changed. It is not supported by the database index.
+	QueryCacheFile string `json:"query_cache_file" env:"QUERY_CACHE_FILE" flag:"queryCacheFile"`
+	// Warmup is the file with the popular queries one per line run on start to populate the query cache.
+	Warmup string `json:"warmup" env:"WARMUP" flag:"warmup"`
+	// MaxWordSize is the maximal size of the indexed word in bytes, the longer words are skipped. 0 means the default
+	// size of 64KB.
+	MaxWordSize int `json:"max_word_size" env:"MAX_WORD_SIZE" flag:"maxWordSize"`
+	// AdminToken is the bearer token of the admin API, the admin API is disabled if it is empty.
+	AdminToken string `json:"admin_token" env:"ADMIN_TOKEN" flag:"adminToken"`
+	// ProtectContent authorizes the requests of the raw texts of the documents with the admin token.
+	ProtectContent bool `json:"protect_content" env:"PROTECT_CONTENT" flag:"protectContent"`
+	// Limit is the maximal number of search results, 0 means no limit.
+	Limit int `json:"limit" env:"LIMIT" flag:"limit"`
+	// MaxCandidates is the maximal number of the documents matching the query before ranking, 0 means no limit.
+	MaxCandidates int `json:"max_candidates" env:"MAX_CANDIDATES" flag:"maxCandidates"`
+	// PhraseBoost multiplies the score of the documents containing the exact phrase of the query enclosed in double
+	// quotes, 1 or less disables the boost.
+	PhraseBoost float64 `json:"phrase_boost" env:"PHRASE_BOOST" flag:"phraseBoost"`
+	// StrictPhrases finds only the documents containing the quoted phrases of the query.
+	StrictPhrases bool `json:"strict_phrases" env:"STRICT_PHRASES" flag:"strictPhrases"`
+	// QueryTimeout is the timeout of the database queries of the search, 0 means no timeout. The search API can return
+	// the partial results fetched before the timeout.
+	QueryTimeout time.Duration `json:"query_timeout" env:"QUERY_TIMEOUT" flag:"queryTimeout"`
+	// MaxTokenCount caps the number of occurrences of every token counted by the ranker, 0 means no cap.
+	MaxTokenCount int `json:"max_token_count" env:"MAX_TOKEN_COUNT" flag:"maxTokenCount"`
+	// EarlyBoost multiplies the score of the documents starting with the query terms, the boost fades with the
+	// position of the first occurrence, 1 or less disables the boost.
+	EarlyBoost float64 `json:"early_boost" env:"EARLY_BOOST" flag:"earlyBoost"`
+	// NormalizeLength divides the scores by the length of the document relative to the average length.
+	NormalizeLength bool `json:"normalize_length" env:"NORMALIZE_LENGTH" flag:"normalizeLength"`
+}
+
+// Default returns the configuration used when no other source sets the value.
+func Default() Config {
+	return Config{
+		Timeout:        10 * time.Second,
+		Progress:       10 * time.Second,
+		GzipMinSize:    1024,
+		LogLevel:       "debug",
+		LogFormat:      "json",
+		Ranker:         "count",
+		Stemmer:        "porter",
+		PhraseBoost:    2,
+		Prompt:         "> ",
+		ReadErrors:     "skip",
+		RecordName:     "name",
+		RecordContent:  "content",
+		NameCollision:  "serialize",
+		MinTokenLength: 2,
+	}
+}
+
+// UnmarshalJSON decodes the configuration file with durations written as strings, e.g. "10s".
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type plain Config
+	aux := struct {
+		*plain
+		Timeout      string `json:"timeout"`
+		Progress     string `json:"progress"`
+		HalfLife     string `json:"half_life"`
+		QueryTimeout string `json:"query_timeout"`
+	}{plain: (*plain)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if err := parseDuration("timeout", aux.Timeout, &c.Timeout); err != nil {
+		return err
+	}
+	if err := parseDuration("progress", aux.Progress, &c.Progress); err != nil {
+		return err
+	}
+	if err := parseDuration("half_life", aux.HalfLife, &c.HalfLife); err != nil {
+		return err
+	}
+	return parseDuration("query_timeout", aux.QueryTimeout, &c.QueryTimeout)
+}
+
+// parseDuration sets the duration if the value is not empty.
+func parseDuration(name string, value string, duration *time.Duration) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("incorrect %s %q: %w", name, value, err)
+	}
+	*duration = parsed
+	return nil
+}
+
+// Flags is the interface of the command line flags implemented by urfave/cli context.
+type Flags interface {
+	IsSet(name string) bool
+	String(name string) string
+	Bool(name string) bool
+	Int(name string) int
+	Float64(name string) float64
+	Duration(name string) time.Duration
+}
+
+// Load resolves the configuration. Every source overrides the previous ones in the following order:
+// defaults, JSON configuration file if the file name is not empty, environment variables, command line flags set
+// explicitly.
+func Load(file string, flags Flags) (*Config, error) {
+	c := Default()
+
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("can not open config file %s: %w", file, err)
+		}
+		defer f.Close()
+		if err := json.NewDecoder(f).Decode(&c); err != nil {
+			return nil, fmt.Errorf("can not read config file %s: %w", file, err)
+		}
+	}
+
+	if err := env.Parse(&c); err != nil {
+		return nil, fmt.Errorf("can not read environment variables: %w", err)
+	}
+
+	if flags != nil {
+		applyFlags(&c, flags)
+	}
+	return &c, nil
+}
+
+func applyFlags(c *Config, flags Flags) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("flag")
+		if name == "" || !flags.IsSet(name) {
+			continue
+		}
+		field := v.Field(i)
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(flags.String(name))
+		case bool:
+			field.SetBool(flags.Bool(name))
+		case int:
+			field.SetInt(int64(flags.Int(name)))
+		case float64:
+			field.SetFloat(flags.Float64(name))
+		case time.Duration:
+			field.SetInt(int64(flags.Duration(name)))
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type flags map[string]interface{}
+
+func (f flags) IsSet(name string) bool {
+	_, ok := f[name]
+	return ok
+}
+
+func (f flags) String(name string) string {
+	v, _ := f[name].(string)
+	return v
+}
+
+func (f flags) Bool(name string) bool {
+	v, _ := f[name].(bool)
+	return v
+}
+
+func (f flags) Int(name string) int {
+	v, _ := f[name].(int)
+	return v
+}
+
+func (f flags) Float64(name string) float64 {
+	v, _ := f[name].(float64)
+	return v
+}
+
+func (f flags) Duration(name string) time.Duration {
+	v, _ := f[name].(time.Duration)
+	return v
+}
+
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func setenv(t *testing.T, name, value string) {
+	if err := os.Setenv(name, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	actual, err := Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Default()
+	if !reflect.DeepEqual(*actual, expected) {
+		t.Errorf("%v is not equal to expected %v", *actual, expected)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := writeConfig(t, `{
+		"postgresql": "postgres://file",
+		"listen": ":8080",
+		"timeout": "30s",
+		"log_level": "info",
+		"stemmer": "light",
+		"limit": 10
+	}`)
+	defer os.RemoveAll(filepath.Dir(file))
+
+	setenv(t, "LISTEN", ":9090")
+	setenv(t, "LOG_LEVEL", "warn")
+	setenv(t, "COUNTS", "true")
+	defer func() {
+		for _, name := range []string{"LISTEN", "LOG_LEVEL", "COUNTS"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	actual, err := Load(file, flags{
+		"logLevel": "error",
+		"timeout":  time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Default()
+	expected.Postgresql = "postgres://file"
+	expected.Stemmer = "light"
+	expected.Limit = 10
+	expected.Listen = ":9090"
+	expected.Counts = true
+	expected.LogLevel = "error"
+	expected.Timeout = time.Minute
+	if !reflect.DeepEqual(*actual, expected) {
+		t.Errorf("%+v is not equal to expected %+v", *actual, expected)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	if _, err := Load("/not/existing/config.json", nil); err == nil {
+		t.Error("missing file must fail")
+	}
+
+	file := writeConfig(t, `{"timeout": "forever"}`)
+	defer os.RemoveAll(filepath.Dir(file))
+	if _, err := Load(file, nil); err == nil {
+		t.Error("incorrect timeout must fail")
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/polisgo2020/search-tariel-x
 
-go 1.14
+go 1.16
 
 require (
 	github.com/caarlos0/env v3.5.0+incompatible
@@ -12,5 +12,6 @@ require (
 	github.com/rs/zerolog v1.18.0
 	github.com/urfave/cli/v2 v2.2.0
 	github.com/zoomio/stopwords v0.5.0
+	golang.org/x/term v0.0.0-20210220032956-6a3ed077a48d
 	golang.org/x/tools v0.0.0-20200413015812-1f08ef6002a8 // indirect
 )
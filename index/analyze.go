@@ -0,0 +1,122 @@
+package index
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Analyze splits the text into the tokens with the default analyzer of the index the same way as the documents are
+// indexed and the queries are parsed, e.g. to preview why the text does not match the query. The tokens are stemmed,
+// the stop words are removed.
+func (i *Index) Analyze(text string) []string {
+	return i.defaultAnalyzer().Analyze(text)
+}
+
+// AnalyzeLanguage splits the text into the tokens with the analyzer of the language, empty language uses the default
+// analyzer. ErrUnknownLanguage is returned if no analyzer is registered for the language.
+func (i *Index) AnalyzeLanguage(language string, text string) ([]string, error) {
+	analyzer, err := i.analyzer(language)
+	if err != nil {
+		return nil, err
+	}
+	return analyzer.Analyze(text), nil
+}
+
+// TokenPosition is the token of the document and the position of its word.
+type TokenPosition struct {
+	Token    string
+	Position int
+}
+
+// Tokenize returns the tokens of the text with the positions of their words exactly as the document of the language is
+// indexed, e.g. to see why the document does not match the query. Nothing is added to the index. Empty language uses
+// the default analyzer.
+func (i *Index) Tokenize(language string, text io.Reader) ([]TokenPosition, error) {
+	data, err := ioutil.ReadAll(text)
+	if err != nil {
+		return nil, fmt.Errorf("can not read text: %w", err)
+	}
+	var tokens []TokenPosition
+	_, err = i.scanTokens(Source{Language: language}, data, 0, func(token string, word string, position int) error {
+		tokens = append(tokens, TokenPosition{Token: token, Position: position})
+		return nil
+	})
+	return tokens, err
+}
+
+// Analyze splits the text into the words of letters and returns their stemmed tokens without stop words.
+// It is the only tokenization of the indexed documents and the queries, so they can not diverge.
+func (a Analyzer) Analyze(text string) []string {
+	var tokens []string
+	for _, word := range a.words(text) {
+		tokens = append(tokens, a.tokens(word)...)
+	}
+	return tokens
+}
+
+// words splits the text into the words of letters. The identifier separators are kept inside the words if
+// SplitIdentifiers is set, so the words are split into the parts by tokens function. The apostrophes are removed
+// before splitting if the analyzer strips them.
+func (a Analyzer) words(text string) []string {
+	words := strings.FieldsFunc(a.stripApostrophes(text), func(r rune) bool {
+		return !a.isWordRune(r)
+	})
+	if !a.SplitIdentifiers {
+		return words
+	}
+	trimmed := words[:0]
+	for _, word := range words {
+		if word = strings.Trim(word, identifierSeparators); word != "" {
+			trimmed = append(trimmed, word)
+		}
+	}
+	return trimmed
+}
+
+// isWordRune checks if the rune is the part of the word, i.e. the letter or the identifier separator if
+// SplitIdentifiers is set.
+func (a Analyzer) isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || a.SplitIdentifiers && isIdentifierSeparator(r)
+}
+
+// tokens returns the stemmed tokens of the word without stop words and short words. If SplitIdentifiers is set, the
+// word joined by the identifier separators produces the whole token followed by the tokens of its parts.
+func (a Analyzer) tokens(word string) []string {
+	words := []string{word}
+	if a.SplitIdentifiers {
+		if parts := strings.FieldsFunc(word, isIdentifierSeparator); len(parts) > 1 {
+			words = append(words, parts...)
+		}
+	}
+	var tokens []string
+	for _, w := range words {
+		if a.isShort(w) {
+			continue
+		}
+		token := a.stem(w)
+		if a.isStopWord(w, token) {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// isShort checks if the word has fewer letters than MinTokenLength. The length of the word is checked instead of its
+// stem, so the short stems of the longer words are kept.
+func (a Analyzer) isShort(word string) bool {
+	return a.MinTokenLength > 0 && utf8.RuneCountInString(word) < a.MinTokenLength
+}
+
+// WithMinTokenLength drops the tokens of the words shorter than the number of letters, e.g. `x` with the length 2, the
+// built-in English stopwords drop the single letters anyway. The documents and the query terms are analyzed the same
+// way, so the short query terms are dropped instead of finding nothing. 0 keeps all tokens, it is the default.
+func WithMinTokenLength(length int) Option {
+	return func(i *Index) {
+		i.minTokenLength = length
+	}
+}
@@ -0,0 +1,90 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIndex_Analyze(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil)
+	defer i.Close()
+	expected := []string{"appl", "banana", "appl"}
+	if actual := i.Analyze("Apples and (bananas), apple!"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestIndex_AnalyzeMinTokenLength(t *testing.T) {
+	for length, expected := range map[int][]string{
+		0: {"q", "appl", "ox", "ti"},
+		2: {"appl", "ox", "ti"},
+		3: {"appl", "ti"},
+	} {
+		// The built-in English stopwords contain the single letters.
+		i := NewIndex(NewMemoryIndex(), nil, WithMinTokenLength(length), WithStopwordsOnly(NewStopwords("the")))
+		// The stem of `ties` is shorter than the word, the length of the word is checked.
+		if actual := i.Analyze("q apples ox ties"); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%d: %v is not equal to expected %v", length, actual, expected)
+		}
+		i.Close()
+	}
+}
+
+func TestIndex_AnalyzeLanguage(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithLanguage("de", Analyzer{Stemmer: GermanStemmer}))
+	defer i.Close()
+	for language, expected := range map[string][]string{"": {"katzen"}, "de": {"katz"}} {
+		actual, err := i.AnalyzeLanguage(language, "Katzen")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", language, actual, expected)
+		}
+	}
+	if _, err := i.AnalyzeLanguage("xx", "Katzen"); !errors.Is(err, ErrUnknownLanguage) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownLanguage)
+	}
+}
+
+// TestAnalyze_IndexAndQuery checks that the same text produces the same tokens when it is indexed and searched.
+func TestAnalyze_IndexAndQuery(t *testing.T) {
+	for _, options := range [][]Option{nil, {WithIdentifierSplitting()}, {WithMinTokenLength(2)}} {
+		for _, text := range []string{
+			"don't stop",
+			"Hello, World!",
+			"e-mail foo_bar",
+			"config.json path/to/file",
+			"R2D2 and C3PO",
+			"Übung—macht «den» Meister",
+			"---",
+		} {
+			engine := NewMemoryIndex()
+			i := NewIndex(engine, nil, options...)
+			if err := i.AddSource("file1", bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+			i.Close()
+
+			indexed := []string{}
+			for token := range engine.Index {
+				indexed = append(indexed, token)
+			}
+			sort.Strings(indexed)
+
+			queried, _, err := i.parseQuery(text, i.defaultAnalyzer())
+			if err != nil {
+				t.Fatal(err)
+			}
+			queried = append([]string{}, queried...)
+			sort.Strings(queried)
+
+			if !reflect.DeepEqual(queried, indexed) {
+				t.Errorf("%s: %v is not equal to expected %v", text, queried, indexed)
+			}
+		}
+	}
+}
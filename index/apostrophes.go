@@ -0,0 +1,54 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Apostrophes is the handling of the apostrophes inside the words, e.g. `don't` or `John's`.
+type Apostrophes string
+
+// Handlings of the apostrophes.
+const (
+	// ApostrophesSplit splits the words at the apostrophes, e.g. `don't` into `don` and `t`, it is the default one.
+	ApostrophesSplit Apostrophes = "split"
+	// ApostrophesStrip removes the apostrophes joining the parts of the words, e.g. `don't` into `dont`.
+	ApostrophesStrip Apostrophes = "strip"
+)
+
+// apostrophes are the typewriter and the typographic apostrophes.
+const apostrophes = "'’"
+
+// ParseApostrophes returns the handling of the apostrophes by its case-insensitive name, empty name is
+// ApostrophesSplit.
+func ParseApostrophes(name string) (Apostrophes, error) {
+	switch apostrophes := Apostrophes(strings.ToLower(name)); apostrophes {
+	case "":
+		return ApostrophesSplit, nil
+	case ApostrophesSplit, ApostrophesStrip:
+		return apostrophes, nil
+	default:
+		return "", fmt.Errorf("unknown apostrophes handling %s, expected split or strip", name)
+	}
+}
+
+// WithApostrophes sets the handling of the apostrophes inside the words, ApostrophesSplit by default. The documents
+// and the queries are handled the same way, so the same option must be used to build and to search over the index.
+func WithApostrophes(apostrophes Apostrophes) Option {
+	return func(i *Index) {
+		i.apostrophes = apostrophes
+	}
+}
+
+// stripApostrophes removes the apostrophes from the text if the analyzer strips them.
+func (a Analyzer) stripApostrophes(text string) string {
+	if a.Apostrophes != ApostrophesStrip {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(apostrophes, r) {
+			return -1
+		}
+		return r
+	}, text)
+}
@@ -0,0 +1,63 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestIndex_SearchApostrophes(t *testing.T) {
+	for _, test := range []struct {
+		apostrophes Apostrophes
+		query       string
+		expected    []string
+	}{
+		{ApostrophesSplit, "don't", []string{"file1"}},
+		{ApostrophesSplit, "don’t", []string{"file1"}},
+		{ApostrophesSplit, "dont", []string{}},
+		{ApostrophesSplit, "john", []string{"file2"}},
+		{ApostrophesStrip, "don't", []string{"file1"}},
+		{ApostrophesStrip, "don’t", []string{"file1"}},
+		{ApostrophesStrip, "dont", []string{"file1"}},
+		{ApostrophesStrip, "john's", []string{"file2"}},
+		{ApostrophesStrip, "johns", []string{"file2"}},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil, WithApostrophes(test.apostrophes))
+		for name, text := range map[string]string{
+			"file1": "I don't know",
+			"file2": "John's book",
+		} {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+
+		results, err := i.Search(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := []string{}
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s %s: %v is not equal to expected %v", test.apostrophes, test.query, actual, test.expected)
+		}
+	}
+}
+
+func TestParseApostrophes(t *testing.T) {
+	for name, expected := range map[string]Apostrophes{"": ApostrophesSplit, "Split": ApostrophesSplit, "strip": ApostrophesStrip} {
+		actual, err := ParseApostrophes(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("%s: %v is not equal to expected %v", name, actual, expected)
+		}
+	}
+	if _, err := ParseApostrophes("keep"); err == nil {
+		t.Error("unknown apostrophes handling must fail")
+	}
+}
@@ -0,0 +1,243 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+)
+
+// LengthsEngine is the interface implemented by the engines which can report the lengths of several documents at once,
+// it is used by the BM25 range algorithm to normalize the term frequencies.
+type LengthsEngine interface {
+	// Lengths returns the number of the token positions of the named documents. The unknown documents are skipped.
+	Lengths(names []string) (map[string]int, error)
+}
+
+// corpus provides the statistics of the whole index to the range algorithms. The statistics are loaded from the engine
+// once on the first use, so the searches ranked without them do not query the engine.
+type corpus struct {
+	engine IndexEngine
+	items  map[*Source]*TmpResultItem
+	// frequencies are the numbers of the found documents with every query token before the found documents are
+	// filtered, e.g. by the phrases or the time range.
+	frequencies map[string]int
+	// stats caches the statistics of the engine scoped to the tenant, nil if they are not cached.
+	stats      *corpusStats
+	tenant     string
+	generation uint64
+	once       sync.Once
+	documents  int
+	average    float64
+	lengths    map[string]int
+	err        error
+}
+
+// load loads the number of the documents, their average length and the lengths of the found documents.
+// The engine must implement StatsEngine and LengthsEngine interfaces, otherwise ErrNotSupported is returned.
+func (c *corpus) load() error {
+	c.once.Do(func() {
+		statsEngine, ok := c.engine.(StatsEngine)
+		if !ok {
+			c.err = ErrNotSupported
+			return
+		}
+		lengthsEngine, ok := c.engine.(LengthsEngine)
+		if !ok {
+			c.err = ErrNotSupported
+			return
+		}
+		stats, err := c.stats.get(c.tenant, c.generation, statsEngine)
+		if err != nil {
+			c.err = err
+			return
+		}
+		names := make([]string, 0, len(c.items))
+		for source := range c.items {
+			names = append(names, source.Name)
+		}
+		c.lengths, c.err = lengthsEngine.Lengths(names)
+		c.documents = stats.Documents
+		if stats.Documents > 0 {
+			c.average = float64(stats.Occurrences) / float64(stats.Documents)
+		}
+	})
+	return c.err
+}
+
+// setCorpus sets the statistics of the engine scoped to the tenant to the found items. It must be called before the
+// found items are filtered, so the document frequencies of the tokens are counted over all documents.
+func (i *Index) setCorpus(engine IndexEngine, tenant string, items map[*Source]*TmpResultItem, tokens []string) {
+	c := &corpus{
+		engine:      engine,
+		items:       items,
+		frequencies: map[string]int{},
+		stats:       i.corpusStats,
+		tenant:      tenant,
+		generation:  i.Generation(),
+	}
+	for _, item := range items {
+		item.corpus = c
+		for _, token := range tokens {
+			if item.frequency(token) > 0 {
+				c.frequencies[token]++
+			}
+		}
+	}
+}
+
+// corpusStatsTTL is the time the statistics of the index are cached for, so the documents added by other processes,
+// e.g. to the shared database, are counted eventually.
+const corpusStatsTTL = time.Minute
+
+// corpusStats caches the statistics of the index by tenant, so the ranked searches do not count the whole index every
+// time. The statistics are dropped when the index mutates or after corpusStatsTTL.
+type corpusStats struct {
+	m          sync.Mutex
+	generation uint64
+	loaded     time.Time
+	stats      map[string]Stats
+}
+
+// get returns the cached statistics of the tenant or loads them from the engine. The engine is queried every time if
+// the cache is nil.
+func (c *corpusStats) get(tenant string, generation uint64, engine StatsEngine) (Stats, error) {
+	if c == nil {
+		return engine.Stats()
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.stats == nil || c.generation != generation || time.Since(c.loaded) > corpusStatsTTL {
+		c.generation = generation
+		c.loaded = time.Now()
+		c.stats = map[string]Stats{}
+	}
+	if stats, ok := c.stats[tenant]; ok {
+		return stats, nil
+	}
+	stats, err := engine.Stats()
+	if err != nil {
+		return Stats{}, err
+	}
+	c.stats[tenant] = stats
+	return stats, nil
+}
+
+// NewBM25 returns the range algorithm scoring the documents with Okapi BM25: the frequency of every query token
+// saturated by k1 and normalized by the document length relative to the average one with b is multiplied by the
+// inverse document frequency of the token. The usual values are k1 = 1.2 and b = 0.75.
+// The documents without some of the tokens are skipped unless the index is created with OperatorOr.
+// The engine must implement StatsEngine and LengthsEngine interfaces, otherwise ErrNotSupported is returned.
+func NewBM25(k1, b float64) RangeAlgorithm {
+	return func(items map[*Source]*TmpResultItem, tokens []string) ([]Result, error) {
+		if len(items) == 0 {
+			return []Result{}, nil
+		}
+		var c *corpus
+		for _, item := range items {
+			c = item.corpus
+			break
+		}
+		if c == nil {
+			return nil, ErrNotSupported
+		}
+		if err := c.load(); err != nil {
+			return nil, fmt.Errorf("can not load statistics of the index: %w", err)
+		}
+
+		results := make([]Result, 0, len(items))
+		for source, item := range items {
+			if !item.matches(tokens) {
+				continue
+			}
+			norm := 1.0
+			if c.average > 0 {
+				norm = 1 - b + b*float64(c.lengths[source.Name])/c.average
+			}
+			score := 0.0
+			for _, token := range tokens {
+				frequency := float64(item.frequency(token))
+				if frequency == 0 {
+					continue
+				}
+				score += idf(c.documents, c.frequencies[token]) * frequency * (k1 + 1) / (frequency + k1*norm) *
+					item.boost(token)
+			}
+			score = item.boostExact(item.boostPhrase(score))
+			results = append(results, Result{
+				Document:  source,
+				Score:     score,
+				Positions: item.occurrences,
+			})
+		}
+		sort.Slice(results, func(i, j int) bool {
+			return ranksBefore(&results[i], &results[j])
+		})
+		return results, nil
+	}
+}
+
+// idf returns the inverse document frequency of the token found in the frequency documents of all documents. It is
+// positive even if the token is found in most of the documents.
+func idf(documents int, frequency int) float64 {
+	return math.Log(1 + (float64(documents-frequency)+0.5)/(float64(frequency)+0.5))
+}
+
+// Lengths counts the positions of the documents in thread-safe way.
+func (i *MemoryIndex) Lengths(names []string) (map[string]int, error) {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	lengths := make(map[string]int, len(names))
+	for _, name := range names {
+		if _, ok := i.Sources[name]; ok {
+			lengths[name] = 0
+		}
+	}
+	for _, occurrences := range i.Index {
+		for name, positions := range occurrences {
+			if _, ok := lengths[name]; ok {
+				lengths[name] += len(positions)
+			}
+		}
+	}
+	return lengths, nil
+}
+
+// Lengths counts the occurrences of the documents in the database.
+func (i *DbIndex) Lengths(names []string) (map[string]int, error) {
+	return i.lengths("", names)
+}
+
+func (i *DbIndex) lengths(tenant string, names []string) (map[string]int, error) {
+	lengths := make(map[string]int, len(names))
+	if len(names) == 0 {
+		return lengths, nil
+	}
+	var rows []struct {
+		Name   string `pg:"name"`
+		Length int    `pg:"length"`
+	}
+	_, err := i.pg.Query(
+		&rows,
+		`SELECT d.name, count(o.document_id) AS length
+			FROM documents AS d LEFT JOIN occurrences AS o ON o.document_id = d.id
+			WHERE d.tenant_id = ? AND d.name IN (?) GROUP BY d.name;`,
+		tenant,
+		pg.In(names),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error counting lengths %w", err)
+	}
+	for _, row := range rows {
+		lengths[row.Name] = row.Length
+	}
+	return lengths, nil
+}
+
+// Lengths counts the occurrences of the tenant's documents in the database.
+func (t *TenantIndex) Lengths(names []string) (map[string]int, error) {
+	return t.lengths(t.tenant, names)
+}
@@ -0,0 +1,136 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNewBM25(t *testing.T) {
+	// The corpus has 3 documents of 2, 12 and 2 positions, the average length is 16/3. Apple is found in 2 documents,
+	// so its idf is ln(1 + (3 - 2 + 0.5) / (2 + 0.5)) = ln(1.6).
+	texts := map[string]string{
+		"short": "apple banana",
+		"long":  "apple apple cherry durian elder grape kiwi lemon mango olive peach plum",
+		"other": "banana cherry",
+	}
+	for _, test := range []struct {
+		k1, b    float64
+		expected []string
+		scores   []float64
+	}{
+		// The short document with one apple outranks the long one with two apples.
+		{1.2, 0.75, []string{"short", "long"}, []float64{0.6314552576, 0.4781539812}},
+		// Without the length normalization the frequency wins.
+		{1.2, 0, []string{"long", "short"}, []float64{0.6462549902, 0.4700036292}},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil, WithRangeAlgorithm(NewBM25(test.k1, test.b)))
+		for name, text := range texts {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+
+		results, err := i.Search("apple")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		var scores []float64
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+			scores = append(scores, result.Score)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: %v is not equal to expected %v", test.b, actual, test.expected)
+		}
+		for k := range scores {
+			if k < len(test.scores) && math.Abs(scores[k]-test.scores[k]) > 1e-9 {
+				t.Errorf("%v: %v is not equal to expected %v", test.b, scores, test.scores)
+			}
+		}
+	}
+}
+
+// statsCountingEngine counts the statistics loaded from the engine.
+type statsCountingEngine struct {
+	*MemoryIndex
+	stats int
+}
+
+func (e *statsCountingEngine) Stats() (Stats, error) {
+	e.stats++
+	return e.MemoryIndex.Stats()
+}
+
+func TestNewBM25_Corpus(t *testing.T) {
+	engine := &statsCountingEngine{MemoryIndex: NewMemoryIndex()}
+	i := NewIndex(engine, nil, WithRangeAlgorithm(NewBM25(1.2, 0.75)))
+	defer i.Close()
+	texts := map[string]string{
+		"short": "apple banana",
+		"long":  "apple apple cherry durian elder grape kiwi lemon mango olive peach plum",
+		"other": "banana cherry",
+	}
+	for name, text := range texts {
+		if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The document frequency of apple counts the long document filtered out of the results.
+	for _, options := range []SearchOptions{{}, {RestrictTo: []string{"short"}}} {
+		results, err := i.SearchWithOptions("apple", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 0 || results[0].Document.Name != "short" || math.Abs(results[0].Score-0.6314552576) > 1e-9 {
+			t.Errorf("%v: %v is not equal to expected score %v of short", options.RestrictTo, results, 0.6314552576)
+		}
+	}
+	// The statistics are loaded once until the index mutates.
+	if engine.stats != 1 {
+		t.Errorf("%d is not equal to expected %d", engine.stats, 1)
+	}
+	if err := i.AddSource("more", bytes.NewBufferString("apple")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.Search("apple"); err != nil {
+		t.Fatal(err)
+	}
+	if engine.stats != 2 {
+		t.Errorf("%d is not equal to expected %d", engine.stats, 2)
+	}
+}
+
+func TestNewBM25_NotSupported(t *testing.T) {
+	engine := &emptyEngine{results: map[string]Occurrences{"appl": {&Source{Name: "file1"}: []int{0}}}}
+	i := NewIndex(engine, nil, WithRangeAlgorithm(NewBM25(1.2, 0.75)))
+	i.Close()
+	if _, err := i.Search("apple"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+}
+
+func TestMemoryIndex_Lengths(t *testing.T) {
+	engine := NewMemoryIndex()
+	for position, token := range []string{"appl", "appl", "banana"} {
+		if err := engine.Add(token, position, Source{Name: "file1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := engine.Add("appl", 0, Source{Name: "file2"}); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := engine.Lengths([]string{"file1", "file3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{"file1": 3}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
@@ -0,0 +1,81 @@
+package index
+
+import (
+	"sync"
+)
+
+// idCache is the thread-safe cache of database ids.
+// Concurrent misses of the same key wait for a single load instead of inserting duplicated rows.
+type idCache struct {
+	m        sync.RWMutex
+	ids      map[interface{}]int
+	inflight map[interface{}]*idCall
+}
+
+// idCall is the load of the id in progress.
+type idCall struct {
+	done chan struct{}
+	id   int
+	err  error
+	// deleted is true if the key is deleted while the load is in progress, so the loaded id is not cached.
+	deleted bool
+}
+
+func newIdCache() *idCache {
+	return &idCache{
+		ids:      map[interface{}]int{},
+		inflight: map[interface{}]*idCall{},
+	}
+}
+
+// get returns the cached id of the key or calls load to get it.
+// Only one load of the key runs at a time, other callers wait for its result.
+// Failed loads are not cached.
+func (c *idCache) get(key interface{}, load func() (int, error)) (int, error) {
+	c.m.RLock()
+	id, ok := c.ids[key]
+	c.m.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	c.m.Lock()
+	if id, ok := c.ids[key]; ok {
+		c.m.Unlock()
+		return id, nil
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.m.Unlock()
+		<-call.done
+		return call.id, call.err
+	}
+	call := &idCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.m.Unlock()
+
+	call.id, call.err = load()
+
+	c.m.Lock()
+	if c.inflight[key] == call {
+		delete(c.inflight, key)
+	}
+	if call.err == nil && !call.deleted {
+		c.ids[key] = call.id
+	}
+	c.m.Unlock()
+	close(call.done)
+
+	return call.id, call.err
+}
+
+// delete evicts the key from the cache. The load of the key in progress is not cached, it may return the id of the
+// deleted row, and the later callers load the key again.
+func (c *idCache) delete(key interface{}) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	delete(c.ids, key)
+	if call, ok := c.inflight[key]; ok {
+		call.deleted = true
+		delete(c.inflight, key)
+	}
+}
@@ -0,0 +1,165 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIdCache_ConcurrentGet(t *testing.T) {
+	c := newIdCache()
+	var lastID int64
+	loads := map[interface{}]int{}
+	loadsM := sync.Mutex{}
+
+	results := make([]map[interface{}]int, 50)
+	wg := &sync.WaitGroup{}
+	for g := range results {
+		results[g] = map[interface{}]int{}
+		wg.Add(1)
+		go func(result map[interface{}]int) {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				var key interface{} = fmt.Sprintf("token%d", k%10)
+				if k%2 == 0 {
+					key = documentKey{tenant: "a", name: fmt.Sprintf("file%d", k%10)}
+				}
+				id, err := c.get(key, func() (int, error) {
+					loadsM.Lock()
+					loads[key]++
+					loadsM.Unlock()
+					return int(atomic.AddInt64(&lastID, 1)), nil
+				})
+				if err != nil {
+					t.Error(err)
+				}
+				if previous, ok := result[key]; ok && previous != id {
+					t.Errorf("%v has different ids %d and %d", key, previous, id)
+				}
+				result[key] = id
+			}
+		}(results[g])
+	}
+	wg.Wait()
+
+	for key, count := range loads {
+		if count != 1 {
+			t.Errorf("%v is loaded %d times", key, count)
+		}
+	}
+	for _, result := range results[1:] {
+		if !reflect.DeepEqual(result, results[0]) {
+			t.Errorf("%v is not equal to expected %v", result, results[0])
+		}
+	}
+}
+
+func TestIdCache_DeleteDuringLoad(t *testing.T) {
+	c := newIdCache()
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := c.get("appl", func() (int, error) {
+			close(loading)
+			<-release
+			return 1, nil
+		}); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-loading
+	c.delete("appl")
+	close(release)
+	<-done
+
+	id, err := c.get("appl", func() (int, error) {
+		return 2, nil
+	})
+	if err != nil || id != 2 {
+		t.Errorf("key deleted during the load must be loaded again, got %d %v", id, err)
+	}
+}
+
+func TestIdCache_ConcurrentDelete(t *testing.T) {
+	c := newIdCache()
+	var lastID int64
+	rows := map[interface{}]int{}
+	rowsM := sync.Mutex{}
+	load := func(key interface{}) func() (int, error) {
+		return func() (int, error) {
+			rowsM.Lock()
+			id, ok := rows[key]
+			rowsM.Unlock()
+			runtime.Gosched()
+			if ok {
+				return id, nil
+			}
+			rowsM.Lock()
+			defer rowsM.Unlock()
+			if id, ok := rows[key]; ok {
+				return id, nil
+			}
+			rows[key] = int(atomic.AddInt64(&lastID, 1))
+			return rows[key], nil
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	for g := 0; g < 50; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				key := fmt.Sprintf("file%d", k%5)
+				if (g+k)%7 == 0 {
+					rowsM.Lock()
+					delete(rows, key)
+					rowsM.Unlock()
+					c.delete(key)
+					continue
+				}
+				if _, err := c.get(key, load(key)); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// The cached ids are the ids of the stored rows, not of the deleted ones.
+	for key, id := range c.ids {
+		if rows[key] != id {
+			t.Errorf("%v is cached as %d, stored as %d", key, id, rows[key])
+		}
+	}
+}
+
+func TestIdCache_FailedLoad(t *testing.T) {
+	c := newIdCache()
+	expectedErr := errors.New("load error")
+	if _, err := c.get("appl", func() (int, error) {
+		return 0, expectedErr
+	}); err != expectedErr {
+		t.Errorf("%v is not equal to expected %v", err, expectedErr)
+	}
+	id, err := c.get("appl", func() (int, error) {
+		return 1, nil
+	})
+	if err != nil || id != 1 {
+		t.Errorf("failed load must not be cached, got %d %v", id, err)
+	}
+
+	c.delete("appl")
+	id, err = c.get("appl", func() (int, error) {
+		return 2, nil
+	})
+	if err != nil || id != 2 {
+		t.Errorf("deleted key must be loaded again, got %d %v", id, err)
+	}
+}
@@ -0,0 +1,140 @@
+package index
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"time"
+)
+
+// savedCache is the query cache persisted with SaveQueryCache function.
+type savedCache struct {
+	Generation string
+	// Settings are the options of the index changing the results, see cacheSettings.
+	Settings string
+	// Entries are ordered from the least recently used one.
+	Entries []savedEntry
+}
+
+// savedEntry is the persisted search results of queryKey or completions of completionKey.
+type savedEntry struct {
+	Completion  bool
+	Query       string
+	Tenant      string
+	Since       time.Time
+	Until       time.Time
+	OrderBy     string
+	Order       string
+	Restricted  bool
+	RestrictTo  string
+	Language    string
+	Boosts      string
+	SortField   string
+	Markers     Markers
+	Limit       int
+	Results     []Result
+	Completions []Completion
+}
+
+// SaveQueryCache writes the cached search results and completions, e.g. on shutdown to load them with LoadQueryCache
+// function on the next start. The engine must implement GenerationEngine interface to validate the loaded cache,
+// otherwise ErrNotSupported is returned. The cache is saved with the options of the index changing the results.
+// Nothing is written if the index is created without WithQueryCache option.
+func (i *Index) SaveQueryCache(w io.Writer) error {
+	generation := engineGeneration(i.getEngine())
+	if generation == "" {
+		return ErrNotSupported
+	}
+	if i.queryCache == nil {
+		return nil
+	}
+	saved := savedCache{Generation: generation, Settings: i.cacheSettings()}
+	i.queryCache.each(func(key interface{}, value interface{}) {
+		switch key := key.(type) {
+		case queryKey:
+			saved.Entries = append(saved.Entries, savedEntry{
+				Query:      key.query,
+				Tenant:     key.tenant,
+				Since:      key.since,
+				Until:      key.until,
+				OrderBy:    key.orderBy,
+				Order:      key.order,
+				Restricted: key.restricted,
+				RestrictTo: key.restrictTo,
+				Language:   key.language,
+				Boosts:     key.boosts,
+				SortField:  key.sortField,
+				Markers:    key.markers,
+				Results:    value.([]Result),
+			})
+		case completionKey:
+			saved.Entries = append(saved.Entries, savedEntry{
+				Completion:  true,
+				Query:       key.prefix,
+				Tenant:      key.tenant,
+				Limit:       key.limit,
+				Completions: value.([]Completion),
+			})
+		}
+	})
+	return gob.NewEncoder(w).Encode(saved)
+}
+
+// LoadQueryCache populates the query cache with the results written by SaveQueryCache function and returns the number
+// of loaded entries. The cache saved for another generation of the engine or by the index with other options is stale
+// and is discarded, so nothing is loaded. Nothing is loaded either if the index is created without WithQueryCache
+// option.
+func (i *Index) LoadQueryCache(r io.Reader) (int, error) {
+	var saved savedCache
+	if err := gob.NewDecoder(r).Decode(&saved); err != nil {
+		return 0, fmt.Errorf("can not decode query cache: %w", err)
+	}
+	if i.queryCache == nil || saved.Generation == "" || saved.Generation != engineGeneration(i.getEngine()) ||
+		saved.Settings != i.cacheSettings() {
+		return 0, nil
+	}
+	for _, entry := range saved.Entries {
+		if entry.Completion {
+			key := completionKey{tenant: entry.Tenant, prefix: entry.Query, limit: entry.Limit}
+			i.queryCache.put(key, append([]Completion{}, entry.Completions...))
+			continue
+		}
+		key := queryKey{
+			query:      entry.Query,
+			tenant:     entry.Tenant,
+			since:      entry.Since,
+			until:      entry.Until,
+			orderBy:    entry.OrderBy,
+			order:      entry.Order,
+			restricted: entry.Restricted,
+			restrictTo: entry.RestrictTo,
+			language:   entry.Language,
+			boosts:     entry.Boosts,
+			sortField:  entry.SortField,
+			markers:    entry.Markers,
+		}
+		i.queryCache.put(key, append([]Result{}, entry.Results...))
+	}
+	if len(saved.Entries) > i.queryCache.size {
+		return i.queryCache.size, nil
+	}
+	return len(saved.Entries), nil
+}
+
+// engineGeneration returns the generation of the engine, empty if it is unknown.
+func engineGeneration(engine IndexEngine) string {
+	generational, ok := engine.(GenerationEngine)
+	if !ok {
+		return ""
+	}
+	return generational.Generation()
+}
+
+// cacheSettings returns the options of the index changing the search results and the settings set with
+// WithQueryCacheSettings option.
+func (i *Index) cacheSettings() string {
+	return fmt.Sprintf("%d:%d:%d:%d:%s:%v:%v:%d:%s:%T:%v:%v:%v:%v:%v:%v:%v:%s", i.limit, i.maxCandidates,
+		i.maxTokenCount, i.maxWordSize, i.operator, i.phraseBoost, i.exactBoost, i.minTokenLength, i.apostrophes,
+		i.stemmer, i.stopwordsOnly, i.splitIdentifiers, i.strictPhrases, i.countsOnly, i.matchedTokens, i.snippets,
+		i.defaultFields, i.queryCacheSettings)
+}
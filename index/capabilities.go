@@ -0,0 +1,100 @@
+package index
+
+import (
+	"reflect"
+)
+
+// Capabilities lists the features supported by the engine of the index, e.g. to hide the unavailable options in UI.
+type Capabilities struct {
+	// Engine is the type name of the engine, e.g. MemoryIndex.
+	Engine string
+	// Positions is true if the search results contain the positions of the matched tokens.
+	Positions bool
+	// Tenants is true if the documents can be searched per tenant.
+	Tenants bool
+	// TimeRange is true if the engine filters the documents by the modification time on its own.
+	TimeRange bool
+	// Restrict is true if the engine restricts the search to the named documents on its own.
+	Restrict bool
+	// Suggestions is true if the engine enumerates the tokens to suggest the query fixes.
+	Suggestions bool
+	// Iterate is true if the engine enumerates the postings, e.g. to dump the index.
+	Iterate bool
+	// Remove is true if the single document can be removed.
+	Remove bool
+	// DeleteByPrefix is true if the documents can be deleted by the name prefix.
+	DeleteByPrefix bool
+	// Stats is true if the engine reports the index statistics.
+	Stats bool
+	// Exclude is true if the documents can be excluded from the search without deleting them.
+	Exclude bool
+	// DocumentStats is true if the engine reports the length and the vocabulary size of the document.
+	DocumentStats bool
+	// ListDocuments is true if the engine lists the indexed documents.
+	ListDocuments bool
+	// Content is true if the engine retains the raw texts of the documents.
+	Content bool
+	// BM25 is true if the engine reports the statistics needed by the BM25 range algorithm.
+	BM25 bool
+	// Snippets is true if the engine stores the words of the documents for the snippets of the results.
+	Snippets bool
+	// Prefix is true if the query terms ending with `*` match the tokens by the prefix.
+	Prefix bool
+	// Metadata is true if the engine keeps the metadata of the documents, e.g. to order the results by the field.
+	Metadata bool
+	// Incremental is true if the unchanged documents are skipped and the changed ones are replaced by the build with
+	// WithIncrementalUpdates option.
+	Incremental bool
+}
+
+// Capabilities returns the features supported by the current engine of the index.
+func (i *Index) Capabilities() Capabilities {
+	engine := i.getEngine()
+	_, counter := engine.(Counter)
+	_, tenants := engine.(TenantEngine)
+	_, timeRange := engine.(TimeRangeEngine)
+	_, restrict := engine.(RestrictedEngine)
+	_, suggestions := engine.(TokenIterator)
+	_, iterate := engine.(Iterator)
+	_, remove := engine.(Remover)
+	_, deleteByPrefix := engine.(PrefixDeleter)
+	_, stats := engine.(StatsEngine)
+	_, exclude := engine.(Excluder)
+	_, documentStats := engine.(DocumentStatsEngine)
+	_, listDocuments := engine.(DocumentLister)
+	_, lengths := engine.(LengthsEngine)
+	_, content := engine.(ContentStore)
+	_, snippets := engine.(SnippetProvider)
+	_, prefix := engine.(PrefixEngine)
+	_, reindexer := engine.(Reindexer)
+	return Capabilities{
+		Engine:         engineName(engine),
+		Positions:      !(counter && i.countsOnly),
+		Tenants:        tenants,
+		TimeRange:      timeRange,
+		Restrict:       restrict,
+		Suggestions:    suggestions,
+		Iterate:        iterate,
+		Remove:         remove,
+		DeleteByPrefix: deleteByPrefix,
+		Stats:          stats,
+		Exclude:        exclude,
+		DocumentStats:  documentStats,
+		ListDocuments:  listDocuments,
+		Content:        content,
+		BM25:           stats && lengths,
+		Snippets:       snippets,
+		Prefix:         prefix,
+		Metadata:       keepsMetadata(engine),
+		Incremental:    reindexer && remove,
+	}
+}
+
+// engineName returns the type name of the engine without the package and the pointer.
+func engineName(engine IndexEngine) string {
+	t := reflect.TypeOf(engine)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
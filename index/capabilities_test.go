@@ -0,0 +1,47 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIndex_Capabilities(t *testing.T) {
+	for _, test := range []struct {
+		index    *Index
+		expected Capabilities
+	}{
+		{
+			&Index{engine: NewMemoryIndex()},
+			Capabilities{
+				Engine:         "MemoryIndex",
+				Positions:      true,
+				Suggestions:    true,
+				Iterate:        true,
+				Remove:         true,
+				DeleteByPrefix: true,
+				Stats:          true,
+				Exclude:        true,
+				DocumentStats:  true,
+				ListDocuments:  true,
+				Content:        true,
+				BM25:           true,
+				Snippets:       true,
+				Prefix:         true,
+				Metadata:       true,
+				Incremental:    true,
+			},
+		},
+		{
+			&Index{engine: &countEngine{}, countsOnly: true},
+			Capabilities{Engine: "countEngine"},
+		},
+		{
+			&Index{engine: &countEngine{}},
+			Capabilities{Engine: "countEngine", Positions: true},
+		},
+	} {
+		if actual := test.index.Capabilities(); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%+v is not equal to expected %+v", actual, test.expected)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package index
+
+import "sort"
+
+// Comparison is the top of the search results produced by one range algorithm.
+// Scores are normalized by the best score of the ranker, so the results of different rankers are comparable.
+type Comparison struct {
+	Ranker  string
+	Results []Result
+}
+
+// Compare runs the query through every range algorithm and returns top n results of each one ordered by the ranker
+// name. Zero n returns all results. It is intended to debug and compare rankers with each other.
+func (i *Index) Compare(query string, rankers map[string]RangeAlgorithm, n int) ([]Comparison, error) {
+	return i.CompareTenant("", query, rankers, n)
+}
+
+// CompareTenant compares the rankers over the documents of the tenant only.
+// Empty tenant compares over the whole engine.
+func (i *Index) CompareTenant(tenant string, query string, rankers map[string]RangeAlgorithm, n int) ([]Comparison, error) {
+	engine, err := i.scoped(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(rankers))
+	for name := range rankers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tokens, boosts, err := i.parseQuery(query, i.defaultAnalyzer())
+	if err != nil {
+		return nil, err
+	}
+	items := map[*Source]*TmpResultItem{}
+	if len(tokens) > 0 {
+		items, err = i.gather(engine, tokens, boosts, nil)
+		if err != nil {
+			return nil, err
+		}
+		i.setCorpus(engine, tenant, items, tokens)
+		phrases := parsePhrases(query, i.defaultAnalyzer())
+		i.filterPhrases(engine, items, phrases)
+		i.matchPhrases(items, phrases)
+		exact, err := i.parseExact(query, i.defaultAnalyzer())
+		if err != nil {
+			return nil, err
+		}
+		if err := i.matchExact(engine, items, exact); err != nil {
+			return nil, err
+		}
+	}
+
+	comparisons := make([]Comparison, 0, len(names))
+	for _, name := range names {
+		results := []Result{}
+		if len(items) > 0 {
+			results, err = rankers[name](items, tokens)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if n > 0 && len(results) > n {
+			results = results[:n]
+		}
+		normalize(results)
+		comparisons = append(comparisons, Comparison{Ranker: name, Results: results})
+	}
+	return comparisons, nil
+}
+
+// normalize divides the scores by the maximal one.
+func normalize(results []Result) {
+	max := 0.0
+	for _, result := range results {
+		if result.Score > max {
+			max = result.Score
+		}
+	}
+	if max == 0 {
+		return
+	}
+	for k := range results {
+		results[k].Score /= max
+	}
+}
@@ -0,0 +1,54 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIndex_Compare(t *testing.T) {
+	s1 := Source{Name: "file1"}
+	s2 := Source{Name: "file2"}
+	ee := &emptyEngine{
+		results: map[string]Occurrences{
+			"appl": {
+				&s1: []int{0},
+				&s2: []int{0, 1, 2},
+			},
+		},
+	}
+	i := &Index{engine: ee}
+
+	invoked := map[string]int{}
+	byName := func(items map[*Source]*TmpResultItem, tokens []string) ([]Result, error) {
+		invoked["name"]++
+		return []Result{{Document: &s1, Score: 5}, {Document: &s2, Score: 1}}, nil
+	}
+	count := func(items map[*Source]*TmpResultItem, tokens []string) ([]Result, error) {
+		invoked["count"]++
+		return ScoreByCount(items, tokens)
+	}
+
+	actual, err := i.Compare("apple", map[string]RangeAlgorithm{"name": byName, "count": count}, 1)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []Comparison{
+		{Ranker: "count", Results: []Result{{Document: &s2, Score: 1, Positions: map[string][]int{"appl": {0, 1, 2}}}}},
+		{Ranker: "name", Results: []Result{{Document: &s1, Score: 1}}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+	if expected := map[string]int{"name": 1, "count": 1}; !reflect.DeepEqual(invoked, expected) {
+		t.Errorf("%v is not equal to expected %v", invoked, expected)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	actual := []Result{{Score: 4}, {Score: 2}, {Score: 1}}
+	normalize(actual)
+	expected := []Result{{Score: 1}, {Score: 0.5}, {Score: 0.25}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
@@ -0,0 +1,122 @@
+package index
+
+import (
+	"sort"
+	"strings"
+)
+
+// Completion is the indexed token starting with the completed prefix and the number of its occurrences.
+type Completion struct {
+	Token string
+	Count int
+}
+
+// completionKey identifies the cached completions.
+type completionKey struct {
+	tenant string
+	prefix string
+	limit  int
+}
+
+// Complete returns up to limit indexed tokens starting with the prefix ordered by the number of their occurrences in
+// all documents, the most frequent first, e.g. for autocomplete. The prefix is not stemmed, so it is matched against
+// the stems, e.g. `appl` completes `apple` and `apples` both stemmed to `appl`. The engine must implement
+// TokenIterator interface, otherwise no completions are returned. Completions are cached with WithQueryCache option.
+func (i *Index) Complete(prefix string, limit int) ([]Completion, error) {
+	return i.CompleteTenant("", prefix, limit)
+}
+
+// CompleteTenant returns the completions of the prefix over the documents of the tenant only.
+// Empty tenant completes over the whole engine.
+func (i *Index) CompleteTenant(tenant string, prefix string, limit int) ([]Completion, error) {
+	prefix = strings.ToLower(trimWord(i.defaultAnalyzer().stripApostrophes(prefix)))
+	if prefix == "" || limit <= 0 {
+		return nil, nil
+	}
+	key := completionKey{tenant: tenant, prefix: prefix, limit: limit}
+	if completions, ok := i.queryCache.get(key); ok {
+		return append([]Completion{}, completions.([]Completion)...), nil
+	}
+	generation := i.Generation()
+	engine, err := i.scoped(tenant)
+	if err != nil {
+		return nil, err
+	}
+	completions, err := i.complete(engine, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	i.queryCache.putAt(generation, key, append([]Completion{}, completions...))
+	return completions, nil
+}
+
+func (i *Index) complete(engine IndexEngine, prefix string, limit int) ([]Completion, error) {
+	iterator, ok := engine.(TokenIterator)
+	if !ok {
+		return nil, nil
+	}
+
+	var tokens []string
+	err := iterator.IterateTokens(func(token string) error {
+		if strings.HasPrefix(token, prefix) && !strings.Contains(token, fieldSeparator) {
+			tokens = append(tokens, token)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return []Completion{}, nil
+	}
+
+	completions, err := countTokens(engine, tokens)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(completions, func(i, j int) bool {
+		if completions[i].Count != completions[j].Count {
+			return completions[i].Count > completions[j].Count
+		}
+		return completions[i].Token < completions[j].Token
+	})
+	if len(completions) > limit {
+		completions = completions[:limit]
+	}
+	return completions, nil
+}
+
+// countTokens returns the number of occurrences of every token in all documents. Only the counts are fetched if the
+// engine implements Counter interface.
+func countTokens(engine IndexEngine, tokens []string) ([]Completion, error) {
+	counts := map[string]int{}
+	if counter, ok := engine.(Counter); ok {
+		countsList, err := counter.Count(tokens)
+		if err != nil {
+			return nil, err
+		}
+		for token, documents := range countsList {
+			for _, count := range documents {
+				counts[token] += count
+			}
+		}
+	} else {
+		occurrencesList, err := engine.Get(tokens)
+		if err != nil {
+			return nil, err
+		}
+		for token, occurrences := range occurrencesList {
+			for _, positions := range occurrences {
+				counts[token] += len(positions)
+			}
+		}
+	}
+
+	completions := make([]Completion, 0, len(tokens))
+	for _, token := range tokens {
+		if count := counts[token]; count > 0 {
+			completions = append(completions, Completion{Token: token, Count: count})
+		}
+	}
+	return completions, nil
+}
@@ -0,0 +1,49 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIndex_Complete(t *testing.T) {
+	engine := NewMemoryIndex()
+	i := NewIndex(engine, nil, WithFields("title"), WithQueryCache(10))
+	if err := i.AddFields(Source{Name: "file1"}, map[string]string{
+		"title": "apricot",
+		"body":  "apples and apricots, applications of apples",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	i.Close()
+
+	expected := []Completion{{"appl", 2}, {"applic", 1}, {"apricot", 1}}
+	actual, err := i.Complete("Ap", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	// The completions are served from the cache until the documents are added through the index.
+	if err := engine.Add("apricot", 10, Source{Name: "file2"}); err != nil {
+		t.Fatal(err)
+	}
+	actual, err = i.Complete("ap", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+	i.mutate()
+	expected = []Completion{{"appl", 2}, {"apricot", 2}}
+	actual, err = i.Complete("ap", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
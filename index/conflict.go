@@ -0,0 +1,51 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-pg/pg/v9"
+)
+
+// ConflictPolicy is the handling of the occurrences inserted to the database again, e.g. when the same content is
+// indexed twice without deleting it first. The occurrence is identified by its token, document and position.
+type ConflictPolicy int
+
+const (
+	// IgnoreConflicts skips the occurrences which are already stored, so indexing the same content again is
+	// idempotent. It is the default policy.
+	IgnoreConflicts ConflictPolicy = iota
+	// FailConflicts reports the stored occurrences of the batch with ErrConflict. The other occurrences of the batch
+	// are inserted and the stored ones are dropped, so the batch is not inserted again.
+	FailConflicts
+)
+
+// ErrConflict is returned by the insert of the batch containing the stored occurrences with FailConflicts policy.
+var ErrConflict = errors.New("occurrences are already stored")
+
+// uniqueViolation is the code of the error of the duplicate key in PostgreSQL.
+const uniqueViolation = "23505"
+
+// isConflict checks if the insert fails because the row is already stored.
+func isConflict(err error) bool {
+	var pgErr pg.Error
+	return errors.As(err, &pgErr) && pgErr.Field('C') == uniqueViolation
+}
+
+// ParseConflictPolicy returns the policy by its name, `ignore` or `fail`. Empty name is IgnoreConflicts.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch name {
+	case "", "ignore":
+		return IgnoreConflicts, nil
+	case "fail":
+		return FailConflicts, nil
+	}
+	return 0, fmt.Errorf("unknown conflict policy %s", name)
+}
+
+// WithConflictPolicy sets the handling of the occurrences which are already stored, IgnoreConflicts by default.
+func WithConflictPolicy(conflicts ConflictPolicy) DbOption {
+	return func(i *DbIndex) {
+		i.conflicts = conflicts
+	}
+}
@@ -0,0 +1,85 @@
+package index
+
+import (
+	"errors"
+)
+
+// ContentStore is the interface implemented by the engines which can retain the raw texts of the documents.
+type ContentStore interface {
+	// SetContent retains the raw text of the document.
+	SetContent(name string, content string) error
+	// Content returns the raw text of the document. ErrUnknownDocument is returned if the document is not indexed or
+	// its text is not retained.
+	Content(name string) (string, error)
+}
+
+// WithRetainedContent makes the index retain the raw texts of the documents added with AddDocument or AddSource
+// functions in the engine, e.g. to show the whole document in the search UI. The engine must implement ContentStore
+// interface, otherwise the texts are not retained. The document added again with the same name retains its latest text,
+// while the document continued with CollisionSerialize handling retains its texts joined by the newline.
+func WithRetainedContent() Option {
+	return func(i *Index) {
+		i.retainContent = true
+	}
+}
+
+// retain passes the raw text of the document to the engine if the index is created with WithRetainedContent option.
+// The text of the continued document is appended to the retained one.
+func (i *Index) retain(name string, content []byte, continued bool) error {
+	if !i.retainContent {
+		return nil
+	}
+	store, ok := i.getEngine().(ContentStore)
+	if !ok {
+		return nil
+	}
+	if continued {
+		retained, err := store.Content(name)
+		if err == nil {
+			return store.SetContent(name, retained+"\n"+string(content))
+		}
+		if !errors.Is(err, ErrUnknownDocument) {
+			return err
+		}
+	}
+	return store.SetContent(name, string(content))
+}
+
+// Content returns the raw text of the document retained with WithRetainedContent option. The engine must implement
+// ContentStore interface, otherwise ErrNotSupported is returned.
+func (i *Index) Content(name string) (string, error) {
+	return i.ContentTenant("", name)
+}
+
+// ContentTenant returns the raw text of the tenant's document. Empty tenant looks for the document in the whole
+// engine.
+func (i *Index) ContentTenant(tenant string, name string) (string, error) {
+	engine, err := i.scoped(tenant)
+	if err != nil {
+		return "", err
+	}
+	store, ok := engine.(ContentStore)
+	if !ok {
+		return "", ErrNotSupported
+	}
+	return store.Content(name)
+}
+
+// SetContent retains the raw text of the document in thread-safe way.
+func (i *MemoryIndex) SetContent(name string, content string) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+	i.Contents[name] = content
+	return nil
+}
+
+// Content returns the retained raw text of the document in thread-safe way.
+func (i *MemoryIndex) Content(name string) (string, error) {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	content, ok := i.Contents[name]
+	if !ok {
+		return "", ErrUnknownDocument
+	}
+	return content, nil
+}
@@ -0,0 +1,74 @@
+package index
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"testing"
+)
+
+func TestIndex_Content(t *testing.T) {
+	for _, test := range []struct {
+		options  []Option
+		expected error
+	}{
+		{nil, ErrUnknownDocument},
+		{[]Option{WithRetainedContent()}, nil},
+	} {
+		engine := NewMemoryIndex()
+		i := NewIndex(engine, nil, test.options...)
+		if err := i.AddSource("file1", strings.NewReader("Apple, banana!")); err != nil {
+			t.Fatal(err)
+		}
+		i.Close()
+
+		// The content survives the encoding of the index.
+		buffer := &bytes.Buffer{}
+		if err := engine.Encode(gob.NewEncoder(buffer)); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := Decode(gob.NewDecoder(buffer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := NewIndex(decoded, nil).Content("file1")
+		if err != test.expected {
+			t.Errorf("%v is not equal to expected %v", err, test.expected)
+		}
+		if err == nil && content != "Apple, banana!" {
+			t.Errorf("%q is not equal to expected %q", content, "Apple, banana!")
+		}
+	}
+
+	i := &Index{engine: &emptyEngine{}}
+	if _, err := i.Content("file1"); err != ErrNotSupported {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+}
+
+func TestIndex_ContentNameCollision(t *testing.T) {
+	for collision, expected := range map[NameCollision]string{
+		"":                 "Banana.",
+		CollisionSerialize: "Apple.\nBanana.",
+	} {
+		var options []Option
+		if collision != "" {
+			options = append(options, WithNameCollision(collision))
+		}
+		i := NewIndex(NewMemoryIndex(), nil, append(options, WithRetainedContent())...)
+		for _, text := range []string{"Apple.", "Banana."} {
+			if err := i.AddSource("file1", strings.NewReader(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+
+		content, err := i.Content("file1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content != expected {
+			t.Errorf("%s: %q is not equal to expected %q", collision, content, expected)
+		}
+	}
+}
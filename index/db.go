@@ -2,9 +2,13 @@ package index
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unsafe"
 
 	"github.com/go-pg/pg/v9"
 	"github.com/rs/zerolog/log"
@@ -27,30 +31,90 @@ func (d dbLogger) AfterQuery(c context.Context, q *pg.QueryEvent) error {
 
 // DbIndex is postgresql-based engine for storing inverted index.
 type DbIndex struct {
+	// stored is the number of the inserted occurrences, it is first for 64-bit alignment of the atomic operations.
+	stored         int64
 	pg             *pg.DB
-	tokensCache    map[string]int
-	tokensM        sync.RWMutex
-	documentsCache map[string]int
-	documentsM     sync.RWMutex
+	tokensCache    *idCache
+	documentsCache *idCache
 	insertC        chan Occurrence
+	flushC         []chan chan error
+	done           chan struct{}
+	closeOnce      sync.Once
+	queryTimeout   time.Duration
+	// conflicts is the handling of the occurrences which are already stored.
+	conflicts ConflictPolicy
+	// flushMemory is the estimated size of the batch in bytes inserted without waiting for the ticker, 0 disables it.
+	flushMemory int
+	// flushHooks are called after every inserted batch by their ids, see OnFlush.
+	flushHooks  map[int]func()
+	lastHook    int
+	flushHooksM sync.RWMutex
+}
+
+// documentKey identifies the document in the documents cache.
+type documentKey struct {
+	tenant string
+	name   string
+}
+
+// DbOption configures the postgresql-based engine.
+type DbOption func(i *DbIndex)
+
+// WithFlushWorkers sets the number of the workers inserting batches of the occurrences in parallel. The occurrences
+// are independent rows, so every worker collects and inserts its own batch. The default is one worker.
+func WithFlushWorkers(workers int) DbOption {
+	return func(i *DbIndex) {
+		if workers < 1 {
+			workers = 1
+		}
+		i.flushC = make([]chan chan error, workers)
+	}
+}
+
+// WithFlushMemory inserts the batch of the worker as soon as its estimated size exceeds the given number of bytes
+// instead of waiting for the ticker, so the bursty indexing does not exhaust the memory. 0 disables the limit.
+// The size is estimated as the number of the batched occurrences multiplied by occurrenceSize.
+func WithFlushMemory(bytes int) DbOption {
+	return func(i *DbIndex) {
+		i.flushMemory = bytes
+	}
+}
+
+// WithQueryTimeout limits the time of fetching the occurrences of the query tokens, the query exceeding the timeout
+// fails. GetPartial returns the occurrences fetched before the timeout instead. 0 means no timeout.
+func WithQueryTimeout(timeout time.Duration) DbOption {
+	return func(i *DbIndex) {
+		i.queryTimeout = timeout
+	}
 }
 
 // NewDbIndex creates new postgresql-based engine.
 // Use the method instead of creating empty struct.
-func NewDbIndex(pg *pg.DB) *DbIndex {
+func NewDbIndex(pg *pg.DB, options ...DbOption) *DbIndex {
 	pg.AddQueryHook(dbLogger{})
 	i := &DbIndex{
 		pg:             pg,
-		tokensCache:    map[string]int{},
-		tokensM:        sync.RWMutex{},
-		documentsCache: map[string]int{},
-		documentsM:     sync.RWMutex{},
+		tokensCache:    newIdCache(),
+		documentsCache: newIdCache(),
 		insertC:        make(chan Occurrence),
+		flushC:         make([]chan chan error, 1),
+		done:           make(chan struct{}),
+	}
+	for _, option := range options {
+		option(i)
 	}
-	go i.flush()
+	i.startWorkers()
 	return i
 }
 
+// startWorkers starts the flush worker for every flush channel.
+func (i *DbIndex) startWorkers() {
+	for worker := range i.flushC {
+		i.flushC[worker] = make(chan chan error)
+		go i.flush(i.flushC[worker])
+	}
+}
+
 // Token is the container for a token in PgSQL.
 type Token struct {
 	ID    int    `pg:"id,pk"`
@@ -59,163 +123,733 @@ type Token struct {
 
 // Document is the container for a document in PgSQL.
 type Document struct {
-	ID   int    `pg:"id,pk"`
-	Name string `pg:"name"`
+	ID        int       `pg:"id,pk"`
+	Name      string    `pg:"name"`
+	TenantID  string    `pg:"tenant_id,use_zero"`
+	CreatedAt time.Time `pg:"created_at"`
+	Hash      string    `pg:"hash"`
+	Language  string    `pg:"language"`
+	Excluded  bool      `pg:"excluded,use_zero"`
 }
 
 // Occurrence is the container for an occurrence in PgSQL.
 type Occurrence struct {
-	ID         int `pg:"id,pk"`
-	TokenID    int `pg:"token_id"`
-	DocumentID int `pg:"document_id"`
-	Position   int `pg:"position"`
+	ID         int    `pg:"id,pk"`
+	TokenID    int    `pg:"token_id"`
+	DocumentID int    `pg:"document_id"`
+	Position   int    `pg:"position"`
+	TenantID   string `pg:"tenant_id,use_zero"`
 }
 
-func (i *DbIndex) flush() {
+// occurrenceSize is the estimated size of the batched occurrence in bytes: the size of the struct itself. The tenant
+// strings are shared by the occurrences of the document, so their content is not counted, and the spare capacity of
+// the batch is not counted either, so the batch may take up to twice the estimated size.
+const occurrenceSize = int(unsafe.Sizeof(Occurrence{}))
+
+// full checks if the estimated size of the batch exceeds the limit set with WithFlushMemory option.
+func (i *DbIndex) full(insertList []Occurrence) bool {
+	return i.flushMemory > 0 && len(insertList)*occurrenceSize > i.flushMemory
+}
+
+// flush is the flush worker. It collects the occurrences into its own batch and inserts the batch every 10 seconds,
+// when the batch exceeds the memory limit or on the request from flushC until the engine is closed. Once the insert
+// fails and the batch is kept, the batch exceeding the memory limit waits for the next tick, so every later occurrence
+// does not retry the insert.
+func (i *DbIndex) flush(flushC chan chan error) {
 	var insertList []Occurrence
+	failed := false
 
 	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			if len(insertList) == 0 {
-				continue
-			}
-			if _, err := i.pg.Model(&insertList).Insert(); err != nil {
+			err := i.insert(&insertList)
+			if err != nil {
 				log.Err(err).Msg("error inserting rows")
-				continue
 			}
-			log.Info().Msgf("inserted %d occurrences", len(insertList))
-			insertList = []Occurrence{}
+			failed = err != nil && len(insertList) > 0
+		case result := <-flushC:
+			err := i.insert(&insertList)
+			failed = err != nil && len(insertList) > 0
+			result <- err
 		case occurrence := <-i.insertC:
 			insertList = append(insertList, occurrence)
+			if !failed && i.full(insertList) {
+				err := i.insert(&insertList)
+				if err != nil {
+					log.Err(err).Msg("error inserting rows")
+				}
+				failed = err != nil && len(insertList) > 0
+			}
+		case <-i.done:
+			return
 		}
 	}
 }
 
+// insert writes the batch of the occurrences and empties it. The batch is kept if the insert fails, e.g. to retry it
+// when the database is reachable again. The occurrences which are already stored are skipped, with FailConflicts
+// policy they are reported with ErrConflict after the other occurrences of the batch are written.
+func (i *DbIndex) insert(insertList *[]Occurrence) error {
+	if len(*insertList) == 0 {
+		return nil
+	}
+	query := i.pg.Model(insertList)
+	if i.conflicts == IgnoreConflicts {
+		query = query.OnConflict("DO NOTHING")
+	}
+	result, err := query.Insert()
+	var conflictErr error
+	if i.conflicts == FailConflicts && isConflict(err) {
+		// The failed batch would fail on every retry, so it is written without the stored occurrences.
+		result, err = i.pg.Model(insertList).OnConflict("DO NOTHING").Insert()
+		if err == nil {
+			conflictErr = fmt.Errorf("%w: %d of %d", ErrConflict, len(*insertList)-result.RowsAffected(), len(*insertList))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&i.stored, int64(result.RowsAffected()))
+	log.Info().Msgf("inserted %d occurrences", result.RowsAffected())
+	*insertList = []Occurrence{}
+	i.flushed()
+	return conflictErr
+}
+
+// OnFlush adds the function called after every inserted batch of the occurrences, e.g. to invalidate the searches
+// cached before the occurrences are in the database. The function is called by the flush workers, so it must not
+// call Flush. The returned function removes the added one, e.g. when the engine is swapped out of the index.
+func (i *DbIndex) OnFlush(fn func()) func() {
+	i.flushHooksM.Lock()
+	defer i.flushHooksM.Unlock()
+	if i.flushHooks == nil {
+		i.flushHooks = map[int]func(){}
+	}
+	i.lastHook++
+	id := i.lastHook
+	i.flushHooks[id] = fn
+	return func() {
+		i.flushHooksM.Lock()
+		defer i.flushHooksM.Unlock()
+		delete(i.flushHooks, id)
+	}
+}
+
+// flushed calls the functions added with OnFlush.
+func (i *DbIndex) flushed() {
+	i.flushHooksM.RLock()
+	defer i.flushHooksM.RUnlock()
+	for _, fn := range i.flushHooks {
+		fn()
+	}
+}
+
+// Stored returns the number of the occurrences inserted to the database, the batched and the skipped stored ones are
+// not counted.
+func (i *DbIndex) Stored() int64 {
+	return atomic.LoadInt64(&i.stored)
+}
+
+// Flush writes the occurrences batched by all workers since the last write immediately instead of waiting for the
+// ticker. The occurrences added before the call are written when it returns without error. ErrEngineClosed is
+// returned after Close.
+func (i *DbIndex) Flush() error {
+	results := make([]chan error, len(i.flushC))
+	for worker, flushC := range i.flushC {
+		results[worker] = make(chan error, 1)
+		select {
+		case flushC <- results[worker]:
+		case <-i.done:
+			return ErrEngineClosed
+		}
+	}
+	var err error
+	for _, result := range results {
+		if insertErr := <-result; insertErr != nil && err == nil {
+			err = fmt.Errorf("error inserting rows: %w", insertErr)
+		}
+	}
+	return err
+}
+
+// flushPending writes the batched occurrences before the documents are deleted, so the occurrences of the deleted
+// documents do not fail the later inserts of the batches. The stored occurrences dropped with FailConflicts policy do
+// not stop the deletion.
+func (i *DbIndex) flushPending() error {
+	if err := i.Flush(); err != nil && !errors.Is(err, ErrConflict) {
+		return err
+	}
+	return nil
+}
+
 // Add adds new token, document and position to the database.
 // If the token or the document has been already inserted the function would take it from cache.
+// ErrEngineClosed is returned after Close.
 func (i *DbIndex) Add(token string, position int, source Source) error {
+	return i.add("", token, position, source)
+}
+
+func (i *DbIndex) add(tenant string, token string, position int, source Source) error {
 	tkn, err := i.getToken(token)
 	if err != nil {
 		return err
 	}
-	doc, err := i.getDocument(source.Name)
+	doc, err := i.getDocument(tenant, source)
 	if err != nil {
 		return err
 	}
-	i.insertC <- Occurrence{
+	select {
+	case i.insertC <- Occurrence{
 		TokenID:    tkn.ID,
 		DocumentID: doc.ID,
 		Position:   position,
+		TenantID:   tenant,
+	}:
+		return nil
+	case <-i.done:
+		return ErrEngineClosed
 	}
-	return err
 }
 
 func (i *DbIndex) getToken(token string) (*Token, error) {
-	i.tokensM.RLock()
-	if id, ok := i.tokensCache[token]; ok {
-		i.tokensM.RUnlock()
-		return &Token{
-			ID:    id,
-			Token: token,
-		}, nil
+	id, err := i.tokensCache.get(token, func() (int, error) {
+		tkn := &Token{}
+		err := i.pg.Model(tkn).Where("token=?", token).Select()
+		if err == nil {
+			return tkn.ID, nil
+		}
+		if err != pg.ErrNoRows {
+			return 0, fmt.Errorf("error selecting %s %w", token, err)
+		}
+
+		tkn.Token = token
+		if _, err := i.pg.Model(tkn).Returning("*").Insert(); err != nil {
+			return 0, fmt.Errorf("error inserting %s %w", token, err)
+		}
+		log.Debug().Msgf("add token %s %d to cache", token, tkn.ID)
+		return tkn.ID, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	i.tokensM.RUnlock()
+	return &Token{
+		ID:    id,
+		Token: token,
+	}, nil
+}
+
+// getDocument returns the document from the cache, the database or inserts the new one with the modification time of
+// the source.
+func (i *DbIndex) getDocument(tenant string, source Source) (*Document, error) {
+	name := source.Name
+	id, err := i.documentsCache.get(documentKey{tenant: tenant, name: name}, func() (int, error) {
+		doc := &Document{}
+		err := i.pg.Model(doc).Where("tenant_id=? AND name=?", tenant, name).Select()
+		if err == nil {
+			return doc.ID, nil
+		}
+		if err != pg.ErrNoRows {
+			return 0, fmt.Errorf("error selecting %s %w", name, err)
+		}
 
-	tkn := &Token{}
-	err := i.pg.Model(tkn).Where("token=?", token).Select()
-	if err != nil && err != pg.ErrNoRows {
-		return nil, fmt.Errorf("error selecting %s %w", token, err)
+		doc.Name = name
+		doc.TenantID = tenant
+		doc.CreatedAt = source.ModTime
+		doc.Hash = source.Hash
+		doc.Language = source.Language
+		if _, err := i.pg.Model(doc).Returning("*").Insert(); err != nil {
+			return 0, fmt.Errorf("error inserting %s %w", name, err)
+		}
+		log.Debug().Msgf("add document %s %d to cache", name, doc.ID)
+		return doc.ID, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &Document{
+		ID:       id,
+		Name:     name,
+		TenantID: tenant,
+	}, nil
+}
+
+// Get returns occurrences list for the list of tokens.
+func (i *DbIndex) Get(tokens []string) (map[string]Occurrences, error) {
+	return i.get("", documentFilter{}, tokens)
+}
+
+// GetPartial returns the occurrences of the tokens fetched before the query timeout. The tokens are fetched one by
+// one, so the occurrences of every returned token are complete, and the tokens not fetched in time are missing.
+func (i *DbIndex) GetPartial(tokens []string) (map[string]Occurrences, bool, error) {
+	return i.getPartial("", documentFilter{}, tokens)
+}
+
+// documentFilter restricts the documents by the modification time and the names. Zero bounds and nil names do not
+// restrict them.
+type documentFilter struct {
+	since time.Time
+	until time.Time
+	names []string
+}
 
-	if err == nil {
-		return tkn, nil
+// where returns the condition on the documents joined as d and its parameters.
+func (r documentFilter) where() (string, []interface{}) {
+	var conditions []string
+	var params []interface{}
+	if !r.since.IsZero() {
+		conditions = append(conditions, "d.created_at >= ?")
+		params = append(params, r.since)
 	}
+	if !r.until.IsZero() {
+		conditions = append(conditions, "d.created_at <= ?")
+		params = append(params, r.until)
+	}
+	if r.names != nil {
+		if len(r.names) == 0 {
+			conditions = append(conditions, "false")
+		} else {
+			conditions = append(conditions, "d.name IN (?)")
+			params = append(params, pg.In(r.names))
+		}
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(conditions, " AND "), params
+}
 
-	i.tokensM.Lock()
-	defer i.tokensM.Unlock()
-	tkn.Token = token
-	if _, err := i.pg.Model(tkn).Returning("*").Insert(); err != nil {
-		return nil, fmt.Errorf("error inserting %s %w", token, err)
+func (i *DbIndex) get(tenant string, r documentFilter, tokens []string) (map[string]Occurrences, error) {
+	ctx, cancel := i.queryContext()
+	defer cancel()
+	results := map[string]Occurrences{}
+	if err := i.fetch(ctx, tenant, r, tokens, results, map[string]*Source{}); err != nil {
+		return nil, err
 	}
-	log.Debug().Msgf("add token %s %d to cache", token, tkn.ID)
-	i.tokensCache[token] = tkn.ID
-	return tkn, nil
+	return results, nil
 }
 
-func (i *DbIndex) getDocument(name string) (*Document, error) {
-	i.documentsM.RLock()
-	if id, ok := i.documentsCache[name]; ok {
-		i.documentsM.RUnlock()
-		return &Document{
-			ID:   id,
-			Name: name,
-		}, nil
+func (i *DbIndex) getPartial(tenant string, r documentFilter, tokens []string) (map[string]Occurrences, bool, error) {
+	ctx, cancel := i.queryContext()
+	defer cancel()
+	results := map[string]Occurrences{}
+	documents := map[string]*Source{}
+	for _, token := range tokens {
+		err := i.fetch(ctx, tenant, r, []string{token}, results, documents)
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			log.Warn().Int("fetched", len(results)).Int("tokens", len(tokens)).Msg("query timeout, partial results")
+			return results, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
 	}
-	i.documentsM.RUnlock()
+	return results, false, nil
+}
 
-	doc := &Document{}
-	err := i.pg.Model(doc).Where("name=?", name).Select()
-	if err != nil && err != pg.ErrNoRows {
-		return nil, fmt.Errorf("error selecting %s %w", name, err)
+// queryContext returns the context of the query limited by the query timeout.
+func (i *DbIndex) queryContext() (context.Context, context.CancelFunc) {
+	if i.queryTimeout <= 0 {
+		return context.WithCancel(context.Background())
 	}
+	return context.WithTimeout(context.Background(), i.queryTimeout)
+}
+
+// fetch adds the occurrences of the tokens to the results. The documents are shared by all fetched tokens.
+func (i *DbIndex) fetch(ctx context.Context, tenant string, r documentFilter, tokens []string,
+	results map[string]Occurrences, documents map[string]*Source) error {
+	return i.fetchWhere(ctx, tenant, r, "t.token IN (?)", pg.In(tokens), results, documents)
+}
 
-	if err == nil {
-		return doc, err
+// fetchWhere adds the occurrences of the tokens matching the condition with the parameter to the results.
+func (i *DbIndex) fetchWhere(ctx context.Context, tenant string, r documentFilter, condition string,
+	param interface{}, results map[string]Occurrences, documents map[string]*Source) error {
+	type item struct {
+		Position  int       `pg:"position"`
+		Token     string    `pg:"token"`
+		Name      string    `pg:"name"`
+		CreatedAt time.Time `pg:"created_at"`
 	}
+	var items []item
+
+	where, params := r.where()
+	_, err := i.pg.QueryContext(
+		ctx,
+		&items,
+		`SELECT position, t.token, d.name, d.created_at FROM occurrences
+			JOIN tokens t ON occurrences.token_id = t.id
+			JOIN documents d on occurrences.document_id = d.id
+			WHERE occurrences.tenant_id = ? AND `+condition+` AND NOT d.excluded`+where+`;`,
+		append([]interface{}{tenant, param}, params...)...,
+	)
 
-	i.documentsM.Lock()
-	defer i.documentsM.Unlock()
-	doc.Name = name
-	if _, err := i.pg.Model(doc).Returning("*").Insert(); err != nil {
-		return nil, fmt.Errorf("error inserting %s %w", name, err)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if _, ok := documents[item.Name]; !ok {
+			documents[item.Name] = &Source{
+				Name:    item.Name,
+				ModTime: item.CreatedAt,
+			}
+		}
+		if _, ok := results[item.Token]; !ok {
+			results[item.Token] = Occurrences{}
+		}
+		doc := documents[item.Name]
+		results[item.Token][doc] = append(results[item.Token][doc], item.Position)
 	}
-	log.Debug().Msgf("add document %s %d to cache", name, doc.ID)
-	i.documentsCache[name] = doc.ID
-	return doc, err
+	return nil
 }
 
-// Get returns occurrences list for the list of tokens.
-func (i *DbIndex) Get(tokens []string) (map[string]Occurrences, error) {
+// Count returns the number of occurrences in every document for the list of tokens.
+// It aggregates rows in the database, so it is much cheaper than Get for frequent tokens.
+func (i *DbIndex) Count(tokens []string) (map[string]Counts, error) {
+	return i.count("", documentFilter{}, tokens)
+}
+
+func (i *DbIndex) count(tenant string, r documentFilter, tokens []string) (map[string]Counts, error) {
 	type item struct {
-		Position int    `pg:"position"`
-		Token    string `pg:"token"`
-		Name     string `pg:"name"`
+		Count     int       `pg:"count"`
+		Token     string    `pg:"token"`
+		Name      string    `pg:"name"`
+		CreatedAt time.Time `pg:"created_at"`
 	}
 	var items []item
 
+	where, params := r.where()
 	_, err := i.pg.Query(
 		&items,
-		`SELECT position, t.token, d.name FROM occurrences
+		`SELECT count(*) AS count, t.token, d.name, d.created_at FROM occurrences
 			JOIN tokens t ON occurrences.token_id = t.id
 			JOIN documents d on occurrences.document_id = d.id
-			WHERE t.token IN (?);`,
-		pg.In(tokens),
+			WHERE occurrences.tenant_id = ? AND t.token IN (?) AND NOT d.excluded`+where+`
+			GROUP BY t.token, d.name, d.created_at;`,
+		append([]interface{}{tenant, pg.In(tokens)}, params...)...,
 	)
-
 	if err != nil {
 		return nil, err
 	}
-	results := map[string]Occurrences{}
+
+	results := map[string]Counts{}
 	documents := map[string]*Source{}
 	for _, item := range items {
 		if _, ok := documents[item.Name]; !ok {
 			documents[item.Name] = &Source{
-				Name: item.Name,
+				Name:    item.Name,
+				ModTime: item.CreatedAt,
 			}
 		}
 		if _, ok := results[item.Token]; !ok {
-			results[item.Token] = Occurrences{}
+			results[item.Token] = Counts{}
 		}
-		doc := documents[item.Name]
-		results[item.Token][doc] = append(results[item.Token][doc], item.Position)
+		results[item.Token][documents[item.Name]] = item.Count
 	}
-	return results, err
+	return results, nil
 }
 
-// Close the engine.
+// Iterate streams all postings from the database ordered by token and document.
+// Rows are read one by one, so the index is never loaded into memory as a whole.
+func (i *DbIndex) Iterate(fn func(posting Posting) error) error {
+	return i.iterate("", fn)
+}
+
+func (i *DbIndex) iterate(tenant string, fn func(posting Posting) error) error {
+	type item struct {
+		Position  int       `pg:"position"`
+		Token     string    `pg:"token"`
+		Name      string    `pg:"name"`
+		CreatedAt time.Time `pg:"created_at"`
+		Hash      string    `pg:"hash"`
+		Language  string    `pg:"language"`
+		Excluded  bool      `pg:"excluded"`
+	}
+
+	var posting *Posting
+	err := i.pg.Model((*Occurrence)(nil)).
+		ColumnExpr("occurrence.position, t.token, d.name, d.created_at, d.hash, d.language, d.excluded").
+		Join("JOIN tokens t ON occurrence.token_id = t.id").
+		Join("JOIN documents d ON occurrence.document_id = d.id").
+		Where("occurrence.tenant_id = ?", tenant).
+		OrderExpr("t.token, d.name, occurrence.position").
+		ForEach(func(row *item) error {
+			if posting != nil && posting.Token == row.Token && posting.Document == row.Name {
+				posting.Positions = append(posting.Positions, row.Position)
+				return nil
+			}
+			if posting != nil {
+				if err := fn(*posting); err != nil {
+					return err
+				}
+			}
+			posting = &Posting{
+				Token:     row.Token,
+				Document:  row.Name,
+				Positions: []int{row.Position},
+				Source: &Source{
+					Name:     row.Name,
+					ModTime:  row.CreatedAt,
+					Hash:     row.Hash,
+					Language: row.Language,
+					Excluded: row.Excluded,
+				},
+			}
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	if posting != nil {
+		return fn(*posting)
+	}
+	return nil
+}
+
+// IterateTokens streams all tokens found in the documents from the database.
+func (i *DbIndex) IterateTokens(fn func(token string) error) error {
+	return i.iterateTokens("", fn)
+}
+
+func (i *DbIndex) iterateTokens(tenant string, fn func(token string) error) error {
+	return i.pg.Model((*Token)(nil)).
+		Where("EXISTS (SELECT 1 FROM occurrences o WHERE o.token_id = token.id AND o.tenant_id = ?)", tenant).
+		ForEach(func(tkn *Token) error {
+			return fn(tkn.Token)
+		})
+}
+
+// DeleteDocument removes the document and all its occurrences from the database.
+// Tokens shared with other documents are kept.
+func (i *DbIndex) DeleteDocument(name string) error {
+	return i.deleteDocument("", name)
+}
+
+func (i *DbIndex) deleteDocument(tenant string, name string) error {
+	if err := i.remove(tenant, name); err != nil && !errors.Is(err, ErrUnknownDocument) {
+		return err
+	}
+	return nil
+}
+
+// Remove removes the document, its occurrences are deleted by the cascade. Tokens shared with other documents are
+// kept. The batched occurrences are flushed first.
+func (i *DbIndex) Remove(source Source) error {
+	return i.remove("", source.Name)
+}
+
+func (i *DbIndex) remove(tenant string, name string) error {
+	if err := i.flushPending(); err != nil {
+		return fmt.Errorf("error deleting %s %w", name, err)
+	}
+	result, err := i.pg.Model((*Document)(nil)).Where("tenant_id=? AND name=?", tenant, name).Delete()
+	if err != nil {
+		return fmt.Errorf("error deleting %s %w", name, err)
+	}
+	i.documentsCache.delete(documentKey{tenant: tenant, name: name})
+	if result.RowsAffected() == 0 {
+		return ErrUnknownDocument
+	}
+	return nil
+}
+
+// SetExcluded excludes the document from the search or includes it back.
+func (i *DbIndex) SetExcluded(name string, excluded bool) error {
+	return i.setExcluded("", name, excluded)
+}
+
+func (i *DbIndex) setExcluded(tenant string, name string, excluded bool) error {
+	result, err := i.pg.Model((*Document)(nil)).
+		Set("excluded = ?", excluded).
+		Where("tenant_id=? AND name=?", tenant, name).
+		Update()
+	if err != nil {
+		return fmt.Errorf("error updating %s %w", name, err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUnknownDocument
+	}
+	return nil
+}
+
+// DeleteByPrefix removes all documents with the name starting with the prefix and their occurrences from the database.
+// The batched occurrences are flushed first.
+func (i *DbIndex) DeleteByPrefix(prefix string) (int, error) {
+	return i.deleteByPrefix("", prefix)
+}
+
+// likeEscaper escapes the special characters of LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (i *DbIndex) deleteByPrefix(tenant string, prefix string) (int, error) {
+	if err := i.flushPending(); err != nil {
+		return 0, fmt.Errorf("error deleting %s %w", prefix, err)
+	}
+	var docs []Document
+	_, err := i.pg.Model(&docs).
+		Where("tenant_id=? AND name LIKE ?", tenant, likeEscaper.Replace(prefix)+"%").
+		Returning("name").
+		Delete()
+	if err != nil {
+		return 0, fmt.Errorf("error deleting %s %w", prefix, err)
+	}
+	for _, doc := range docs {
+		i.documentsCache.delete(documentKey{tenant: tenant, name: doc.Name})
+	}
+	return len(docs), nil
+}
+
+// CompactTokens removes the tokens which are not found in any document, e.g. after the documents are deleted, and
+// evicts them from the cache. It returns the number of the removed tokens. The tokens are shared by all tenants, so the
+// whole database is compacted. The batched occurrences are flushed first, the documents must not be added concurrently
+// as their new tokens may be removed before their occurrences are inserted. Only the cache of this engine is evicted,
+// so the compaction requires exclusive access to the database: the other processes using it, e.g. the web servers,
+// keep the ids of the removed tokens in their caches and must be stopped or restarted after the compaction.
+func (i *DbIndex) CompactTokens() (int, error) {
+	if err := i.Flush(); err != nil {
+		return 0, err
+	}
+	var tokens []Token
+	_, err := i.pg.Query(
+		&tokens,
+		`DELETE FROM tokens AS t
+			WHERE NOT EXISTS (SELECT 1 FROM occurrences AS o WHERE o.token_id = t.id)
+			RETURNING t.token;`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting orphaned tokens %w", err)
+	}
+	for _, token := range tokens {
+		i.tokensCache.delete(token.Token)
+	}
+	return len(tokens), nil
+}
+
+// Tenant returns the view of the engine which adds, searches and deletes only the documents of the tenant.
+func (i *DbIndex) Tenant(tenant string) IndexEngine {
+	return &TenantIndex{
+		DbIndex: i,
+		tenant:  tenant,
+	}
+}
+
+// Between returns the view of the engine which searches only the documents modified in the time range.
+func (i *DbIndex) Between(since time.Time, until time.Time) IndexEngine {
+	return &TenantIndex{
+		DbIndex:        i,
+		documentFilter: documentFilter{since: since, until: until},
+	}
+}
+
+// Restrict returns the view of the engine which searches only the named documents.
+func (i *DbIndex) Restrict(names []string) IndexEngine {
+	return &TenantIndex{
+		DbIndex:        i,
+		documentFilter: documentFilter{names: names},
+	}
+}
+
+// Close flushes the occurrences batched by all workers, stops the workers and closes the engine.
 func (i *DbIndex) Close() {
-	i.pg.Close()
+	i.closeOnce.Do(func() {
+		if err := i.Flush(); err != nil {
+			log.Err(err).Msg("error flushing rows on close")
+		}
+		close(i.done)
+		i.pg.Close()
+	})
+}
+
+// TenantIndex is the postgresql-based engine restricted to the documents of a single tenant and optionally to the
+// documents modified in the time range and to the named documents. Create it with DbIndex.Tenant, DbIndex.Between or
+// DbIndex.Restrict functions.
+type TenantIndex struct {
+	*DbIndex
+	tenant string
+	documentFilter
+}
+
+// Between returns the view of the tenant's documents modified in the time range.
+func (t *TenantIndex) Between(since time.Time, until time.Time) IndexEngine {
+	filter := t.documentFilter
+	filter.since, filter.until = since, until
+	return &TenantIndex{
+		DbIndex:        t.DbIndex,
+		tenant:         t.tenant,
+		documentFilter: filter,
+	}
+}
+
+// Restrict returns the view of the tenant's documents with the names.
+func (t *TenantIndex) Restrict(names []string) IndexEngine {
+	filter := t.documentFilter
+	filter.names = names
+	return &TenantIndex{
+		DbIndex:        t.DbIndex,
+		tenant:         t.tenant,
+		documentFilter: filter,
+	}
+}
+
+// Tenant returns the view itself for its own tenant and nil for another tenant, so the view is never widened to the
+// documents of the other tenants.
+func (t *TenantIndex) Tenant(tenant string) IndexEngine {
+	if tenant != t.tenant {
+		return nil
+	}
+	return t
+}
+
+// Add adds new token, document and position of the tenant to the database.
+func (t *TenantIndex) Add(token string, position int, source Source) error {
+	return t.add(t.tenant, token, position, source)
+}
+
+// Get returns occurrences list of the tenant's documents for the list of tokens.
+func (t *TenantIndex) Get(tokens []string) (map[string]Occurrences, error) {
+	return t.get(t.tenant, t.documentFilter, tokens)
+}
+
+// GetPartial returns the occurrences of the tokens in the tenant's documents fetched before the query timeout.
+func (t *TenantIndex) GetPartial(tokens []string) (map[string]Occurrences, bool, error) {
+	return t.getPartial(t.tenant, t.documentFilter, tokens)
+}
+
+// Count returns the number of occurrences in every tenant's document for the list of tokens.
+func (t *TenantIndex) Count(tokens []string) (map[string]Counts, error) {
+	return t.count(t.tenant, t.documentFilter, tokens)
+}
+
+// Iterate streams all postings of the tenant from the database.
+func (t *TenantIndex) Iterate(fn func(posting Posting) error) error {
+	return t.iterate(t.tenant, fn)
+}
+
+// IterateTokens streams all tokens found in the tenant's documents from the database.
+func (t *TenantIndex) IterateTokens(fn func(token string) error) error {
+	return t.iterateTokens(t.tenant, fn)
+}
+
+// DeleteDocument removes the tenant's document and all its occurrences from the database.
+func (t *TenantIndex) DeleteDocument(name string) error {
+	return t.deleteDocument(t.tenant, name)
 }
+
+// Remove removes the tenant's document and its occurrences from the database.
+func (t *TenantIndex) Remove(source Source) error {
+	return t.remove(t.tenant, source.Name)
+}
+
+// DeleteByPrefix removes the tenant's documents with the name starting with the prefix from the database.
+func (t *TenantIndex) DeleteByPrefix(prefix string) (int, error) {
+	return t.deleteByPrefix(t.tenant, prefix)
+}
+
+// SetExcluded excludes the tenant's document from the search or includes it back.
+func (t *TenantIndex) SetExcluded(name string, excluded bool) error {
+	return t.setExcluded(t.tenant, name, excluded)
+}
+
+// Close does nothing because the connection is owned by the parent DbIndex.
+func (t *TenantIndex) Close() {}
@@ -0,0 +1,921 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+)
+
+// newTestDbIndex connects to the migrated database from PGSQL environment variable.
+// The test is skipped if the variable is not set.
+func newTestDbIndex(tb testing.TB, options ...DbOption) *DbIndex {
+	url := os.Getenv("PGSQL")
+	if url == "" {
+		tb.Skip("PGSQL is not set")
+	}
+	opt, err := pg.ParseURL(url)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return NewDbIndex(pg.Connect(opt), options...)
+}
+
+// waitOccurrences polls the engine until the token is flushed to the database.
+func waitOccurrences(t *testing.T, engine IndexEngine, token string) Occurrences {
+	return waitDocuments(t, engine, token, 1)
+}
+
+// waitDocuments polls the engine until the token of the given number of documents is flushed to the database.
+func waitDocuments(t *testing.T, engine IndexEngine, token string, documents int) Occurrences {
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		results, err := engine.Get([]string{token})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results[token]) >= documents {
+			return results[token]
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("token %s has not been flushed", token)
+	return nil
+}
+
+func TestDbIndex_TenantIsolation(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	suffix := time.Now().UnixNano()
+	token := fmt.Sprintf("tenanttoken%d", suffix)
+	a := i.Tenant(fmt.Sprintf("a%d", suffix))
+	b := i.Tenant(fmt.Sprintf("b%d", suffix))
+
+	if err := a.Add(token, 0, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(token, 5, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for tenant, expected := range map[IndexEngine]int{a: 0, b: 5} {
+		occurrences := waitOccurrences(t, tenant, token)
+		if len(occurrences) != 1 {
+			t.Fatalf("%v must contain exactly one document", occurrences)
+		}
+		for _, positions := range occurrences {
+			if len(positions) != 1 || positions[0] != expected {
+				t.Errorf("%v is not equal to expected %v", positions, []int{expected})
+			}
+		}
+	}
+
+	results, err := i.Get([]string{token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results[token]) != 0 {
+		t.Errorf("default tenant must not see %v", results[token])
+	}
+
+	if err := a.(*TenantIndex).DeleteDocument("file1"); err != nil {
+		t.Fatal(err)
+	}
+	results, err = a.Get([]string{token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results[token]) != 0 {
+		t.Errorf("deleted document is still found %v", results[token])
+	}
+	waitOccurrences(t, b, token)
+}
+
+func TestTenantIndex_Tenant(t *testing.T) {
+	engine := (&DbIndex{}).Tenant("acme")
+	if scoped := engine.(TenantEngine).Tenant("acme"); scoped != engine {
+		t.Errorf("%v is not equal to expected %v", scoped, engine)
+	}
+	// The other tenant is rejected before any query, so the database is not needed.
+	i := &Index{engine: engine}
+	if _, err := i.SearchTenant("other", "apple"); !errors.Is(err, ErrTenantsNotSupported) {
+		t.Errorf("%v is not equal to expected %v", err, ErrTenantsNotSupported)
+	}
+}
+
+func TestDbIndex_Iterate(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	suffix := time.Now().UnixNano()
+	engine := i.Tenant(fmt.Sprintf("iterate%d", suffix))
+	if err := engine.Add("appl", 0, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Add("appl", 2, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Add("banana", 1, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	waitOccurrences(t, engine, "appl")
+	waitOccurrences(t, engine, "banana")
+
+	var actual []Posting
+	if err := engine.(Iterator).Iterate(func(posting Posting) error {
+		if posting.Source == nil || posting.Source.Name != posting.Document {
+			t.Errorf("%v is not equal to expected %s", posting.Source, posting.Document)
+		}
+		posting.Source = nil
+		actual = append(actual, posting)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Posting{
+		{Token: "appl", Document: "file1", Positions: []int{0, 2}},
+		{Token: "banana", Document: "file1", Positions: []int{1}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestDbIndex_ConcurrentAdd(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	suffix := time.Now().UnixNano()
+	engine := i.Tenant(fmt.Sprintf("concurrent%d", suffix))
+	tokens := make([]string, 10)
+	for k := range tokens {
+		tokens[k] = fmt.Sprintf("concurrent%d_%d", suffix, k)
+	}
+
+	wg := &sync.WaitGroup{}
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for k, token := range tokens {
+				source := Source{Name: fmt.Sprintf("file%d", k%3)}
+				if err := engine.Add(token, g, source); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for _, token := range tokens {
+		count, err := i.pg.Model((*Token)(nil)).Where("token=?", token).Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("token %s is inserted %d times", token, count)
+		}
+	}
+	for k := 0; k < 3; k++ {
+		count, err := i.pg.Model((*Document)(nil)).
+			Where("tenant_id=? AND name=?", fmt.Sprintf("concurrent%d", suffix), fmt.Sprintf("file%d", k)).
+			Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("document file%d is inserted %d times", k, count)
+		}
+	}
+}
+
+func TestDbIndex_Count(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("count%d", time.Now().UnixNano()))
+	for position := 0; position < 3; position++ {
+		if err := engine.Add("appl", position, Source{Name: "file1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := engine.Add("appl", 0, Source{Name: "file2"}); err != nil {
+		t.Fatal(err)
+	}
+	waitDocuments(t, engine, "appl", 2)
+
+	counts, err := engine.(Counter).Count([]string{"appl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := map[string]int{}
+	for source, count := range counts["appl"] {
+		actual[source.Name] = count
+	}
+	expected := map[string]int{"file1": 3, "file2": 1}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+// benchmarkDbIndex fills the tenant with the frequent token occurring in many documents.
+func benchmarkDbIndex(b *testing.B) (*DbIndex, IndexEngine) {
+	url := os.Getenv("PGSQL")
+	if url == "" {
+		b.Skip("PGSQL is not set")
+	}
+	opt, err := pg.ParseURL(url)
+	if err != nil {
+		b.Fatal(err)
+	}
+	i := NewDbIndex(pg.Connect(opt))
+	engine := i.Tenant("benchmark")
+
+	occurrences, err := engine.Get([]string{"frequent"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(occurrences["frequent"]) == 0 {
+		for document := 0; document < 100; document++ {
+			for position := 0; position < 1000; position++ {
+				if err := engine.Add("frequent", position, Source{Name: fmt.Sprintf("file%d", document)}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		for len(occurrences["frequent"]) < 100 {
+			time.Sleep(time.Second)
+			if occurrences, err = engine.Get([]string{"frequent"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return i, engine
+}
+
+func BenchmarkDbIndex_Get(b *testing.B) {
+	i, engine := benchmarkDbIndex(b)
+	defer i.Close()
+
+	b.ResetTimer()
+	rows := 0
+	for n := 0; n < b.N; n++ {
+		occurrences, err := engine.Get([]string{"frequent"})
+		if err != nil {
+			b.Fatal(err)
+		}
+		rows = 0
+		for _, positions := range occurrences["frequent"] {
+			rows += len(positions)
+		}
+	}
+	b.ReportMetric(float64(rows), "rows/op")
+}
+
+func BenchmarkDbIndex_Count(b *testing.B) {
+	i, engine := benchmarkDbIndex(b)
+	defer i.Close()
+
+	b.ResetTimer()
+	rows := 0
+	for n := 0; n < b.N; n++ {
+		counts, err := engine.(Counter).Count([]string{"frequent"})
+		if err != nil {
+			b.Fatal(err)
+		}
+		rows = len(counts["frequent"])
+	}
+	b.ReportMetric(float64(rows), "rows/op")
+}
+
+func TestDbIndex_CompactTokens(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	kept, orphaned := "kept"+suffix, "orphaned"+suffix
+	engine := i.Tenant("compact" + suffix)
+	for _, item := range []struct {
+		token    string
+		document string
+	}{
+		{kept, "file1"},
+		{orphaned, "file2"},
+		{kept, "file2"},
+	} {
+		if err := engine.Add(item.token, 0, Source{Name: item.document}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitDocuments(t, engine, kept, 2)
+	if err := engine.(*TenantIndex).DeleteDocument("file2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := i.CompactTokens(); err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	if _, err := i.pg.Query(&actual, `SELECT token FROM tokens WHERE token IN (?, ?) ORDER BY token;`, kept, orphaned); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{kept}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	// The removed token is added again instead of using the stale cached id.
+	if err := engine.Add(orphaned, 0, Source{Name: "file3"}); err != nil {
+		t.Fatal(err)
+	}
+	waitDocuments(t, engine, orphaned, 1)
+}
+
+func TestDbIndex_DeleteByPrefix(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("prefix%d", time.Now().UnixNano()))
+	for _, name := range []string{"docs/file1", "docs/file2", "docs_file3", "other/file4"} {
+		if err := engine.Add("appl", 0, Source{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitDocuments(t, engine, "appl", 4)
+
+	deleted, err := engine.(PrefixDeleter).DeleteByPrefix("docs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("%d is not equal to expected 2", deleted)
+	}
+
+	occurrences, err := engine.Get([]string{"appl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for source := range occurrences["appl"] {
+		actual = append(actual, source.Name)
+	}
+	sort.Strings(actual)
+	expected := []string{"docs_file3", "other/file4"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestDbIndex_Remove(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("remove%d", time.Now().UnixNano()))
+	for _, name := range []string{"file1", "file2"} {
+		if err := engine.Add("appl", 0, Source{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitDocuments(t, engine, "appl", 2)
+
+	if err := engine.(Remover).Remove(Source{Name: "file2"}); err != nil {
+		t.Fatal(err)
+	}
+	occurrences, err := engine.Get([]string{"appl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for source := range occurrences["appl"] {
+		actual = append(actual, source.Name)
+	}
+	expected := []string{"file1"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+	if err := engine.(Remover).Remove(Source{Name: "file2"}); !errors.Is(err, ErrUnknownDocument) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownDocument)
+	}
+}
+
+func TestDbIndex_RemoveBatched(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	// The occurrences of the removed document are not flushed yet.
+	engine := i.Tenant(fmt.Sprintf("remove%d", time.Now().UnixNano()))
+	if err := engine.Add("appl", 0, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.(Remover).Remove(Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Add("appl", 0, Source{Name: "file2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	occurrences, err := engine.Get([]string{"appl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for source := range occurrences["appl"] {
+		actual = append(actual, source.Name)
+	}
+	expected := []string{"file2"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestDbIndex_Flush(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("flush%d", time.Now().UnixNano()))
+	if err := engine.Add("appl", 0, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := engine.Get([]string{"appl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results["appl"]) != 1 {
+		t.Errorf("%d is not equal to expected 1", len(results["appl"]))
+	}
+
+	if err := i.Flush(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDbIndex_FlushEmpty(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		i := &DbIndex{
+			insertC: make(chan Occurrence),
+			flushC:  make([]chan chan error, workers),
+			done:    make(chan struct{}),
+		}
+		i.startWorkers()
+		if err := i.Flush(); err != nil {
+			t.Error(err)
+		}
+		close(i.done)
+	}
+}
+
+func TestDbIndex_FlushClosed(t *testing.T) {
+	i := &DbIndex{
+		insertC: make(chan Occurrence),
+		flushC:  make([]chan chan error, 2),
+		done:    make(chan struct{}),
+	}
+	i.startWorkers()
+	close(i.done)
+	if err := i.Flush(); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("%v is not equal to expected %v", err, ErrEngineClosed)
+	}
+	if _, err := i.CompactTokens(); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("%v is not equal to expected %v", err, ErrEngineClosed)
+	}
+}
+
+func TestDbIndex_AddClosed(t *testing.T) {
+	i := newTestDbIndex(t)
+	engine := i.Tenant(fmt.Sprintf("closed%d", time.Now().UnixNano()))
+	if err := engine.Add("appl", 0, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+	// The token and the document are cached, so nothing is queried before the occurrence is sent to the workers.
+	if err := engine.Add("appl", 1, Source{Name: "file1"}); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("%v is not equal to expected %v", err, ErrEngineClosed)
+	}
+}
+
+func TestDbIndex_FlushWorkers(t *testing.T) {
+	i := newTestDbIndex(t, WithFlushWorkers(4))
+
+	tenant := fmt.Sprintf("workers%d", time.Now().UnixNano())
+	engine := i.Tenant(tenant)
+	for position := 0; position < 100; position++ {
+		if err := engine.Add("appl", position, Source{Name: "file1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Close flushes the batches of all workers.
+	i.Close()
+
+	i = newTestDbIndex(t)
+	defer i.Close()
+	results, err := i.Tenant(tenant).Get([]string{"appl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions := 0
+	for _, p := range results["appl"] {
+		positions += len(p)
+	}
+	if positions != 100 {
+		t.Errorf("%d is not equal to expected 100", positions)
+	}
+}
+
+func TestDbIndex_SetExcluded(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("excluded%d", time.Now().UnixNano()))
+	for _, name := range []string{"file1", "template"} {
+		if err := engine.Add("appl", 0, Source{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := i.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	excluder := engine.(Excluder)
+	if err := excluder.SetExcluded("template", true); err != nil {
+		t.Fatal(err)
+	}
+	results, err := engine.Get([]string{"appl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results["appl"]) != 1 {
+		t.Errorf("%d is not equal to expected 1", len(results["appl"]))
+	}
+	if err := excluder.SetExcluded("template", false); err != nil {
+		t.Fatal(err)
+	}
+	waitDocuments(t, engine, "appl", 2)
+	if err := excluder.SetExcluded("file2", true); !errors.Is(err, ErrUnknownDocument) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownDocument)
+	}
+}
+
+func TestDbIndex_DocumentStats(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("stats%d", time.Now().UnixNano()))
+	for position, token := range []string{"appl", "appl", "banana", "appl"} {
+		if err := engine.Add(token, position, Source{Name: "file1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := i.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := engine.(DocumentStatsEngine).DocumentStats("file1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DocumentStats{Length: 4, Vocabulary: 2}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%+v is not equal to expected %+v", actual, expected)
+	}
+	if _, err := engine.(DocumentStatsEngine).DocumentStats("file2"); !errors.Is(err, ErrUnknownDocument) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownDocument)
+	}
+}
+
+func TestDbIndex_ListDocuments(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("list%d", time.Now().UnixNano()))
+	for _, name := range []string{"file2", "file3", "file1"} {
+		if err := engine.Add("appl", 0, Source{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := i.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		options  ListOptions
+		expected []string
+	}{
+		{ListOptions{Limit: 2, Order: OrderByName}, []string{"file1", "file2"}},
+		{ListOptions{Limit: 2, Offset: 1, Order: OrderByID}, []string{"file3", "file1"}},
+	} {
+		list, err := engine.(DocumentLister).ListDocuments(test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for _, document := range list.Documents {
+			actual = append(actual, document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%+v: %v is not equal to expected %v", test.options, actual, test.expected)
+		}
+		if list.Total != 3 {
+			t.Errorf("%d is not equal to expected %d", list.Total, 3)
+		}
+	}
+}
+
+func TestDbIndex_Between(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("between%d", time.Now().UnixNano()))
+	old := Source{Name: "old", ModTime: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := engine.Add("appl", 0, old); err != nil {
+		t.Fatal(err)
+	}
+	recent := Source{Name: "new", ModTime: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)}
+	if err := engine.Add("appl", 0, recent); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := engine.(TimeRangeEngine).Between(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}).
+		Get([]string{"appl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for source := range results["appl"] {
+		actual = append(actual, source.Name)
+		if !source.ModTime.Equal(recent.ModTime) {
+			t.Errorf("%v is not equal to expected %v", source.ModTime, recent.ModTime)
+		}
+	}
+	if expected := []string{"new"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestDbIndex_Restrict(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("restrict%d", time.Now().UnixNano()))
+	for _, name := range []string{"file1", "file2", "file3"} {
+		if err := engine.Add("appl", 0, Source{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := i.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		names    []string
+		expected []string
+	}{
+		{[]string{"file1", "file3"}, []string{"file1", "file3"}},
+		{[]string{}, nil},
+	} {
+		results, err := engine.(RestrictedEngine).Restrict(test.names).Get([]string{"appl"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for source := range results["appl"] {
+			actual = append(actual, source.Name)
+		}
+		sort.Strings(actual)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v is not equal to expected %v", actual, test.expected)
+		}
+	}
+}
+
+func TestDbIndex_Stats(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("stats%d", time.Now().UnixNano()))
+	for position, token := range []string{"appl", "banana", "appl"} {
+		if err := engine.Add(token, position, Source{Name: "file1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := engine.Add("appl", 0, Source{Name: "file2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := engine.(StatsEngine).Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Stats{Documents: 2, Tokens: 2, Occurrences: 4}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+// BenchmarkDbIndex_Add reports the ingestion throughput with one and several flush workers.
+func BenchmarkDbIndex_Add(b *testing.B) {
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			i := newTestDbIndex(b, WithFlushWorkers(workers))
+			defer i.Close()
+			engine := i.Tenant(fmt.Sprintf("bench%d", time.Now().UnixNano()))
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if err := engine.Add(fmt.Sprintf("token%d", n%1000), n, Source{Name: "file1"}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := i.Flush(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestDbIndex_Lengths(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("lengths%d", time.Now().UnixNano()))
+	for position, token := range []string{"appl", "appl", "banana"} {
+		if err := engine.Add(token, position, Source{Name: "file1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := engine.Add("appl", 0, Source{Name: "file2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := engine.(LengthsEngine).Lengths([]string{"file1", "file3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{"file1": 3}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestDbIndex_InsertConflict(t *testing.T) {
+	for _, test := range []struct {
+		conflicts ConflictPolicy
+		fails     bool
+	}{
+		{IgnoreConflicts, false},
+		{FailConflicts, true},
+	} {
+		i := newTestDbIndex(t, WithConflictPolicy(test.conflicts))
+		tenant := fmt.Sprintf("conflict%d", time.Now().UnixNano())
+		engine := i.Tenant(tenant)
+		if err := engine.Add("appl", 0, Source{Name: "file1"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := i.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.Add("appl", 0, Source{Name: "file1"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := i.Flush(); (err != nil) != test.fails {
+			t.Errorf("%v: %v is not equal to expected failure %v", test.conflicts, err, test.fails)
+		}
+		count, err := i.pg.Model((*Occurrence)(nil)).Where("tenant_id = ?", tenant).Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("%v: %d is not equal to expected %d", test.conflicts, count, 1)
+		}
+		i.Close()
+	}
+}
+
+func TestDbIndex_InsertConflictDropped(t *testing.T) {
+	i := newTestDbIndex(t, WithConflictPolicy(FailConflicts))
+	defer i.Close()
+	tenant := fmt.Sprintf("conflict%d", time.Now().UnixNano())
+	engine := i.Tenant(tenant)
+	if err := engine.Add("appl", 0, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The valid occurrence batched with the stored one is written.
+	if err := engine.Add("appl", 0, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Add("appl", 1, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Flush(); !errors.Is(err, ErrConflict) {
+		t.Errorf("%v is not equal to expected %v", err, ErrConflict)
+	}
+
+	// The conflicting batch is not retried.
+	if err := engine.Add("appl", 2, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Flush(); err != nil {
+		t.Errorf("%v is not equal to expected nil", err)
+	}
+	count, err := i.pg.Model((*Occurrence)(nil)).Where("tenant_id = ?", tenant).Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("%d is not equal to expected %d", count, 3)
+	}
+}
+
+func TestIsConflict(t *testing.T) {
+	for _, test := range []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{errors.New("connection refused"), false},
+		{pgError{"23505"}, true},
+		{fmt.Errorf("error inserting rows: %w", pgError{"23505"}), true},
+		{pgError{"23503"}, false},
+	} {
+		if actual := isConflict(test.err); actual != test.expected {
+			t.Errorf("%v: %v is not equal to expected %v", test.err, actual, test.expected)
+		}
+	}
+}
+
+// pgError is the error of PostgreSQL with the code.
+type pgError struct {
+	code string
+}
+
+func (e pgError) Error() string {
+	return "ERROR #" + e.code
+}
+
+func (e pgError) Field(field byte) string {
+	if field == 'C' {
+		return e.code
+	}
+	return ""
+}
+
+func (e pgError) IntegrityViolation() bool {
+	return strings.HasPrefix(e.code, "23")
+}
+
+func TestDbIndex_full(t *testing.T) {
+	for _, test := range []struct {
+		flushMemory int
+		batched     int
+		expected    bool
+	}{
+		{0, 1000, false},
+		{3 * occurrenceSize, 3, false},
+		{3 * occurrenceSize, 4, true},
+	} {
+		i := &DbIndex{flushMemory: test.flushMemory}
+		if actual := i.full(make([]Occurrence, test.batched)); actual != test.expected {
+			t.Errorf("%d/%d: %v is not equal to expected %v", test.flushMemory, test.batched, actual, test.expected)
+		}
+	}
+}
+
+func TestDbIndex_FlushMemory(t *testing.T) {
+	i := newTestDbIndex(t, WithFlushMemory(2*occurrenceSize))
+	defer i.Close()
+	engine := i.Tenant(fmt.Sprintf("memory%d", time.Now().UnixNano()))
+	for position := 0; position < 3; position++ {
+		if err := engine.Add("appl", position, Source{Name: "file1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The batch exceeding the limit is inserted without Flush and before the ticker fires.
+	deadline := time.Now().Add(5 * time.Second)
+	for i.Stored() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stored := i.Stored(); stored != 3 {
+		t.Errorf("%d is not equal to expected %d", stored, 3)
+	}
+}
@@ -0,0 +1,38 @@
+package index
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// now returns the current time, it is replaced in tests.
+var now = time.Now
+
+// WithTimeDecay wraps the range algorithm to multiply the scores by the exponential decay of the document age, so the
+// score of the document halves every halfLife. The documents without modification time are not decayed.
+func WithTimeDecay(rangeAlgorithm RangeAlgorithm, halfLife time.Duration) RangeAlgorithm {
+	return func(items map[*Source]*TmpResultItem, tokens []string) ([]Result, error) {
+		results, err := rangeAlgorithm(items, tokens)
+		if err != nil {
+			return nil, err
+		}
+		current := now()
+		for k := range results {
+			results[k].Score *= decay(results[k].Document.ModTime, current, halfLife)
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+		return results, nil
+	}
+}
+
+// decay returns the multiplier of the score of the document modified at modTime.
+func decay(modTime time.Time, current time.Time, halfLife time.Duration) float64 {
+	age := current.Sub(modTime)
+	if modTime.IsZero() || halfLife <= 0 || age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
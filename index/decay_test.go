@@ -0,0 +1,35 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithTimeDecay(t *testing.T) {
+	current := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	defer func(n func() time.Time) { now = n }(now)
+	now = func() time.Time { return current }
+
+	old := &Source{Name: "old", ModTime: current.Add(-48 * time.Hour)}
+	recent := &Source{Name: "new", ModTime: current.Add(-24 * time.Hour)}
+	unknown := &Source{Name: "unknown"}
+	items := map[*Source]*TmpResultItem{
+		old:     {count: 1, occurrences: map[string][]int{"appl": {0, 1}}},
+		recent:  {count: 1, occurrences: map[string][]int{"appl": {0, 1}}},
+		unknown: {count: 1, occurrences: map[string][]int{"appl": {0}}},
+	}
+
+	actual, err := WithTimeDecay(ScoreByCount, 24*time.Hour)(items, []string{"appl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Result{
+		{Document: recent, Score: 1, Positions: map[string][]int{"appl": {0, 1}}},
+		{Document: unknown, Score: 1, Positions: map[string][]int{"appl": {0}}},
+		{Document: old, Score: 0.5, Positions: map[string][]int{"appl": {0, 1}}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
@@ -0,0 +1,62 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// WithDeduplication skips the documents with the same content as the documents already added to the index.
+// The names of the skipped documents are available with Duplicates function.
+func WithDeduplication() Option {
+	return func(i *Index) {
+		i.dedup = &dedup{
+			hashes:     map[string]string{},
+			duplicates: map[string]string{},
+		}
+	}
+}
+
+// dedup remembers the content hashes of the added documents.
+type dedup struct {
+	m          sync.Mutex
+	hashes     map[string]string
+	duplicates map[string]string
+}
+
+// claim registers the document and returns the name of the document with the same hash added before.
+// Nothing is registered if the deduplication is disabled.
+func (d *dedup) claim(hash string, name string) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	if original, ok := d.hashes[hash]; ok && original != name {
+		d.duplicates[name] = original
+		return original, true
+	}
+	d.hashes[hash] = name
+	return "", false
+}
+
+// Duplicates returns the names of the skipped documents mapped to the names of the documents with the same content.
+// It is empty if the index is created without WithDeduplication option.
+func (i *Index) Duplicates() map[string]string {
+	duplicates := map[string]string{}
+	if i.dedup == nil {
+		return duplicates
+	}
+	i.dedup.m.Lock()
+	defer i.dedup.m.Unlock()
+	for name, original := range i.dedup.duplicates {
+		duplicates[name] = original
+	}
+	return duplicates
+}
+
+// contentHash returns the hex-encoded SHA-256 of the content.
+func contentHash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
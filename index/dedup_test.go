@@ -0,0 +1,59 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestIndex_AddSourceDeduplication(t *testing.T) {
+	engine := NewMemoryIndex()
+	i := NewIndex(engine, nil, WithDeduplication())
+	for _, name := range []string{"file1", "copy", "file1"} {
+		if err := i.AddSource(name, bytes.NewBufferString("apple banana")); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := i.AddSource("file2", bytes.NewBufferString("apple orange")); err != nil {
+		t.Error(err)
+	}
+	i.Close()
+
+	var actual []string
+	for name := range engine.Sources {
+		actual = append(actual, name)
+	}
+	if len(actual) != 2 || engine.Sources["copy"] != nil {
+		t.Errorf("%v is not equal to expected [file1 file2]", actual)
+	}
+	expected := map[string]string{"copy": "file1"}
+	if duplicates := i.Duplicates(); !reflect.DeepEqual(duplicates, expected) {
+		t.Errorf("%v is not equal to expected %v", duplicates, expected)
+	}
+	hash := contentHash([]byte("apple banana"))
+	if engine.Sources["file1"].Hash != hash {
+		t.Errorf("%s is not equal to expected %s", engine.Sources["file1"].Hash, hash)
+	}
+}
+
+func TestIndex_AddSourceWithoutDeduplication(t *testing.T) {
+	engine := NewMemoryIndex()
+	i := NewIndex(engine, nil)
+	for _, name := range []string{"file1", "copy"} {
+		if err := i.AddSource(name, bytes.NewBufferString("apple banana")); err != nil {
+			t.Error(err)
+		}
+	}
+	i.Close()
+
+	if len(engine.Sources) != 2 {
+		t.Errorf("%d is not equal to expected 2", len(engine.Sources))
+	}
+	if duplicates := i.Duplicates(); len(duplicates) != 0 {
+		t.Errorf("%v is not empty", duplicates)
+	}
+	// The content is not hashed without deduplication and incremental updates.
+	if hash := engine.Sources["file1"].Hash; hash != "" {
+		t.Errorf("%s is not empty", hash)
+	}
+}
@@ -0,0 +1,61 @@
+package index
+
+// Remover is the interface implemented by the engines which can remove a single document. The read-only engines, e.g.
+// the federation of the index files, do not implement it.
+type Remover interface {
+	// Remove removes the document named as the source and all its positions. The tokens shared with other documents
+	// are kept. ErrUnknownDocument is returned if the document is not indexed.
+	Remove(source Source) error
+}
+
+// RemoveSource removes the document by its name, e.g. to reindex the changed file without rebuilding the whole index.
+// The engine must implement Remover interface, otherwise ErrNotSupported is returned.
+func (i *Index) RemoveSource(name string) error {
+	return i.RemoveSourceTenant("", name)
+}
+
+// RemoveSourceTenant removes the tenant's document by its name.
+// Empty tenant looks for the document in the whole engine.
+func (i *Index) RemoveSourceTenant(tenant string, name string) error {
+	engine, err := i.scoped(tenant)
+	if err != nil {
+		return err
+	}
+	remover, ok := engine.(Remover)
+	if !ok {
+		return ErrNotSupported
+	}
+	defer i.mutate()
+	if err := remover.Remove(Source{Name: name}); err != nil {
+		return err
+	}
+	i.names.forget(name)
+	return nil
+}
+
+// DeleteByPrefix removes all documents with the name starting with the prefix, e.g. to reindex the whole directory.
+// It returns the number of removed documents. The engine must implement PrefixDeleter interface, otherwise
+// ErrNotSupported is returned.
+func (i *Index) DeleteByPrefix(prefix string) (int, error) {
+	return i.DeleteByPrefixTenant("", prefix)
+}
+
+// DeleteByPrefixTenant removes the tenant's documents with the name starting with the prefix.
+// Empty tenant removes the documents from the whole engine.
+func (i *Index) DeleteByPrefixTenant(tenant string, prefix string) (int, error) {
+	engine, err := i.scoped(tenant)
+	if err != nil {
+		return 0, err
+	}
+	deleter, ok := engine.(PrefixDeleter)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	defer i.mutate()
+	deleted, err := deleter.DeleteByPrefix(prefix)
+	if err != nil {
+		return deleted, err
+	}
+	i.names.forgetPrefix(prefix)
+	return deleted, nil
+}
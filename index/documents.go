@@ -0,0 +1,134 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OrderByID orders the listed documents in the order they are stored, the engine must identify the documents.
+const OrderByID = "id"
+
+// ListOptions selects the page of the listed documents.
+type ListOptions struct {
+	// Limit is the maximal number of the listed documents, 0 means no limit.
+	Limit int
+	// Offset is the number of the documents skipped from the beginning of the list.
+	Offset int
+	// Order is the order of the documents: OrderByName (default) or OrderByID.
+	Order string
+}
+
+// DocumentList is the page of the indexed documents.
+type DocumentList struct {
+	Documents []Source
+	// Total is the number of all documents regardless of the page, e.g. for the pagination UI.
+	Total int
+}
+
+// DocumentLister is the interface implemented by the engines which can list the indexed documents.
+type DocumentLister interface {
+	// ListDocuments returns the page of the documents. ErrNotSupported is returned if the order is not supported by
+	// the engine.
+	ListDocuments(options ListOptions) (DocumentList, error)
+}
+
+// ListDocuments returns the page of the indexed documents and the number of all of them. The engine must implement
+// DocumentLister interface, otherwise ErrNotSupported is returned.
+func (i *Index) ListDocuments(options ListOptions) (DocumentList, error) {
+	return i.ListDocumentsTenant("", options)
+}
+
+// ListDocumentsTenant returns the page of the tenant's documents. Empty tenant lists the documents of the whole
+// engine.
+func (i *Index) ListDocumentsTenant(tenant string, options ListOptions) (DocumentList, error) {
+	engine, err := i.scoped(tenant)
+	if err != nil {
+		return DocumentList{}, err
+	}
+	lister, ok := engine.(DocumentLister)
+	if !ok {
+		return DocumentList{}, ErrNotSupported
+	}
+	switch options.Order {
+	case "":
+		options.Order = OrderByName
+	case OrderByName, OrderByID:
+	default:
+		return DocumentList{}, fmt.Errorf("%w: %s", ErrUnknownOrder, options.Order)
+	}
+	return lister.ListDocuments(options)
+}
+
+// ListDocuments returns the page of the documents ordered by name in thread-safe way. The documents of MemoryIndex
+// have no identifiers, so OrderByID is not supported.
+func (i *MemoryIndex) ListDocuments(options ListOptions) (DocumentList, error) {
+	if options.Order != OrderByName {
+		return DocumentList{}, fmt.Errorf("%w: order by %s", ErrNotSupported, options.Order)
+	}
+	i.m.RLock()
+	names := make([]string, 0, len(i.Sources))
+	for name := range i.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names = page(names, options)
+	list := DocumentList{Documents: make([]Source, 0, len(names)), Total: len(i.Sources)}
+	for _, name := range names {
+		list.Documents = append(list.Documents, *i.Sources[name])
+	}
+	i.m.RUnlock()
+	return list, nil
+}
+
+// page returns the names within the limit and the offset of the options.
+func page(names []string, options ListOptions) []string {
+	if options.Offset >= len(names) {
+		return nil
+	}
+	names = names[options.Offset:]
+	if options.Limit > 0 && options.Limit < len(names) {
+		names = names[:options.Limit]
+	}
+	return names
+}
+
+// ListDocuments returns the page of the documents from the database.
+func (i *DbIndex) ListDocuments(options ListOptions) (DocumentList, error) {
+	return i.listDocuments("", options)
+}
+
+func (i *DbIndex) listDocuments(tenant string, options ListOptions) (DocumentList, error) {
+	var docs []Document
+	query := i.pg.Model(&docs).Where("tenant_id=?", tenant).Offset(options.Offset)
+	switch options.Order {
+	case OrderByName:
+		query = query.Order("name")
+	case OrderByID:
+		query = query.Order("id")
+	default:
+		return DocumentList{}, fmt.Errorf("%w: order by %s", ErrNotSupported, options.Order)
+	}
+	if options.Limit > 0 {
+		query = query.Limit(options.Limit)
+	}
+	total, err := query.SelectAndCount()
+	if err != nil {
+		return DocumentList{}, fmt.Errorf("error listing documents %w", err)
+	}
+	list := DocumentList{Documents: make([]Source, 0, len(docs)), Total: total}
+	for _, doc := range docs {
+		list.Documents = append(list.Documents, Source{
+			Name:     doc.Name,
+			ModTime:  doc.CreatedAt,
+			Hash:     doc.Hash,
+			Language: doc.Language,
+			Excluded: doc.Excluded,
+		})
+	}
+	return list, nil
+}
+
+// ListDocuments returns the page of the tenant's documents from the database.
+func (t *TenantIndex) ListDocuments(options ListOptions) (DocumentList, error) {
+	return t.listDocuments(t.tenant, options)
+}
@@ -0,0 +1,43 @@
+package index
+
+import (
+	"sort"
+)
+
+// WithEarlyBoost wraps the range algorithm to multiply the scores of the documents where the query terms first appear
+// near the beginning. The score is multiplied by 1 + (boost - 1) / (1 + first), where first is the earliest position
+// of the found tokens, so the document starting with the term gets the whole boost and the boost fades with the
+// position. The boost 1 or less and the documents without positions, e.g. in the index created with WithCountsOnly
+// option, keep the scores.
+func WithEarlyBoost(rangeAlgorithm RangeAlgorithm, boost float64) RangeAlgorithm {
+	return func(items map[*Source]*TmpResultItem, tokens []string) ([]Result, error) {
+		results, err := rangeAlgorithm(items, tokens)
+		if err != nil || boost <= 1 {
+			return results, err
+		}
+		for k := range results {
+			results[k].Score *= early(results[k].Positions, boost)
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+		return results, nil
+	}
+}
+
+// early returns the multiplier of the score of the document with the positions of the found tokens.
+func early(positions map[string][]int, boost float64) float64 {
+	first := -1
+	for _, tokenPositions := range positions {
+		// The positions are not sorted by some engines, e.g. DbIndex, so all of them are checked.
+		for _, position := range tokenPositions {
+			if first < 0 || position < first {
+				first = position
+			}
+		}
+	}
+	if first < 0 {
+		return 1
+	}
+	return 1 + (boost-1)/float64(1+first)
+}
@@ -0,0 +1,57 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWithEarlyBoost(t *testing.T) {
+	for _, test := range []struct {
+		boost    float64
+		expected []string
+	}{
+		{1, []string{"footer", "intro"}},
+		{2, []string{"intro", "footer"}},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil, WithRangeAlgorithm(WithEarlyBoost(ScoreByCount, test.boost)))
+		for name, text := range map[string]string{
+			"intro":  "zebra apple banana cherry durian",
+			"footer": "apple banana cherry durian zebra",
+		} {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+
+		results, err := i.Search("zebra")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: %v is not equal to expected %v", test.boost, actual, test.expected)
+		}
+	}
+}
+
+func TestEarly(t *testing.T) {
+	for _, test := range []struct {
+		positions map[string][]int
+		expected  float64
+	}{
+		{nil, 1},
+		{map[string][]int{"appl": {0, 5}}, 3},
+		{map[string][]int{"appl": {3, 5}, "banana": {1}}, 2},
+		{map[string][]int{"appl": {5, 0}}, 3},
+		{map[string][]int{"appl": {}}, 1},
+	} {
+		if actual := early(test.positions, 3); actual != test.expected {
+			t.Errorf("%v: %v is not equal to expected %v", test.positions, actual, test.expected)
+		}
+	}
+}
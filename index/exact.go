@@ -0,0 +1,118 @@
+package index
+
+import (
+	"strings"
+)
+
+// exactPrefix marks the token of the original form of the word, e.g. `=apples` for `Apples` stemmed to `appl`. Tokens
+// contain letters only, so the prefix can not be the part of the stemmed token.
+const exactPrefix = "="
+
+// WithExactBoost multiplies the score of the documents containing the original form of the query term, e.g. the
+// document with `apples` ranks above the document with `apple` only for the query `apples`, while both of them are
+// found by the stem `appl`. The forms are compared in lower case. The factor is applied once for every query term
+// found in its original form, the factor 1 or less disables the boost.
+// The original forms are indexed as the additional tokens, so the same option must be used to build and to search
+// over the index, and the index statistics count these tokens too. The original forms are not matched with
+// WithCountsOnly option.
+func WithExactBoost(factor float64) Option {
+	return func(i *Index) {
+		i.exactBoost = factor
+	}
+}
+
+// exactToken returns the token of the original form of the word.
+func exactToken(word string) string {
+	return exactPrefix + strings.ToLower(word)
+}
+
+// isExactToken checks if the stored token is the original form of the word, e.g. to skip it in the suggestions.
+func isExactToken(token string) bool {
+	return strings.Contains(token, exactPrefix)
+}
+
+// parseExact returns the tokens of the original forms of the query terms by the query tokens. The terms are parsed the
+// same way as by parseQuery.
+func (i *Index) parseExact(query string, analyzer Analyzer) (map[string][]string, error) {
+	if i.exactBoost <= 1 {
+		return nil, nil
+	}
+	exact := map[string][]string{}
+	for _, term := range strings.Fields(query) {
+		term, _ = splitBoost(term)
+		field, value, err := i.queryField(term)
+		if err != nil {
+			return nil, err
+		}
+		// The prefix terms match the stems only.
+		if _, ok := parsePrefix(value, analyzer); ok {
+			continue
+		}
+		for _, word := range analyzer.words(value) {
+			// The whole word is matched, the split identifier parts have no original form of their own.
+			tokens := analyzer.tokens(word)
+			if len(tokens) == 0 {
+				continue
+			}
+			token := fieldToken(field, tokens[0])
+			exact[token] = append(exact[token], fieldToken(field, exactToken(word)))
+		}
+	}
+	return exact, nil
+}
+
+// matchExact sets the exact boost of the items containing the original forms of the query tokens.
+func (i *Index) matchExact(engine IndexEngine, items map[*Source]*TmpResultItem, exact map[string][]string) error {
+	if i.exactBoost <= 1 || len(exact) == 0 || len(items) == 0 {
+		return nil
+	}
+	if _, ok := engine.(Counter); ok && i.countsOnly {
+		return nil
+	}
+	var forms []string
+	for _, tokens := range exact {
+		forms = append(forms, tokens...)
+	}
+	stored, queried := i.storedTokens(forms)
+	occurrencesList, err := engine.Get(stored)
+	if err != nil {
+		return err
+	}
+	// The sources of the separate queries may differ, so the documents are matched by name.
+	found := map[string]map[string]bool{}
+	for storedToken, occurrences := range occurrencesList {
+		for source, positions := range occurrences {
+			if len(positions) == 0 {
+				continue
+			}
+			for _, form := range queried[storedToken] {
+				if found[form] == nil {
+					found[form] = map[string]bool{}
+				}
+				found[form][source.Name] = true
+			}
+		}
+	}
+	for source, item := range items {
+		for _, tokens := range exact {
+			for _, form := range tokens {
+				if found[form][source.Name] {
+					if item.exactBoost == 0 {
+						item.exactBoost = 1
+					}
+					item.exactBoost *= i.exactBoost
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// boostExact multiplies the score by the boost of the query terms found in their original forms.
+func (item *TmpResultItem) boostExact(score float64) float64 {
+	if item.exactBoost == 0 {
+		return score
+	}
+	return score * item.exactBoost
+}
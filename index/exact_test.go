@@ -0,0 +1,61 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestIndex_SearchExactBoost(t *testing.T) {
+	for _, test := range []struct {
+		options  []Option
+		query    string
+		expected []string
+	}{
+		{nil, "apples", []string{"stemmed", "exact"}},
+		{[]Option{WithExactBoost(3)}, "apples", []string{"exact", "stemmed"}},
+		{[]Option{WithExactBoost(3)}, "Apples^1", []string{"exact", "stemmed"}},
+		{[]Option{WithExactBoost(3)}, "apple", []string{"stemmed", "exact"}},
+		{[]Option{WithExactBoost(3), WithDefaultFields("title", BodyField)}, "apples", []string{"exact", "stemmed"}},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil, test.options...)
+		for name, text := range map[string]string{
+			"exact":   "fresh apples",
+			"stemmed": "apple pie, apple juice",
+		} {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+
+		results, err := i.Search(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.query, actual, test.expected)
+		}
+	}
+}
+
+func TestIndex_SuggestExactBoost(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithExactBoost(3))
+	if err := i.AddSource("file1", bytes.NewBufferString("apples")); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+
+	suggestions, err := i.Suggest("applex", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"appl"}
+	if !reflect.DeepEqual(suggestions, expected) {
+		t.Errorf("%v is not equal to expected %v", suggestions, expected)
+	}
+}
@@ -0,0 +1,38 @@
+package index
+
+import (
+	"errors"
+)
+
+// ErrUnknownDocument is returned when the document is not found in the index.
+var ErrUnknownDocument = errors.New("unknown document")
+
+// Excluder is the interface implemented by the engines which can exclude the documents from the search without
+// deleting them.
+type Excluder interface {
+	// SetExcluded excludes the document from the search or includes it back. ErrUnknownDocument is returned if the
+	// document is not indexed.
+	SetExcluded(name string, excluded bool) error
+}
+
+// SetExcluded excludes the document from the search results or includes it back, e.g. to suppress boilerplate
+// documents reversibly. The excluded document is kept in the index. The engine must implement Excluder interface,
+// otherwise ErrNotSupported is returned.
+func (i *Index) SetExcluded(name string, excluded bool) error {
+	return i.SetExcludedTenant("", name, excluded)
+}
+
+// SetExcludedTenant excludes the tenant's document from the search results or includes it back.
+// Empty tenant looks for the document in the whole engine.
+func (i *Index) SetExcludedTenant(tenant string, name string, excluded bool) error {
+	engine, err := i.scoped(tenant)
+	if err != nil {
+		return err
+	}
+	excluder, ok := engine.(Excluder)
+	if !ok {
+		return ErrNotSupported
+	}
+	defer i.mutate()
+	return excluder.SetExcluded(name, excluded)
+}
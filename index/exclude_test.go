@@ -0,0 +1,51 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIndex_SetExcluded(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil)
+	for name, text := range map[string]string{
+		"file1":    "apple banana",
+		"template": "apple apple apple",
+	} {
+		if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	for _, test := range []struct {
+		excluded bool
+		expected []string
+	}{
+		{true, []string{"file1"}},
+		{false, []string{"template", "file1"}},
+	} {
+		if err := i.SetExcluded("template", test.excluded); err != nil {
+			t.Fatal(err)
+		}
+		results, err := i.Search("apple")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: %v is not equal to expected %v", test.excluded, actual, test.expected)
+		}
+	}
+
+	if err := i.SetExcluded("file2", true); !errors.Is(err, ErrUnknownDocument) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownDocument)
+	}
+	if err := NewIndex(&countEngine{}, nil).SetExcluded("file1", true); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+}
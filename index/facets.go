@@ -0,0 +1,25 @@
+package index
+
+// Facets is the number of the matching documents containing every query token. The keys are the stemmed tokens in
+// the form they are matched, e.g. `appl` for `apples` query.
+type Facets map[string]int
+
+// count adds the documents matching the query tokens to the facets. Every query token is counted even if no matching
+// document contains it.
+func (f Facets) count(items map[*Source]*TmpResultItem, tokens []string) {
+	for _, token := range tokens {
+		if _, ok := f[token]; !ok {
+			f[token] = 0
+		}
+	}
+	for _, item := range items {
+		if !item.matches(tokens) {
+			continue
+		}
+		for _, token := range tokens {
+			if item.frequency(token) > 0 {
+				f[token]++
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestIndex_SearchWithFacets(t *testing.T) {
+	for _, test := range []struct {
+		options  []Option
+		query    string
+		results  int
+		expected Facets
+	}{
+		{[]Option{WithDefaultOperator(OperatorOr)}, "apple banana", 2, Facets{"appl": 2, "banana": 1}},
+		{[]Option{WithDefaultOperator(OperatorOr), WithLimit(1)}, "apple banana", 1, Facets{"appl": 2, "banana": 1}},
+		{nil, "apple banana", 1, Facets{"appl": 1, "banana": 1}},
+		{nil, "apple durian", 0, Facets{"appl": 0, "durian": 0}},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil, test.options...)
+		for name, text := range map[string]string{
+			"file1": "apple banana",
+			"file2": "apple",
+			"file3": "cherry",
+		} {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+
+		results, facets, err := i.SearchWithFacets(test.query, SearchOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != test.results {
+			t.Errorf("%s: %d is not equal to expected %d", test.query, len(results), test.results)
+		}
+		if !reflect.DeepEqual(facets, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.query, facets, test.expected)
+		}
+	}
+}
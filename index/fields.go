@@ -0,0 +1,180 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BodyField is the field of the text added with AddSource and AddDocument functions.
+// Its tokens are stored without the field prefix, so they are matched by the query terms without the field.
+const BodyField = "body"
+
+// fieldSeparator separates the field and the token, e.g. `title:appl`. Tokens contain letters only, so the separator
+// can not be the part of the token.
+const fieldSeparator = ":"
+
+// ErrUnknownField is returned when the query term is scoped by the field unknown to the index with fields.
+var ErrUnknownField = errors.New("unknown field")
+
+// WithFields sets the fields of the documents which can be searched with `field:value` query terms in addition to
+// BodyField.
+func WithFields(fields ...string) Option {
+	return func(i *Index) {
+		if i.fields == nil {
+			i.fields = map[string]bool{}
+		}
+		for _, field := range fields {
+			i.fields[strings.ToLower(field)] = true
+		}
+	}
+}
+
+// WithDefaultFields sets the fields matched by the query terms without the field, only BodyField by default. E.g. with
+// `WithDefaultFields("title", "body")` the term `apple` matches the title and the body but not the notes field, while
+// the term `notes:apple` still matches the notes. The fields are registered as with WithFields option. The occurrences
+// of the term in all default fields are counted together, so the boost of the term, e.g. `apple^2`, applies to every
+// default field equally.
+func WithDefaultFields(fields ...string) Option {
+	return func(i *Index) {
+		WithFields(fields...)(i)
+		i.defaultFields = nil
+		for _, field := range fields {
+			i.defaultFields = append(i.defaultFields, strings.ToLower(field))
+		}
+	}
+}
+
+// WithUnknownFieldsIgnored makes the query terms scoped by the unknown fields match any field instead of failing the
+// search with ErrUnknownField.
+func WithUnknownFieldsIgnored() Option {
+	return func(i *Index) {
+		i.ignoreUnknownFields = true
+	}
+}
+
+// AddFields scan new document consisting of several fields, e.g. title and body, and add extracted tokens to the index
+// in thread-safe way. Positions are counted in every field separately.
+func (i *Index) AddFields(source Source, fields map[string]string) error {
+	if i.isClosed() {
+		return ErrEngineClosed
+	}
+	indexed, err := i.indexFields(source, fields)
+	i.count(indexed, err)
+	return err
+}
+
+// indexFields passes the tokens of the fields to the engine. It returns false if the document is skipped or fails, the
+// tokens are in the engine when it returns.
+func (i *Index) indexFields(source Source, fields map[string]string) (indexed bool, err error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var content strings.Builder
+	for _, name := range names {
+		content.WriteString(name)
+		content.WriteByte(0)
+		content.WriteString(fields[name])
+		content.WriteByte(0)
+	}
+	positions, err := i.names.claim(source.Name)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if !indexed {
+			positions = nil
+		}
+		i.names.release(source.Name, positions)
+	}()
+	if ok, err := i.register(&source, []byte(content.String()), positions); !ok {
+		return false, err
+	}
+	defer i.commit(i.send)
+	for _, name := range names {
+		field := strings.ToLower(name)
+		if positions[field], err = i.addTokensAt(source, field, []byte(fields[name]), positions[field]); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// storedTokens returns the tokens to fetch from the engine for the query tokens and the query tokens matched by every
+// stored token. The query token without the field is expanded to the tokens of the default fields, so the stored
+// token may be matched by both the scoped and the unscoped query tokens, e.g. `title:apple apple`.
+func (i *Index) storedTokens(tokens []string) ([]string, map[string][]string) {
+	queried := make(map[string][]string, len(tokens))
+	var stored []string
+	add := func(storedToken string, token string) {
+		if _, ok := queried[storedToken]; !ok {
+			stored = append(stored, storedToken)
+		}
+		queried[storedToken] = append(queried[storedToken], token)
+	}
+	for _, token := range tokens {
+		if i.defaultFields == nil || strings.Contains(token, fieldSeparator) {
+			add(token, token)
+			continue
+		}
+		for _, field := range i.defaultFields {
+			add(fieldToken(field, token), token)
+		}
+	}
+	return stored, queried
+}
+
+// fieldToken returns the token stored for the field.
+func fieldToken(field string, token string) string {
+	if field == "" || field == BodyField {
+		return token
+	}
+	return field + fieldSeparator + token
+}
+
+// tokenField returns the field of the stored token, BodyField for the token without the field.
+func tokenField(token string) string {
+	idx := strings.Index(token, fieldSeparator)
+	if idx < 0 {
+		return BodyField
+	}
+	return token[:idx]
+}
+
+// splitField returns the field and the value of the query term, e.g. `title:apple`. The field is empty if the term is
+// not scoped.
+func splitField(term string) (string, string) {
+	idx := strings.Index(term, fieldSeparator)
+	if idx <= 0 {
+		return "", term
+	}
+	field := term[:idx]
+	for _, r := range field {
+		if !unicode.IsLetter(r) {
+			return "", term
+		}
+	}
+	return strings.ToLower(field), term[idx+len(fieldSeparator):]
+}
+
+// queryField returns the validated field and the value of the query term. The term scoped by the unknown field is the
+// plain text if the index has no fields but BodyField, e.g. `note: apple` or `http://example`. Otherwise the unknown
+// field is dropped if it is ignored by the option or fails the search with ErrUnknownField.
+func (i *Index) queryField(term string) (string, string, error) {
+	field, value := splitField(term)
+	if field == "" || field == BodyField || i.fields[field] {
+		return field, value, nil
+	}
+	if i.fields == nil {
+		return "", term, nil
+	}
+	if i.ignoreUnknownFields {
+		return "", value, nil
+	}
+	return "", "", fmt.Errorf("%w %s", ErrUnknownField, field)
+}
@@ -0,0 +1,148 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newFieldsTestIndex(t *testing.T, options ...Option) *Index {
+	engine := NewMemoryIndex()
+	i := NewIndex(engine, nil, append([]Option{WithFields("title")}, options...)...)
+	if err := i.AddFields(Source{Name: "titled"}, map[string]string{
+		"title": "Apple",
+		"body":  "banana orange",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.AddFields(Source{Name: "mentioned"}, map[string]string{
+		"title": "Fruits",
+		"body":  "apple banana",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+	return i
+}
+
+func TestIndex_SearchFields(t *testing.T) {
+	i := newFieldsTestIndex(t)
+
+	for query, expected := range map[string][]string{
+		"title:apple":              {"titled"},
+		"apple":                    {"mentioned"},
+		"body:apple":               {"mentioned"},
+		"title:apple body:banana":  {"titled"},
+		"title:fruits apple":       {"mentioned"},
+		"title:orange":             nil,
+		"Title:Apple^2 body:apple": nil,
+	} {
+		results, err := i.Search(query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+		if actual := names(results); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", query, actual, expected)
+		}
+	}
+}
+
+func TestIndex_SearchUnknownField(t *testing.T) {
+	i := newFieldsTestIndex(t)
+	if _, err := i.Search("author:apple"); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownField)
+	}
+
+	i = newFieldsTestIndex(t, WithUnknownFieldsIgnored())
+	results, err := i.Search("author:apple")
+	if err != nil {
+		t.Error(err)
+	}
+	if actual, expected := names(results), []string{"mentioned"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestIndex_SearchColonWithoutFields(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil)
+	for name, text := range map[string]string{"note": "note apple", "link": "http example"} {
+		if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	// The terms looking like the fields are the plain text if the index has no fields.
+	for query, expected := range map[string][]string{
+		"note: apple":    {"note"},
+		"note:apple":     {"note"},
+		"http://example": {"link"},
+		"body:apple":     {"note"},
+	} {
+		results, err := i.Search(query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+		if actual := names(results); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", query, actual, expected)
+		}
+	}
+}
+
+func TestSplitField(t *testing.T) {
+	for term, expected := range map[string][2]string{
+		"title:apple": {"title", "apple"},
+		"Title:apple": {"title", "apple"},
+		"apple":       {"", "apple"},
+		":apple":      {"", ":apple"},
+		"12:30":       {"", "12:30"},
+		"title:":      {"title", ""},
+		"a:b:c":       {"a", "b:c"},
+	} {
+		field, value := splitField(term)
+		if actual := [2]string{field, value}; actual != expected {
+			t.Errorf("%s: %v is not equal to expected %v", term, actual, expected)
+		}
+	}
+}
+
+func TestIndex_SearchDefaultFields(t *testing.T) {
+	engine := NewMemoryIndex()
+	i := NewIndex(engine, nil, WithFields("notes"), WithDefaultFields("title", "body"))
+	for name, fields := range map[string]map[string]string{
+		"titled": {"title": "Apple", "body": "banana apple", "notes": "orange"},
+		"noted":  {"title": "Fruits", "body": "banana", "notes": "apple apple apple"},
+	} {
+		if err := i.AddFields(Source{Name: name}, fields); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	for query, expected := range map[string][]string{
+		"apple":              {"titled"},
+		"notes:apple":        {"noted"},
+		"banana":             {"noted", "titled"},
+		"orange":             nil,
+		"title:apple apple":  {"titled"},
+		"apple notes:orange": {"titled"},
+	} {
+		results, err := i.Search(query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+		if actual := names(results); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", query, actual, expected)
+		}
+	}
+
+	// The occurrences in the title and the body are counted together.
+	results, err := i.Search("apple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Score != 2 {
+		t.Errorf("%v is not equal to expected score 2", results)
+	}
+}
@@ -0,0 +1,85 @@
+package index
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Markers is the pair of strings wrapping highlighted words, e.g. `<b>` and `</b>` or `**` and `**`.
+// Markers are inserted as is, so they must be safe for the client's markup.
+type Markers struct {
+	Pre  string
+	Post string
+}
+
+// DefaultMarkers wrap highlighted words with HTML mark tag.
+var DefaultMarkers = Markers{Pre: "<mark>", Post: "</mark>"}
+
+// markers returns the markers of the snippets set in the options or DefaultMarkers.
+func (o SearchOptions) markers() Markers {
+	if o.Markers == (Markers{}) {
+		return DefaultMarkers
+	}
+	return o.Markers
+}
+
+// Highlight wraps the words of the text matching the query with the markers.
+// Words are split and matched the same way as documents are indexed, see Analyze function. The rest of the text is
+// HTML-escaped to prevent injection. The text is treated as BodyField, so the terms scoped by other fields are not
+// highlighted.
+func (i *Index) Highlight(text string, query string, markers Markers) string {
+	analyzer := i.defaultAnalyzer()
+	tokens, _, _ := i.parseQuery(query, analyzer)
+	matched := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		matched[token] = true
+	}
+
+	b := &strings.Builder{}
+	for len(text) > 0 {
+		start, end := analyzer.nextWord(text)
+		if start < 0 {
+			b.WriteString(html.EscapeString(text))
+			break
+		}
+		word := text[start:end]
+
+		b.WriteString(html.EscapeString(text[:start]))
+		if matchesAny(analyzer.Analyze(word), matched) {
+			b.WriteString(markers.Pre)
+			b.WriteString(html.EscapeString(word))
+			b.WriteString(markers.Post)
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+		text = text[end:]
+	}
+	return b.String()
+}
+
+// nextWord returns the bounds of the first word of the text split the same way as the documents are indexed, or -1 if
+// the text has no words.
+func (a Analyzer) nextWord(text string) (int, int) {
+	start := strings.IndexFunc(text, unicode.IsLetter)
+	if start < 0 {
+		return -1, -1
+	}
+	end := strings.IndexFunc(text[start:], func(r rune) bool { return !a.isWordRune(r) })
+	if end < 0 {
+		end = len(text)
+	} else {
+		end += start
+	}
+	word := strings.TrimRight(text[start:end], identifierSeparators)
+	return start, start + len(word)
+}
+
+func matchesAny(tokens []string, matched map[string]bool) bool {
+	for _, token := range tokens {
+		if _, ok := matchedToken(token, matched); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package index
+
+import (
+	"testing"
+)
+
+func TestIndex_Highlight(t *testing.T) {
+	i := &Index{}
+	for _, c := range []struct {
+		text     string
+		query    string
+		markers  Markers
+		expected string
+	}{
+		{
+			text:     "Apples and a banana.",
+			query:    "apple",
+			markers:  DefaultMarkers,
+			expected: "<mark>Apples</mark> and a banana.",
+		},
+		{
+			text:     "  apples,  (banana)!",
+			query:    "banana apple",
+			markers:  Markers{Pre: "**", Post: "**"},
+			expected: "  **apples**,  (**banana**)!",
+		},
+		{
+			text:     `<script>alert("apple")</script> & apple`,
+			query:    "apple",
+			markers:  Markers{Pre: "<b>", Post: "</b>"},
+			expected: "&lt;script&gt;alert(&#34;<b>apple</b>&#34;)&lt;/script&gt; &amp; <b>apple</b>",
+		},
+		{
+			text:     "<apple>",
+			query:    "apple",
+			markers:  DefaultMarkers,
+			expected: "&lt;<mark>apple</mark>&gt;",
+		},
+		{
+			text:     "Übung macht",
+			query:    "übung",
+			markers:  DefaultMarkers,
+			expected: "<mark>Übung</mark> macht",
+		},
+		{
+			text:     "Apples, applications and bananas.",
+			query:    "appl*",
+			markers:  DefaultMarkers,
+			expected: "<mark>Apples</mark>, <mark>applications</mark> and bananas.",
+		},
+	} {
+		if actual := i.Highlight(c.text, c.query, c.markers); actual != c.expected {
+			t.Errorf("%s is not equal to expected %s", actual, c.expected)
+		}
+	}
+}
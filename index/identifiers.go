@@ -0,0 +1,21 @@
+package index
+
+import (
+	"strings"
+)
+
+// identifierSeparators join the parts of the identifiers and the file names, e.g. `user_name` or `config.json`.
+const identifierSeparators = "_./"
+
+// WithIdentifierSplitting indexes the whole words joined by underscores, dots and slashes in addition to their parts,
+// e.g. `config.json` is found by `config`, by `json` and the query `config.json` matches the exact identifier only.
+// The query terms are split the same way, so the same option must be used to build and to search over the index.
+func WithIdentifierSplitting() Option {
+	return func(i *Index) {
+		i.splitIdentifiers = true
+	}
+}
+
+func isIdentifierSeparator(r rune) bool {
+	return strings.ContainsRune(identifierSeparators, r)
+}
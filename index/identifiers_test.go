@@ -0,0 +1,59 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestIndex_SearchIdentifiers(t *testing.T) {
+	for _, test := range []struct {
+		options  []Option
+		query    string
+		expected []string
+	}{
+		{[]Option{WithIdentifierSplitting()}, "config", []string{"file1"}},
+		{[]Option{WithIdentifierSplitting()}, "json", []string{"file1"}},
+		{[]Option{WithIdentifierSplitting()}, "config.json", []string{"file1"}},
+		{[]Option{WithIdentifierSplitting()}, "user", []string{"file2"}},
+		{[]Option{WithIdentifierSplitting()}, "user_name", []string{"file2"}},
+		{[]Option{WithIdentifierSplitting()}, "path/to", []string{}},
+		// The whole identifier is not indexed without the option, the words of the query are matched separately.
+		{nil, "config", []string{"file1"}},
+		{nil, "config.json", []string{"file1"}},
+		{nil, "json config", []string{"file1"}},
+		{[]Option{WithIdentifierSplitting()}, "json config", []string{"file1"}},
+		{[]Option{WithIdentifierSplitting()}, "json.config", []string{}},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil, test.options...)
+		if err := i.AddSource("file1", bytes.NewBufferString("edit config.json first")); err != nil {
+			t.Fatal(err)
+		}
+		if err := i.AddSource("file2", bytes.NewBufferString("set the user_name")); err != nil {
+			t.Fatal(err)
+		}
+		i.Close()
+
+		results, err := i.Search(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := []string{}
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.query, actual, test.expected)
+		}
+	}
+}
+
+func TestAnalyzer_AnalyzeIdentifiers(t *testing.T) {
+	a := Analyzer{SplitIdentifiers: true}
+	expected := []string{"config.json", "config", "json"}
+	for _, term := range []string{"config.json", "(config.json),", "_config.json_"} {
+		if actual := a.Analyze(term); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", term, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Reindexer is the interface implemented by the engines which keep the content hashes of the documents, so the
+// unchanged documents are not indexed again.
+type Reindexer interface {
+	// NeedsReindex checks if the document is not indexed yet or is indexed with the content of the other hash.
+	NeedsReindex(name string, hash string) (bool, error)
+}
+
+// WithIncrementalUpdates skips the documents added with the same content as the indexed documents with the same
+// names and removes the old occurrences of the changed documents before adding them again, e.g. to rebuild the index
+// of the directory reading only the changed files. The engine must implement Reindexer and Remover interfaces,
+// otherwise all documents are added as usual.
+func WithIncrementalUpdates() Option {
+	return func(i *Index) {
+		i.incremental = true
+	}
+}
+
+// reindex checks if the document is changed since it is indexed and removes its old occurrences. It returns false if
+// the document is indexed with the same content. All documents are changed if the option is not set.
+func (i *Index) reindex(source Source) (bool, error) {
+	if !i.incremental {
+		return true, nil
+	}
+	engine := i.getEngine()
+	reindexer, ok := engine.(Reindexer)
+	if !ok {
+		return true, nil
+	}
+	changed, err := reindexer.NeedsReindex(source.Name, source.Hash)
+	if err != nil {
+		return false, fmt.Errorf("can not check %s: %w", source.Name, err)
+	}
+	if !changed {
+		log.Debug().Str("document", source.Name).Msg("skip unchanged document")
+		return false, nil
+	}
+	remover, ok := engine.(Remover)
+	if !ok {
+		return true, nil
+	}
+	if err := remover.Remove(Source{Name: source.Name}); err != nil && !errors.Is(err, ErrUnknownDocument) {
+		return false, fmt.Errorf("can not remove %s: %w", source.Name, err)
+	}
+	return true, nil
+}
+
+// NeedsReindex compares the hash with the hash of the document in thread-safe way.
+func (i *MemoryIndex) NeedsReindex(name string, hash string) (bool, error) {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	source, ok := i.Sources[name]
+	return !ok || source.Hash != hash, nil
+}
+
+// NeedsReindex compares the hash with the hash stored in the document row.
+func (i *DbIndex) NeedsReindex(name string, hash string) (bool, error) {
+	return i.needsReindex("", name, hash)
+}
+
+// NeedsReindex compares the hash with the hash of the tenant's document.
+func (t *TenantIndex) NeedsReindex(name string, hash string) (bool, error) {
+	return t.needsReindex(t.tenant, name, hash)
+}
+
+func (i *DbIndex) needsReindex(tenant string, name string, hash string) (bool, error) {
+	doc := &Document{}
+	err := i.pg.Model(doc).Column("hash").Where("tenant_id=? AND name=?", tenant, name).Select()
+	if err == pg.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error selecting %s %w", name, err)
+	}
+	return doc.Hash != hash, nil
+}
@@ -0,0 +1,146 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// foundNames returns the sorted names of the documents found by the query.
+func foundNames(t *testing.T, i *Index, query string) []string {
+	results, err := i.Search(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, result := range results {
+		names = append(names, result.Document.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestIndex_AddDocumentIncremental(t *testing.T) {
+	engine := NewMemoryIndex()
+	built := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	i := NewIndex(engine, nil, WithIncrementalUpdates())
+	for name, text := range map[string]string{"unchanged": "apple banana", "modified": "apple kiwi"} {
+		if err := i.AddDocument(Source{Name: name, ModTime: built}, bytes.NewBufferString(text)); err != nil {
+			t.Error(err)
+		}
+	}
+	i.Close()
+	unchanged := engine.Sources["unchanged"]
+
+	rebuilt := built.Add(time.Hour)
+	i = NewIndex(engine, nil, WithIncrementalUpdates())
+	for name, text := range map[string]string{"unchanged": "apple banana", "modified": "apple cherry", "new": "cherry"} {
+		if err := i.AddDocument(Source{Name: name, ModTime: rebuilt}, bytes.NewBufferString(text)); err != nil {
+			t.Error(err)
+		}
+	}
+	i.Close()
+
+	// The unchanged document does not advance the generation.
+	if generation := i.Generation(); generation != 2 {
+		t.Errorf("%d is not equal to expected 2", generation)
+	}
+	if engine.Sources["unchanged"] != unchanged || !unchanged.ModTime.Equal(built) {
+		t.Errorf("%+v is not equal to expected %+v", engine.Sources["unchanged"], unchanged)
+	}
+	for name, text := range map[string]string{"modified": "apple cherry", "new": "cherry"} {
+		source := engine.Sources[name]
+		if source == nil || source.Hash != contentHash([]byte(text)) || !source.ModTime.Equal(rebuilt) {
+			t.Errorf("%s: %+v is not reindexed", name, source)
+		}
+	}
+	for query, expected := range map[string][]string{
+		"apple":  {"modified", "unchanged"},
+		"kiwi":   {},
+		"cherry": {"modified", "new"},
+	} {
+		if actual := foundNames(t, i, query); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", query, actual, expected)
+		}
+	}
+}
+
+func TestIndex_AddDocumentIncrementalSerialized(t *testing.T) {
+	engine := NewMemoryIndex()
+	i := NewIndex(engine, nil, WithIncrementalUpdates(), WithNameCollision(CollisionSerialize))
+	for _, text := range []string{"apple", "banana"} {
+		if err := i.AddDocumentSync(Source{Name: "file1"}, bytes.NewBufferString(text)); err != nil {
+			t.Error(err)
+		}
+	}
+	i.Close()
+
+	// The second part continues the document instead of replacing the first one.
+	expected := []string{"file1"}
+	for _, query := range []string{"apple", "banana"} {
+		if actual := foundNames(t, i, query); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", query, actual, expected)
+		}
+	}
+}
+
+func TestMemoryIndex_NeedsReindex(t *testing.T) {
+	engine := NewMemoryIndex()
+	if err := engine.Add("apple", 0, Source{Name: "file1", Hash: "hash1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name     string
+		hash     string
+		expected bool
+	}{
+		{"file1", "hash1", false},
+		{"file1", "hash2", true},
+		{"file2", "hash1", true},
+	} {
+		actual, err := engine.NeedsReindex(test.name, test.hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != test.expected {
+			t.Errorf("%s %s: %v is not equal to expected %v", test.name, test.hash, actual, test.expected)
+		}
+	}
+}
+
+func TestDbIndex_NeedsReindex(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	suffix := time.Now().UnixNano()
+	token := fmt.Sprintf("reindextoken%d", suffix)
+	name := fmt.Sprintf("reindex%d", suffix)
+	tenant := i.Tenant(fmt.Sprintf("reindex%d", suffix))
+	if err := i.Add(token, 0, Source{Name: name, Hash: "hash1"}); err != nil {
+		t.Fatal(err)
+	}
+	waitOccurrences(t, i, token)
+
+	for _, test := range []struct {
+		engine   Reindexer
+		name     string
+		hash     string
+		expected bool
+	}{
+		{i, name, "hash1", false},
+		{i, name, "hash2", true},
+		{i, name + "new", "hash1", true},
+		{tenant.(Reindexer), name, "hash1", true},
+	} {
+		actual, err := test.engine.NeedsReindex(test.name, test.hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != test.expected {
+			t.Errorf("%s %s: %v is not equal to expected %v", test.name, test.hash, actual, test.expected)
+		}
+	}
+}
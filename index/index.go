@@ -17,7 +17,13 @@ and add them to the index. AddSource can be called in thread-safe way, e.g.:
 	input := bytes.NewBuffer([]byte("input document"))
 	err := i.AddSource("document name", input)
 
+Positions of the tokens are counted from 0 in every document and stored per document, so the positions of different
+documents never mix: the spilled runs and the streamed postings are merged document by document. The documents joined
+into one, i.e. the documents added with the same name with CollisionSerialize handling, are separated by the gap of
+positionGap positions, so the quoted phrases and the early occurrences do not match across the join.
+
 To encode in-memory index to file system, network, etc. use Encode function with the object which implements Encoder interface.
+The positions are encoded as the gaps between the consecutive positions to keep the file small.
 
 	encoder := json.NewEncoder(file)
 	err := engine.Encode(encoder)
@@ -28,27 +34,53 @@ To create in-memory index from encoded data use Decode function.
 	engine, err := index.Decode(decoder)
 	i := index.NewIndex(engine, nil)
 
+Engines implementing Iterator interface can be encoded posting by posting with EncodeStream function and decoded into
+in-memory engine with DecodeStream function.
+
+	err := index.EncodeStream(engine, gob.NewEncoder(file))
+
 Do not encode and decode in-memory engine directly with for example json.Marshal because it may lead to data races.
 */
 package index
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
 
-	"github.com/reiver/go-porterstemmer"
 	"github.com/rs/zerolog/log"
-	"github.com/zoomio/stopwords"
 )
 
 // Source contains the name of the file.
 type Source struct {
 	Name string
+	// ModTime is the modification time of the file, zero if it is unknown.
+	ModTime time.Time
+	// Hash is the fingerprint of the content of the file computed while adding it to the index with WithDeduplication
+	// or WithIncrementalUpdates option, empty otherwise.
+	Hash string
+	// Language is the code of the language the document is indexed with, empty for the default analyzer.
+	Language string
+	// Excluded is true if the document is excluded from the search with SetExcluded function.
+	Excluded bool
+	// Metadata are the attributes of the document, e.g. tags, matched by MetadataBoost and ordering the results with
+	// SortByField. They are kept by MemoryIndex only, see MetadataEngine. AddRecords sets them from the record fields.
+	Metadata map[string]string
 }
 
+// positionGap is the number of the positions skipped between the documents joined into one, it exceeds the snippets
+// and the phrases, so no phrase matches across the join.
+const positionGap = 100
+
 // Occurrences contain map of document to positions
 type Occurrences map[*Source][]int
 
@@ -56,6 +88,39 @@ type newToken struct {
 	source   Source
 	token    string
 	position int
+	// applied marks the end of the document instead of the token, it is closed when the preceding tokens are in the
+	// engine, see commit.
+	applied chan struct{}
+}
+
+// Counts contain map of document to the number of occurrences.
+type Counts map[*Source]int
+
+// Counter is the interface implemented by the engines which can count occurrences cheaper than return positions.
+type Counter interface {
+	// Count returns the number of occurrences in every document for the list of tokens.
+	Count(tokens []string) (map[string]Counts, error)
+}
+
+// PrefixDeleter is the interface implemented by the engines which can delete documents by the name prefix.
+type PrefixDeleter interface {
+	// DeleteByPrefix removes all documents with the name starting with the prefix and returns their number.
+	DeleteByPrefix(prefix string) (int, error)
+}
+
+// GenerationEngine is the interface implemented by the engines which identify the indexed content, e.g. by the
+// checksum of the index file. The persisted query cache is loaded for the engine of the same generation only.
+type GenerationEngine interface {
+	Generation() string
+}
+
+// FlushNotifier is the interface implemented by the engines which write the added tokens in batches, e.g. DbIndex.
+// The generation of the index advances when the batch is written instead of when the document is added.
+type FlushNotifier interface {
+	// OnFlush adds the function called after every written batch and returns the function removing it.
+	OnFlush(fn func()) func()
+	// Flush writes the batched tokens at once.
+	Flush() error
 }
 
 // IndexEngine is the interface for the data storage object.
@@ -68,143 +133,810 @@ type IndexEngine interface {
 	Close()
 }
 
+// TenantEngine is the interface implemented by the engines which isolate documents of different tenants.
+type TenantEngine interface {
+	// Tenant returns the storage scoped to the documents of the tenant, nil if the engine is already scoped to another
+	// tenant.
+	Tenant(tenant string) IndexEngine
+}
+
+// ErrNotSupported is returned when the engine does not implement the optional interface needed for the operation.
+var ErrNotSupported = errors.New("operation is not supported by index engine")
+
+// ErrTenantsNotSupported is returned when the tenant-scoped search is requested over the engine without tenants.
+var ErrTenantsNotSupported = errors.New("index engine does not support tenants")
+
 // Index uses engine to store the list of indexed documents, the inverted index and search over the index.
 type Index struct {
+	// documents, skipped and failed count the indexed, the skipped and the failed documents, tokens counts the added
+	// tokens, generation counts the mutations, they are first for 64-bit alignment of the atomic operations.
+	documents  int64
+	skipped    int64
+	failed     int64
+	tokens     int64
+	generation uint64
+	// engineM guards the engine swapped with SwapEngine function, use getEngine to read it.
+	engineM        sync.RWMutex
 	engine         IndexEngine
 	rangeAlgorithm RangeAlgorithm
+	stemmer        Stemmer
+	matchedTokens  bool
+	countsOnly     bool
+	limit          int
+	maxTokenCount  int
+	maxCandidates  int
+	stopwords      Stopwords
+	languages      map[string]Analyzer
+	dedup          *dedup
+	queryCache     *queryCache
+	fields         map[string]bool
+	maxWordSize    int
+	operator       Operator
 	chanIn         chan newToken
+	closed         chan struct{}
+	done           chan struct{}
+	closeOnce      sync.Once
+	// ignoreUnknownFields drops the unknown fields of the query terms instead of failing the search.
+	ignoreUnknownFields bool
+	// topRangeAlgorithm is the top-k variant of the range algorithm used for the limited results.
+	topRangeAlgorithm TopRangeAlgorithm
+	// rescorers wrap the range algorithm to change the scores of its results, see WithRescorer.
+	rescorers []Rescorer
+	// splitIdentifiers adds the parts of the identifiers to the tokens, see WithIdentifierSplitting.
+	splitIdentifiers bool
+	// phraseBoost multiplies the score of the documents containing the quoted phrases, see WithPhraseBoost.
+	phraseBoost float64
+	// defaultFields are the fields matched by the query terms without the field, nil means BodyField only.
+	defaultFields []string
+	// apostrophes is the handling of the apostrophes inside the words, see WithApostrophes.
+	apostrophes Apostrophes
+	// minTokenLength drops the tokens of the short words, see WithMinTokenLength.
+	minTokenLength int
+	// stopwordsOnly skips the built-in English stopwords, see WithStopwordsOnly.
+	stopwordsOnly bool
+	// queryCacheSettings describes the settings of the search for the saved query cache, see WithQueryCacheSettings.
+	queryCacheSettings string
+	// unwatch stops watching the batches written by the engine, nil if the engine does not notify, see watchFlushes.
+	unwatch func()
+	// exactBoost multiplies the score of the documents containing the original forms of the query terms, see
+	// WithExactBoost.
+	exactBoost float64
+	// names tracks the names of the added documents, see WithNameCollision.
+	names *nameRegistry
+	// incremental skips the unchanged documents and replaces the changed ones, see WithIncrementalUpdates.
+	incremental bool
+	// strictPhrases requires the quoted phrases of the query, see WithStrictPhrases.
+	strictPhrases bool
+	// retainContent retains the raw texts of the documents in the engine, see WithRetainedContent.
+	retainContent bool
+	// snippets stores the words of the documents and sets the snippets of the results, see WithSnippets.
+	snippets bool
+	// corpusStats caches the statistics of the index for the range algorithms, e.g. BM25.
+	corpusStats *corpusStats
+}
+
+// Option configures the index created with NewIndex function.
+type Option func(i *Index)
+
+// WithRangeAlgorithm sets the range algorithm, it overrides the one passed to NewIndex.
+func WithRangeAlgorithm(rangeAlgorithm RangeAlgorithm) Option {
+	return func(i *Index) {
+		i.rangeAlgorithm = rangeAlgorithm
+	}
+}
+
+// WithStemmer sets the stemmer used to prepare indexed documents and queries. PorterStemmer is used by default.
+func WithStemmer(stemmer Stemmer) Option {
+	return func(i *Index) {
+		i.stemmer = stemmer
+	}
 }
 
 func (i *Index) listen() {
-	for t := range i.chanIn {
-		if err := i.engine.Add(t.token, t.position, t.source); err != nil {
-			log.Error().Err(err).Msgf("error inserting %s %s %d", t.token, t.source, t.position)
+	defer close(i.done)
+	for {
+		var t newToken
+		select {
+		case <-i.closed:
+			return
+		case token, ok := <-i.chanIn:
+			if !ok {
+				return
+			}
+			t = token
+		}
+		if err := i.insert(t); err != nil {
+			log.Error().Err(err).Msgf("error inserting %s %s %d", t.token, t.source.Name, t.position)
 		}
 	}
 }
 
+// insert adds the token to the engine and counts it. The mark of the end of the document advances the generation
+// of the index instead unless the engine advances it once the batch is written, see FlushNotifier.
+func (i *Index) insert(t newToken) error {
+	if t.applied != nil {
+		if _, ok := i.getEngine().(FlushNotifier); !ok {
+			i.mutate()
+		}
+		close(t.applied)
+		return nil
+	}
+	if err := i.getEngine().Add(t.token, t.position, t.source); err != nil {
+		return err
+	}
+	atomic.AddInt64(&i.tokens, 1)
+	return nil
+}
+
+// send passes the token to the listener adding it to the engine. It returns ErrEngineClosed if the index is closed.
+func (i *Index) send(t newToken) error {
+	select {
+	case i.chanIn <- t:
+		return nil
+	case <-i.closed:
+		return ErrEngineClosed
+	}
+}
+
+// commit passes the mark of the end of the document to add function after the tokens of the document and waits until
+// the generation of the index is advanced, so the searches cached before the tokens are in the engine are stale. The
+// generation is advanced at once if the index is closed meanwhile.
+func (i *Index) commit(add func(t newToken) error) {
+	applied := make(chan struct{})
+	if err := add(newToken{applied: applied}); err != nil {
+		i.mutate()
+		return
+	}
+	<-applied
+}
+
+// getEngine returns the current engine of the index.
+func (i *Index) getEngine() IndexEngine {
+	i.engineM.RLock()
+	defer i.engineM.RUnlock()
+	return i.engine
+}
+
+// SwapEngine replaces the engine of the index and returns the previous one, e.g. to load the rebuilt index without
+// restart. Searches started before the swap finish over the previous engine, so the caller must close it only when
+// they are done. The query cache is cleared and the names of the added documents are forgotten, see WithNameCollision.
+func (i *Index) SwapEngine(engine IndexEngine) IndexEngine {
+	unwatch := i.watchFlushes(engine)
+	i.engineM.Lock()
+	old := i.engine
+	i.engine = engine
+	unwatch, i.unwatch = i.unwatch, unwatch
+	i.engineM.Unlock()
+	if unwatch != nil {
+		unwatch()
+	}
+	i.names.forgetAll()
+	i.mutate()
+	return old
+}
+
+// watchFlushes advances the generation of the index after every batch written by the engine while it is the engine of
+// the index, see FlushNotifier. It returns the function to stop watching, nil if the engine does not notify.
+func (i *Index) watchFlushes(engine IndexEngine) func() {
+	notifier, ok := engine.(FlushNotifier)
+	if !ok {
+		return nil
+	}
+	return notifier.OnFlush(func() {
+		if i.getEngine() == engine {
+			i.mutate()
+		}
+	})
+}
+
+// Generation returns the number of the mutations of the index: the added, removed and excluded documents and the
+// swapped engines. It only increases, so the values cached at the generation are stale once it changes. The query
+// cache is validated against it.
+func (i *Index) Generation() uint64 {
+	return atomic.LoadUint64(&i.generation)
+}
+
+// mutate advances the generation of the index and clears the query cache.
+func (i *Index) mutate() {
+	i.queryCache.reset(atomic.AddUint64(&i.generation, 1))
+}
+
+// ErrEngineClosed is returned when the document is added to the closed index or the closed DbIndex is used.
+var ErrEngineClosed = errors.New("index is closed")
+
+// Close stops adding the documents and waits until the tokens already passed to the index are added to the engine.
+// The engine writing the tokens in batches is flushed, see FlushNotifier, so the batches written later do not advance
+// the generation of the index. AddSource returns ErrEngineClosed after Close, the documents being added concurrently
+// are added partially. The engine is not closed, it is owned by the caller.
+func (i *Index) Close() {
+	i.closeOnce.Do(func() {
+		close(i.closed)
+		<-i.done
+		i.names.forgetAll()
+		i.engineM.Lock()
+		engine, unwatch := i.engine, i.unwatch
+		i.unwatch = nil
+		i.engineM.Unlock()
+		if unwatch == nil {
+			return
+		}
+		if err := engine.(FlushNotifier).Flush(); err != nil && !errors.Is(err, ErrEngineClosed) {
+			log.Error().Err(err).Msg("error flushing engine on close")
+		}
+		unwatch()
+	})
+}
+
+// isClosed checks if Close has been called.
+func (i *Index) isClosed() bool {
+	select {
+	case <-i.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithMatchedTokens makes the search fill the list of matched query tokens of every result.
+func WithMatchedTokens() Option {
+	return func(i *Index) {
+		i.matchedTokens = true
+	}
+}
+
+// WithCountsOnly tells the index that the range algorithm uses only the number of occurrences and does not need their
+// positions, so engines implementing Counter interface are asked for counts. Result positions are not filled then.
+func WithCountsOnly() Option {
+	return func(i *Index) {
+		i.countsOnly = true
+	}
+}
+
+// WithLimit limits the number of search results, 0 means no limit.
+func WithLimit(limit int) Option {
+	return func(i *Index) {
+		i.limit = limit
+	}
+}
+
+// WithMaxTokenCount caps the number of occurrences of every token counted by the range algorithm, so the documents
+// repeating the keyword many times do not dominate the results. 0 means no cap.
+func WithMaxTokenCount(max int) Option {
+	return func(i *Index) {
+		i.maxTokenCount = max
+	}
+}
+
+// ErrTooManyCandidates is returned when the query matches more documents than allowed by WithMaxCandidates option.
+var ErrTooManyCandidates = errors.New("result set too large, refine your query")
+
+// WithMaxCandidates limits the number of the documents matching the query collected before ranking, so a broad query
+// can not exhaust the memory. The search fails with ErrTooManyCandidates when the limit is exceeded, 0 means no limit.
+func WithMaxCandidates(max int) Option {
+	return func(i *Index) {
+		i.maxCandidates = max
+	}
+}
+
 // NewIndex return empty index.
 // Use NewIndex function instead of creating empty instance of index.
-func NewIndex(engine IndexEngine, rangeAlgorithm RangeAlgorithm) *Index {
+func NewIndex(engine IndexEngine, rangeAlgorithm RangeAlgorithm, options ...Option) *Index {
 	i := &Index{
 		engine:         engine,
 		chanIn:         make(chan newToken),
+		closed:         make(chan struct{}),
+		done:           make(chan struct{}),
 		rangeAlgorithm: rangeAlgorithm,
+		corpusStats:    &corpusStats{},
 	}
+	for _, option := range options {
+		option(i)
+	}
+	i.unwatch = i.watchFlushes(engine)
 	go i.listen()
 	return i
 }
 
 // AddSource scan new document and add extracted tokens to the index in thread-safe way.
 func (i *Index) AddSource(name string, text io.Reader) error {
-	source := Source{Name: name}
+	return i.AddDocument(Source{Name: name}, text)
+}
 
-	scanner := bufio.NewScanner(text)
-	scanner.Split(bufio.ScanWords)
-	var position int
-	for scanner.Scan() {
-		token := i.prepare(scanner.Text())
-		if stopwords.IsStopWord(token) {
-			continue
+// AddDocument scan new document with its metadata, e.g. the modification time, and add extracted tokens to the index
+// in thread-safe way. The document is searchable when it returns, or once its batch is written by the engine writing the
+// tokens in batches, see FlushNotifier.
+func (i *Index) AddDocument(source Source, text io.Reader) error {
+	return i.addDocument(source, text, i.send)
+}
+
+// AddSourceSync scans new document like AddSource, but adds the tokens to the engine in the calling goroutine instead
+// of passing them to the listener, e.g. to load the documents one by one without the channel overhead. The first error
+// of the engine is returned.
+func (i *Index) AddSourceSync(name string, text io.Reader) error {
+	return i.AddDocumentSync(Source{Name: name}, text)
+}
+
+// AddDocumentSync scans new document with its metadata like AddDocument, but adds the tokens to the engine in the
+// calling goroutine, see AddSourceSync.
+func (i *Index) AddDocumentSync(source Source, text io.Reader) error {
+	return i.addDocument(source, text, i.insert)
+}
+
+// addDocument scans the document and passes its tokens to add function.
+func (i *Index) addDocument(source Source, text io.Reader, add func(t newToken) error) error {
+	if i.isClosed() {
+		return ErrEngineClosed
+	}
+	indexed, err := i.indexDocument(source, text, add)
+	i.count(indexed, err)
+	return err
+}
+
+// indexDocument passes the tokens of the document to add function. It returns false if the document is skipped or
+// fails, the tokens are in the engine when it returns.
+func (i *Index) indexDocument(source Source, text io.Reader, add func(t newToken) error) (indexed bool, err error) {
+	data, err := ioutil.ReadAll(text)
+	if err != nil {
+		return false, fmt.Errorf("can not read %s: %w", source.Name, err)
+	}
+	positions, err := i.names.claim(source.Name)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if !indexed {
+			positions = nil
+		}
+		i.names.release(source.Name, positions)
+	}()
+	if ok, err := i.register(&source, data, positions); !ok {
+		return false, err
+	}
+	defer i.commit(add)
+	if err := i.retain(source.Name, data, len(positions) > 0); err != nil {
+		return false, fmt.Errorf("can not retain %s: %w", source.Name, err)
+	}
+	start := positions[BodyField]
+	if positions[BodyField], err = i.addTokensWith(source, BodyField, data, start, add); err != nil {
+		return false, err
+	}
+	if err := i.storeWords(source, data, start); err != nil {
+		return false, fmt.Errorf("can not store words of %s: %w", source.Name, err)
+	}
+	return true, nil
+}
+
+// count counts the added document as indexed, skipped as the duplicate or the unchanged one, or failed.
+func (i *Index) count(indexed bool, err error) {
+	switch {
+	case err != nil:
+		atomic.AddInt64(&i.failed, 1)
+	case indexed:
+		atomic.AddInt64(&i.documents, 1)
+	default:
+		atomic.AddInt64(&i.skipped, 1)
+	}
+}
+
+// register sets the content hash of the document. It returns false if the document is the duplicate of the document
+// added before or is indexed with the same content, see WithDeduplication and WithIncrementalUpdates. The document
+// continuing the document with the same name, i.e. with the positions
+// following the added one, is not checked for the changes. Neither option set, the content is not hashed at all.
+func (i *Index) register(source *Source, content []byte, positions map[string]int) (bool, error) {
+	if i.dedup == nil && !i.incremental {
+		return true, nil
+	}
+	source.Hash = contentHash(content)
+	if original, ok := i.dedup.claim(source.Hash, source.Name); ok {
+		log.Info().Str("document", source.Name).Str("original", original).Msg("skip duplicate document")
+		return false, nil
+	}
+	if len(positions) == 0 {
+		changed, err := i.reindex(*source)
+		if err != nil || !changed {
+			return false, err
 		}
-		i.chanIn <- newToken{
+	}
+	return true, nil
+}
+
+// addTokens passes the tokens of the field text to the engine. It returns ErrEngineClosed if the index is closed.
+func (i *Index) addTokens(source Source, field string, data []byte) error {
+	_, err := i.addTokensAt(source, field, data, 0)
+	return err
+}
+
+// addTokensAt passes the tokens of the field text starting from the position to the engine and returns the position
+// following the last word, e.g. to continue the document with the appended text.
+func (i *Index) addTokensAt(source Source, field string, data []byte, position int) (int, error) {
+	return i.addTokensWith(source, field, data, position, i.send)
+}
+
+// addTokensWith passes the tokens of the field text starting from the position to add function and returns the
+// position following the last word.
+func (i *Index) addTokensWith(source Source, field string, data []byte, position int,
+	add func(t newToken) error) (int, error) {
+	return i.scanTokens(source, data, position, func(token string, word string, position int) error {
+		return add(newToken{
 			source:   source,
-			token:    token,
+			token:    fieldToken(field, token),
 			position: position,
+		})
+	})
+}
+
+// scanTokens calls add for every token of the text with its original word and the position of the word starting from
+// the position and returns the position following the last word. Scanning stops on the first error returned by add.
+func (i *Index) scanTokens(source Source, data []byte, position int,
+	add func(token string, word string, position int) error) (int, error) {
+	analyzer, err := i.analyzer(source.Language)
+	if err != nil {
+		return position, err
+	}
+	maxWordSize := i.maxWordSize
+	if maxWordSize <= 0 {
+		maxWordSize = bufio.MaxScanTokenSize
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxWordSize)
+	scanner.Split(scanWords(maxWordSize, func() {
+		log.Warn().Str("document", source.Name).Int("max_word_size", maxWordSize).Msg("skip too long word")
+	}))
+	for scanner.Scan() {
+		for _, word := range analyzer.words(scanner.Text()) {
+			tokens := analyzer.tokens(word)
+			if len(tokens) == 0 {
+				continue
+			}
+			if i.exactBoost > 1 {
+				tokens = append(tokens, exactToken(word))
+			}
+			// The parts of the split word and its original form share the position of the whole word.
+			for _, token := range tokens {
+				if err := add(token, word, position); err != nil {
+					return position, err
+				}
+			}
+			position++
 		}
-		position++
 	}
-	return nil
+	if err := scanner.Err(); err != nil {
+		log.Error().Err(err).Str("document", source.Name).Msg("error scanning document")
+	}
+	return position, nil
 }
 
-func (i *Index) prepare(rawToken string) string {
-	token := strings.TrimFunc(rawToken, func(r rune) bool {
+// trimWord strips the non-letter characters around the word.
+func trimWord(rawToken string) string {
+	return strings.TrimFunc(rawToken, func(r rune) bool {
 		return !unicode.IsLetter(r)
 	})
-	return porterstemmer.StemString(token)
 }
 
-// Result contains the document description and the score.
+// Result contains the document description, the score and the positions of the matched tokens.
+// MatchedTokens is filled in the query order only if the index is created with WithMatchedTokens option.
 type Result struct {
-	Document *Source
-	Score    int
+	Document      *Source
+	Score         float64
+	Positions     map[string][]int
+	MatchedTokens []string
+	// Snippet is the excerpt of the document around the matched words wrapped in the markers of the search options,
+	// DefaultMarkers by default. It is set if the index is created with WithSnippets option and the engine stores the
+	// words of the document.
+	Snippet string
 }
 
 // TmpResultItem is the container for temporary search results produced by the search function.
 // Use this container to filter and sort results with custom RangeAlgorithm function.
+// The occurrences contain positions of the tokens, the counts contain only the number of occurrences if the index is
+// created with WithCountsOnly option.
 type TmpResultItem struct {
 	count       int
 	occurrences map[string][]int
+	counts      map[string]int
+	boosts      map[string]float64
+	maxCount    int
+	operator    Operator
+	// phraseBoost is the multiplier of the score for the matched phrases, 0 if no phrase is matched.
+	phraseBoost float64
+	// exactBoost is the multiplier of the score for the original forms of the query terms, 0 if none is matched.
+	exactBoost float64
+	// corpus provides the statistics of the whole index, e.g. the number of the documents, nil if unknown.
+	corpus *corpus
+	// fields are the positions of the tokens by the field they are found in, the phrases are matched within a field.
+	// It is nil if the index has no fields but BodyField.
+	fields map[string]map[string][]int
+}
+
+// addOccurrences adds the positions of the token found in the field, empty field if the index has no fields. The
+// positions of the token found in several default fields are merged, they are counted in every field separately.
+func (item *TmpResultItem) addOccurrences(field string, token string, positions []int) {
+	if field != "" {
+		item.addFieldOccurrences(field, token, positions)
+	}
+	current, ok := item.occurrences[token]
+	if !ok {
+		item.count++
+		item.occurrences[token] = positions
+		return
+	}
+	merged := append(append(make([]int, 0, len(current)+len(positions)), current...), positions...)
+	sort.Ints(merged)
+	item.occurrences[token] = merged
+}
+
+// addFieldOccurrences adds the positions of the token to the positions of the field.
+func (item *TmpResultItem) addFieldOccurrences(field string, token string, positions []int) {
+	if item.fields == nil {
+		item.fields = map[string]map[string][]int{}
+	}
+	if item.fields[field] == nil {
+		item.fields[field] = map[string][]int{}
+	}
+	item.fields[field][token] = mergePositions(item.fields[field][token], positions)
+}
+
+// frequency returns the number of occurrences of the token in the document capped by WithMaxTokenCount option.
+func (item *TmpResultItem) frequency(token string) int {
+	count := item.counts[token]
+	if positions, ok := item.occurrences[token]; ok {
+		count = len(positions)
+	}
+	if item.maxCount > 0 && count > item.maxCount {
+		return item.maxCount
+	}
+	return count
+}
+
+// boost returns the multiplier of the token's contribution to the score set in the query, e.g. `apple^2`.
+func (item *TmpResultItem) boost(token string) float64 {
+	if boost, ok := item.boosts[token]; ok {
+		return boost
+	}
+	return defaultBoost
 }
 
 type RangeAlgorithm func(items map[*Source]*TmpResultItem, tokens []string) ([]Result, error)
 
+// RangeAlgorithms lists the available range algorithms by name.
+var RangeAlgorithms = map[string]RangeAlgorithm{
+	"count": ScoreByCount,
+	"bm25":  NewBM25(1.2, 0.75),
+}
+
 // ScoreByCount is the default scoring algorithm which ranges search results by count of found tokens.
+// The documents without some of the tokens are skipped unless the index is created with OperatorOr.
+// The documents with equal scores are ordered by name.
 func ScoreByCount(items map[*Source]*TmpResultItem, tokens []string) ([]Result, error) {
+	results := scoreByCount(items, tokens)
+	sort.Slice(results, func(i, j int) bool {
+		return ranksBefore(&results[i], &results[j])
+	})
+	return results, nil
+}
+
+// scoreByCount returns the unordered results scored by count of found tokens.
+func scoreByCount(items map[*Source]*TmpResultItem, tokens []string) []Result {
 	results := make([]Result, 0, len(items))
 
 	for source, item := range items {
-		if item.count < len(tokens) {
+		if !item.matches(tokens) {
 			continue
 		}
-		score := 0
-		for _, positions := range item.occurrences {
-			score += len(positions)
+		score := 0.0
+		for _, token := range tokens {
+			score += float64(item.frequency(token)) * item.boost(token)
 		}
+		score = item.boostExact(item.boostPhrase(score))
 		results = append(results, Result{
-			Document: source,
-			Score:    score,
+			Document:  source,
+			Score:     score,
+			Positions: item.occurrences,
 		})
 	}
+	return results
+}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
+// Search query over the index. Empty slice is returned if no documents match the query, use Suggest function to get
+// the hints for such query.
+// Query terms can be boosted with `^` suffix, e.g. `apple^2 banana` doubles the contribution of apple to the score.
+// The default range algorithm is `ScoreByCount` which ranges search results by count of found tokens.
+func (i *Index) Search(query string) ([]Result, error) {
+	generation := i.Generation()
+	return i.search(generation, i.getEngine(), query, SearchOptions{})
+}
+
+// SearchTenant searches query over the documents of the tenant only.
+// The engine must implement TenantEngine interface, otherwise ErrTenantsNotSupported is returned. It is returned for
+// the engine scoped to another tenant as well. Empty tenant searches over the whole engine.
+func (i *Index) SearchTenant(tenant string, query string) ([]Result, error) {
+	return i.SearchWithOptions(query, SearchOptions{Tenant: tenant})
+}
+
+// scoped returns the engine restricted to the tenant's documents or the whole engine for empty tenant.
+func (i *Index) scoped(tenant string) (IndexEngine, error) {
+	if tenant == "" {
+		return i.getEngine(), nil
+	}
+	engine, ok := i.getEngine().(TenantEngine)
+	if !ok {
+		return nil, ErrTenantsNotSupported
+	}
+	scoped := engine.Tenant(tenant)
+	if scoped == nil {
+		return nil, ErrTenantsNotSupported
+	}
+	return scoped, nil
+}
 
+// search searches the query over the engine using the query cache. The generation of the index must be taken before
+// the engine, so the results of the engine swapped meanwhile are not cached.
+func (i *Index) search(generation uint64, engine IndexEngine, query string, options SearchOptions) ([]Result, error) {
+	key := newQueryKey(query, options)
+	if results, ok := i.queryCache.get(key); ok {
+		return append([]Result{}, results.([]Result)...), nil
+	}
+	results, err := i.searchEngine(engine, query, options, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	i.queryCache.putAt(generation, key, append([]Result{}, results...))
 	return results, nil
 }
 
-// Search query over the index.
-// The default range algorithm is `ScoreByCount` which ranges search results by count of found tokens.
-func (i *Index) Search(query string) ([]Result, error) {
-	rawTokens := strings.FieldsFunc(query, func(r rune) bool {
-		return !unicode.IsLetter(r)
-	})
+// searchEngine searches the query over the engine. The facets are filled with the number of the matching documents
+// containing every query token if they are not nil. The partial results are allowed if partial is not nil, it is set
+// to true if the engine fetched only some occurrences.
+func (i *Index) searchEngine(engine IndexEngine, query string, options SearchOptions, facets Facets,
+	partial *bool) ([]Result, error) {
+	analyzer, err := i.analyzer(options.Language)
+	if err != nil {
+		return nil, err
+	}
+	tokens, boosts, err := i.parseQuery(query, analyzer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return []Result{}, nil
+	}
 
-	items := map[*Source]*TmpResultItem{}
-	tokens := make([]string, 0, len(rawTokens))
+	items, err := i.gather(engine, tokens, boosts, partial)
+	if err != nil {
+		return nil, err
+	}
+	i.setCorpus(engine, options.Tenant, items, tokens)
+	phrases := parsePhrases(query, analyzer)
+	i.filterPhrases(engine, items, phrases)
+	i.matchPhrases(items, phrases)
+	exact, err := i.parseExact(query, analyzer)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.matchExact(engine, items, exact); err != nil {
+		return nil, err
+	}
+	options.filter(items)
+	if facets != nil {
+		facets.count(items, tokens)
+	}
+	if len(items) == 0 {
+		return []Result{}, nil
+	}
+
+	results, err := i.ranker(options)(items, tokens)
+	if err != nil {
+		return nil, err
+	}
+	options.boost(results)
+	options.sort(results)
+	if i.limit > 0 && len(results) > i.limit {
+		results = results[:i.limit]
+	}
+	if err := i.setSnippets(engine, results, options.markers()); err != nil {
+		return nil, err
+	}
+	if !i.matchedTokens {
+		return results, nil
+	}
 
-	for _, rawToken := range rawTokens {
-		token := porterstemmer.StemString(rawToken)
-		if stopwords.IsStopWord(token) {
+	for k := range results {
+		item, ok := items[results[k].Document]
+		if !ok {
 			continue
 		}
-		tokens = append(tokens, token)
+		for _, token := range tokens {
+			if item.frequency(token) > 0 {
+				results[k].MatchedTokens = append(results[k].MatchedTokens, token)
+			}
+		}
 	}
+	return results, nil
+}
 
-	occurrencesList, err := i.engine.Get(tokens)
-	if err != nil || len(occurrencesList) == 0 {
-		return nil, err
+// gather collects the occurrences of the tokens by documents. If the index is created with WithCountsOnly option
+// and the engine implements Counter interface only counts of the occurrences are collected. The partial occurrences
+// are collected if partial is not nil, see getOccurrences.
+func (i *Index) gather(engine IndexEngine, tokens []string, boosts map[string]float64,
+	partial *bool) (map[*Source]*TmpResultItem, error) {
+	items := map[*Source]*TmpResultItem{}
+	stored, queried := i.storedTokens(tokens)
+	stored, prefixes := splitPrefixes(stored)
+
+	if counter, ok := engine.(Counter); ok && i.countsOnly {
+		countsList := map[string]Counts{}
+		if len(stored) > 0 {
+			var err error
+			if countsList, err = counter.Count(stored); err != nil {
+				return nil, err
+			}
+		}
+		countsList, err := addPrefixCounts(engine, prefixes, countsList)
+		if err != nil {
+			return nil, err
+		}
+		for storedToken, counts := range countsList {
+			for source, count := range counts {
+				if _, ok := items[source]; !ok {
+					if i.maxCandidates > 0 && len(items) >= i.maxCandidates {
+						return nil, ErrTooManyCandidates
+					}
+					items[source] = &TmpResultItem{
+						count:    0,
+						counts:   map[string]int{},
+						boosts:   boosts,
+						maxCount: i.maxTokenCount,
+						operator: i.operator,
+					}
+				}
+
+				item := items[source]
+				for _, token := range queried[storedToken] {
+					if _, ok := item.counts[token]; !ok {
+						item.count++
+					}
+					item.counts[token] += count
+				}
+			}
+		}
+		return items, nil
 	}
 
-	for token, occurrences := range occurrencesList {
+	occurrencesList := map[string]Occurrences{}
+	if len(stored) > 0 {
+		var err error
+		if occurrencesList, err = getOccurrences(engine, stored, partial); err != nil {
+			return nil, err
+		}
+	}
+	occurrencesList, err := addPrefixes(engine, prefixes, occurrencesList)
+	if err != nil {
+		return nil, err
+	}
+	for storedToken, occurrences := range occurrencesList {
 		for source, positions := range occurrences {
 			if _, ok := items[source]; !ok {
+				if i.maxCandidates > 0 && len(items) >= i.maxCandidates {
+					return nil, ErrTooManyCandidates
+				}
 				items[source] = &TmpResultItem{
 					count:       0,
 					occurrences: map[string][]int{},
+					boosts:      boosts,
+					maxCount:    i.maxTokenCount,
+					operator:    i.operator,
 				}
 			}
 
 			item := items[source]
-			item.count++
-			item.occurrences[token] = positions
+			field := ""
+			if i.fields != nil {
+				field = tokenField(storedToken)
+			}
+			for _, token := range queried[storedToken] {
+				item.addOccurrences(field, token, positions)
+			}
 		}
 	}
-
-	if i.rangeAlgorithm == nil {
-		return ScoreByCount(items, tokens)
-	}
-
-	return i.rangeAlgorithm(items, tokens)
+	return items, nil
 }
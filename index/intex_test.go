@@ -2,34 +2,24 @@ package index
 
 import (
 	"bytes"
+	"fmt"
+	"math"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 )
 
 func TestIndex_AddSource(t *testing.T) {
-	i := &Index{
-		chanIn: make(chan newToken, 10000),
-	}
+	e := NewMemoryIndex()
+	i := NewIndex(e, nil)
 	if err := i.AddSource("file1", bytes.NewBufferString("an apple banana raspberry")); err != nil {
 		t.Error(err)
 	}
 	if err := i.AddSource("file2", bytes.NewBufferString("apple the banana orange")); err != nil {
 		t.Error(err)
 	}
-	close(i.chanIn)
-
-	e := MemoryIndex{
-		Index:   map[string]MemoryOccurrences{},
-		Sources: map[string]*Source{},
-		m:       &sync.RWMutex{},
-	}
-
-	for tok := range i.chanIn {
-		if err := e.Add(tok.token, tok.position, tok.source); err != nil {
-			t.Error(err)
-		}
-	}
+	i.Close()
 
 	expected := map[string]MemoryOccurrences{
 		"appl":      {"file1": []int{0}, "file2": []int{0}},
@@ -65,12 +55,14 @@ func TestScoreByCount(t *testing.T) {
 	actual, _ := ScoreByCount(input, []string{"appl", "banana"})
 	expected := []Result{
 		{
-			Document: s2,
-			Score:    3,
+			Document:  s2,
+			Score:     3,
+			Positions: input[s2].occurrences,
 		},
 		{
-			Document: s1,
-			Score:    2,
+			Document:  s1,
+			Score:     2,
+			Positions: input[s1].occurrences,
 		},
 	}
 	if !reflect.DeepEqual(actual, expected) {
@@ -99,8 +91,9 @@ func TestScoreByCount2(t *testing.T) {
 	actual, _ := ScoreByCount(input, []string{"appl", "banana"})
 	expected := []Result{
 		{
-			Document: s2,
-			Score:    3,
+			Document:  s2,
+			Score:     3,
+			Positions: input[s2].occurrences,
 		},
 	}
 	if !reflect.DeepEqual(actual, expected) {
@@ -127,17 +120,14 @@ func (ee *emptyEngine) Close() {}
 func TestIndex_Search(t *testing.T) {
 	ee := &emptyEngine{}
 
-	i := &Index{
-		engine: ee,
-		chanIn: make(chan newToken, 10000),
-	}
+	i := NewIndex(ee, nil)
 	if err := i.AddSource("file1", bytes.NewBufferString("an apple banana raspberry")); err != nil {
 		t.Error(err)
 	}
 	if err := i.AddSource("file2", bytes.NewBufferString("apple apple the banana orange")); err != nil {
 		t.Error(err)
 	}
-	close(i.chanIn)
+	i.Close()
 
 	s1 := Source{Name: "file1"}
 	s2 := Source{Name: "file2"}
@@ -153,6 +143,7 @@ func TestIndex_Search(t *testing.T) {
 		},
 	}
 
+	boosts := map[string]float64{"appl": 1, "banana": 1}
 	expected := map[*Source]*TmpResultItem{
 		&s1: {
 			count: 2,
@@ -160,6 +151,7 @@ func TestIndex_Search(t *testing.T) {
 				"banana": {1},
 				"appl":   {0},
 			},
+			boosts: boosts,
 		},
 		&s2: {
 			count: 2,
@@ -167,10 +159,17 @@ func TestIndex_Search(t *testing.T) {
 				"banana": {2},
 				"appl":   {0, 1},
 			},
+			boosts: boosts,
 		},
 	}
 
 	i.rangeAlgorithm = func(actual map[*Source]*TmpResultItem, tokens []string) (results []Result, err error) {
+		for _, item := range actual {
+			if item.corpus == nil || item.corpus.engine != ee {
+				t.Errorf("corpus of the engine is expected")
+			}
+			item.corpus = nil
+		}
 		if !reflect.DeepEqual(actual, expected) {
 			t.Errorf("%v is not equal to expected %v", actual, expected)
 		}
@@ -191,9 +190,529 @@ func TestNewIndex(t *testing.T) {
 	if err := i.AddSource("file2", bytes.NewBufferString("apple apple the banana orange")); err != nil {
 		t.Error(err)
 	}
-	close(i.chanIn)
+	i.Close()
+	i.Close()
 
 	if ee.sourcesCount != 7 {
 		t.Errorf("Count of documents %d != 2", ee.sourcesCount)
 	}
 }
+
+// blockingAddEngine blocks the first Add until release is closed.
+type blockingAddEngine struct {
+	emptyEngine
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (e *blockingAddEngine) Add(token string, position int, source Source) error {
+	e.once.Do(func() {
+		close(e.started)
+		<-e.release
+	})
+	return e.emptyEngine.Add(token, position, source)
+}
+
+func TestIndex_CloseDuringAdd(t *testing.T) {
+	engine := &blockingAddEngine{started: make(chan struct{}), release: make(chan struct{})}
+	i := NewIndex(engine, nil)
+
+	errC := make(chan error)
+	go func() {
+		errC <- i.AddSource("file1", bytes.NewBufferString("apple banana orange"))
+	}()
+	<-engine.started
+
+	closed := make(chan struct{})
+	go func() {
+		i.Close()
+		close(closed)
+	}()
+	if err := <-errC; err != ErrEngineClosed {
+		t.Errorf("%v is not equal to expected %v", err, ErrEngineClosed)
+	}
+	close(engine.release)
+	<-closed
+
+	if err := i.AddSource("file2", bytes.NewBufferString("apple")); err != ErrEngineClosed {
+		t.Errorf("%v is not equal to expected %v", err, ErrEngineClosed)
+	}
+	if engine.sourcesCount != 1 {
+		t.Errorf("%d is not equal to expected 1", engine.sourcesCount)
+	}
+}
+
+func TestIndex_CloseStopsListener(t *testing.T) {
+	for n := 0; n < 100; n++ {
+		i := NewIndex(&emptyEngine{}, nil)
+		if err := i.AddSource("file1", bytes.NewBufferString("apple")); err != nil {
+			t.Fatal(err)
+		}
+		i.Close()
+		select {
+		case <-i.done:
+		default:
+			t.Fatalf("listener of index %d is not stopped", n)
+		}
+	}
+}
+
+// failingEngine fails to add the tokens.
+type failingEngine struct {
+	emptyEngine
+	err error
+}
+
+func (e *failingEngine) Add(token string, position int, source Source) error {
+	return e.err
+}
+
+func TestIndex_AddSourceSync(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil)
+	if err := i.AddSourceSync("file1", bytes.NewBufferString("apple banana")); err != nil {
+		t.Fatal(err)
+	}
+	// The tokens are added before AddSourceSync returns, so the document is found without waiting for the listener.
+	results, err := i.Search("banana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Document.Name != "file1" {
+		t.Errorf("%v is not equal to expected [file1]", results)
+	}
+	i.Close()
+	if err := i.AddSourceSync("file2", bytes.NewBufferString("apple")); err != ErrEngineClosed {
+		t.Errorf("%v is not equal to expected %v", err, ErrEngineClosed)
+	}
+
+	errFailed := fmt.Errorf("failed")
+	failing := NewIndex(&failingEngine{err: errFailed}, nil)
+	defer failing.Close()
+	if err := failing.AddSourceSync("file1", bytes.NewBufferString("apple")); err != errFailed {
+		t.Errorf("%v is not equal to expected %v", err, errFailed)
+	}
+}
+
+type tenantEngine struct {
+	tenants map[string]*emptyEngine
+}
+
+func (te *tenantEngine) Add(token string, position int, source Source) error {
+	return nil
+}
+
+func (te *tenantEngine) Get(tokens []string) (map[string]Occurrences, error) {
+	return nil, nil
+}
+
+func (te *tenantEngine) Close() {}
+
+func (te *tenantEngine) Tenant(tenant string) IndexEngine {
+	return te.tenants[tenant]
+}
+
+func TestIndex_SearchTenant(t *testing.T) {
+	s1 := Source{Name: "file1"}
+	s2 := Source{Name: "file2"}
+	te := &tenantEngine{
+		tenants: map[string]*emptyEngine{
+			"a": {results: map[string]Occurrences{"appl": {&s1: []int{0}}}},
+			"b": {results: map[string]Occurrences{"appl": {&s2: []int{0, 1}}}},
+		},
+	}
+	i := &Index{engine: te}
+
+	actual, err := i.SearchTenant("a", "apple")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []Result{{Document: &s1, Score: 1, Positions: map[string][]int{"appl": {0}}}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	actual, err = i.SearchTenant("b", "apple")
+	if err != nil {
+		t.Error(err)
+	}
+	expected = []Result{{Document: &s2, Score: 2, Positions: map[string][]int{"appl": {0, 1}}}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestIndex_SearchTenantNotSupported(t *testing.T) {
+	i := &Index{engine: &emptyEngine{}}
+	if _, err := i.SearchTenant("a", "apple"); err != ErrTenantsNotSupported {
+		t.Errorf("%v is not equal to expected %v", err, ErrTenantsNotSupported)
+	}
+}
+
+func TestIndex_SearchBoost(t *testing.T) {
+	s1 := Source{Name: "file1"}
+	s2 := Source{Name: "file2"}
+	ee := &emptyEngine{
+		results: map[string]Occurrences{
+			"appl": {
+				&s1: []int{0},
+				&s2: []int{0, 1},
+			},
+			"banana": {
+				&s1: []int{1, 2, 3},
+				&s2: []int{2},
+			},
+		},
+	}
+	i := &Index{engine: ee}
+
+	actual, err := i.Search("apple banana")
+	if err != nil {
+		t.Error(err)
+	}
+	p1 := map[string][]int{"appl": {0}, "banana": {1, 2, 3}}
+	p2 := map[string][]int{"appl": {0, 1}, "banana": {2}}
+	expected := []Result{{Document: &s1, Score: 4, Positions: p1}, {Document: &s2, Score: 3, Positions: p2}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	actual, err = i.Search("apple^3 banana")
+	if err != nil {
+		t.Error(err)
+	}
+	expected = []Result{{Document: &s2, Score: 7, Positions: p2}, {Document: &s1, Score: 6, Positions: p1}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tokens, boosts, err := (&Index{}).parseQuery("the apple^2.5 banana apples^bad", Analyzer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTokens := []string{"appl", "banana", "bad"}
+	if !reflect.DeepEqual(tokens, expectedTokens) {
+		t.Errorf("%v is not equal to expected %v", tokens, expectedTokens)
+	}
+	expectedBoosts := map[string]float64{"appl": 2.5, "banana": 1, "bad": 1}
+	if !reflect.DeepEqual(boosts, expectedBoosts) {
+		t.Errorf("%v is not equal to expected %v", boosts, expectedBoosts)
+	}
+}
+
+func TestParseQuery_NonFiniteBoost(t *testing.T) {
+	for _, query := range []string{"apple^Inf", "apple^1e400", "apple^NaN", "apple^-Inf"} {
+		_, boosts, err := (&Index{}).parseQuery(query, Analyzer{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if boost := boosts["appl"]; boost != defaultBoost {
+			t.Errorf("%s: %v is not equal to expected %v", query, boost, defaultBoost)
+		}
+	}
+
+	i := NewIndex(NewMemoryIndex(), nil, WithDefaultOperator(OperatorOr))
+	if err := i.AddSource("file1", strings.NewReader("apple inf")); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+	results, err := i.Search("apple^Inf banana^1e400")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("%d is not equal to expected 1", len(results))
+	}
+	for _, result := range results {
+		if math.IsInf(result.Score, 0) || math.IsNaN(result.Score) {
+			t.Errorf("%v is not finite", result.Score)
+		}
+	}
+}
+
+func TestIndex_SearchMatchedTokens(t *testing.T) {
+	s1 := Source{Name: "file1"}
+	s2 := Source{Name: "file2"}
+	ee := &emptyEngine{
+		results: map[string]Occurrences{
+			"appl":   {&s1: []int{0}, &s2: []int{0}},
+			"banana": {&s2: []int{1}},
+		},
+	}
+	allDocuments := func(items map[*Source]*TmpResultItem, tokens []string) ([]Result, error) {
+		return []Result{{Document: &s1}, {Document: &s2}}, nil
+	}
+	i := NewIndex(ee, allDocuments, WithMatchedTokens())
+	defer close(i.chanIn)
+
+	results, err := i.Search("banana apple")
+	if err != nil {
+		t.Error(err)
+	}
+	actual := [][]string{results[0].MatchedTokens, results[1].MatchedTokens}
+	expected := [][]string{{"appl"}, {"banana", "appl"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	i.matchedTokens = false
+	results, err = i.Search("banana apple")
+	if err != nil {
+		t.Error(err)
+	}
+	if results[0].MatchedTokens != nil || results[1].MatchedTokens != nil {
+		t.Errorf("matched tokens must be empty without the option, got %v", results)
+	}
+}
+
+type countEngine struct {
+	emptyEngine
+	counts map[string]Counts
+}
+
+func (ce *countEngine) Count(tokens []string) (map[string]Counts, error) {
+	return ce.counts, nil
+}
+
+func TestIndex_SearchCountsOnly(t *testing.T) {
+	s1 := Source{Name: "file1"}
+	s2 := Source{Name: "file2"}
+	ce := &countEngine{
+		emptyEngine: emptyEngine{
+			results: map[string]Occurrences{
+				"appl": {&s1: []int{0}},
+			},
+		},
+		counts: map[string]Counts{
+			"appl":   {&s1: 1, &s2: 3},
+			"banana": {&s1: 1, &s2: 2},
+		},
+	}
+
+	i := &Index{engine: ce, countsOnly: true}
+	actual, err := i.Search("apple banana")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []Result{{Document: &s2, Score: 5}, {Document: &s1, Score: 2}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	i.countsOnly = false
+	actual, err = i.Search("apple")
+	if err != nil {
+		t.Error(err)
+	}
+	expected = []Result{{Document: &s1, Score: 1, Positions: map[string][]int{"appl": {0}}}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestIndex_DeleteByPrefix(t *testing.T) {
+	e := NewMemoryIndex()
+	if err := e.Add("appl", 0, Source{Name: "docs/file1"}); err != nil {
+		t.Error(err)
+	}
+	i := &Index{engine: e}
+	deleted, err := i.DeleteByPrefix("docs/")
+	if err != nil || deleted != 1 {
+		t.Errorf("expected 1 deleted document, got %d %v", deleted, err)
+	}
+
+	i = &Index{engine: &emptyEngine{}}
+	if _, err := i.DeleteByPrefix("docs/"); err != ErrNotSupported {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+}
+
+func TestIndex_RemoveSource(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithQueryCache(10), WithNameCollision(CollisionSerialize))
+	for name, text := range map[string]string{
+		"file1": "apple banana",
+		"file2": "apple orange",
+	} {
+		if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+	if _, err := i.Search("apple"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := i.RemoveSource("file2"); err != nil {
+		t.Fatal(err)
+	}
+	results, err := i.Search("apple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Document.Name != "file1" {
+		t.Errorf("%v is not equal to expected [file1]", results)
+	}
+	if err := i.RemoveSource("file2"); err != ErrUnknownDocument {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownDocument)
+	}
+
+	// The document added again after the removal starts from the beginning.
+	i = NewIndex(NewMemoryIndex(), nil, WithNameCollision(CollisionSerialize))
+	if err := i.AddSource("file1", bytes.NewBufferString("apple banana")); err != nil {
+		t.Fatal(err)
+	}
+	if positions := positionsOf(t, i, "banana", "banana"); !reflect.DeepEqual(positions, []int{1}) {
+		t.Errorf("%v is not equal to expected %v", positions, []int{1})
+	}
+	if err := i.RemoveSource("file1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.AddSource("file1", bytes.NewBufferString("cherry")); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+	results, err = i.Search("cherry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !reflect.DeepEqual(results[0].Positions["cherri"], []int{0}) {
+		t.Errorf("%v is not equal to expected position 0", results)
+	}
+
+	i = &Index{engine: &emptyEngine{}}
+	if err := i.RemoveSource("file1"); err != ErrNotSupported {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+}
+
+func TestIndex_SearchMaxTokenCount(t *testing.T) {
+	stuffed := Source{Name: "stuffed"}
+	natural := Source{Name: "natural"}
+	ee := &emptyEngine{
+		results: map[string]Occurrences{
+			"appl": {
+				&stuffed: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+				&natural: []int{0, 5},
+			},
+			"banana": {
+				&stuffed: []int{10},
+				&natural: []int{1, 6},
+			},
+		},
+	}
+
+	i := &Index{engine: ee}
+	actual, err := i.Search("apple banana")
+	if err != nil {
+		t.Error(err)
+	}
+	if actual[0].Document != &stuffed {
+		t.Errorf("%v is not equal to expected %v", actual[0].Document, &stuffed)
+	}
+
+	i = &Index{engine: ee, maxTokenCount: 2}
+	actual, err = i.Search("apple banana")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []Result{
+		{Document: &natural, Score: 4, Positions: map[string][]int{"appl": {0, 5}, "banana": {1, 6}}},
+		{Document: &stuffed, Score: 3, Positions: map[string][]int{"appl": {0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, "banana": {10}}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestIndex_SearchMaxCandidates(t *testing.T) {
+	s1 := &Source{Name: "file1"}
+	s2 := &Source{Name: "file2"}
+	s3 := &Source{Name: "file3"}
+	engine := &countEngine{
+		emptyEngine: emptyEngine{
+			results: map[string]Occurrences{
+				"appl": {s1: []int{0}, s2: []int{0}, s3: []int{1}},
+			},
+		},
+		counts: map[string]Counts{
+			"appl": {s1: 1, s2: 1, s3: 1},
+		},
+	}
+	for _, countsOnly := range []bool{false, true} {
+		i := &Index{engine: engine, countsOnly: countsOnly, maxCandidates: 2}
+		if _, err := i.Search("apple"); err != ErrTooManyCandidates {
+			t.Errorf("%v is not equal to expected %v", err, ErrTooManyCandidates)
+		}
+
+		i.maxCandidates = 3
+		results, err := i.Search("apple")
+		if err != nil {
+			t.Error(err)
+		}
+		if len(results) != 3 {
+			t.Errorf("%d is not equal to expected 3", len(results))
+		}
+	}
+}
+
+func TestIndex_SwapEngine(t *testing.T) {
+	engines := make([]*MemoryIndex, 2)
+	for k, name := range []string{"first", "second"} {
+		engines[k] = NewMemoryIndex()
+		if err := engines[k].Add("appl", 0, Source{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i := NewIndex(engines[0], nil, WithQueryCache(10))
+	defer i.Close()
+
+	stop := make(chan struct{})
+	errs := make(chan error, 4)
+	var wg sync.WaitGroup
+	for k := 0; k < 4; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				results, err := i.SearchWithOptions("apple", SearchOptions{OrderBy: OrderByName})
+				if err != nil {
+					errs <- err
+					return
+				}
+				// Every search sees one of the engines as a whole.
+				if len(results) != 1 {
+					errs <- fmt.Errorf("%d results are found over one engine", len(results))
+					return
+				}
+			}
+		}()
+	}
+	for k := 0; k < 1000; k++ {
+		old := i.SwapEngine(engines[(k+1)%2])
+		if old != engines[k%2] {
+			t.Errorf("%v is not equal to expected %v", old, engines[k%2])
+		}
+	}
+	close(stop)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// The results of the searches over the previous engine are not cached.
+	results, err := i.SearchWithOptions("apple", SearchOptions{OrderBy: OrderByName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Document.Name != "first" {
+		t.Errorf("%v is not equal to expected first", results)
+	}
+}
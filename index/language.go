@@ -0,0 +1,68 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownLanguage is returned when no analyzer is registered for the language of the document or the query.
+var ErrUnknownLanguage = errors.New("unknown language")
+
+// Analyzer is the stemmer and the stopwords used to prepare the text of the language.
+// The zero Analyzer uses PorterStemmer and the built-in English stopwords only.
+type Analyzer struct {
+	Stemmer   Stemmer
+	Stopwords Stopwords
+	// StopwordsOnly ignores only the Stopwords instead of adding them to the built-in English ones.
+	StopwordsOnly bool
+	// SplitIdentifiers adds the whole words joined by underscores, dots and slashes to their parts.
+	SplitIdentifiers bool
+	// Apostrophes is the handling of the apostrophes inside the words, the words are split at them by default.
+	Apostrophes Apostrophes
+	// MinTokenLength drops the tokens of the words shorter than the number of letters, e.g. the single letters of the
+	// formulas. 0 keeps all tokens.
+	MinTokenLength int
+}
+
+// LanguageStemmers lists the stemmers of the languages by ISO 639 language code.
+var LanguageStemmers = map[string]Stemmer{
+	"en": PorterStemmer,
+	"de": GermanStemmer,
+}
+
+// WithLanguage registers the analyzer of the language. The documents with the language set in Source.Language are
+// indexed with the analyzer and the queries with the language set in SearchOptions.Language are parsed with it.
+// The documents and the queries without the language use the stemmer and the stopwords of the index.
+func WithLanguage(language string, analyzer Analyzer) Option {
+	return func(i *Index) {
+		if i.languages == nil {
+			i.languages = map[string]Analyzer{}
+		}
+		i.languages[language] = analyzer
+	}
+}
+
+// analyzer returns the analyzer of the language or the default one for empty language.
+func (i *Index) analyzer(language string) (Analyzer, error) {
+	if language == "" {
+		return i.defaultAnalyzer(), nil
+	}
+	analyzer, ok := i.languages[language]
+	if !ok {
+		return Analyzer{}, fmt.Errorf("%w: %s", ErrUnknownLanguage, language)
+	}
+	return analyzer, nil
+}
+
+// defaultAnalyzer returns the analyzer with the stemmer, the stopwords, the identifier splitting, the handling of the
+// apostrophes and the minimal length of the tokens of the index.
+func (i *Index) defaultAnalyzer() Analyzer {
+	return Analyzer{
+		Stemmer:          i.stemmer,
+		Stopwords:        i.stopwords,
+		StopwordsOnly:    i.stopwordsOnly,
+		SplitIdentifiers: i.splitIdentifiers,
+		Apostrophes:      i.apostrophes,
+		MinTokenLength:   i.minTokenLength,
+	}
+}
@@ -0,0 +1,46 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIndex_SearchLanguage(t *testing.T) {
+	engine := NewMemoryIndex()
+	i := NewIndex(engine, nil, WithLanguage("de", Analyzer{Stemmer: GermanStemmer, Stopwords: NewStopwords("die", "der")}))
+	source := Source{Name: "file1", Language: "de"}
+	if err := i.AddDocument(source, bytes.NewBufferString("Die Häuser der Stadt sind alt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.AddDocument(Source{Name: "file2", Language: "fr"}, bytes.NewBufferString("la maison")); !errors.Is(err, ErrUnknownLanguage) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownLanguage)
+	}
+	i.Close()
+
+	for _, test := range []struct {
+		language string
+		expected []string
+	}{
+		{"de", []string{"file1"}},
+		// The default analyzer stems Haus to hau which is not indexed.
+		{"", []string{}},
+	} {
+		results, err := i.SearchWithOptions("Haus", SearchOptions{Language: test.language})
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := []string{}
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.language, actual, test.expected)
+		}
+	}
+
+	if _, err := i.SearchWithOptions("Haus", SearchOptions{Language: "fr"}); !errors.Is(err, ErrUnknownLanguage) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownLanguage)
+	}
+}
@@ -0,0 +1,36 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+)
+
+// WithLengthNormalization wraps the range algorithm to divide the scores by the length of the document relative to the
+// average length, so the short focused document is not beaten by the long one mentioning the terms incidentally as
+// often. The documents of the average length keep the scores. The engine must implement StatsEngine and LengthsEngine
+// interfaces, otherwise ErrNotSupported is returned.
+func WithLengthNormalization(rangeAlgorithm RangeAlgorithm) RangeAlgorithm {
+	return func(items map[*Source]*TmpResultItem, tokens []string) ([]Result, error) {
+		results, err := rangeAlgorithm(items, tokens)
+		if err != nil || len(results) == 0 {
+			return results, err
+		}
+		item, ok := items[results[0].Document]
+		if !ok || item.corpus == nil {
+			return nil, ErrNotSupported
+		}
+		c := item.corpus
+		if err := c.load(); err != nil {
+			return nil, fmt.Errorf("can not load statistics of the index: %w", err)
+		}
+		for k := range results {
+			if length := c.lengths[results[k].Document.Name]; length > 0 && c.average > 0 {
+				results[k].Score *= c.average / float64(length)
+			}
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+		return results, nil
+	}
+}
@@ -0,0 +1,57 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWithLengthNormalization(t *testing.T) {
+	for _, test := range []struct {
+		rangeAlgorithm RangeAlgorithm
+		expected       []string
+		scores         []float64
+	}{
+		{ScoreByCount, []string{"long", "short"}, []float64{1, 1}},
+		// The average length is 4, so the score of the document of 2 words doubles and of 6 words drops by a third.
+		{WithLengthNormalization(ScoreByCount), []string{"short", "long"}, []float64{2, 4.0 / 6}},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil, WithRangeAlgorithm(test.rangeAlgorithm))
+		for name, text := range map[string]string{
+			"short": "apple banana",
+			"long":  "cherry durian apple grape kiwi lemon",
+		} {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+
+		results, err := i.Search("apple")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		var scores []float64
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+			scores = append(scores, result.Score)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v is not equal to expected %v", actual, test.expected)
+		}
+		if !reflect.DeepEqual(scores, test.scores) {
+			t.Errorf("%v is not equal to expected %v", scores, test.scores)
+		}
+	}
+}
+
+func TestWithLengthNormalization_NotSupported(t *testing.T) {
+	engine := &emptyEngine{results: map[string]Occurrences{"appl": {&Source{Name: "file1"}: []int{0}}}}
+	i := NewIndex(engine, nil, WithRangeAlgorithm(WithLengthNormalization(ScoreByCount)))
+	i.Close()
+	if _, err := i.Search("apple"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+}
@@ -1,6 +1,10 @@
 package index
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"sync"
 )
 
@@ -10,18 +14,44 @@ type MemoryOccurrences map[string][]int
 type MemoryIndex struct {
 	Index   map[string]MemoryOccurrences
 	Sources map[string]*Source
-	m       *sync.RWMutex
+	// Contents are the raw texts of the documents retained with WithRetainedContent option.
+	Contents map[string]string
+	// Words are the original words of the documents by their positions stored with WithSnippets option.
+	Words      map[string][]string
+	m          *sync.RWMutex
+	generation string
 }
 
 func NewMemoryIndex() *MemoryIndex {
 	i := &MemoryIndex{
-		Index:   map[string]MemoryOccurrences{},
-		Sources: map[string]*Source{},
-		m:       &sync.RWMutex{},
+		Index:    map[string]MemoryOccurrences{},
+		Sources:  map[string]*Source{},
+		Contents: map[string]string{},
+		Words:    map[string][]string{},
+		m:        &sync.RWMutex{},
 	}
 	return i
 }
 
+// SetGeneration sets the identifier of the indexed content, e.g. the checksum of the decoded index file.
+func (i *MemoryIndex) SetGeneration(generation string) {
+	i.m.Lock()
+	defer i.m.Unlock()
+	i.generation = generation
+}
+
+// Generation returns the identifier of the indexed content set with SetGeneration, empty if it is unknown.
+func (i *MemoryIndex) Generation() string {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	return i.generation
+}
+
+// KeepsMetadata returns true, the documents are kept with their metadata.
+func (i *MemoryIndex) KeepsMetadata() bool {
+	return true
+}
+
 // Add adds new token, document and position to the memory list.
 func (i *MemoryIndex) Add(token string, position int, source Source) error {
 	i.m.Lock()
@@ -48,6 +78,9 @@ func (i *MemoryIndex) Get(tokens []string) (map[string]Occurrences, error) {
 		result := Occurrences{}
 		for document, positions := range i.Index[token] {
 			source := i.Sources[document]
+			if source.Excluded {
+				continue
+			}
 			result[source] = positions
 		}
 		results[token] = result
@@ -55,6 +88,88 @@ func (i *MemoryIndex) Get(tokens []string) (map[string]Occurrences, error) {
 	return results, nil
 }
 
+// SetExcluded excludes the document from the search or includes it back in thread-safe way.
+func (i *MemoryIndex) SetExcluded(name string, excluded bool) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+	source, ok := i.Sources[name]
+	if !ok {
+		return ErrUnknownDocument
+	}
+	// The source is replaced, because the previous search results may still refer to it.
+	updated := *source
+	updated.Excluded = excluded
+	i.Sources[name] = &updated
+	return nil
+}
+
+// IterateTokens calls fn for every token of the MemoryIndex in thread-safe way.
+func (i *MemoryIndex) IterateTokens(fn func(token string) error) error {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	for token, occurrences := range i.Index {
+		if len(occurrences) == 0 {
+			continue
+		}
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes the document and prunes its positions from every token in thread-safe way.
+// Tokens left without documents are removed as well.
+func (i *MemoryIndex) Remove(source Source) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	if _, ok := i.Sources[source.Name]; !ok {
+		return ErrUnknownDocument
+	}
+	delete(i.Sources, source.Name)
+	delete(i.Contents, source.Name)
+	delete(i.Words, source.Name)
+	for token, occurrences := range i.Index {
+		delete(occurrences, source.Name)
+		if len(occurrences) == 0 {
+			delete(i.Index, token)
+		}
+	}
+	return nil
+}
+
+// DeleteByPrefix removes all documents with the name starting with the prefix in thread-safe way.
+// Tokens left without documents are removed as well.
+func (i *MemoryIndex) DeleteByPrefix(prefix string) (int, error) {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	deleted := 0
+	for name := range i.Sources {
+		if strings.HasPrefix(name, prefix) {
+			delete(i.Sources, name)
+			delete(i.Contents, name)
+			delete(i.Words, name)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return 0, nil
+	}
+	for token, occurrences := range i.Index {
+		for name := range occurrences {
+			if strings.HasPrefix(name, prefix) {
+				delete(occurrences, name)
+			}
+		}
+		if len(occurrences) == 0 {
+			delete(i.Index, token)
+		}
+	}
+	return deleted, nil
+}
+
 func (i *MemoryIndex) Close() {}
 
 // Encoder is the interface implemented by the object that can encode data from the MemoryIndex.
@@ -63,12 +178,56 @@ type Encoder interface {
 	Encode(e interface{}) error
 }
 
+// encodedIndex is the encoded MemoryIndex. The positions are stored as the gaps between the consecutive positions if
+// Delta is set, so the dense postings take less space. The index encoded without Delta has absolute positions.
+type encodedIndex struct {
+	Index   map[string]MemoryOccurrences
+	Sources map[string]*Source
+	Delta   bool
+	// Contents are empty if the content is not retained or the index is encoded by the older version.
+	Contents map[string]string
+	// Words are empty if the words are not stored or the index is encoded by the older version.
+	Words map[string][]string
+}
+
 // Encode is the thread-safe function to encode MemoryIndex.
 func (i *MemoryIndex) Encode(encoder Encoder) error {
 	i.m.RLock()
 	defer i.m.RUnlock()
 
-	return encoder.Encode(i)
+	encoded := encodedIndex{
+		Index:    make(map[string]MemoryOccurrences, len(i.Index)),
+		Sources:  i.Sources,
+		Delta:    true,
+		Contents: i.Contents,
+		Words:    i.Words,
+	}
+	for token, occurrences := range i.Index {
+		deltas := make(MemoryOccurrences, len(occurrences))
+		for document, positions := range occurrences {
+			deltas[document] = deltaEncode(positions)
+		}
+		encoded.Index[token] = deltas
+	}
+	return encoder.Encode(encoded)
+}
+
+// deltaEncode returns the gaps between the consecutive positions, the first one is kept as is.
+func deltaEncode(positions []int) []int {
+	deltas := make([]int, len(positions))
+	previous := 0
+	for k, position := range positions {
+		deltas[k] = position - previous
+		previous = position
+	}
+	return deltas
+}
+
+// deltaDecode restores the absolute positions from the gaps in place.
+func deltaDecode(deltas []int) {
+	for k := 1; k < len(deltas); k++ {
+		deltas[k] += deltas[k-1]
+	}
 }
 
 // Decoder is the interface implemented by the object that can decode data into the MemoryIndex.
@@ -77,10 +236,117 @@ type Decoder interface {
 	Decode(e interface{}) error
 }
 
+// ErrIndexTruncated is returned when the encoded index ends unexpectedly, e.g. the index file is truncated.
+var ErrIndexTruncated = errors.New("index data is truncated, rebuild the index")
+
+// ErrIndexFormat is returned when the encoded index can not be decoded, e.g. it was written with another encoder or
+// corrupted.
+var ErrIndexFormat = errors.New("index data has unexpected format, check the encoding or rebuild the index")
+
+// decodeError classifies the error of the decoder.
+func decodeError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %v", ErrIndexTruncated, err)
+	}
+	return fmt.Errorf("%w: %v", ErrIndexFormat, err)
+}
+
 // Decode is the thread-safe function to extract index from the encoded data.
 func Decode(decoder Decoder) (*MemoryIndex, error) {
 	i := NewMemoryIndex()
 	i.m.Lock()
 	defer i.m.Unlock()
-	return i, decoder.Decode(i)
+
+	encoded := encodedIndex{Index: i.Index, Sources: i.Sources, Contents: i.Contents, Words: i.Words}
+	if err := decoder.Decode(&encoded); err != nil {
+		return i, decodeError(err)
+	}
+	if encoded.Delta {
+		for _, occurrences := range encoded.Index {
+			for _, positions := range occurrences {
+				deltaDecode(positions)
+			}
+		}
+	}
+	i.Index, i.Sources, i.Contents, i.Words = encoded.Index, encoded.Sources, encoded.Contents, encoded.Words
+	if i.Contents == nil {
+		i.Contents = map[string]string{}
+	}
+	if i.Words == nil {
+		i.Words = map[string][]string{}
+	}
+	return i, nil
+}
+
+// Posting is the list of positions of the token in the document.
+type Posting struct {
+	Token     string
+	Document  string
+	Positions []int
+	// Source is the document with its attributes, e.g. the modification time, nil if the engine does not keep them or
+	// the posting is not the first one of the document in the stream encoded by EncodeStream.
+	Source *Source
+}
+
+// Iterator is the interface implemented by the engines which can enumerate all stored postings.
+type Iterator interface {
+	// Iterate calls fn for every stored posting. Iteration stops on the first error returned by fn.
+	Iterate(fn func(posting Posting) error) error
+}
+
+// Iterate calls fn for every posting of the MemoryIndex in thread-safe way.
+func (i *MemoryIndex) Iterate(fn func(posting Posting) error) error {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	for token, occurrences := range i.Index {
+		for document, positions := range occurrences {
+			posting := Posting{Token: token, Document: document, Positions: positions, Source: i.Sources[document]}
+			if err := fn(posting); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EncodeStream encodes postings of the engine one by one, so the whole index is never held in memory, only the names
+// of the documents are. The source of the document is encoded with its first posting only.
+// Use DecodeStream to read the encoded data.
+func EncodeStream(engine Iterator, encoder Encoder) error {
+	encoded := map[string]bool{}
+	return engine.Iterate(func(posting Posting) error {
+		if posting.Source != nil {
+			if encoded[posting.Document] {
+				posting.Source = nil
+			}
+			encoded[posting.Document] = true
+		}
+		return encoder.Encode(posting)
+	})
+}
+
+// DecodeStream extracts in-memory index from the data encoded by EncodeStream. The documents encoded without the
+// source, e.g. by the older version, are restored by their names only.
+func DecodeStream(decoder Decoder) (*MemoryIndex, error) {
+	i := NewMemoryIndex()
+	for {
+		var posting Posting
+		err := decoder.Decode(&posting)
+		if err == io.EOF {
+			return i, nil
+		}
+		if err != nil {
+			return nil, decodeError(err)
+		}
+		source := Source{Name: posting.Document}
+		if posting.Source != nil {
+			source = *posting.Source
+			source.Name = posting.Document
+		}
+		for _, position := range posting.Positions {
+			if err := i.Add(posting.Token, position, source); err != nil {
+				return nil, err
+			}
+		}
+	}
 }
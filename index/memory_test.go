@@ -1,9 +1,15 @@
 package index
 
 import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestMemoryIndex_Add(t *testing.T) {
@@ -92,3 +98,262 @@ func TestMemoryIndex_Get(t *testing.T) {
 		t.Errorf("%v is not equal to expected %v", occurences, expected)
 	}
 }
+
+func TestEncodeStream(t *testing.T) {
+	i := NewMemoryIndex()
+	s1 := Source{Name: "file1", ModTime: time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC), Hash: "h1", Language: "en"}
+	s2 := Source{Name: "file2", Excluded: true, Metadata: map[string]string{"price": "3"}}
+	if err := i.Add("appl", 0, s1); err != nil {
+		t.Error(err)
+	}
+	if err := i.Add("appl", 0, s2); err != nil {
+		t.Error(err)
+	}
+	if err := i.Add("appl", 3, s2); err != nil {
+		t.Error(err)
+	}
+	if err := i.Add("banana", 1, s1); err != nil {
+		t.Error(err)
+	}
+
+	for name, codec := range map[string]struct {
+		encoder func(buf *bytes.Buffer) Encoder
+		decoder func(buf *bytes.Buffer) Decoder
+	}{
+		"gob": {
+			encoder: func(buf *bytes.Buffer) Encoder { return gob.NewEncoder(buf) },
+			decoder: func(buf *bytes.Buffer) Decoder { return gob.NewDecoder(buf) },
+		},
+		"json": {
+			encoder: func(buf *bytes.Buffer) Encoder { return json.NewEncoder(buf) },
+			decoder: func(buf *bytes.Buffer) Decoder { return json.NewDecoder(buf) },
+		},
+	} {
+		buf := &bytes.Buffer{}
+		if err := EncodeStream(i, codec.encoder(buf)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		decoded, err := DecodeStream(codec.decoder(buf))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(decoded.Index, i.Index) {
+			t.Errorf("%s: %v is not equal to expected %v", name, decoded.Index, i.Index)
+		}
+		if !reflect.DeepEqual(decoded.Sources, i.Sources) {
+			t.Errorf("%s: %v is not equal to expected %v", name, decoded.Sources, i.Sources)
+		}
+	}
+}
+
+func TestMemoryIndex_DeleteByPrefix(t *testing.T) {
+	i := NewMemoryIndex()
+	for _, item := range []struct {
+		token    string
+		document string
+	}{
+		{"appl", "docs/file1"},
+		{"banana", "docs/file1"},
+		{"appl", "docs/file2"},
+		{"appl", "other/file3"},
+		{"orang", "docs/file2"},
+	} {
+		if err := i.Add(item.token, 0, Source{Name: item.document}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	deleted, err := i.DeleteByPrefix("docs/")
+	if err != nil {
+		t.Error(err)
+	}
+	if deleted != 2 {
+		t.Errorf("%d is not equal to expected 2", deleted)
+	}
+
+	expected := map[string]MemoryOccurrences{
+		"appl": {"other/file3": []int{0}},
+	}
+	if !reflect.DeepEqual(i.Index, expected) {
+		t.Errorf("%v is not equal to expected %v", i.Index, expected)
+	}
+	expectedSources := map[string]*Source{"other/file3": {Name: "other/file3"}}
+	if !reflect.DeepEqual(i.Sources, expectedSources) {
+		t.Errorf("%v is not equal to expected %v", i.Sources, expectedSources)
+	}
+}
+
+func TestMemoryIndex_Remove(t *testing.T) {
+	i := NewMemoryIndex()
+	for _, item := range []struct {
+		token    string
+		position int
+		document string
+	}{
+		{"appl", 0, "file1"},
+		{"banana", 1, "file1"},
+		{"appl", 0, "file2"},
+		{"orang", 1, "file2"},
+	} {
+		if err := i.Add(item.token, item.position, Source{Name: item.document}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := i.Remove(Source{Name: "file2"}); err != nil {
+		t.Error(err)
+	}
+	expected := map[string]MemoryOccurrences{
+		"appl":   {"file1": []int{0}},
+		"banana": {"file1": []int{1}},
+	}
+	if !reflect.DeepEqual(i.Index, expected) {
+		t.Errorf("%v is not equal to expected %v", i.Index, expected)
+	}
+	expectedSources := map[string]*Source{"file1": {Name: "file1"}}
+	if !reflect.DeepEqual(i.Sources, expectedSources) {
+		t.Errorf("%v is not equal to expected %v", i.Sources, expectedSources)
+	}
+	if err := i.Remove(Source{Name: "file2"}); err != ErrUnknownDocument {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownDocument)
+	}
+}
+
+func TestMemoryIndex_Encode(t *testing.T) {
+	i := NewMemoryIndex()
+	for _, item := range []struct {
+		token    string
+		position int
+		document string
+	}{
+		{"appl", 0, "file1"},
+		{"appl", 5, "file1"},
+		{"appl", 12, "file1"},
+		{"banana", 1, "file1"},
+		{"appl", 3, "file2"},
+	} {
+		if err := i.Add(item.token, item.position, Source{Name: item.document}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, codec := range map[string]struct {
+		encoder func(buf *bytes.Buffer) Encoder
+		decoder func(buf *bytes.Buffer) Decoder
+	}{
+		"gob": {
+			encoder: func(buf *bytes.Buffer) Encoder { return gob.NewEncoder(buf) },
+			decoder: func(buf *bytes.Buffer) Decoder { return gob.NewDecoder(buf) },
+		},
+		"json": {
+			encoder: func(buf *bytes.Buffer) Encoder { return json.NewEncoder(buf) },
+			decoder: func(buf *bytes.Buffer) Decoder { return json.NewDecoder(buf) },
+		},
+	} {
+		buf := &bytes.Buffer{}
+		if err := i.Encode(codec.encoder(buf)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		decoded, err := Decode(codec.decoder(buf))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(decoded.Index, i.Index) {
+			t.Errorf("%s: %v is not equal to expected %v", name, decoded.Index, i.Index)
+		}
+		if !reflect.DeepEqual(decoded.Sources, i.Sources) {
+			t.Errorf("%s: %v is not equal to expected %v", name, decoded.Sources, i.Sources)
+		}
+
+		// The index encoded before delta encoding has absolute positions.
+		buf.Reset()
+		if err := codec.encoder(buf).Encode(i); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		decoded, err = Decode(codec.decoder(buf))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(decoded.Index, i.Index) {
+			t.Errorf("%s: %v is not equal to expected %v", name, decoded.Index, i.Index)
+		}
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	i := NewMemoryIndex()
+	if err := i.Add("appl", 0, Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	encode := func(encoder func(buf *bytes.Buffer) Encoder, stream bool) []byte {
+		buf := &bytes.Buffer{}
+		var err error
+		if stream {
+			err = EncodeStream(i, encoder(buf))
+		} else {
+			err = i.Encode(encoder(buf))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	gobEncoder := func(buf *bytes.Buffer) Encoder { return gob.NewEncoder(buf) }
+	jsonEncoder := func(buf *bytes.Buffer) Encoder { return json.NewEncoder(buf) }
+	gobDecoder := func(data []byte) Decoder { return gob.NewDecoder(bytes.NewReader(data)) }
+	jsonDecoder := func(data []byte) Decoder { return json.NewDecoder(bytes.NewReader(data)) }
+
+	gobData := encode(gobEncoder, false)
+	jsonData := encode(jsonEncoder, false)
+	gobStream := encode(gobEncoder, true)
+	jsonStream := encode(jsonEncoder, true)
+
+	for name, test := range map[string]struct {
+		decode   func(decoder Decoder) (*MemoryIndex, error)
+		decoder  Decoder
+		expected error
+	}{
+		"empty gob":             {Decode, gobDecoder(nil), ErrIndexTruncated},
+		"truncated gob":         {Decode, gobDecoder(gobData[:len(gobData)/2]), ErrIndexTruncated},
+		"truncated json":        {Decode, jsonDecoder(jsonData[:len(jsonData)/2]), ErrIndexTruncated},
+		"json as gob":           {Decode, gobDecoder(jsonData), ErrIndexFormat},
+		"gob as json":           {Decode, jsonDecoder(gobData), ErrIndexFormat},
+		"truncated gob stream":  {DecodeStream, gobDecoder(gobStream[:len(gobStream)-2]), ErrIndexTruncated},
+		"truncated json stream": {DecodeStream, jsonDecoder(jsonStream[:len(jsonStream)-3]), ErrIndexTruncated},
+	} {
+		if _, err := test.decode(test.decoder); !errors.Is(err, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", name, err, test.expected)
+		}
+	}
+}
+
+// BenchmarkMemoryIndex_Encode reports the size of the encoded index with dense postings with absolute and delta
+// encoded positions.
+func BenchmarkMemoryIndex_Encode(b *testing.B) {
+	i := NewMemoryIndex()
+	for document := 0; document < 100; document++ {
+		source := Source{Name: fmt.Sprintf("file%d", document)}
+		for position := 0; position < 10000; position++ {
+			if err := i.Add(fmt.Sprintf("token%d", position%10), 100000+position, source); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	for name, encode := range map[string]func(encoder Encoder) error{
+		"absolute": func(encoder Encoder) error { return encoder.Encode(i) },
+		"delta":    i.Encode,
+	} {
+		b.Run(name, func(b *testing.B) {
+			var size int
+			for n := 0; n < b.N; n++ {
+				buf := &bytes.Buffer{}
+				if err := encode(gob.NewEncoder(buf)); err != nil {
+					b.Fatal(err)
+				}
+				size = buf.Len()
+			}
+			b.ReportMetric(float64(size), "file-bytes")
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MetadataEngine is the engine keeping the metadata of the documents, e.g. to order the results by the metadata field.
+type MetadataEngine interface {
+	// KeepsMetadata checks if the documents returned by the engine carry the metadata they are added with.
+	KeepsMetadata() bool
+}
+
+// keepsMetadata checks if the engine returns the documents with their metadata.
+func keepsMetadata(engine IndexEngine) bool {
+	metadataEngine, ok := engine.(MetadataEngine)
+	return ok && metadataEngine.KeepsMetadata()
+}
+
+// MetadataBoost multiplies the score of the documents whose metadata field has the value by the factor, e.g. to prefer
+// the documents tagged as official without excluding the others as the filters do.
+type MetadataBoost struct {
+	Field  string
+	Value  string
+	Factor float64
+}
+
+// matches checks if the metadata field of the document has the value.
+func (b MetadataBoost) matches(source *Source) bool {
+	value, ok := source.Metadata[b.Field]
+	return ok && value == b.Value
+}
+
+// boost multiplies the scores of the results matching the boosts of the options and orders the results by the score
+// again. The factors of several matching boosts are multiplied.
+func (o SearchOptions) boost(results []Result) {
+	if len(o.Boosts) == 0 {
+		return
+	}
+	for k := range results {
+		for _, boost := range o.Boosts {
+			if boost.matches(results[k].Document) {
+				results[k].Score *= boost.Factor
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return ranksBefore(&results[i], &results[j])
+	})
+}
+
+// boostsKey returns the key of the query cache distinguishing the searches with different boosts.
+func boostsKey(boosts []MetadataBoost) string {
+	keys := make([]string, 0, len(boosts))
+	for _, boost := range boosts {
+		keys = append(keys, fmt.Sprintf("%q=%q^%g", boost.Field, boost.Value, boost.Factor))
+	}
+	return strings.Join(keys, ",")
+}
+
+// SortByField returns the options ordering the results by the numeric value of the metadata field in the direction,
+// OrderAsc or OrderDesc, e.g. by the price in the catalog. The documents are matched by the query as usual, the
+// documents without the value or with the value which is not a number follow the others in both directions. The
+// results with the same value keep the order by the score. The search returns ErrNotSupported if the engine does not
+// keep the metadata, e.g. DbIndex.
+func (o SearchOptions) SortByField(field string, order string) SearchOptions {
+	o.OrderBy = OrderByField
+	o.SortField = field
+	o.Order = order
+	return o
+}
+
+// sortByField orders the results by the numeric value of the metadata field, the results without the value are last.
+func (o SearchOptions) sortByField(results []Result) {
+	ascending := o.ascending()
+	values := make(map[*Source]float64, len(results))
+	for _, result := range results {
+		value, err := strconv.ParseFloat(result.Document.Metadata[o.SortField], 64)
+		if err == nil && !math.IsNaN(value) {
+			values[result.Document] = value
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, aOk := values[results[i].Document]
+		b, bOk := values[results[j].Document]
+		if !aOk || !bOk {
+			return aOk && !bOk
+		}
+		if ascending {
+			return a < b
+		}
+		return a > b
+	})
+}
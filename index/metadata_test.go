@@ -0,0 +1,103 @@
+package index
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndex_SearchMetadataBoost(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithQueryCache(10))
+	for _, document := range []struct {
+		source Source
+		text   string
+	}{
+		{Source{Name: "blog", Metadata: map[string]string{"tag": "community"}}, "apple apple apple"},
+		{Source{Name: "forum"}, "apple apple"},
+		{Source{Name: "manual", Metadata: map[string]string{"tag": "official"}}, "apple"},
+	} {
+		if err := i.AddDocument(document.source, strings.NewReader(document.text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	for _, test := range []struct {
+		boosts   []MetadataBoost
+		expected []string
+	}{
+		{nil, []string{"blog", "forum", "manual"}},
+		{[]MetadataBoost{{Field: "tag", Value: "official", Factor: 5}}, []string{"manual", "blog", "forum"}},
+		{[]MetadataBoost{{Field: "tag", Value: "official", Factor: 2.5}}, []string{"blog", "manual", "forum"}},
+		{[]MetadataBoost{{Field: "tag", Value: "unknown", Factor: 5}}, []string{"blog", "forum", "manual"}},
+	} {
+		results, err := i.SearchWithOptions("apple", SearchOptions{Boosts: test.boosts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: %v is not equal to expected %v", test.boosts, actual, test.expected)
+		}
+	}
+}
+
+func TestIndex_SearchSortByField(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithQueryCache(10))
+	for _, document := range []struct {
+		name  string
+		price string
+		text  string
+	}{
+		{"cheap", "1.5", "apple"},
+		{"expensive", "20", "apple apple"},
+		{"free", "", "apple apple apple"},
+		{"medium", "7", "apple"},
+		{"unpriced", "n/a", "apple apple apple apple"},
+		{"other", "3", "banana"},
+	} {
+		source := Source{Name: document.name}
+		if document.price != "" {
+			source.Metadata = map[string]string{"price": document.price}
+		}
+		if err := i.AddDocument(source, strings.NewReader(document.text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	for _, test := range []struct {
+		order    string
+		expected []string
+	}{
+		{OrderAsc, []string{"cheap", "medium", "expensive", "unpriced", "free"}},
+		{OrderDesc, []string{"expensive", "medium", "cheap", "unpriced", "free"}},
+		{"", []string{"expensive", "medium", "cheap", "unpriced", "free"}},
+	} {
+		results, err := i.SearchWithOptions("apple", SearchOptions{}.SortByField("price", test.order))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.order, actual, test.expected)
+		}
+	}
+
+	if _, err := i.SearchWithOptions("apple", SearchOptions{OrderBy: OrderByField}); !errors.Is(err, ErrUnknownOrder) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownOrder)
+	}
+
+	i = &Index{engine: &emptyEngine{}}
+	options := SearchOptions{}.SortByField("price", OrderAsc)
+	if _, err := i.SearchWithOptions("apple", options); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+}
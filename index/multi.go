@@ -0,0 +1,368 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DuplicatePolicy is the handling of the documents with the same name found in several engines of MultiEngine.
+type DuplicatePolicy int
+
+const (
+	// MergeDuplicates treats the documents with the same name as the same document, so the document is returned and
+	// ranked once with the occurrences from all engines. The positions are kept per engine: the positions from every
+	// later engine holding the name are shifted by enginePositionGap after the ones of the earlier engine, so the
+	// occurrences of all engines are counted and no phrase matches across the engines.
+	MergeDuplicates DuplicatePolicy = iota
+	// SuffixDuplicates treats the documents with the same name as different documents. The name of the document found
+	// in several engines gets the suffix with the number of the engine starting from 2 for the second engine holding
+	// the name, e.g. `file1` and `file1#2`. The name from the first engine is kept as is. The engines holding the name
+	// are found by the names of all their documents, so the suffix is the same for every query. The engine which can
+	// not list its documents is considered holding every name.
+	SuffixDuplicates
+)
+
+// ParseDuplicatePolicy returns the policy by its name, `merge` or `suffix`.
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	switch name {
+	case "merge":
+		return MergeDuplicates, nil
+	case "suffix":
+		return SuffixDuplicates, nil
+	}
+	return 0, fmt.Errorf("unknown duplicate policy %s", name)
+}
+
+// enginePositionGap is the shift of the positions of the document from every later engine holding its name under
+// MergeDuplicates policy, it exceeds the positions of any document.
+const enginePositionGap = 1 << 24
+
+// MultiEngine is the read-only engine federating several engines, e.g. the index files built separately, into one
+// index. The occurrences of the tokens are collected from all engines before ranking, the documents with the same name
+// are handled according to the duplicate policy. The lengths, the retained texts and the snippets of the documents are
+// read from the engines holding them, e.g. for BM25 range algorithm. Create it with NewMultiEngine function.
+type MultiEngine struct {
+	engines    []IndexEngine
+	duplicates DuplicatePolicy
+
+	// names are the names of the documents of every engine listed once, nil for the engine which can not list its
+	// documents.
+	names     []map[string]bool
+	namesOnce sync.Once
+	namesErr  error
+}
+
+// NewMultiEngine returns the engine searching over all engines.
+func NewMultiEngine(duplicates DuplicatePolicy, engines ...IndexEngine) *MultiEngine {
+	return &MultiEngine{
+		engines:    engines,
+		duplicates: duplicates,
+	}
+}
+
+// Add is not supported, the documents are added to the federated engines.
+func (m *MultiEngine) Add(token string, position int, source Source) error {
+	return ErrNotSupported
+}
+
+// KeepsMetadata checks if all engines keep the metadata of the documents.
+func (m *MultiEngine) KeepsMetadata() bool {
+	for _, engine := range m.engines {
+		if !keepsMetadata(engine) {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the occurrences of the tokens in all engines.
+func (m *MultiEngine) Get(tokens []string) (map[string]Occurrences, error) {
+	found := make([]map[string]Occurrences, 0, len(m.engines))
+	for _, engine := range m.engines {
+		occurrencesList, err := engine.Get(tokens)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, occurrencesList)
+	}
+	return m.merge(found)
+}
+
+// loadNames lists the names of the documents of all engines once.
+func (m *MultiEngine) loadNames() error {
+	m.namesOnce.Do(m.listNames)
+	return m.namesErr
+}
+
+// listNames lists the names of the documents of every engine which can list them.
+func (m *MultiEngine) listNames() {
+	m.names = make([]map[string]bool, len(m.engines))
+	for n, engine := range m.engines {
+		lister, ok := engine.(DocumentLister)
+		if !ok {
+			continue
+		}
+		list, err := lister.ListDocuments(ListOptions{Order: OrderByName})
+		if errors.Is(err, ErrNotSupported) {
+			continue
+		}
+		if err != nil {
+			m.namesErr = err
+			return
+		}
+		m.names[n] = make(map[string]bool, len(list.Documents))
+		for _, source := range list.Documents {
+			m.names[n][source.Name] = true
+		}
+	}
+}
+
+// merge merges the occurrences found by every engine in the order of the engines, the documents held by several
+// engines are merged or renamed by the duplicate policy.
+func (m *MultiEngine) merge(found []map[string]Occurrences) (map[string]Occurrences, error) {
+	if err := m.loadNames(); err != nil {
+		return nil, err
+	}
+	sources := map[string]*Source{}
+	results := map[string]Occurrences{}
+	for n, occurrencesList := range found {
+		for token, occurrences := range occurrencesList {
+			if results[token] == nil {
+				results[token] = Occurrences{}
+			}
+			for source, positions := range occurrences {
+				name := m.name(source.Name, n)
+				merged, ok := sources[name]
+				if !ok {
+					renamed := *source
+					renamed.Name = name
+					merged = &renamed
+					sources[name] = merged
+				} else if source.ModTime.After(merged.ModTime) {
+					merged.ModTime = source.ModTime
+				}
+				results[token][merged] = mergePositions(results[token][merged], m.shift(positions, source.Name, n))
+			}
+		}
+	}
+	return results, nil
+}
+
+// holds checks if the engine n holds the document with the name. The engine which can not list its documents is
+// considered holding every name.
+func (m *MultiEngine) holds(n int, name string) bool {
+	return m.names[n] == nil || m.names[n][name]
+}
+
+// earlier returns the number of the engines before the engine n holding the name.
+func (m *MultiEngine) earlier(name string, n int) int {
+	earlier := 0
+	for k := 0; k < n; k++ {
+		if m.holds(k, name) {
+			earlier++
+		}
+	}
+	return earlier
+}
+
+// name returns the name of the document of the engine n suffixed by the number of the engines holding the name up to
+// the engine n.
+func (m *MultiEngine) name(name string, n int) string {
+	if m.duplicates != SuffixDuplicates {
+		return name
+	}
+	earlier := m.earlier(name, n)
+	if earlier == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s#%d", name, earlier+1)
+}
+
+// engineName returns the name of the document of the engine n returned by Get as the name, false if the engine n does
+// not hold such document.
+func (m *MultiEngine) engineName(name string, n int) (string, bool) {
+	if m.holds(n, name) && m.name(name, n) == name {
+		return name, true
+	}
+	idx := strings.LastIndex(name, "#")
+	if idx > 0 && m.holds(n, name[:idx]) && m.name(name[:idx], n) == name {
+		return name[:idx], true
+	}
+	return "", false
+}
+
+// shift returns the positions of the document of the engine n shifted by the engines holding the name before it, the
+// positions of the first engine are kept as is.
+func (m *MultiEngine) shift(positions []int, name string, n int) []int {
+	if m.duplicates != MergeDuplicates {
+		return positions
+	}
+	earlier := m.earlier(name, n)
+	if earlier == 0 {
+		return positions
+	}
+	shifted := make([]int, len(positions))
+	for j, position := range positions {
+		shifted[j] = position + earlier*enginePositionGap
+	}
+	return shifted
+}
+
+// Lengths sums the lengths of the documents held by several engines. All engines must implement LengthsEngine
+// interface, otherwise ErrNotSupported is returned.
+func (m *MultiEngine) Lengths(names []string) (map[string]int, error) {
+	if err := m.loadNames(); err != nil {
+		return nil, err
+	}
+	lengths := make(map[string]int, len(names))
+	for n, engine := range m.engines {
+		lengthsEngine, ok := engine.(LengthsEngine)
+		if !ok {
+			return nil, ErrNotSupported
+		}
+		// renamed are the names of the documents of the engine by their names in the engine.
+		renamed := map[string][]string{}
+		for _, name := range names {
+			if engineName, ok := m.engineName(name, n); ok {
+				renamed[engineName] = append(renamed[engineName], name)
+			}
+		}
+		engineNames := make([]string, 0, len(renamed))
+		for engineName := range renamed {
+			engineNames = append(engineNames, engineName)
+		}
+		engineLengths, err := lengthsEngine.Lengths(engineNames)
+		if err != nil {
+			return nil, err
+		}
+		for engineName, length := range engineLengths {
+			for _, name := range renamed[engineName] {
+				lengths[name] += length
+			}
+		}
+	}
+	return lengths, nil
+}
+
+// SetContent is not supported, the texts are retained by the federated engines.
+func (m *MultiEngine) SetContent(name string, content string) error {
+	return ErrNotSupported
+}
+
+// Content returns the retained text of the document, the texts of the document held by several engines are joined by
+// the newline. ErrUnknownDocument is returned if no engine retains the text of the document.
+func (m *MultiEngine) Content(name string) (string, error) {
+	if err := m.loadNames(); err != nil {
+		return "", err
+	}
+	var contents []string
+	for n, engine := range m.engines {
+		store, ok := engine.(ContentStore)
+		if !ok {
+			continue
+		}
+		engineName, ok := m.engineName(name, n)
+		if !ok {
+			continue
+		}
+		content, err := store.Content(engineName)
+		if errors.Is(err, ErrUnknownDocument) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		contents = append(contents, content)
+	}
+	if len(contents) == 0 {
+		return "", ErrUnknownDocument
+	}
+	return strings.Join(contents, "\n"), nil
+}
+
+// AddWords is not supported, the words are stored by the federated engines.
+func (m *MultiEngine) AddWords(name string, position int, words []string) error {
+	return ErrNotSupported
+}
+
+// Snippet returns the excerpt of the document from the first engine storing its words with some of the positions. The
+// positions of the document merged from several engines are shifted back to the positions of every engine.
+// ErrUnknownDocument is returned if no engine stores the words of the document.
+func (m *MultiEngine) Snippet(source *Source, positions []int, markers Markers) (string, error) {
+	if err := m.loadNames(); err != nil {
+		return "", err
+	}
+	for n, engine := range m.engines {
+		provider, ok := engine.(SnippetProvider)
+		if !ok {
+			continue
+		}
+		engineName, ok := m.engineName(source.Name, n)
+		if !ok {
+			continue
+		}
+		shift := 0
+		if m.duplicates == MergeDuplicates {
+			shift = m.earlier(engineName, n) * enginePositionGap
+		}
+		var enginePositions []int
+		for _, position := range positions {
+			if position >= shift && position < shift+enginePositionGap {
+				enginePositions = append(enginePositions, position-shift)
+			}
+		}
+		if len(enginePositions) == 0 {
+			continue
+		}
+		renamed := *source
+		renamed.Name = engineName
+		snippet, err := provider.Snippet(&renamed, enginePositions, markers)
+		if errors.Is(err, ErrUnknownDocument) {
+			continue
+		}
+		return snippet, err
+	}
+	return "", ErrUnknownDocument
+}
+
+// Generation joins the generations of all engines, it is empty if the generation of any engine is unknown.
+func (m *MultiEngine) Generation() string {
+	generations := make([]string, 0, len(m.engines))
+	for _, engine := range m.engines {
+		generational, ok := engine.(GenerationEngine)
+		if !ok || generational.Generation() == "" {
+			return ""
+		}
+		generations = append(generations, generational.Generation())
+	}
+	return fmt.Sprintf("%d:%s", m.duplicates, strings.Join(generations, ","))
+}
+
+// Close closes all engines.
+func (m *MultiEngine) Close() {
+	for _, engine := range m.engines {
+		engine.Close()
+	}
+}
+
+// mergePositions returns the sorted positions of both lists without duplicates.
+func mergePositions(positions []int, added []int) []int {
+	if len(positions) == 0 {
+		return append([]int{}, added...)
+	}
+	seen := make(map[int]bool, len(positions))
+	for _, position := range positions {
+		seen[position] = true
+	}
+	for _, position := range added {
+		if !seen[position] {
+			seen[position] = true
+			positions = append(positions, position)
+		}
+	}
+	sort.Ints(positions)
+	return positions
+}
@@ -0,0 +1,156 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMultiEngine_Get(t *testing.T) {
+	build := func(documents map[string]string) IndexEngine {
+		engine := NewMemoryIndex()
+		i := NewIndex(engine, nil)
+		for name, text := range documents {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+		return engine
+	}
+	first := build(map[string]string{"file1": "apple banana", "file2": "banana"})
+	second := build(map[string]string{"file1": "apple apple cherry"})
+
+	for _, test := range []struct {
+		duplicates DuplicatePolicy
+		expected   map[string]map[string][]int
+	}{
+		{
+			MergeDuplicates,
+			map[string]map[string][]int{
+				"appl":   {"file1": {0, enginePositionGap, enginePositionGap + 1}},
+				"cherri": {"file1": {enginePositionGap + 2}},
+			},
+		},
+		{
+			SuffixDuplicates,
+			map[string]map[string][]int{
+				"appl":   {"file1": {0}, "file1#2": {0, 1}},
+				"cherri": {"file1#2": {2}},
+			},
+		},
+	} {
+		occurrencesList, err := NewMultiEngine(test.duplicates, first, second).Get([]string{"appl", "cherri"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := map[string]map[string][]int{}
+		for token, occurrences := range occurrencesList {
+			actual[token] = map[string][]int{}
+			for source, positions := range occurrences {
+				actual[token][source.Name] = positions
+			}
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: %v is not equal to expected %v", test.duplicates, actual, test.expected)
+		}
+
+		results, err := NewIndex(NewMultiEngine(test.duplicates, first, second), nil).Search("apple")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != len(test.expected["appl"]) {
+			t.Errorf("%v: %v is not equal to expected %v", test.duplicates, len(results), len(test.expected["appl"]))
+		}
+	}
+
+	// The phrase does not match across the engines holding the merged document.
+	third := build(map[string]string{"file1": "plum plum cherry"})
+	results, err := NewIndex(NewMultiEngine(MergeDuplicates, first, third), nil, WithStrictPhrases()).Search(`"banana cherry"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("%v is not equal to expected %v", len(results), 0)
+	}
+
+	// The suffix does not depend on the engines holding the name among the found ones.
+	occurrencesList, err := NewMultiEngine(SuffixDuplicates, first, second).Get([]string{"cherri"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for source := range occurrencesList["cherri"] {
+		if source.Name != "file1#2" {
+			t.Errorf("%v is not equal to expected %v", source.Name, "file1#2")
+		}
+	}
+
+	if err := NewMultiEngine(MergeDuplicates, first).Add("appl", 0, Source{Name: "file3"}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+}
+
+func TestMultiEngine_Documents(t *testing.T) {
+	build := func(documents map[string]string) IndexEngine {
+		engine := NewMemoryIndex()
+		i := NewIndex(engine, nil, WithRetainedContent(), WithSnippets())
+		for name, text := range documents {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+		return engine
+	}
+	first := build(map[string]string{"file1": "apple banana", "file2": "banana"})
+	second := build(map[string]string{"file1": "apple apple cherry"})
+
+	for _, test := range []struct {
+		duplicates DuplicatePolicy
+		lengths    map[string]int
+		contents   map[string]string
+	}{
+		{
+			MergeDuplicates,
+			map[string]int{"file1": 5, "file2": 1},
+			map[string]string{"file1": "apple banana\napple apple cherry"},
+		},
+		{
+			SuffixDuplicates,
+			map[string]int{"file1": 2, "file1#2": 3, "file2": 1},
+			map[string]string{"file1": "apple banana", "file1#2": "apple apple cherry"},
+		},
+	} {
+		engine := NewMultiEngine(test.duplicates, first, second)
+		lengths, err := engine.Lengths([]string{"file1", "file1#2", "file2", "file3"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(lengths, test.lengths) {
+			t.Errorf("%v: %v is not equal to expected %v", test.duplicates, lengths, test.lengths)
+		}
+		for name, expected := range test.contents {
+			content, err := engine.Content(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content != expected {
+				t.Errorf("%v: %q is not equal to expected %q", test.duplicates, content, expected)
+			}
+		}
+		if _, err := engine.Content("file3"); !errors.Is(err, ErrUnknownDocument) {
+			t.Errorf("%v: %v is not equal to expected %v", test.duplicates, err, ErrUnknownDocument)
+		}
+
+		// The ranker needing the lengths and the snippets work over the federated engines.
+		i := NewIndex(engine, nil, WithRangeAlgorithm(NewBM25(1.2, 0.75)), WithSnippets())
+		results, err := i.Search("cherry")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Snippet != "apple apple <mark>cherry</mark>" {
+			t.Errorf("%v: %v is not equal to expected snippet %q", test.duplicates, results, "apple apple <mark>cherry</mark>")
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// NameCollision is the handling of the documents added with the name of the document already added to the index.
+type NameCollision string
+
+// Handlings of the documents with the same name.
+const (
+	// CollisionSerialize indexes the documents with the same name one after another, the positions of every field of
+	// the later document continue the positions of the earlier one after the gap, so the document is indexed as their
+	// concatenation without the phrases spanning the join.
+	CollisionSerialize NameCollision = "serialize"
+	// CollisionReject fails adding the document with ErrDuplicateName.
+	CollisionReject NameCollision = "reject"
+)
+
+// ErrDuplicateName is returned when the document is added with the name of the document already added to the index
+// with CollisionReject handling.
+var ErrDuplicateName = errors.New("document with the same name is already added")
+
+// ParseNameCollision returns the handling of the documents with the same name by its case-insensitive name.
+func ParseNameCollision(name string) (NameCollision, error) {
+	switch collision := NameCollision(strings.ToLower(name)); collision {
+	case CollisionSerialize, CollisionReject:
+		return collision, nil
+	default:
+		return "", fmt.Errorf("unknown name collision handling %s, expected serialize or reject", name)
+	}
+}
+
+// WithNameCollision sets the handling of the documents added with AddSource, AddDocument and AddFields functions with
+// the name of the document already added to the index, e.g. the files of the concurrent build mapped to the same
+// name. Without the option such documents are indexed concurrently and their positions interleave. The index keeps
+// the names of the added documents until they are removed or deleted by prefix through the index, the engine is
+// swapped or the index is closed. The documents already stored in the engine are not checked.
+func WithNameCollision(collision NameCollision) Option {
+	return func(i *Index) {
+		i.names = &nameRegistry{
+			collision: collision,
+			busy:      map[string]chan struct{}{},
+			positions: map[string]map[string]int{},
+		}
+	}
+}
+
+// nameRegistry tracks the documents being added and the positions following the added ones.
+type nameRegistry struct {
+	m         sync.Mutex
+	collision NameCollision
+	// busy are the names of the documents being added, the channel is closed when the document is added.
+	busy map[string]chan struct{}
+	// positions are the positions following the last word of every field of the added documents.
+	positions map[string]map[string]int
+}
+
+// claim waits until the document with the same name is added and returns the positions following its fields after
+// positionGap, so the later document does not continue the phrases of the earlier one. It
+// returns ErrDuplicateName if the name is already added and the collisions are rejected. The claimed name must be
+// released. Nothing is tracked if the option is not set.
+func (n *nameRegistry) claim(name string) (map[string]int, error) {
+	positions := map[string]int{}
+	if n == nil {
+		return positions, nil
+	}
+	for {
+		n.m.Lock()
+		busy, isBusy := n.busy[name]
+		_, isAdded := n.positions[name]
+		if n.collision == CollisionReject && (isBusy || isAdded) {
+			n.m.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateName, name)
+		}
+		if isBusy {
+			n.m.Unlock()
+			<-busy
+			continue
+		}
+		n.busy[name] = make(chan struct{})
+		for field, position := range n.positions[name] {
+			positions[field] = position + positionGap
+		}
+		n.m.Unlock()
+		return positions, nil
+	}
+}
+
+// release remembers the positions following the fields of the added document and lets the next document with the
+// same name to be added. Nil positions are not remembered, e.g. the document is skipped or fails, so the name is not
+// recorded as added.
+func (n *nameRegistry) release(name string, positions map[string]int) {
+	if n == nil {
+		return
+	}
+	n.m.Lock()
+	defer n.m.Unlock()
+	if positions != nil {
+		n.positions[name] = positions
+	}
+	close(n.busy[name])
+	delete(n.busy, name)
+}
+
+// forget forgets the positions of the removed document, so the document added with the same name starts from the
+// beginning.
+func (n *nameRegistry) forget(name string) {
+	if n == nil {
+		return
+	}
+	n.m.Lock()
+	defer n.m.Unlock()
+	delete(n.positions, name)
+}
+
+// forgetPrefix forgets the positions of the documents deleted by the prefix of their names.
+func (n *nameRegistry) forgetPrefix(prefix string) {
+	if n == nil {
+		return
+	}
+	n.m.Lock()
+	defer n.m.Unlock()
+	for name := range n.positions {
+		if strings.HasPrefix(name, prefix) {
+			delete(n.positions, name)
+		}
+	}
+}
+
+// forgetAll forgets the positions of all added documents, e.g. when the engine is swapped or no documents can be
+// added anymore.
+func (n *nameRegistry) forgetAll() {
+	if n == nil {
+		return
+	}
+	n.m.Lock()
+	defer n.m.Unlock()
+	n.positions = map[string]map[string]int{}
+}
@@ -0,0 +1,174 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestIndex_AddSourceNameCollision(t *testing.T) {
+	for _, collision := range []NameCollision{CollisionSerialize, CollisionReject} {
+		engine := NewMemoryIndex()
+		i := NewIndex(engine, nil, WithNameCollision(collision))
+		texts := []string{"apple banana cherry", "durian orange lemon"}
+		errs := make([]error, len(texts))
+		var wg sync.WaitGroup
+		for k, text := range texts {
+			wg.Add(1)
+			go func(k int, text string) {
+				defer wg.Done()
+				errs[k] = i.AddSource("same", bytes.NewBufferString(text))
+			}(k, text)
+		}
+		wg.Wait()
+		i.Close()
+
+		var positions []int
+		for _, occurrences := range engine.Index {
+			positions = append(positions, occurrences["same"]...)
+		}
+		sort.Ints(positions)
+
+		switch collision {
+		case CollisionSerialize:
+			for _, err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			// The positions of the later document continue the positions of the earlier one after the gap.
+			if expected := []int{0, 1, 2, 103, 104, 105}; !reflect.DeepEqual(positions, expected) {
+				t.Errorf("%s: %v is not equal to expected %v", collision, positions, expected)
+			}
+		case CollisionReject:
+			rejected := 0
+			for _, err := range errs {
+				if errors.Is(err, ErrDuplicateName) {
+					rejected++
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if rejected != 1 {
+				t.Errorf("%s: %d is not equal to expected %d", collision, rejected, 1)
+			}
+			if expected := []int{0, 1, 2}; !reflect.DeepEqual(positions, expected) {
+				t.Errorf("%s: %v is not equal to expected %v", collision, positions, expected)
+			}
+		}
+	}
+}
+
+func TestIndex_AddFieldsNameCollision(t *testing.T) {
+	engine := NewMemoryIndex()
+	i := NewIndex(engine, nil, WithNameCollision(CollisionSerialize), WithFields("title"), WithStrictPhrases())
+	for _, fields := range []map[string]string{
+		{"title": "red apple", "body": "green pear"},
+		{"title": "banana split", "body": "ripe plum"},
+	} {
+		if err := i.AddFields(Source{Name: "same"}, fields); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	// Every field continues after the gap.
+	for token, expected := range map[string][]int{
+		"title" + fieldSeparator + "appl":   {1},
+		"title" + fieldSeparator + "banana": {102},
+		"pear":                              {1},
+		"plum":                              {103},
+	} {
+		if actual := engine.Index[token]["same"]; !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", token, actual, expected)
+		}
+	}
+	for query, expected := range map[string]int{`"pear ripe"`: 0, `title:"apple banana"`: 0, `"ripe plum"`: 1} {
+		results, err := i.Search(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != expected {
+			t.Errorf("%s: %d is not equal to expected %d", query, len(results), expected)
+		}
+	}
+}
+
+func TestIndex_DeleteByPrefixNameCollision(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithNameCollision(CollisionReject))
+	defer i.Close()
+	if err := i.AddSource("dir/file1", bytes.NewBufferString("apple")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.DeleteByPrefix("dir/"); err != nil {
+		t.Fatal(err)
+	}
+	// The deleted name is not rejected.
+	if err := i.AddSource("dir/file1", bytes.NewBufferString("banana")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The names added to the previous engine are not rejected.
+	i.SwapEngine(NewMemoryIndex())
+	if err := i.AddSource("dir/file1", bytes.NewBufferString("cherry")); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.AddSource("dir/file1", bytes.NewBufferString("cherry")); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("%v is not equal to expected %v", err, ErrDuplicateName)
+	}
+}
+
+func TestIndex_SkippedNameCollision(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithNameCollision(CollisionReject), WithDeduplication())
+	defer i.Close()
+	if err := i.AddSource("file1", bytes.NewBufferString("apple")); err != nil {
+		t.Fatal(err)
+	}
+	// The duplicate is skipped, so its name is not added.
+	if err := i.AddSource("file2", bytes.NewBufferString("apple")); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.AddSource("file2", bytes.NewBufferString("banana")); err != nil {
+		t.Errorf("%v is not equal to expected %v", err, nil)
+	}
+	if err := i.AddFields(Source{Name: "file3"}, map[string]string{"title": "apple"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.AddFields(Source{Name: "file4"}, map[string]string{"title": "apple"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.AddFields(Source{Name: "file4"}, map[string]string{"title": "banana"}); err != nil {
+		t.Errorf("%v is not equal to expected %v", err, nil)
+	}
+
+	// The failed document is not added either.
+	errFailed := errors.New("failed")
+	engine := &failingEngine{err: errFailed}
+	failing := NewIndex(engine, nil, WithNameCollision(CollisionReject))
+	defer failing.Close()
+	if err := failing.AddSourceSync("file1", bytes.NewBufferString("apple")); !errors.Is(err, errFailed) {
+		t.Errorf("%v is not equal to expected %v", err, errFailed)
+	}
+	engine.err = nil
+	if err := failing.AddSourceSync("file1", bytes.NewBufferString("apple")); err != nil {
+		t.Errorf("%v is not equal to expected %v", err, nil)
+	}
+}
+
+func TestParseNameCollision(t *testing.T) {
+	for name, expected := range map[string]NameCollision{"serialize": CollisionSerialize, "Reject": CollisionReject} {
+		actual, err := ParseNameCollision(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("%s: %v is not equal to expected %v", name, actual, expected)
+		}
+	}
+	if _, err := ParseNameCollision("merge"); err == nil {
+		t.Error("unknown name collision handling must fail")
+	}
+}
@@ -0,0 +1,41 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operator combines the bare query terms.
+type Operator string
+
+// Operators between the bare query terms.
+const (
+	// OperatorAnd finds the documents with all the terms, it is the default operator.
+	OperatorAnd Operator = "AND"
+	// OperatorOr finds the documents with any of the terms, the documents with more terms usually rank higher.
+	OperatorOr Operator = "OR"
+)
+
+// ParseOperator returns the operator by its case-insensitive name, empty name is OperatorAnd.
+func ParseOperator(name string) (Operator, error) {
+	switch operator := Operator(strings.ToUpper(name)); operator {
+	case "":
+		return OperatorAnd, nil
+	case OperatorAnd, OperatorOr:
+		return operator, nil
+	default:
+		return "", fmt.Errorf("unknown operator %s, expected AND or OR", name)
+	}
+}
+
+// WithDefaultOperator sets the operator between the bare query terms, OperatorAnd by default.
+func WithDefaultOperator(operator Operator) Option {
+	return func(i *Index) {
+		i.operator = operator
+	}
+}
+
+// matches checks if the document matches the query terms combined with the default operator of the index.
+func (item *TmpResultItem) matches(tokens []string) bool {
+	return item.operator == OperatorOr || item.count >= len(tokens)
+}
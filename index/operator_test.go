@@ -0,0 +1,82 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIndex_SearchDefaultOperator(t *testing.T) {
+	for _, test := range []struct {
+		operator Operator
+		expected []string
+	}{
+		{"", []string{"file1"}},
+		{OperatorAnd, []string{"file1"}},
+		{OperatorOr, []string{"file1", "file2", "file3"}},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil, WithDefaultOperator(test.operator))
+		for name, text := range map[string]string{
+			"file1": "apple banana",
+			"file2": "apple orange",
+			"file3": "banana",
+		} {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+
+		results, err := i.Search("apple banana")
+		if err != nil {
+			t.Error(err)
+		}
+		actual := names(results)
+		sort.Strings(actual)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%q: %v is not equal to expected %v", test.operator, actual, test.expected)
+		}
+	}
+}
+
+func TestParseOperator(t *testing.T) {
+	for name, expected := range map[string]Operator{"": OperatorAnd, "or": OperatorOr, "AND": OperatorAnd} {
+		actual, err := ParseOperator(name)
+		if err != nil {
+			t.Error(err)
+		}
+		if actual != expected {
+			t.Errorf("%s is not equal to expected %s", actual, expected)
+		}
+	}
+	if _, err := ParseOperator("XOR"); err == nil {
+		t.Error("unknown operator is parsed")
+	}
+}
+
+func TestIndex_SearchDefaultOperatorCounts(t *testing.T) {
+	s1 := &Source{Name: "file1"}
+	s2 := &Source{Name: "file2"}
+	engine := &countEngine{
+		counts: map[string]Counts{
+			"appl":   {s1: 2, s2: 1},
+			"banana": {s2: 1},
+		},
+	}
+	for operator, expected := range map[Operator][]string{
+		OperatorAnd: {"file2"},
+		OperatorOr:  {"file1", "file2"},
+	} {
+		i := &Index{engine: engine, countsOnly: true, operator: operator}
+		results, err := i.Search("apple banana")
+		if err != nil {
+			t.Error(err)
+		}
+		actual := names(results)
+		sort.Strings(actual)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", operator, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package index
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrUnknownOrder is returned when the order of the search results is not supported.
+var ErrUnknownOrder = errors.New("unknown order of results")
+
+// ErrUnknownDirection is returned when the direction of the order is neither OrderAsc nor OrderDesc.
+var ErrUnknownDirection = errors.New("unknown direction of order")
+
+// Orders of the search results.
+const (
+	// OrderByScore orders the results by the score of the range algorithm, it is the default order.
+	OrderByScore = "score"
+	// OrderByTime orders the results by the modification time of the documents, the most recent first.
+	OrderByTime = "time"
+	// OrderByName orders the results by the names of the documents alphabetically.
+	OrderByName = "name"
+	// OrderByField orders the results by the numeric value of the metadata field set with SortByField, the highest
+	// first.
+	OrderByField = "field"
+)
+
+// Directions of the order of the search results.
+const (
+	// OrderAsc orders the results from the lowest value to the highest one, it is the default for OrderByName.
+	OrderAsc = "asc"
+	// OrderDesc orders the results from the highest value to the lowest one, it is the default for OrderByScore and
+	// OrderByTime.
+	OrderDesc = "desc"
+)
+
+// SearchOptions restricts and orders the search results.
+type SearchOptions struct {
+	// Tenant restricts the search to the documents of the tenant, empty tenant searches over the whole engine.
+	Tenant string
+	// Since excludes the documents modified before the time, zero time does not restrict the search.
+	Since time.Time
+	// Until excludes the documents modified after the time, zero time does not restrict the search.
+	Until time.Time
+	// OrderBy is the order of the results, OrderByScore if it is empty.
+	OrderBy string
+	// Order is the direction of the order, OrderAsc or OrderDesc. The default direction depends on OrderBy.
+	Order string
+	// RestrictTo limits the search to the documents with the names, e.g. to search within the previous results.
+	// Nil does not restrict the search, the empty list matches no documents.
+	RestrictTo []string
+	// Language is the code of the language of the query registered with WithLanguage option. The query is stemmed and
+	// the stopwords are removed with the analyzer of the language, the default analyzer is used if it is empty.
+	Language string
+	// Boosts multiply the scores of the documents matching the metadata conditions, the other documents are kept.
+	Boosts []MetadataBoost
+	// SortField is the metadata field ordering the results with OrderByField.
+	SortField string
+	// Markers wrap the matched words of the snippets of the results, DefaultMarkers if they are empty.
+	Markers Markers
+}
+
+// TimeRangeEngine is the interface implemented by the engines which can filter the documents by the modification time
+// on their own, e.g. in the database query.
+type TimeRangeEngine interface {
+	// Between returns the view of the engine with the documents modified in the time range only.
+	// Zero since or until does not restrict the range.
+	Between(since time.Time, until time.Time) IndexEngine
+}
+
+// RestrictedEngine is the interface implemented by the engines which can restrict the documents by the names on their
+// own, e.g. in the database query.
+type RestrictedEngine interface {
+	// Restrict returns the view of the engine with the named documents only, nil names do not restrict it.
+	Restrict(names []string) IndexEngine
+}
+
+// SearchWithOptions searches query over the documents restricted by the options.
+// The documents without the modification time are excluded if the time range is set.
+func (i *Index) SearchWithOptions(query string, options SearchOptions) ([]Result, error) {
+	results, _, err := i.searchWithOptions(query, options, nil, nil)
+	return results, err
+}
+
+// SearchWithFacets searches query over the documents restricted by the options like SearchWithOptions and counts the
+// matching documents containing every query token, e.g. to show "apple (12), banana (8)" in UI.
+// The facets count all matching documents regardless of the limit of the results. The results are not cached.
+func (i *Index) SearchWithFacets(query string, options SearchOptions) ([]Result, Facets, error) {
+	return i.searchWithOptions(query, options, Facets{}, nil)
+}
+
+// searchWithOptions searches query over the engine restricted by the options. The facets are counted if they are not
+// nil, the partial results are allowed if partial is not nil.
+func (i *Index) searchWithOptions(query string, options SearchOptions, facets Facets,
+	partial *bool) ([]Result, Facets, error) {
+	switch options.OrderBy {
+	case "", OrderByScore, OrderByTime, OrderByName:
+	case OrderByField:
+		if options.SortField == "" {
+			return nil, nil, ErrUnknownOrder
+		}
+	default:
+		return nil, nil, ErrUnknownOrder
+	}
+	if options.Order != "" && options.Order != OrderAsc && options.Order != OrderDesc {
+		return nil, nil, ErrUnknownDirection
+	}
+	if options.RestrictTo != nil && len(options.RestrictTo) == 0 {
+		return []Result{}, facets, nil
+	}
+	generation := i.Generation()
+	engine, err := i.scoped(options.Tenant)
+	if err != nil {
+		return nil, nil, err
+	}
+	if options.OrderBy == OrderByField && !keepsMetadata(engine) {
+		return nil, nil, ErrNotSupported
+	}
+	if timeRangeEngine, ok := engine.(TimeRangeEngine); ok && options.timeRange() {
+		engine = timeRangeEngine.Between(options.Since, options.Until)
+	}
+	if restrictedEngine, ok := engine.(RestrictedEngine); ok && options.RestrictTo != nil {
+		engine = restrictedEngine.Restrict(options.RestrictTo)
+	}
+	if facets != nil {
+		results, err := i.searchEngine(engine, query, options, facets, partial)
+		if err != nil {
+			return nil, nil, err
+		}
+		return results, facets, nil
+	}
+	if partial != nil {
+		results, err := i.searchPartial(generation, engine, query, options, partial)
+		return results, nil, err
+	}
+	results, err := i.search(generation, engine, query, options)
+	return results, nil, err
+}
+
+func (o SearchOptions) timeRange() bool {
+	return !o.Since.IsZero() || !o.Until.IsZero()
+}
+
+// filter removes the documents modified out of the time range and the documents not listed in RestrictTo.
+func (o SearchOptions) filter(items map[*Source]*TmpResultItem) {
+	if o.timeRange() {
+		for source := range items {
+			if source.ModTime.IsZero() ||
+				!o.Since.IsZero() && source.ModTime.Before(o.Since) ||
+				!o.Until.IsZero() && source.ModTime.After(o.Until) {
+				delete(items, source)
+			}
+		}
+	}
+	if o.RestrictTo != nil {
+		names := make(map[string]bool, len(o.RestrictTo))
+		for _, name := range o.RestrictTo {
+			names[name] = true
+		}
+		for source := range items {
+			if !names[source.Name] {
+				delete(items, source)
+			}
+		}
+	}
+}
+
+// sort orders the ranked results if the order differs from the default one.
+func (o SearchOptions) sort(results []Result) {
+	var less func(a, b *Result) bool
+	switch o.OrderBy {
+	case OrderByTime:
+		less = func(a, b *Result) bool {
+			return a.Document.ModTime.Before(b.Document.ModTime)
+		}
+	case OrderByName:
+		less = func(a, b *Result) bool {
+			return a.Document.Name < b.Document.Name
+		}
+	case OrderByField:
+		o.sortByField(results)
+		return
+	default:
+		if !o.ascending() {
+			// The range algorithm orders the results by the score already.
+			return
+		}
+		less = func(a, b *Result) bool {
+			return a.Score < b.Score
+		}
+	}
+	ascending := o.ascending()
+	sort.SliceStable(results, func(i, j int) bool {
+		if ascending {
+			return less(&results[i], &results[j])
+		}
+		return less(&results[j], &results[i])
+	})
+}
+
+// rescores checks if the scores of the results of the range algorithm are changed by the options, e.g. by the metadata
+// boosts.
+func (o SearchOptions) rescores() bool {
+	return len(o.Boosts) > 0
+}
+
+// byScore checks if the results are ordered by the range algorithm, i.e. by score descending.
+func (o SearchOptions) byScore() bool {
+	return (o.OrderBy == "" || o.OrderBy == OrderByScore) && !o.ascending()
+}
+
+// ascending checks the direction of the order taking the default direction of OrderBy into account.
+func (o SearchOptions) ascending() bool {
+	if o.Order == "" {
+		return o.OrderBy == OrderByName
+	}
+	return o.Order == OrderAsc
+}
@@ -0,0 +1,124 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTimeTestIndex(t *testing.T) *Index {
+	engine := NewMemoryIndex()
+	for _, item := range []struct {
+		token    string
+		position int
+		source   Source
+	}{
+		{"appl", 0, Source{Name: "old", ModTime: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{"appl", 1, Source{Name: "old", ModTime: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{"appl", 0, Source{Name: "new", ModTime: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)}},
+		{"appl", 0, Source{Name: "unknown"}},
+	} {
+		if err := engine.Add(item.token, item.position, item.source); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Index{engine: engine}
+}
+
+func names(results []Result) []string {
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Document.Name)
+	}
+	return names
+}
+
+func TestIndex_SearchWithOptionsTimeRange(t *testing.T) {
+	i := newTimeTestIndex(t)
+
+	for _, test := range []struct {
+		options  SearchOptions
+		expected []string
+	}{
+		{SearchOptions{Since: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}, []string{"new"}},
+		{SearchOptions{Until: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}, []string{"old"}},
+		{SearchOptions{
+			Since: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+			Until: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		}, []string{"old", "new"}},
+		{SearchOptions{Since: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}, nil},
+	} {
+		results, err := i.SearchWithOptions("apple", test.options)
+		if err != nil {
+			t.Error(err)
+		}
+		if actual := names(results); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v is not equal to expected %v", actual, test.expected)
+		}
+	}
+}
+
+func TestIndex_SearchWithOptionsOrderByTime(t *testing.T) {
+	i := newTimeTestIndex(t)
+
+	results, err := i.SearchWithOptions("apple", SearchOptions{OrderBy: OrderByTime})
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []string{"new", "old", "unknown"}
+	if actual := names(results); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	if _, err := i.SearchWithOptions("apple", SearchOptions{OrderBy: "size"}); err != ErrUnknownOrder {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownOrder)
+	}
+}
+
+func TestIndex_SearchWithOptionsRestrictTo(t *testing.T) {
+	i := newTimeTestIndex(t)
+
+	for _, test := range []struct {
+		options  SearchOptions
+		expected []string
+	}{
+		{SearchOptions{RestrictTo: []string{"new", "unknown", "missing"}, OrderBy: OrderByTime}, []string{"new", "unknown"}},
+		{SearchOptions{RestrictTo: []string{"old"}}, []string{"old"}},
+		{SearchOptions{RestrictTo: []string{}}, nil},
+		{SearchOptions{OrderBy: OrderByTime}, []string{"new", "old", "unknown"}},
+	} {
+		results, err := i.SearchWithOptions("apple", test.options)
+		if err != nil {
+			t.Error(err)
+		}
+		if actual := names(results); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: %v is not equal to expected %v", test.options.RestrictTo, actual, test.expected)
+		}
+	}
+}
+
+func TestIndex_SearchWithOptionsOrder(t *testing.T) {
+	i := newTimeTestIndex(t)
+
+	for _, test := range []struct {
+		options  SearchOptions
+		expected []string
+	}{
+		{SearchOptions{OrderBy: OrderByScore, Order: OrderAsc, RestrictTo: []string{"old", "new"}}, []string{"new", "old"}},
+		{SearchOptions{OrderBy: OrderByName}, []string{"new", "old", "unknown"}},
+		{SearchOptions{OrderBy: OrderByName, Order: OrderDesc}, []string{"unknown", "old", "new"}},
+		{SearchOptions{OrderBy: OrderByTime, Order: OrderAsc}, []string{"unknown", "old", "new"}},
+	} {
+		results, err := i.SearchWithOptions("apple", test.options)
+		if err != nil {
+			t.Error(err)
+		}
+		if actual := names(results); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: %v is not equal to expected %v", test.options, actual, test.expected)
+		}
+	}
+
+	if _, err := i.SearchWithOptions("apple", SearchOptions{Order: "up"}); err != ErrUnknownDirection {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownDirection)
+	}
+}
@@ -0,0 +1,33 @@
+package index
+
+// SearchPaged searches query like Search and returns the page of the ranked results skipping offset results and
+// holding at most limit results, 0 limit means no limit. The total number of the found results is returned as well, it
+// is capped by WithLimit option. The negative limit and offset are treated as 0.
+func (i *Index) SearchPaged(query string, limit, offset int) ([]Result, int, error) {
+	return i.SearchPagedWithOptions(query, SearchOptions{}, limit, offset)
+}
+
+// SearchPagedWithOptions searches query over the documents restricted by the options like SearchWithOptions and returns
+// the page of the results like SearchPaged.
+func (i *Index) SearchPagedWithOptions(query string, options SearchOptions, limit, offset int) ([]Result, int, error) {
+	results, err := i.SearchWithOptions(query, options)
+	if err != nil {
+		return nil, 0, err
+	}
+	return pageResults(results, limit, offset), len(results), nil
+}
+
+// pageResults returns the results from offset to offset+limit, the empty slice if offset is past the end.
+func pageResults(results []Result, limit, offset int) []Result {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(results) {
+		return []Result{}
+	}
+	results = results[offset:]
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,51 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestIndex_SearchPaged(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil)
+	for name, text := range map[string]string{
+		"file1": "apple apple apple",
+		"file2": "apple apple",
+		"file3": "apple",
+	} {
+		if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	for _, test := range []struct {
+		limit    int
+		offset   int
+		expected []string
+	}{
+		{0, 0, []string{"file1", "file2", "file3"}},
+		{2, 0, []string{"file1", "file2"}},
+		{2, 2, []string{"file3"}},
+		{10, 0, []string{"file1", "file2", "file3"}},
+		{1, 1, []string{"file2"}},
+		{0, 3, []string{}},
+		{2, 10, []string{}},
+		{-1, -1, []string{"file1", "file2", "file3"}},
+	} {
+		results, total, err := i.SearchPaged("apple", test.limit, test.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 3 {
+			t.Errorf("%d/%d: %d is not equal to expected %d", test.limit, test.offset, total, 3)
+		}
+		actual := []string{}
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%d/%d: %v is not equal to expected %v", test.limit, test.offset, actual, test.expected)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package index
+
+// PartialEngine is the interface implemented by the engines which can return the occurrences fetched before the query
+// timeout instead of failing the query.
+type PartialEngine interface {
+	// GetPartial returns the occurrences of the tokens and true if some occurrences are not fetched in time.
+	GetPartial(tokens []string) (map[string]Occurrences, bool, error)
+}
+
+// SearchPartial searches query like SearchWithOptions, but if the engine runs out of the query timeout it returns the
+// results ranked over the occurrences fetched so far with true partial flag instead of failing the search. The partial
+// results may miss the matching documents and their scores, so they may differ from one search to another, and they
+// are not cached. The engine must implement PartialEngine interface, otherwise the results are always complete.
+func (i *Index) SearchPartial(query string, options SearchOptions) ([]Result, bool, error) {
+	var partial bool
+	results, _, err := i.searchWithOptions(query, options, nil, &partial)
+	if err != nil {
+		return nil, false, err
+	}
+	return results, partial, nil
+}
+
+// SearchPartialWithFacets searches query and counts the facets like SearchWithFacets, but allows the partial results
+// like SearchPartial. The facets of the partial results count the fetched occurrences only.
+func (i *Index) SearchPartialWithFacets(query string, options SearchOptions) ([]Result, Facets, bool, error) {
+	var partial bool
+	results, facets, err := i.searchWithOptions(query, options, Facets{}, &partial)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return results, facets, partial, nil
+}
+
+// searchPartial searches the query over the engine allowing the partial results, only the complete ones are cached.
+func (i *Index) searchPartial(generation uint64, engine IndexEngine, query string, options SearchOptions,
+	partial *bool) ([]Result, error) {
+	key := newQueryKey(query, options)
+	if results, ok := i.queryCache.get(key); ok {
+		return append([]Result{}, results.([]Result)...), nil
+	}
+	results, err := i.searchEngine(engine, query, options, nil, partial)
+	if err != nil {
+		return nil, err
+	}
+	if !*partial {
+		i.queryCache.putAt(generation, key, append([]Result{}, results...))
+	}
+	return results, nil
+}
+
+// getOccurrences returns the occurrences of the tokens. The partial occurrences are fetched if partial is not nil and
+// the engine implements PartialEngine interface, partial is set to true if some occurrences are not fetched.
+func getOccurrences(engine IndexEngine, tokens []string, partial *bool) (map[string]Occurrences, error) {
+	partialEngine, ok := engine.(PartialEngine)
+	if partial == nil || !ok {
+		return engine.Get(tokens)
+	}
+	occurrencesList, incomplete, err := partialEngine.GetPartial(tokens)
+	if err != nil {
+		return nil, err
+	}
+	if incomplete {
+		*partial = true
+	}
+	return occurrencesList, nil
+}
@@ -0,0 +1,81 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// timeoutEngine simulates the query timeout fired after the occurrences of the first token are fetched.
+type timeoutEngine struct {
+	*MemoryIndex
+}
+
+func (e timeoutEngine) GetPartial(tokens []string) (map[string]Occurrences, bool, error) {
+	occurrencesList, err := e.Get(tokens[:1])
+	if err != nil {
+		return nil, false, err
+	}
+	return occurrencesList, len(tokens) > 1, nil
+}
+
+func TestIndex_SearchPartial(t *testing.T) {
+	i := NewIndex(timeoutEngine{NewMemoryIndex()}, nil, WithDefaultOperator(OperatorOr), WithQueryCache(10))
+	for name, text := range map[string]string{
+		"apple":  "apple",
+		"banana": "banana",
+	} {
+		if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	for _, test := range []struct {
+		query    string
+		partial  bool
+		expected []string
+	}{
+		{"apple banana", true, []string{"apple"}},
+		{"apple banana", true, []string{"apple"}},
+		{"banana", false, []string{"banana"}},
+	} {
+		results, partial, err := i.SearchPartial(test.query, SearchOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if partial != test.partial {
+			t.Errorf("%s: %v is not equal to expected %v", test.query, partial, test.partial)
+		}
+		var actual []string
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.query, actual, test.expected)
+		}
+	}
+
+	results, facets, partial, err := i.SearchPartialWithFacets("apple banana", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !partial {
+		t.Errorf("%v is not equal to expected %v", partial, true)
+	}
+	if len(results) != 1 {
+		t.Errorf("%d is not equal to expected %d", len(results), 1)
+	}
+	if expected := (Facets{"appl": 1, "banana": 0}); !reflect.DeepEqual(facets, expected) {
+		t.Errorf("%v is not equal to expected %v", facets, expected)
+	}
+
+	// The search without the partial results uses Get, so it finds all the documents.
+	results, err = i.Search("apple banana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Errorf("%d is not equal to expected %d", len(results), 2)
+	}
+}
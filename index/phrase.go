@@ -0,0 +1,130 @@
+package index
+
+import (
+	"strings"
+)
+
+// phraseQuote encloses the phrase in the query, e.g. `"new york"`.
+const phraseQuote = `"`
+
+// WithPhraseBoost multiplies the score of the documents containing the exact phrase of the query enclosed in double
+// quotes by the factor, e.g. `"new york" city`. The words of the phrase are matched as the separate terms as well, so
+// the documents with the scattered words are found too but rank below the exact phrase. The factor is applied once
+// for every matched phrase, the factor 1 or less disables the boost. The phrases are not matched with WithCountsOnly
+// option as the positions are not fetched.
+func WithPhraseBoost(factor float64) Option {
+	return func(i *Index) {
+		i.phraseBoost = factor
+	}
+}
+
+// WithStrictPhrases makes the quoted phrases of the query required: only the documents containing the words of every
+// phrase at the consecutive positions are found, e.g. `"apple banana"` does not find `banana apple`. The terms out of
+// the quotes are matched as usual. The phrases are not checked with WithCountsOnly option as the positions are not
+// fetched.
+func WithStrictPhrases() Option {
+	return func(i *Index) {
+		i.strictPhrases = true
+	}
+}
+
+// parsePhrases returns the tokens of the phrases enclosed in double quotes in the order of their words. The stop words
+// are skipped as they are not indexed. The phrases of a single token are matched as the terms, so they are skipped too.
+func parsePhrases(query string, analyzer Analyzer) [][]string {
+	parts := strings.Split(query, phraseQuote)
+	var phrases [][]string
+	for k := 1; k+1 < len(parts); k += 2 {
+		var phrase []string
+		for _, word := range analyzer.words(parts[k]) {
+			// The whole word takes the position, the split identifier parts share it.
+			if tokens := analyzer.tokens(word); len(tokens) > 0 {
+				phrase = append(phrase, tokens[0])
+			}
+		}
+		if len(phrase) > 1 {
+			phrases = append(phrases, phrase)
+		}
+	}
+	return phrases
+}
+
+// matchPhrases sets the phrase boost of the items containing the phrases.
+func (i *Index) matchPhrases(items map[*Source]*TmpResultItem, phrases [][]string) {
+	if i.phraseBoost <= 1 || len(phrases) == 0 {
+		return
+	}
+	for _, item := range items {
+		for _, phrase := range phrases {
+			if item.containsPhrase(phrase) {
+				if item.phraseBoost == 0 {
+					item.phraseBoost = 1
+				}
+				item.phraseBoost *= i.phraseBoost
+			}
+		}
+	}
+}
+
+// filterPhrases removes the items without some of the phrases if the index is created with WithStrictPhrases option.
+func (i *Index) filterPhrases(engine IndexEngine, items map[*Source]*TmpResultItem, phrases [][]string) {
+	if !i.strictPhrases || len(phrases) == 0 {
+		return
+	}
+	if _, ok := engine.(Counter); ok && i.countsOnly {
+		return
+	}
+	for source, item := range items {
+		for _, phrase := range phrases {
+			if !item.containsPhrase(phrase) {
+				delete(items, source)
+				break
+			}
+		}
+	}
+}
+
+// containsPhrase checks if the tokens of the phrase occur at the consecutive positions of one field of the document.
+func (item *TmpResultItem) containsPhrase(phrase []string) bool {
+	if item.fields == nil {
+		return containsPhrase(item.occurrences, phrase)
+	}
+	for _, occurrencesList := range item.fields {
+		if containsPhrase(occurrencesList, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsPhrase checks if the tokens of the phrase occur at the consecutive positions.
+func containsPhrase(occurrencesList map[string][]int, phrase []string) bool {
+	positions := make([]map[int]bool, len(phrase))
+	for k, token := range phrase {
+		occurrences, ok := occurrencesList[token]
+		if !ok {
+			return false
+		}
+		positions[k] = make(map[int]bool, len(occurrences))
+		for _, position := range occurrences {
+			positions[k][position] = true
+		}
+	}
+	for start := range positions[0] {
+		matched := true
+		for k := 1; k < len(phrase) && matched; k++ {
+			matched = positions[k][start+k]
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// boostPhrase multiplies the score by the boost of the matched phrases.
+func (item *TmpResultItem) boostPhrase(score float64) float64 {
+	if item.phraseBoost == 0 {
+		return score
+	}
+	return score * item.phraseBoost
+}
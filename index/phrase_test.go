@@ -0,0 +1,129 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestIndex_SearchPhraseBoost(t *testing.T) {
+	for _, test := range []struct {
+		options  []Option
+		query    string
+		expected []string
+	}{
+		{nil, `"machine learning"`, []string{"scattered", "exact"}},
+		{[]Option{WithPhraseBoost(3)}, `"machine learning"`, []string{"exact", "scattered"}},
+		{[]Option{WithPhraseBoost(3)}, `machine learning`, []string{"scattered", "exact"}},
+		{[]Option{WithPhraseBoost(3)}, `"learning machine"`, []string{"scattered", "exact"}},
+		{[]Option{WithPhraseBoost(3)}, `"machine the learning"`, []string{"exact", "scattered"}},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil, test.options...)
+		for name, text := range map[string]string{
+			"exact":     "the machine learning course",
+			"scattered": "learning tools, machine shop, learning books, machine oil",
+		} {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+
+		results, err := i.Search(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.query, actual, test.expected)
+		}
+	}
+}
+
+func TestIndex_SearchStrictPhrases(t *testing.T) {
+	for _, test := range []struct {
+		options  []Option
+		query    string
+		expected []string
+	}{
+		{nil, `"apple banana"`, []string{"ordered", "reversed"}},
+		{[]Option{WithStrictPhrases()}, `"apple banana"`, []string{"ordered"}},
+		{[]Option{WithStrictPhrases()}, `"banana apple"`, []string{"reversed"}},
+		{[]Option{WithStrictPhrases()}, `apple banana`, []string{"ordered", "reversed"}},
+		{[]Option{WithStrictPhrases()}, `"apple banana" cherry`, []string{"ordered"}},
+		{[]Option{WithStrictPhrases()}, `"apple banana" durian`, nil},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil, test.options...)
+		for name, text := range map[string]string{
+			"ordered":  "apple banana cherry",
+			"reversed": "banana apple cherry",
+		} {
+			if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+
+		results, err := i.Search(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.query, actual, test.expected)
+		}
+	}
+}
+
+func TestParsePhrases(t *testing.T) {
+	phrases := parsePhrases(`"Machine Learning" course "apple" "the big apples" "unclosed phrase`, Analyzer{})
+	expected := [][]string{{"machin", "learn"}, {"big", "appl"}}
+	if !reflect.DeepEqual(phrases, expected) {
+		t.Errorf("%v is not equal to expected %v", phrases, expected)
+	}
+}
+
+func TestIndex_SearchPhraseAcrossJoin(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithStrictPhrases(), WithNameCollision(CollisionSerialize))
+	for _, text := range []string{"red apple", "banana split"} {
+		if err := i.AddSource("joined", bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	for query, expected := range map[string]int{`"red apple"`: 1, `"banana split"`: 1, `"apple banana"`: 0} {
+		results, err := i.Search(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != expected {
+			t.Errorf("%s: %d is not equal to expected %d", query, len(results), expected)
+		}
+	}
+}
+
+func TestIndex_SearchPhraseAcrossFields(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithStrictPhrases(), WithDefaultFields("title", "body"))
+	fields := map[string]string{"title": "machine", "body": "ideas learning"}
+	if err := i.AddFields(Source{Name: "file1"}, fields); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+
+	for query, expected := range map[string]int{`"machine learning"`: 0, `"ideas learning"`: 1} {
+		results, err := i.Search(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != expected {
+			t.Errorf("%s: %d is not equal to expected %d", query, len(results), expected)
+		}
+	}
+}
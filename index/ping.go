@@ -0,0 +1,39 @@
+package index
+
+import (
+	"fmt"
+)
+
+// Pinger is the interface implemented by the engines depending on the external storage, e.g. the database, which can
+// check that the storage is reachable.
+type Pinger interface {
+	Ping() error
+}
+
+// Ping checks that the engine of the index is reachable. The engines without the external storage are always reachable.
+func (i *Index) Ping() error {
+	if pinger, ok := i.getEngine().(Pinger); ok {
+		return pinger.Ping()
+	}
+	return nil
+}
+
+// Ping runs the trivial query to check the connection to the database.
+func (i *DbIndex) Ping() error {
+	if _, err := i.pg.Exec("SELECT 1"); err != nil {
+		return fmt.Errorf("can not ping database %w", err)
+	}
+	return nil
+}
+
+// Ping checks that all engines are reachable.
+func (m *MultiEngine) Ping() error {
+	for _, engine := range m.engines {
+		if pinger, ok := engine.(Pinger); ok {
+			if err := pinger.Ping(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
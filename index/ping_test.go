@@ -0,0 +1,64 @@
+package index
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/go-pg/pg/v9"
+)
+
+// downEngine simulates the engine with the unreachable storage.
+type downEngine struct {
+	*MemoryIndex
+	err error
+}
+
+func (e downEngine) Ping() error {
+	return e.err
+}
+
+func TestIndex_Ping(t *testing.T) {
+	errDown := errors.New("down")
+	for _, test := range []struct {
+		name     string
+		engine   IndexEngine
+		expected error
+	}{
+		{"memory", NewMemoryIndex(), nil},
+		{"up", downEngine{NewMemoryIndex(), nil}, nil},
+		{"down", downEngine{NewMemoryIndex(), errDown}, errDown},
+		{"multi", NewMultiEngine(MergeDuplicates, NewMemoryIndex(), downEngine{NewMemoryIndex(), errDown}), errDown},
+	} {
+		i := NewIndex(test.engine, nil)
+		if err := i.Ping(); err != test.expected {
+			t.Errorf("%s: %v is not equal to expected %v", test.name, err, test.expected)
+		}
+		i.Close()
+	}
+}
+
+func TestDbIndex_Ping(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+	if err := i.Ping(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDbIndex_PingUnreachable(t *testing.T) {
+	// The port of the closed listener refuses the connections.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	db := pg.Connect(&pg.Options{Addr: addr, MaxRetries: 0})
+	defer db.Close()
+	i := &DbIndex{pg: db}
+	if err := i.Ping(); err == nil {
+		t.Error("ping of the unreachable database succeeds")
+	}
+}
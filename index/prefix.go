@@ -0,0 +1,212 @@
+package index
+
+import (
+	"strings"
+)
+
+// prefixSuffix marks the query term matching all tokens starting with it, e.g. `appl*`.
+const prefixSuffix = "*"
+
+// PrefixEngine is the interface implemented by the engines which can find the tokens by the prefix.
+type PrefixEngine interface {
+	// GetPrefix returns the occurrences of all tokens starting with the prefix by the tokens.
+	GetPrefix(prefix string) (map[string]Occurrences, error)
+}
+
+// parsePrefix returns the prefix of the query term ending with prefixSuffix and true, or false for the other terms.
+// The prefix is not stemmed, so it is matched against the stems like the completions, e.g. `appl*` matches `apple`,
+// `apples` and `application`, while `apple*` does not match `apples` stemmed to `appl`.
+func parsePrefix(value string, analyzer Analyzer) (string, bool) {
+	if !strings.HasSuffix(value, prefixSuffix) {
+		return "", false
+	}
+	return strings.ToLower(trimWord(analyzer.stripApostrophes(strings.TrimSuffix(value, prefixSuffix)))), true
+}
+
+// isPrefixToken checks if the query token is the prefix term.
+func isPrefixToken(token string) bool {
+	return strings.HasSuffix(token, prefixSuffix)
+}
+
+// splitPrefixes separates the prefix terms from the other stored tokens.
+func splitPrefixes(stored []string) ([]string, []string) {
+	var tokens, prefixes []string
+	for _, token := range stored {
+		if isPrefixToken(token) {
+			prefixes = append(prefixes, token)
+		} else {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, prefixes
+}
+
+// matchesPrefix checks if the stored token is matched by the prefix of the same field. The original forms of the words
+// are skipped, so the exact boost does not double the occurrences.
+func matchesPrefix(token string, prefix string) bool {
+	return strings.HasPrefix(token, prefix) && !isExactToken(token) &&
+		strings.Count(token, fieldSeparator) == strings.Count(prefix, fieldSeparator)
+}
+
+// matchedToken returns the query token matching the token of the word: the token itself or the prefix term it starts
+// with.
+func matchedToken(token string, matched map[string]bool) (string, bool) {
+	if matched[token] {
+		return token, true
+	}
+	for query := range matched {
+		if isPrefixToken(query) && matchesPrefix(token, strings.TrimSuffix(query, prefixSuffix)) {
+			return query, true
+		}
+	}
+	return "", false
+}
+
+// addPrefixes adds the occurrences of the prefix terms to the occurrences of the other tokens, see getPrefixes.
+func addPrefixes(engine IndexEngine, prefixes []string,
+	occurrencesList map[string]Occurrences) (map[string]Occurrences, error) {
+	if len(prefixes) == 0 {
+		return occurrencesList, nil
+	}
+	sources := map[string]*Source{}
+	for _, occurrences := range occurrencesList {
+		for source := range occurrences {
+			sources[source.Name] = source
+		}
+	}
+	prefixed, err := getPrefixes(engine, prefixes, sources)
+	if err != nil {
+		return nil, err
+	}
+	for token, occurrences := range occurrencesList {
+		prefixed[token] = occurrences
+	}
+	return prefixed, nil
+}
+
+// addPrefixCounts adds the numbers of the occurrences of the prefix terms to the counts of the other tokens, see
+// getPrefixes.
+func addPrefixCounts(engine IndexEngine, prefixes []string, countsList map[string]Counts) (map[string]Counts, error) {
+	if len(prefixes) == 0 {
+		return countsList, nil
+	}
+	sources := map[string]*Source{}
+	for _, counts := range countsList {
+		for source := range counts {
+			sources[source.Name] = source
+		}
+	}
+	prefixed, err := getPrefixes(engine, prefixes, sources)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]Counts, len(countsList)+len(prefixed))
+	for token, counts := range countsList {
+		results[token] = counts
+	}
+	for token, occurrences := range prefixed {
+		counts := make(Counts, len(occurrences))
+		for source, positions := range occurrences {
+			counts[source] = len(positions)
+		}
+		results[token] = counts
+	}
+	return results, nil
+}
+
+// getPrefixes returns the occurrences of the tokens matched by every prefix term merged by the documents. The
+// documents are shared by the names with the sources found by the other tokens, so every document is gathered once.
+// The engine must implement PrefixEngine interface, otherwise ErrNotSupported is returned.
+func getPrefixes(engine IndexEngine, prefixes []string, sources map[string]*Source) (map[string]Occurrences, error) {
+	prefixEngine, ok := engine.(PrefixEngine)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	results := make(map[string]Occurrences, len(prefixes))
+	for _, token := range prefixes {
+		prefix := strings.TrimSuffix(token, prefixSuffix)
+		found, err := prefixEngine.GetPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		merged := Occurrences{}
+		for storedToken, occurrences := range found {
+			if !matchesPrefix(storedToken, prefix) {
+				continue
+			}
+			for source, positions := range occurrences {
+				shared, ok := sources[source.Name]
+				if !ok {
+					shared = source
+					sources[source.Name] = shared
+				}
+				merged[shared] = mergePositions(merged[shared], positions)
+			}
+		}
+		results[token] = merged
+	}
+	return results, nil
+}
+
+// GetPrefix scans all tokens for the ones starting with the prefix in thread-safe way.
+func (i *MemoryIndex) GetPrefix(prefix string) (map[string]Occurrences, error) {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	results := map[string]Occurrences{}
+	for token, occurrences := range i.Index {
+		if !strings.HasPrefix(token, prefix) {
+			continue
+		}
+		result := Occurrences{}
+		for document, positions := range occurrences {
+			source := i.Sources[document]
+			if source.Excluded {
+				continue
+			}
+			result[source] = positions
+		}
+		if len(result) > 0 {
+			results[token] = result
+		}
+	}
+	return results, nil
+}
+
+// GetPrefix fetches the occurrences of the tokens matched by LIKE with the escaped prefix.
+func (i *DbIndex) GetPrefix(prefix string) (map[string]Occurrences, error) {
+	return i.getPrefix("", documentFilter{}, prefix)
+}
+
+// GetPrefix fetches the occurrences of the tokens starting with the prefix in the tenant's documents.
+func (t *TenantIndex) GetPrefix(prefix string) (map[string]Occurrences, error) {
+	return t.getPrefix(t.tenant, t.documentFilter, prefix)
+}
+
+func (i *DbIndex) getPrefix(tenant string, r documentFilter, prefix string) (map[string]Occurrences, error) {
+	ctx, cancel := i.queryContext()
+	defer cancel()
+	results := map[string]Occurrences{}
+	pattern := likeEscaper.Replace(prefix) + "%"
+	if err := i.fetchWhere(ctx, tenant, r, "t.token LIKE ?", pattern, results, map[string]*Source{}); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetPrefix merges the tokens starting with the prefix found by all engines the same way as Get. All engines must
+// implement PrefixEngine interface, otherwise ErrNotSupported is returned.
+func (m *MultiEngine) GetPrefix(prefix string) (map[string]Occurrences, error) {
+	found := make([]map[string]Occurrences, 0, len(m.engines))
+	for _, engine := range m.engines {
+		prefixEngine, ok := engine.(PrefixEngine)
+		if !ok {
+			return nil, ErrNotSupported
+		}
+		occurrencesList, err := prefixEngine.GetPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, occurrencesList)
+	}
+	return m.merge(found)
+}
@@ -0,0 +1,144 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestIndex_SearchPrefix(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithFields("title"))
+	for name, text := range map[string]string{
+		"file1": "apple application",
+		"file2": "apples banana",
+		"file3": "applause",
+		"file4": "banana",
+	} {
+		if err := i.AddSourceSync(name, bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := i.AddFields(Source{Name: "file5"}, map[string]string{"title": "apple", BodyField: "cherry"}); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+
+	for _, test := range []struct {
+		query     string
+		expected  []string
+		positions map[string][]int
+	}{
+		{"appl*", []string{"file1", "file2", "file3"}, map[string][]int{"file1": {0, 1}, "file2": {0}, "file3": {0}}},
+		{"appl* banana", []string{"file2"}, nil},
+		{"applic*", []string{"file1"}, nil},
+		{"APPLI*", []string{"file1"}, nil},
+		{"title:app*", []string{"file5"}, map[string][]int{"file5": {0}}},
+		{"cher*", []string{"file5"}, nil},
+		{"xyz*", nil, nil},
+		{"*", nil, nil},
+	} {
+		var actual []string
+		results, err := i.Search(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, result := range results {
+			actual = append(actual, result.Document.Name)
+			if test.positions == nil {
+				continue
+			}
+			for token, positions := range result.Positions {
+				if !reflect.DeepEqual(positions, test.positions[result.Document.Name]) {
+					t.Errorf("%s: %s %s %v is not equal to expected %v", test.query, result.Document.Name, token,
+						positions, test.positions[result.Document.Name])
+				}
+			}
+		}
+		sort.Strings(actual)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.query, actual, test.expected)
+		}
+	}
+}
+
+func TestIndex_SearchPrefixNotSupported(t *testing.T) {
+	i := NewIndex(&emptyEngine{}, nil)
+	defer i.Close()
+	if _, err := i.Search("appl*"); err != ErrNotSupported {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+}
+
+func TestMultiEngine_GetPrefix(t *testing.T) {
+	first := NewMemoryIndex()
+	second := NewMemoryIndex()
+	for _, item := range []struct {
+		engine   IndexEngine
+		token    string
+		position int
+		document string
+	}{
+		{first, "appl", 0, "file1"},
+		{first, "applic", 1, "file1"},
+		{second, "appl", 2, "file1"},
+		{second, "banana", 0, "file2"},
+	} {
+		if err := item.engine.Add(item.token, item.position, Source{Name: item.document}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := NewMultiEngine(MergeDuplicates, first, second).GetPrefix("appl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := map[string]map[string][]int{}
+	for token, occurrences := range found {
+		actual[token] = map[string][]int{}
+		for source, positions := range occurrences {
+			actual[token][source.Name] = positions
+		}
+	}
+	expected := map[string]map[string][]int{
+		"appl":   {"file1": {0, enginePositionGap + 2}},
+		"applic": {"file1": {1}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestDbIndex_GetPrefix(t *testing.T) {
+	i := newTestDbIndex(t)
+	defer i.Close()
+
+	engine := i.Tenant(fmt.Sprintf("prefix%d", time.Now().UnixNano()))
+	for position, token := range []string{"appl", "applic", "banana"} {
+		if err := engine.Add(token, position, Source{Name: "file1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitOccurrences(t, engine, "banana")
+
+	for prefix, expected := range map[string][]string{
+		"appl":   {"appl", "applic"},
+		"app_":   nil,
+		"banana": {"banana"},
+	} {
+		found, err := engine.(PrefixEngine).GetPrefix(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for token := range found {
+			actual = append(actual, token)
+		}
+		sort.Strings(actual)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", prefix, actual, expected)
+		}
+	}
+}
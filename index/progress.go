@@ -0,0 +1,42 @@
+package index
+
+import (
+	"sync/atomic"
+)
+
+// Progress is the number of the documents and the tokens added to the index, e.g. to report the build progress.
+type Progress struct {
+	// Documents is the number of the indexed documents, their tokens are passed to the engine.
+	Documents int64
+	// Skipped is the number of the documents skipped as the duplicates or as the unchanged ones.
+	Skipped int64
+	// Failed is the number of the documents failed to be added, e.g. rejected by the name collision.
+	Failed int64
+	// Tokens is the number of the tokens passed to the engine.
+	Tokens int64
+	// Stored is the number of the occurrences written by the engine or -1 if the engine does not report it.
+	Stored int64
+}
+
+// StoredCounter is the interface implemented by the engines which write the occurrences in batches, so the number of
+// written ones lags behind the added ones.
+type StoredCounter interface {
+	// Stored returns the number of the occurrences written to the storage.
+	Stored() int64
+}
+
+// Progress returns the number of the documents and the tokens added to the index so far. The document is counted when
+// its tokens are added, so the counters lag behind the documents being added concurrently.
+func (i *Index) Progress() Progress {
+	progress := Progress{
+		Documents: atomic.LoadInt64(&i.documents),
+		Skipped:   atomic.LoadInt64(&i.skipped),
+		Failed:    atomic.LoadInt64(&i.failed),
+		Tokens:    atomic.LoadInt64(&i.tokens),
+		Stored:    -1,
+	}
+	if counter, ok := i.getEngine().(StoredCounter); ok {
+		progress.Stored = counter.Stored()
+	}
+	return progress
+}
@@ -0,0 +1,46 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type storedEngine struct {
+	*MemoryIndex
+}
+
+func (e storedEngine) Stored() int64 {
+	return 42
+}
+
+func TestIndex_Progress(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithDeduplication(), WithNameCollision(CollisionReject))
+	for _, document := range []struct {
+		name     string
+		text     string
+		expected error
+	}{
+		{"file1", "an apple banana", nil},
+		{"file2", "orange", nil},
+		{"copy", "orange", nil},
+		{"file1", "cherry", ErrDuplicateName},
+	} {
+		if err := i.AddSource(document.name, bytes.NewBufferString(document.text)); !errors.Is(err, document.expected) {
+			t.Errorf("%v is not equal to expected %v", err, document.expected)
+		}
+	}
+	i.Close()
+
+	// The duplicate is skipped, the document with the same name is rejected.
+	expected := Progress{Documents: 2, Skipped: 1, Failed: 1, Tokens: 3, Stored: -1}
+	if actual := i.Progress(); actual != expected {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	i = NewIndex(storedEngine{NewMemoryIndex()}, nil)
+	defer i.Close()
+	if actual := i.Progress().Stored; actual != 42 {
+		t.Errorf("%d is not equal to expected 42", actual)
+	}
+}
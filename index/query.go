@@ -0,0 +1,62 @@
+package index
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// boostSeparator separates the query term and its boost, e.g. `apple^2`.
+const boostSeparator = "^"
+
+// defaultBoost is the boost of the query term without explicit boost.
+const defaultBoost = 1.0
+
+// parseQuery splits the query into unique tokens without stop words stemmed by the analyzer.
+// Every query term may be followed by `^boost` to multiply its contribution to the score, e.g. `apple^2 banana`.
+// The boost of the token found several times in the query is the maximal one.
+// The term may be scoped by the field, e.g. `title:apple`, to match the occurrences in the field only.
+// The term ending with `*` is kept as the prefix token matching all tokens starting with it, e.g. `appl*`.
+func (i *Index) parseQuery(query string, analyzer Analyzer) ([]string, map[string]float64, error) {
+	var tokens []string
+	boosts := map[string]float64{}
+
+	for _, term := range strings.Fields(query) {
+		term, boost := splitBoost(term)
+		field, value, err := i.queryField(term)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var analyzed []string
+		if prefix, ok := parsePrefix(value, analyzer); !ok {
+			analyzed = analyzer.Analyze(value)
+		} else if prefix != "" {
+			analyzed = []string{prefix + prefixSuffix}
+		}
+		for _, token := range analyzed {
+			token = fieldToken(field, token)
+			if current, ok := boosts[token]; ok {
+				if boost > current {
+					boosts[token] = boost
+				}
+				continue
+			}
+			tokens = append(tokens, token)
+			boosts[token] = boost
+		}
+	}
+	return tokens, boosts, nil
+}
+
+// splitBoost returns the query term without the boost suffix and the boost, defaultBoost if it is not set. The negative
+// and the non-finite boosts, e.g. `^Inf` or `^1e400`, are kept as the part of the term, so the scores stay finite.
+func splitBoost(term string) (string, float64) {
+	if idx := strings.LastIndex(term, boostSeparator); idx >= 0 {
+		value, err := strconv.ParseFloat(term[idx+len(boostSeparator):], 64)
+		if err == nil && value >= 0 && !math.IsInf(value, 0) {
+			return term[:idx], value
+		}
+	}
+	return term, defaultBoost
+}
@@ -0,0 +1,196 @@
+package index
+
+import (
+	"bufio"
+	"container/list"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithQueryCache caches the results of up to size recent queries and completions. The cache is cleared when documents
+// are added or deleted through the index.
+func WithQueryCache(size int) Option {
+	return func(i *Index) {
+		if size > 0 {
+			i.queryCache = newQueryCache(size)
+		}
+	}
+}
+
+// WithQueryCacheSettings describes the settings of the search the index can not compare itself, e.g. the range
+// algorithm and its rescorers. The cache saved with SaveQueryCache function is loaded only by the index with the same
+// settings.
+func WithQueryCacheSettings(settings string) Option {
+	return func(i *Index) {
+		i.queryCacheSettings = settings
+	}
+}
+
+// queryKey identifies the cached search results.
+type queryKey struct {
+	query   string
+	tenant  string
+	since   time.Time
+	until   time.Time
+	orderBy string
+	order   string
+	// restrictTo is the joined list of the names if restricted is true.
+	restricted bool
+	restrictTo string
+	language   string
+	boosts     string
+	sortField  string
+	markers    Markers
+}
+
+func newQueryKey(query string, options SearchOptions) queryKey {
+	return queryKey{
+		query:      query,
+		tenant:     options.Tenant,
+		since:      options.Since,
+		until:      options.Until,
+		orderBy:    options.OrderBy,
+		order:      options.Order,
+		restricted: options.RestrictTo != nil,
+		restrictTo: strings.Join(options.RestrictTo, "\x00"),
+		language:   options.Language,
+		boosts:     boostsKey(options.Boosts),
+		sortField:  options.SortField,
+		markers:    options.Markers,
+	}
+}
+
+// queryEntry is the cached search results or completions.
+type queryEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// queryCache is the thread-safe LRU cache of the search results and the completions. The keys are queryKey and
+// completionKey, the cached values must not be modified.
+type queryCache struct {
+	m       sync.Mutex
+	size    int
+	order   *list.List
+	entries map[interface{}]*list.Element
+	// generation is the generation of the index the cache is reset at, see putAt.
+	generation uint64
+}
+
+func newQueryCache(size int) *queryCache {
+	return &queryCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[interface{}]*list.Element{},
+	}
+}
+
+// get returns the cached value.
+func (c *queryCache) get(key interface{}) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*queryEntry).value, true
+}
+
+// put stores the value and evicts the least recently used ones if the cache is full.
+func (c *queryCache) put(key interface{}, value interface{}) {
+	if c == nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.store(key, value)
+}
+
+// store puts the value to the cache, the cache must be locked.
+func (c *queryCache) store(key interface{}, value interface{}) {
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*queryEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&queryEntry{key: key, value: value})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*queryEntry).key)
+	}
+}
+
+// putAt stores the value computed at the generation of the index unless the cache is reset at the later generation
+// since then, e.g. the results of the search running over the engine swapped meanwhile are not cached.
+func (c *queryCache) putAt(generation uint64, key interface{}, value interface{}) {
+	if c == nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.generation == generation {
+		c.store(key, value)
+	}
+}
+
+// reset removes all cached values after the index is mutated to the generation. The generation of the cache never
+// goes back, so the concurrent mutations can be reset in any order.
+func (c *queryCache) reset(generation uint64) {
+	if c == nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.order.Init()
+	c.entries = map[interface{}]*list.Element{}
+	if generation > c.generation {
+		c.generation = generation
+	}
+}
+
+// each calls f for every cached value starting from the least recently used one.
+func (c *queryCache) each(f func(key interface{}, value interface{})) {
+	if c == nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	for element := c.order.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*queryEntry)
+		f(entry.key, entry.value)
+	}
+}
+
+func (c *queryCache) len() int {
+	if c == nil {
+		return 0
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.order.Len()
+}
+
+// Warmup runs the queries written one per line to populate the query cache, e.g. with the popular queries before
+// accepting traffic. Empty lines are skipped. It returns the number of run queries.
+func (i *Index) Warmup(queries io.Reader) (int, error) {
+	scanner := bufio.NewScanner(queries)
+	count := 0
+	for scanner.Scan() {
+		query := strings.TrimSpace(scanner.Text())
+		if query == "" {
+			continue
+		}
+		if _, err := i.Search(query); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, scanner.Err()
+}
@@ -0,0 +1,407 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingEngine counts the searches over the engine.
+type countingEngine struct {
+	*MemoryIndex
+	gets int
+}
+
+func (e *countingEngine) Get(tokens []string) (map[string]Occurrences, error) {
+	e.gets++
+	return e.MemoryIndex.Get(tokens)
+}
+
+func TestIndex_SearchQueryCache(t *testing.T) {
+	engine := &countingEngine{MemoryIndex: NewMemoryIndex()}
+	i := NewIndex(engine, nil, WithQueryCache(2))
+	if err := i.AddSource("file1", bytes.NewBufferString("apple banana")); err != nil {
+		t.Error(err)
+	}
+	i.Close()
+
+	first, err := i.Search("apple")
+	if err != nil {
+		t.Error(err)
+	}
+	second, err := i.Search("apple")
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("%v is not equal to expected %v", second, first)
+	}
+	if engine.gets != 1 {
+		t.Errorf("%d is not equal to expected 1", engine.gets)
+	}
+
+	for _, query := range []string{"banana", "orange", "apple"} {
+		if _, err := i.Search(query); err != nil {
+			t.Error(err)
+		}
+	}
+	if engine.gets != 4 {
+		t.Errorf("evicted query is not searched again: %d is not equal to expected 4", engine.gets)
+	}
+}
+
+func TestQueryCache_Clear(t *testing.T) {
+	i := NewIndex(&emptyEngine{}, nil, WithQueryCache(10))
+	defer i.Close()
+	if _, err := i.Search("apple"); err != nil {
+		t.Error(err)
+	}
+	if i.queryCache.len() != 1 {
+		t.Errorf("%d is not equal to expected 1", i.queryCache.len())
+	}
+	if err := i.AddSource("file1", bytes.NewBufferString("apple")); err != nil {
+		t.Error(err)
+	}
+	if i.queryCache.len() != 0 {
+		t.Errorf("%d is not equal to expected 0", i.queryCache.len())
+	}
+}
+
+func TestQueryCache_PutAt(t *testing.T) {
+	c := newQueryCache(10)
+	c.putAt(0, "apple", 1)
+	c.reset(1)
+	c.putAt(0, "banana", 2)
+	if _, ok := c.get("banana"); ok {
+		t.Error("value computed before reset is cached")
+	}
+	c.putAt(1, "cherry", 3)
+	if value, ok := c.get("cherry"); !ok || value != 3 {
+		t.Errorf("%v is not equal to expected %v", value, 3)
+	}
+
+	// The concurrent mutations may reset the cache out of order, the later generation wins.
+	c.reset(3)
+	c.reset(2)
+	c.putAt(2, "durian", 4)
+	if _, ok := c.get("durian"); ok {
+		t.Error("value computed at the earlier generation is cached")
+	}
+}
+
+func TestIndex_Generation(t *testing.T) {
+	engine := NewMemoryIndex()
+	i := NewIndex(engine, nil, WithQueryCache(10))
+	defer i.Close()
+	generation := i.Generation()
+	for _, test := range []struct {
+		name   string
+		mutate func() error
+	}{
+		{"add", func() error {
+			if err := i.AddSource("file1", bytes.NewBufferString("apple")); err != nil {
+				return err
+			}
+			// The tokens are added to the engine asynchronously, the document must be stored to be excluded.
+			for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+				if occurrences, _ := engine.Get([]string{"appl"}); len(occurrences["appl"]) > 0 {
+					break
+				}
+			}
+			return nil
+		}},
+		{"exclude", func() error { return i.SetExcluded("file1", true) }},
+		{"include", func() error { return i.SetExcluded("file1", false) }},
+		{"remove", func() error { return i.RemoveSource("file1") }},
+		{"delete", func() error {
+			_, err := i.DeleteByPrefix("file")
+			return err
+		}},
+		{"swap", func() error {
+			i.SwapEngine(engine)
+			return nil
+		}},
+	} {
+		if _, err := i.Search("apple"); err != nil {
+			t.Fatal(err)
+		}
+		if i.queryCache.len() != 1 {
+			t.Errorf("%s: %d is not equal to expected 1", test.name, i.queryCache.len())
+		}
+		if err := test.mutate(); err != nil {
+			t.Fatal(err)
+		}
+		if i.Generation() <= generation {
+			t.Errorf("%s: %d is not greater than %d", test.name, i.Generation(), generation)
+		}
+		generation = i.Generation()
+		if i.queryCache.len() != 0 {
+			t.Errorf("%s: %d is not equal to expected 0", test.name, i.queryCache.len())
+		}
+	}
+}
+
+// gateEngine signals every added token and waits for the release before adding it.
+type gateEngine struct {
+	*MemoryIndex
+	adding  chan struct{}
+	release chan struct{}
+}
+
+func (e gateEngine) Add(token string, position int, source Source) error {
+	e.adding <- struct{}{}
+	<-e.release
+	return e.MemoryIndex.Add(token, position, source)
+}
+
+func TestIndex_GenerationAfterAdd(t *testing.T) {
+	engine := gateEngine{NewMemoryIndex(), make(chan struct{}), make(chan struct{})}
+	i := NewIndex(engine, nil, WithQueryCache(10))
+	defer i.Close()
+	errC := make(chan error)
+	go func() {
+		errC <- i.AddSource("file1", bytes.NewBufferString("apple"))
+	}()
+
+	// The search while the tokens are being added caches the results without the document.
+	<-engine.adding
+	results, err := i.Search("apple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("%d is not equal to expected 0", len(results))
+	}
+	close(engine.release)
+	if err := <-errC; err != nil {
+		t.Fatal(err)
+	}
+
+	results, err = i.Search("apple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Document.Name != "file1" {
+		t.Errorf("%v is not equal to expected [file1]", results)
+	}
+}
+
+// batchEngine keeps the added tokens until they are flushed, like DbIndex.
+type batchEngine struct {
+	*MemoryIndex
+	m        sync.Mutex
+	batch    []newToken
+	flushed  map[int]func()
+	lastHook int
+}
+
+func (e *batchEngine) Add(token string, position int, source Source) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.batch = append(e.batch, newToken{token: token, position: position, source: source})
+	return nil
+}
+
+func (e *batchEngine) OnFlush(fn func()) func() {
+	e.m.Lock()
+	defer e.m.Unlock()
+	if e.flushed == nil {
+		e.flushed = map[int]func(){}
+	}
+	e.lastHook++
+	id := e.lastHook
+	e.flushed[id] = fn
+	return func() {
+		e.m.Lock()
+		defer e.m.Unlock()
+		delete(e.flushed, id)
+	}
+}
+
+// hooks returns the number of the functions called after every flush.
+func (e *batchEngine) hooks() int {
+	e.m.Lock()
+	defer e.m.Unlock()
+	return len(e.flushed)
+}
+
+func (e *batchEngine) Flush() error {
+	e.m.Lock()
+	defer e.m.Unlock()
+	for _, t := range e.batch {
+		if err := e.MemoryIndex.Add(t.token, t.position, t.source); err != nil {
+			return err
+		}
+	}
+	e.batch = nil
+	for _, fn := range e.flushed {
+		fn()
+	}
+	return nil
+}
+
+func TestIndex_GenerationAfterFlush(t *testing.T) {
+	engine := &batchEngine{MemoryIndex: NewMemoryIndex()}
+	i := NewIndex(engine, nil, WithQueryCache(10))
+	defer i.Close()
+	if err := i.AddSource("file1", bytes.NewBufferString("apple")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The search before the batch is written caches the results without the document.
+	generation := i.Generation()
+	results, err := i.Search("apple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("%d is not equal to expected 0", len(results))
+	}
+	if err := engine.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if actual := i.Generation(); actual != generation+1 {
+		t.Errorf("%d is not equal to expected %d", actual, generation+1)
+	}
+
+	results, err = i.Search("apple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Document.Name != "file1" {
+		t.Errorf("%v is not equal to expected [file1]", results)
+	}
+
+	// The batches of the swapped engine do not advance the generation.
+	i.SwapEngine(NewMemoryIndex())
+	generation = i.Generation()
+	if err := engine.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if actual := i.Generation(); actual != generation {
+		t.Errorf("%d is not equal to expected %d", actual, generation)
+	}
+
+	// The engine swapped out and back in is watched once, the closed index stops watching it.
+	i.SwapEngine(engine)
+	other := NewIndex(engine, nil)
+	if actual := engine.hooks(); actual != 2 {
+		t.Errorf("%d is not equal to expected %d", actual, 2)
+	}
+	other.Close()
+	i.Close()
+	if actual := engine.hooks(); actual != 0 {
+		t.Errorf("%d is not equal to expected %d", actual, 0)
+	}
+}
+
+func TestIndex_CloseFlushes(t *testing.T) {
+	engine := &batchEngine{MemoryIndex: NewMemoryIndex()}
+	i := NewIndex(engine, nil, WithQueryCache(10))
+	if err := i.AddSource("file1", bytes.NewBufferString("apple")); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+
+	results, err := i.Search("apple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("%d is not equal to expected %d", len(results), 1)
+	}
+}
+
+func TestIndex_Warmup(t *testing.T) {
+	i := &Index{engine: &emptyEngine{}, queryCache: newQueryCache(10)}
+	count, err := i.Warmup(strings.NewReader("apple\n\nbanana orange\napple\n"))
+	if err != nil {
+		t.Error(err)
+	}
+	if count != 3 {
+		t.Errorf("%d is not equal to expected 3", count)
+	}
+	for _, query := range []string{"apple", "banana orange"} {
+		if _, ok := i.queryCache.get(queryKey{query: query}); !ok {
+			t.Errorf("%s is not cached", query)
+		}
+	}
+	if i.queryCache.len() != 2 {
+		t.Errorf("%d is not equal to expected 2", i.queryCache.len())
+	}
+}
+
+func TestIndex_SaveQueryCache(t *testing.T) {
+	build := func(generation string, options ...Option) (*Index, *countingEngine) {
+		engine := &countingEngine{MemoryIndex: NewMemoryIndex()}
+		engine.SetGeneration(generation)
+		i := NewIndex(engine, nil, append([]Option{WithQueryCache(10), WithQueryCacheSettings("bm25")}, options...)...)
+		if err := i.AddSource("file1", bytes.NewBufferString("apple banana")); err != nil {
+			t.Fatal(err)
+		}
+		i.Close()
+		return i, engine
+	}
+
+	i, _ := build("1")
+	expected, err := i.Search("apple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.Complete("ban", 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.SearchWithOptions("apple", SearchOptions{}.SortByField("price", OrderAsc)); err != nil {
+		t.Fatal(err)
+	}
+	saved := &bytes.Buffer{}
+	if err := i.SaveQueryCache(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		generation string
+		options    []Option
+		loaded     int
+		gets       int
+	}{
+		{"1", nil, 3, 0},
+		{"2", nil, 0, 1},
+		{"1", []Option{WithLimit(1)}, 0, 1},
+		{"1", []Option{WithQueryCacheSettings("count")}, 0, 1},
+	} {
+		restarted, engine := build(test.generation, test.options...)
+		loaded, err := restarted.LoadQueryCache(bytes.NewReader(saved.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded != test.loaded {
+			t.Errorf("%s: %d is not equal to expected %d", test.generation, loaded, test.loaded)
+		}
+		// The entries of the searches with different options are kept apart.
+		if restarted.queryCache.len() != test.loaded {
+			t.Errorf("%s: %d is not equal to expected %d", test.generation, restarted.queryCache.len(), test.loaded)
+		}
+		actual, err := restarted.Search("apple")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.generation, actual, expected)
+		}
+		if engine.gets != test.gets {
+			t.Errorf("%s: %d is not equal to expected %d", test.generation, engine.gets, test.gets)
+		}
+	}
+
+	if err := NewIndex(NewMemoryIndex(), nil, WithQueryCache(10)).SaveQueryCache(saved); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+	if _, err := i.LoadQueryCache(bytes.NewBufferString("broken")); err == nil {
+		t.Error("broken cache is loaded")
+	}
+}
@@ -0,0 +1,171 @@
+package index
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RecordFormat is the format of the file with the records indexed as separate documents.
+type RecordFormat string
+
+// Formats of the records.
+const (
+	// RecordsCSV is the comma-separated values with the header row naming the fields.
+	RecordsCSV RecordFormat = "csv"
+	// RecordsJSONL is the JSON object per line.
+	RecordsJSONL RecordFormat = "jsonl"
+)
+
+// ErrMissingField is returned when the record has no name or content field.
+var ErrMissingField = errors.New("missing field")
+
+// ParseRecordFormat returns the format of the records by its case-insensitive name.
+func ParseRecordFormat(name string) (RecordFormat, error) {
+	switch format := RecordFormat(strings.ToLower(name)); format {
+	case RecordsCSV, RecordsJSONL:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown record format %s, expected csv or jsonl", name)
+	}
+}
+
+// RecordOptions selects the fields of the records used as the document name, as the document content and as the
+// document metadata.
+type RecordOptions struct {
+	Format       RecordFormat
+	NameField    string
+	ContentField string
+	// MetadataFields are the fields kept as the metadata of the document, e.g. to order the results by the price. The
+	// fields missing in the record are skipped.
+	MetadataFields []string
+}
+
+// AddRecords indexes every record read from the reader as the document named by the name field of the record with the
+// content field as the text. The documents inherit the modification time and the language of the source. The number
+// of the indexed records is returned, the reading stops at the first malformed record or the record without the
+// fields.
+func (i *Index) AddRecords(source Source, reader io.Reader, options RecordOptions) (int, error) {
+	next, err := recordReader(reader, options)
+	if err != nil {
+		return 0, fmt.Errorf("can not read records of %s: %w", source.Name, err)
+	}
+	added := 0
+	for {
+		record, err := next()
+		if err == io.EOF {
+			return added, nil
+		}
+		if err != nil {
+			return added, fmt.Errorf("can not read record %d of %s: %w", added+1, source.Name, err)
+		}
+		name, ok := record[options.NameField]
+		if !ok || name == "" {
+			return added, fmt.Errorf("record %d of %s: %w %s", added+1, source.Name, ErrMissingField, options.NameField)
+		}
+		content, ok := record[options.ContentField]
+		if !ok {
+			return added, fmt.Errorf("record %d of %s: %w %s", added+1, source.Name, ErrMissingField, options.ContentField)
+		}
+		document := source
+		document.Name = name
+		document.Metadata = options.metadata(source.Metadata, record)
+		if err := i.AddDocument(document, strings.NewReader(content)); err != nil {
+			return added, err
+		}
+		added++
+	}
+}
+
+// metadata returns the metadata of the source with the metadata fields of the record.
+func (o RecordOptions) metadata(metadata map[string]string, record map[string]string) map[string]string {
+	if len(o.MetadataFields) == 0 {
+		return metadata
+	}
+	fields := make(map[string]string, len(metadata)+len(o.MetadataFields))
+	for field, value := range metadata {
+		fields[field] = value
+	}
+	for _, field := range o.MetadataFields {
+		if value, ok := record[field]; ok {
+			fields[field] = value
+		}
+	}
+	return fields
+}
+
+// recordReader returns the function reading the next record as the values by the field names. io.EOF is returned at
+// the end of the records.
+func recordReader(reader io.Reader, options RecordOptions) (func() (map[string]string, error), error) {
+	switch options.Format {
+	case RecordsCSV:
+		return csvReader(reader)
+	case RecordsJSONL:
+		return jsonlReader(reader), nil
+	default:
+		return nil, fmt.Errorf("unknown record format %s", options.Format)
+	}
+}
+
+// csvReader reads the header row and returns the function reading the rows by the header names.
+func csvReader(reader io.Reader) (func() (map[string]string, error), error) {
+	rows := csv.NewReader(reader)
+	header, err := rows.Read()
+	if err == io.EOF {
+		return func() (map[string]string, error) {
+			return nil, io.EOF
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() (map[string]string, error) {
+		row, err := rows.Read()
+		if err != nil {
+			return nil, err
+		}
+		record := make(map[string]string, len(header))
+		for k, field := range header {
+			record[field] = row[k]
+		}
+		return record, nil
+	}, nil
+}
+
+// jsonlReader returns the function reading the JSON objects line by line, the empty lines are skipped. The string
+// values are used as is, the other values are formatted as JSON, e.g. the numbers.
+func jsonlReader(reader io.Reader) func() (map[string]string, error) {
+	lines := bufio.NewReader(reader)
+	return func() (map[string]string, error) {
+		for {
+			line, err := lines.ReadBytes('\n')
+			if len(strings.TrimSpace(string(line))) > 0 {
+				return decodeRecord(line)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// decodeRecord decodes the JSON object to the values by the field names.
+func decodeRecord(line []byte) (map[string]string, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(line, &object); err != nil {
+		return nil, err
+	}
+	record := make(map[string]string, len(object))
+	for field, raw := range object {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		record[field] = value
+	}
+	return record, nil
+}
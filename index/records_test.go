@@ -0,0 +1,99 @@
+package index
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndex_AddRecords(t *testing.T) {
+	for _, test := range []struct {
+		format  RecordFormat
+		records string
+	}{
+		{RecordsCSV, "id,title,body\n1,apple,\"red apple, green banana\"\n2,cherry,cherry pie\n"},
+		{RecordsJSONL, `{"id": 1, "title": "apple", "body": "red apple, green banana"}` + "\n\n" +
+			`{"id": 2, "title": "cherry", "body": "cherry pie"}`},
+	} {
+		i := NewIndex(NewMemoryIndex(), nil)
+		options := RecordOptions{Format: test.format, NameField: "title", ContentField: "body"}
+		added, err := i.AddRecords(Source{Name: "fruits"}, strings.NewReader(test.records), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i.Close()
+		if added != 2 {
+			t.Errorf("%s: %d is not equal to expected %d", test.format, added, 2)
+		}
+
+		for query, expected := range map[string][]string{
+			"banana": {"apple"},
+			"pie":    {"cherry"},
+			"fruits": nil,
+		} {
+			results, err := i.Search(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actual []string
+			for _, result := range results {
+				actual = append(actual, result.Document.Name)
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s %s: %v is not equal to expected %v", test.format, query, actual, expected)
+			}
+		}
+	}
+}
+
+func TestIndex_AddRecordsMissingField(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil)
+	defer i.Close()
+	options := RecordOptions{Format: RecordsJSONL, NameField: "id", ContentField: "body"}
+	records := `{"id": 1, "body": "apple"}` + "\n" + `{"id": 2, "title": "banana"}`
+	added, err := i.AddRecords(Source{Name: "fruits"}, strings.NewReader(records), options)
+	if !errors.Is(err, ErrMissingField) {
+		t.Errorf("%v is not equal to expected %v", err, ErrMissingField)
+	}
+	if added != 1 {
+		t.Errorf("%d is not equal to expected %d", added, 1)
+	}
+}
+
+func TestIndex_AddRecordsMetadata(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil)
+	options := RecordOptions{Format: RecordsCSV, NameField: "title", ContentField: "body",
+		MetadataFields: []string{"price", "color"}}
+	records := "title,body,price\napple,fresh fruit,3\nbanana,fresh fruit,1\ncherry,fresh fruit,2\n"
+	source := Source{Name: "fruits", Metadata: map[string]string{"shop": "market"}}
+	if _, err := i.AddRecords(source, strings.NewReader(records), options); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+
+	results, err := i.SearchWithOptions("fruit", SearchOptions{}.SortByField("price", OrderAsc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, result := range results {
+		actual = append(actual, result.Document.Name)
+	}
+	if expected := []string{"banana", "cherry", "apple"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+	expected := map[string]string{"shop": "market", "price": "3"}
+	if actual := results[2].Document.Metadata; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestParseRecordFormat(t *testing.T) {
+	if format, err := ParseRecordFormat("CSV"); err != nil || format != RecordsCSV {
+		t.Errorf("%v is not equal to expected %v", format, RecordsCSV)
+	}
+	if _, err := ParseRecordFormat("xml"); err == nil {
+		t.Error("error is expected for unknown format")
+	}
+}
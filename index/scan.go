@@ -0,0 +1,49 @@
+package index
+
+import (
+	"bufio"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WithMaxWordSize sets the maximal size of the word in bytes, bufio.MaxScanTokenSize by default. The longer words,
+// e.g. the whole line of the minified file, are skipped with the warning instead of failing the document.
+func WithMaxWordSize(size int) Option {
+	return func(i *Index) {
+		i.maxWordSize = size
+	}
+}
+
+// scanWords is the split function like bufio.ScanWords which drops the words not fitting in max bytes. The skipped
+// function is called for every dropped word.
+func scanWords(max int, skipped func()) bufio.SplitFunc {
+	var skipping bool
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		// start is the beginning of the words following the dropped one, the scanning continues from it at once, so
+		// the dropped word ending at the end of the full buffer does not stop the scanner.
+		start := 0
+		if skipping {
+			start = len(data)
+			for width, i := 0, 0; i < len(data); i += width {
+				var r rune
+				r, width = utf8.DecodeRune(data[i:])
+				if unicode.IsSpace(r) {
+					skipping = false
+					start = i
+					break
+				}
+			}
+			if skipping {
+				return len(data), nil, nil
+			}
+		}
+		advance, token, err := bufio.ScanWords(data[start:], atEOF)
+		if advance == 0 && token == nil && err == nil && start == 0 && len(data) >= max {
+			// The scanner buffer is full and the word is not finished yet.
+			skipping = true
+			skipped()
+			return len(data), nil, nil
+		}
+		return start + advance, token, err
+	}
+}
@@ -0,0 +1,76 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndex_AddSourceHugeWord(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		options []Option
+		size    int
+	}{
+		{"default", nil, 2 * bufio.MaxScanTokenSize},
+		{"configured", []Option{WithMaxWordSize(16)}, 100},
+	} {
+		engine := NewMemoryIndex()
+		i := NewIndex(engine, nil, tc.options...)
+		text := "apple " + strings.Repeat("x", tc.size) + " banana\norange"
+		if err := i.AddSource("file1", bytes.NewBufferString(text)); err != nil {
+			t.Error(err)
+		}
+		i.Close()
+
+		expected := map[string]MemoryOccurrences{
+			"appl":   {"file1": {0}},
+			"banana": {"file1": {1}},
+			"orang":  {"file1": {2}},
+		}
+		if !reflect.DeepEqual(engine.Index, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", tc.name, engine.Index, expected)
+		}
+	}
+}
+
+func TestScanWords(t *testing.T) {
+	var skipped int
+	scanner := bufio.NewScanner(strings.NewReader("short verylongword ok tail-longer-than-max"))
+	scanner.Buffer(nil, 8)
+	scanner.Split(scanWords(8, func() { skipped++ }))
+	var actual []string
+	for scanner.Scan() {
+		actual = append(actual, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Error(err)
+	}
+	expected := []string{"short", "ok"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+	if skipped != 2 {
+		t.Errorf("%d is not equal to expected 2", skipped)
+	}
+}
+
+func TestScanWordsBufferBoundary(t *testing.T) {
+	// The dropped word fills the scanner buffer exactly, the words after it are longer than the buffer together.
+	scanner := bufio.NewScanner(strings.NewReader("verylong ok fine tail done"))
+	scanner.Buffer(nil, 8)
+	scanner.Split(scanWords(8, func() {}))
+	var actual []string
+	for scanner.Scan() {
+		actual = append(actual, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Error(err)
+	}
+	expected := []string{"ok", "fine", "tail", "done"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
@@ -0,0 +1,91 @@
+package index
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// Manifest lists the shard files of the sharded index. The shard files are named relative to the manifest.
+type Manifest struct {
+	Shards []string `json:"shards"`
+}
+
+// Split distributes the documents of the index over n shards by the hash of their names in thread-safe way, so the
+// same document always goes to the same shard. The shards share the sources, the positions, the retained texts and the
+// words with the index, so the index must not be changed while they are used.
+func (i *MemoryIndex) Split(n int) []*MemoryIndex {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	if n < 1 {
+		n = 1
+	}
+	shards := make([]*MemoryIndex, n)
+	for k := range shards {
+		shards[k] = NewMemoryIndex()
+	}
+	shardOf := make(map[string]*MemoryIndex, len(i.Sources))
+	for name, source := range i.Sources {
+		hash := fnv.New32a()
+		hash.Write([]byte(name))
+		shard := shards[hash.Sum32()%uint32(n)]
+		shard.Sources[name] = source
+		if content, ok := i.Contents[name]; ok {
+			shard.Contents[name] = content
+		}
+		if words, ok := i.Words[name]; ok {
+			shard.Words[name] = words
+		}
+		shardOf[name] = shard
+	}
+	for token, occurrences := range i.Index {
+		for name, positions := range occurrences {
+			shard := shardOf[name]
+			if _, ok := shard.Index[token]; !ok {
+				shard.Index[token] = MemoryOccurrences{}
+			}
+			shard.Index[token][name] = positions
+		}
+	}
+	return shards
+}
+
+// DecodeShards decodes the shards with the decode function concurrently, at most workers shards at once, and returns
+// the engine federating them. Less than 1 worker decodes all shards at once. The shards hold different documents, so
+// they are merged by name. The decoded shards are closed if any shard can not be decoded.
+func DecodeShards(shards []string, workers int, decode func(shard string) (IndexEngine, error)) (*MultiEngine, error) {
+	if workers < 1 || workers > len(shards) {
+		workers = len(shards)
+	}
+	engines := make([]IndexEngine, len(shards))
+	errs := make([]error, len(shards))
+	jobs := make(chan int)
+	wg := &sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range jobs {
+				engines[k], errs[k] = decode(shards[k])
+			}
+		}()
+	}
+	for k := range shards {
+		jobs <- k
+	}
+	close(jobs)
+	wg.Wait()
+
+	for k, err := range errs {
+		if err == nil {
+			continue
+		}
+		for _, engine := range engines {
+			if engine != nil {
+				engine.Close()
+			}
+		}
+		return nil, fmt.Errorf("can not decode shard %s: %w", shards[k], err)
+	}
+	return NewMultiEngine(MergeDuplicates, engines...), nil
+}
@@ -0,0 +1,91 @@
+package index
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeShards(t *testing.T) {
+	engine := NewMemoryIndex()
+	i := NewIndex(engine, nil, WithSnippets(), WithRetainedContent())
+	for name, text := range map[string]string{
+		"file1": "apple banana",
+		"file2": "banana cherry",
+		"file3": "apple apple cherry",
+		"file4": "durian",
+		"file5": "banana banana banana",
+	} {
+		if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	files := map[string]*bytes.Buffer{}
+	var shards []string
+	for k, shard := range engine.Split(3) {
+		name := fmt.Sprintf("index.%d", k)
+		files[name] = &bytes.Buffer{}
+		if err := shard.Encode(gob.NewEncoder(files[name])); err != nil {
+			t.Fatal(err)
+		}
+		shards = append(shards, name)
+	}
+	decoded, err := DecodeShards(shards, 2, func(shard string) (IndexEngine, error) {
+		return Decode(gob.NewDecoder(files[shard]))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoded.Close()
+
+	names := func(i *Index, query string) []string {
+		results, err := i.Search(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, result := range results {
+			names = append(names, result.Document.Name)
+		}
+		return names
+	}
+	sharded := NewIndex(decoded, nil)
+	for _, query := range []string{"apple", "banana", "cherry", "durian", "apple cherry"} {
+		actual, expected := names(sharded, query), names(i, query)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", query, actual, expected)
+		}
+	}
+
+	// The shards keep the words and the texts of their documents.
+	results, err := NewIndex(decoded, nil, WithSnippets()).Search("durian")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Snippet != "<mark>durian</mark>" {
+		t.Errorf("%v is not equal to expected snippet %q", results, "<mark>durian</mark>")
+	}
+	content, err := sharded.Content("file4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "durian" {
+		t.Errorf("%q is not equal to expected %q", content, "durian")
+	}
+
+	broken := errors.New("broken")
+	_, err = DecodeShards([]string{"index.0", "index.1"}, 0, func(shard string) (IndexEngine, error) {
+		if shard == "index.1" {
+			return nil, broken
+		}
+		return NewMemoryIndex(), nil
+	})
+	if !errors.Is(err, broken) {
+		t.Errorf("%v is not equal to expected %v", err, broken)
+	}
+}
@@ -0,0 +1,238 @@
+package index
+
+import (
+	"errors"
+	"html"
+	"sort"
+	"strings"
+)
+
+// snippetSize is the number of the words of the snippets of the search results.
+const snippetSize = 15
+
+// SnippetProvider is the interface implemented by the engines which keep the original words of the documents, so the
+// search results can show the excerpts of the texts around the matched words.
+type SnippetProvider interface {
+	// AddWords stores the original words of the document starting from the position of the first word.
+	AddWords(name string, position int, words []string) error
+	// Snippet returns the excerpt of the document around the best match of the positions with the words at the
+	// positions wrapped in the markers. ErrUnknownDocument is returned if the words of the document are not stored.
+	Snippet(source *Source, positions []int, markers Markers) (string, error)
+}
+
+// WithSnippets makes the index store the original words of the documents added with AddDocument or AddSource functions
+// in the engine and set the snippets of the search results. The engine must implement SnippetProvider interface,
+// otherwise the results have no snippets. The snippets are not set if the index is created with WithCountsOnly option,
+// because the results have no positions then.
+func WithSnippets() Option {
+	return func(i *Index) {
+		i.snippets = true
+	}
+}
+
+// storeWords passes the words of the text starting from the position to the engine if the index is created with
+// WithSnippets option.
+func (i *Index) storeWords(source Source, data []byte, position int) error {
+	if !i.snippets {
+		return nil
+	}
+	provider, ok := i.getEngine().(SnippetProvider)
+	if !ok {
+		return nil
+	}
+	var words []string
+	// The tokens of the same word share the position, so the word is taken once.
+	_, err := i.scanTokens(source, data, position, func(token string, word string, wordPosition int) error {
+		if wordPosition == position+len(words) {
+			words = append(words, word)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return provider.AddWords(source.Name, position, words)
+}
+
+// setSnippets sets the snippets of the results around the positions of their body tokens with the matched words
+// wrapped in the markers if the index is created with WithSnippets option. The results of the documents without the
+// stored words have no snippets.
+func (i *Index) setSnippets(engine IndexEngine, results []Result, markers Markers) error {
+	if !i.snippets {
+		return nil
+	}
+	provider, ok := engine.(SnippetProvider)
+	if !ok {
+		return nil
+	}
+	for k := range results {
+		var positions []int
+		for token, tokenPositions := range results[k].Positions {
+			if !strings.Contains(token, fieldSeparator) {
+				positions = append(positions, tokenPositions...)
+			}
+		}
+		if len(positions) == 0 {
+			continue
+		}
+		sort.Ints(positions)
+		snippet, err := provider.Snippet(results[k].Document, positions, markers)
+		if errors.Is(err, ErrUnknownDocument) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		results[k].Snippet = snippet
+	}
+	return nil
+}
+
+// AddWords stores the words of the document in thread-safe way. The words stored at the same positions before are
+// replaced.
+func (i *MemoryIndex) AddWords(name string, position int, words []string) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+	stored := i.Words[name]
+	if len(stored) < position+len(words) {
+		stored = append(stored, make([]string, position+len(words)-len(stored))...)
+	}
+	copy(stored[position:], words)
+	i.Words[name] = stored
+	return nil
+}
+
+// Snippet returns the excerpt of the stored words of the document in thread-safe way.
+func (i *MemoryIndex) Snippet(source *Source, positions []int, markers Markers) (string, error) {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	words, ok := i.Words[source.Name]
+	if !ok {
+		return "", ErrUnknownDocument
+	}
+	return wordsSnippet(words, positions, snippetSize, markers), nil
+}
+
+// wordsSnippet returns the window of at most size words which is the best excerpt of the words matched at the
+// positions, see bestWindow function. The matched words are told apart by their text. The words at the positions are
+// wrapped in the markers, the words are HTML-escaped and joined with spaces. The ellipses mark the words cut from the
+// beginning and the end. The empty string is returned if no position refers to the words.
+func wordsSnippet(words []string, positions []int, size int, markers Markers) string {
+	terms := make([][]string, len(words))
+	matched := false
+	for _, position := range positions {
+		if position >= 0 && position < len(words) {
+			terms[position] = []string{words[position]}
+			matched = true
+		}
+	}
+	if !matched || size <= 0 {
+		return ""
+	}
+
+	first := bestWindow(terms, size)
+	last := windowEnd(first, size, len(words))
+	parts := make([]string, 0, last-first+2)
+	if first > 0 {
+		parts = append(parts, "...")
+	}
+	for k := first; k < last; k++ {
+		// The gap between the joined documents has no words.
+		if words[k] == "" {
+			continue
+		}
+		word := html.EscapeString(words[k])
+		if len(terms[k]) > 0 {
+			word = markers.Pre + word + markers.Post
+		}
+		parts = append(parts, word)
+	}
+	if last < len(words) {
+		parts = append(parts, "...")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Snippet returns the window of at most size words of the text which is the best preview of the document for the
+// query, see bestWindow function. The beginning of the text is returned if no word matches, the whole text is returned
+// if the size is not positive. The words are not highlighted, see Highlight function.
+func (i *Index) Snippet(text string, query string, size int) string {
+	if size <= 0 {
+		return text
+	}
+	analyzer := i.defaultAnalyzer()
+	tokens, _, _ := i.parseQuery(query, analyzer)
+	matched := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		matched[token] = true
+	}
+
+	var bounds [][2]int
+	var terms [][]string
+	for offset := 0; offset < len(text); {
+		start, end := analyzer.nextWord(text[offset:])
+		if start < 0 {
+			break
+		}
+		start, end = offset+start, offset+end
+		var wordTerms []string
+		for _, token := range analyzer.Analyze(text[start:end]) {
+			if query, ok := matchedToken(token, matched); ok {
+				wordTerms = append(wordTerms, query)
+			}
+		}
+		bounds = append(bounds, [2]int{start, end})
+		terms = append(terms, wordTerms)
+		offset = end
+	}
+	if len(bounds) == 0 {
+		return ""
+	}
+
+	first := bestWindow(terms, size)
+	last := windowEnd(first, size, len(bounds)) - 1
+	return text[bounds[first][0]:bounds[last][1]]
+}
+
+// bestWindow returns the first word of the window of at most size words which is the best excerpt for the query, the
+// terms list the query terms matched by every word. The windows start at the matched words and are moved back to keep
+// size words at the end of the text. They are scored by the number of the distinct terms they contain, then by the
+// number of the matched words, so the window where the terms are dense wins over the first match. The earlier window
+// wins the ties. The window starts at the first word if no word matches.
+func bestWindow(terms [][]string, size int) int {
+	best, bestTerms, bestMatches := 0, 0, 0
+	for k := range terms {
+		if len(terms[k]) == 0 {
+			continue
+		}
+		first := k
+		if first > len(terms)-size {
+			first = len(terms) - size
+		}
+		if first < 0 {
+			first = 0
+		}
+		distinct := map[string]bool{}
+		matches := 0
+		for _, wordTerms := range terms[first:windowEnd(first, size, len(terms))] {
+			if len(wordTerms) > 0 {
+				matches++
+			}
+			for _, term := range wordTerms {
+				distinct[term] = true
+			}
+		}
+		if len(distinct) > bestTerms || (len(distinct) == bestTerms && matches > bestMatches) {
+			best, bestTerms, bestMatches = first, len(distinct), matches
+		}
+	}
+	return best
+}
+
+// windowEnd returns the end of the window of size words starting at first, it is capped by the number of the words.
+func windowEnd(first int, size int, count int) int {
+	if first+size > count {
+		return count
+	}
+	return first + size
+}
@@ -0,0 +1,92 @@
+package index
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIndex_Snippet(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil)
+	defer i.Close()
+	text := "Apple pie is sweet. Bake it slowly, then serve. Try apples, bananas and cherries in the smoothie!"
+	for _, test := range []struct {
+		query    string
+		size     int
+		expected string
+	}{
+		// The first match contains one term, the best window contains all of them.
+		{"apple banana cherry", 5, "apples, bananas and cherries in"},
+		{"apple", 3, "Apple pie is"},
+		// The ties are won by the earlier window.
+		{"apple pie", 1, "Apple"},
+		{"apple pie", 2, "Apple pie"},
+		{"durian", 3, "Apple pie is"},
+		{"apple", 0, text},
+	} {
+		if actual := i.Snippet(text, test.query, test.size); actual != test.expected {
+			t.Errorf("%s: %q is not equal to expected %q", test.query, actual, test.expected)
+		}
+	}
+	if actual := i.Snippet("...", "apple", 3); actual != "" {
+		t.Errorf("%q is not equal to expected %q", actual, "")
+	}
+}
+
+func TestIndex_SearchSnippets(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil, WithSnippets())
+	text := "One two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen " +
+		"seventeen eighteen nineteen twenty. The apples grow on the apple tree in the garden, twenty-one twenty-two " +
+		"twenty-three twenty-four twenty-five twenty-six."
+	if err := i.AddSource("orchard", strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.AddSource("short", strings.NewReader("apple")); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+
+	results, err := i.Search("apple garden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("%d is not equal to expected %d", len(results), 1)
+	}
+	// The stop words have no positions, so they are not shown.
+	expected := "... eighteen nineteen twenty <mark>apples</mark> grow <mark>apple</mark> tree <mark>garden</mark> " +
+		"twenty twenty twenty three twenty twenty twenty"
+	if results[0].Snippet != expected {
+		t.Errorf("%q is not equal to expected %q", results[0].Snippet, expected)
+	}
+
+	results, err = i.SearchWithOptions("apple", SearchOptions{Markers: Markers{Pre: "**", Post: "**"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if !strings.Contains(result.Snippet, "**apple") {
+			t.Errorf("%q does not contain the query term", result.Snippet)
+		}
+	}
+}
+
+func TestWordsSnippet(t *testing.T) {
+	words := []string{"a", "b", "c", "d", "e", "f"}
+	for _, test := range []struct {
+		positions []int
+		size      int
+		expected  string
+	}{
+		{[]int{0}, 3, "<b>a</b> b c ..."},
+		{[]int{5}, 3, "... d e <b>f</b>"},
+		{[]int{1, 4, 5}, 3, "... d <b>e</b> <b>f</b>"},
+		{[]int{2}, 10, "a b <b>c</b> d e f"},
+		// The dense matches win over the first match, as in Snippet function.
+		{[]int{0, 3, 4}, 3, "... <b>d</b> <b>e</b> f"},
+		{[]int{10}, 3, ""},
+	} {
+		if actual := wordsSnippet(words, test.positions, test.size, Markers{Pre: "<b>", Post: "</b>"}); actual != test.expected {
+			t.Errorf("%v: %q is not equal to expected %q", test.positions, actual, test.expected)
+		}
+	}
+}
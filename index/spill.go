@@ -0,0 +1,218 @@
+package index
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrWriteOnly is returned when the write-only engine is asked for the occurrences.
+var ErrWriteOnly = errors.New("index engine is write-only, encode it and decode to search")
+
+// SpillIndex is the write-only engine to build large indexes which do not fit into memory.
+// Postings are kept in memory until their number reaches the limit, then they are spilled to the sorted temporary
+// file. Use EncodeStream to merge the spilled files into the streamed index.
+type SpillIndex struct {
+	m      sync.Mutex
+	limit  int
+	size   int
+	buffer *MemoryIndex
+	dir    string
+	runs   []string
+}
+
+// NewSpillIndex creates new engine keeping up to limit positions in memory. Temporary files are created in the
+// dir, system temporary directory is used if it is empty.
+func NewSpillIndex(dir string, limit int) (*SpillIndex, error) {
+	tmp, err := ioutil.TempDir(dir, "index-spill")
+	if err != nil {
+		return nil, fmt.Errorf("can not create temporary directory: %w", err)
+	}
+	return &SpillIndex{
+		limit:  limit,
+		buffer: NewMemoryIndex(),
+		dir:    tmp,
+	}, nil
+}
+
+// Add adds new token, document and position to the memory and spills it to the disk when the limit is reached.
+func (i *SpillIndex) Add(token string, position int, source Source) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+	if err := i.buffer.Add(token, position, source); err != nil {
+		return err
+	}
+	i.size++
+	if i.size < i.limit {
+		return nil
+	}
+	return i.spill()
+}
+
+// spill writes the postings from the memory to the new temporary file sorted by token and document.
+func (i *SpillIndex) spill() error {
+	if i.size == 0 {
+		return nil
+	}
+	postings := make([]Posting, 0, len(i.buffer.Index))
+	for token, occurrences := range i.buffer.Index {
+		for document, positions := range occurrences {
+			postings = append(postings, Posting{
+				Token:     token,
+				Document:  document,
+				Positions: positions,
+				Source:    i.buffer.Sources[document],
+			})
+		}
+	}
+	sort.Slice(postings, func(a, b int) bool {
+		return postings[a].less(postings[b])
+	})
+
+	name := filepath.Join(i.dir, fmt.Sprintf("run%d", len(i.runs)))
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("can not create spill file: %w", err)
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(w)
+	for _, posting := range postings {
+		if err := encoder.Encode(posting); err != nil {
+			return fmt.Errorf("can not write spill file: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("can not write spill file: %w", err)
+	}
+
+	i.runs = append(i.runs, name)
+	i.buffer = NewMemoryIndex()
+	i.size = 0
+	return nil
+}
+
+// Get is not supported by the write-only engine.
+func (i *SpillIndex) Get(tokens []string) (map[string]Occurrences, error) {
+	return nil, ErrWriteOnly
+}
+
+// Iterate merges all spilled files and calls fn for every posting ordered by token and document.
+// Positions of the document spilled to several files are merged.
+func (i *SpillIndex) Iterate(fn func(posting Posting) error) error {
+	i.m.Lock()
+	defer i.m.Unlock()
+	if err := i.spill(); err != nil {
+		return err
+	}
+
+	cursors := &runHeap{}
+	for _, name := range i.runs {
+		file, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("can not open spill file: %w", err)
+		}
+		defer file.Close()
+		cursor := &runCursor{decoder: gob.NewDecoder(bufio.NewReader(file))}
+		ok, err := cursor.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			*cursors = append(*cursors, cursor)
+		}
+	}
+	heap.Init(cursors)
+
+	var current *Posting
+	for cursors.Len() > 0 {
+		cursor := (*cursors)[0]
+		posting := cursor.posting
+		if current != nil && current.Token == posting.Token && current.Document == posting.Document {
+			current.Positions = append(current.Positions, posting.Positions...)
+			if current.Source == nil {
+				current.Source = posting.Source
+			}
+		} else {
+			if current != nil {
+				sort.Ints(current.Positions)
+				if err := fn(*current); err != nil {
+					return err
+				}
+			}
+			current = &posting
+		}
+
+		ok, err := cursor.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(cursors, 0)
+		} else {
+			heap.Pop(cursors)
+		}
+	}
+	if current != nil {
+		sort.Ints(current.Positions)
+		return fn(*current)
+	}
+	return nil
+}
+
+// Close removes the temporary files.
+func (i *SpillIndex) Close() {
+	if err := os.RemoveAll(i.dir); err != nil {
+		log.Error().Err(err).Msgf("can not remove %s", i.dir)
+	}
+}
+
+func (p Posting) less(other Posting) bool {
+	if p.Token != other.Token {
+		return p.Token < other.Token
+	}
+	return p.Document < other.Document
+}
+
+// runCursor reads postings from the spilled file one by one.
+type runCursor struct {
+	decoder *gob.Decoder
+	posting Posting
+}
+
+func (c *runCursor) next() (bool, error) {
+	c.posting = Posting{}
+	err := c.decoder.Decode(&c.posting)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("can not read spill file: %w", err)
+	}
+	return true, nil
+}
+
+// runHeap orders the cursors by their current postings.
+type runHeap []*runCursor
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(a, b int) bool  { return h[a].posting.less(h[b].posting) }
+func (h runHeap) Swap(a, b int)       { h[a], h[b] = h[b], h[a] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*runCursor)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,147 @@
+package index
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSpillIndex(t *testing.T) {
+	i, err := NewSpillIndex("", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.Close()
+	s1 := Source{Name: "file1", ModTime: time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)}
+	s2 := Source{Name: "file2"}
+	if err := i.Add("appl", 3, s2); err != nil {
+		t.Error(err)
+	}
+	if err := i.Add("banana", 1, s1); err != nil {
+		t.Error(err)
+	}
+	if err := i.Add("appl", 0, s1); err != nil {
+		t.Error(err)
+	}
+	if err := i.Add("appl", 0, s2); err != nil {
+		t.Error(err)
+	}
+	if err := i.Add("orang", 2, s2); err != nil {
+		t.Error(err)
+	}
+	if len(i.runs) != 2 {
+		t.Errorf("%v is not equal to expected %v", len(i.runs), 2)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := EncodeStream(i, gob.NewEncoder(buf)); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeStream(gob.NewDecoder(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]MemoryOccurrences{
+		"appl":   {"file1": []int{0}, "file2": []int{0, 3}},
+		"banana": {"file1": []int{1}},
+		"orang":  {"file2": []int{2}},
+	}
+	if !reflect.DeepEqual(decoded.Index, expected) {
+		t.Errorf("%v is not equal to expected %v", decoded.Index, expected)
+	}
+	expectedSources := map[string]*Source{"file1": &s1, "file2": &s2}
+	if !reflect.DeepEqual(decoded.Sources, expectedSources) {
+		t.Errorf("%v is not equal to expected %v", decoded.Sources, expectedSources)
+	}
+
+	if _, err := i.Get([]string{"appl"}); err != ErrWriteOnly {
+		t.Errorf("%v is not equal to expected %v", err, ErrWriteOnly)
+	}
+}
+
+type iteratorEngine interface {
+	IndexEngine
+	Iterator
+}
+
+func benchmarkBuild(b *testing.B, newEngine func() (iteratorEngine, error)) {
+	var peak uint64
+	stats := &runtime.MemStats{}
+	for n := 0; n < b.N; n++ {
+		runtime.GC()
+		engine, err := newEngine()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for d := 0; d < 100; d++ {
+			source := Source{Name: fmt.Sprintf("file%d", d)}
+			for p := 0; p < 5000; p++ {
+				if err := engine.Add(fmt.Sprintf("token%d", p%2000+d), p, source); err != nil {
+					b.Fatal(err)
+				}
+				if p%1000 == 0 {
+					runtime.ReadMemStats(stats)
+					if stats.HeapInuse > peak {
+						peak = stats.HeapInuse
+					}
+				}
+			}
+		}
+		if err := EncodeStream(engine, gob.NewEncoder(ioutil.Discard)); err != nil {
+			b.Fatal(err)
+		}
+		engine.Close()
+	}
+	b.ReportMetric(float64(peak), "peak-heap-bytes")
+}
+
+func BenchmarkMemoryIndex_Build(b *testing.B) {
+	benchmarkBuild(b, func() (iteratorEngine, error) {
+		return NewMemoryIndex(), nil
+	})
+}
+
+func BenchmarkSpillIndex_Build(b *testing.B) {
+	benchmarkBuild(b, func() (iteratorEngine, error) {
+		return NewSpillIndex("", 50000)
+	})
+}
+
+func TestSpillIndex_PositionsPerDocument(t *testing.T) {
+	spill, err := NewSpillIndex("", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spill.Close()
+	i := NewIndex(spill, nil)
+	if err := i.AddSource("file1", bytes.NewBufferString("apple banana")); err != nil {
+		t.Error(err)
+	}
+	if err := i.AddSource("file2", bytes.NewBufferString("cherry apple")); err != nil {
+		t.Error(err)
+	}
+	i.Close()
+
+	buf := &bytes.Buffer{}
+	if err := EncodeStream(spill, gob.NewEncoder(buf)); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeStream(gob.NewDecoder(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last token of file1 and the first token of file2 are not adjacent after the runs are merged.
+	expected := map[string]MemoryOccurrences{
+		"appl":   {"file1": {0}, "file2": {1}},
+		"banana": {"file1": {1}},
+		"cherri": {"file2": {0}},
+	}
+	if !reflect.DeepEqual(decoded.Index, expected) {
+		t.Errorf("%v is not equal to expected %v", decoded.Index, expected)
+	}
+}
@@ -0,0 +1,225 @@
+package index
+
+import (
+	"fmt"
+)
+
+// Stats is the size of the index.
+type Stats struct {
+	// Documents is the number of the indexed documents.
+	Documents int
+	// Tokens is the number of the unique tokens found in the documents.
+	Tokens int
+	// Occurrences is the total number of the token positions.
+	Occurrences int
+}
+
+// StatsEngine is the interface implemented by the engines which can report the size of the index.
+type StatsEngine interface {
+	Stats() (Stats, error)
+}
+
+// Stats returns the size of the index, e.g. to plan the capacity. The engine must implement StatsEngine interface,
+// otherwise ErrNotSupported is returned.
+func (i *Index) Stats() (Stats, error) {
+	return i.StatsTenant("")
+}
+
+// StatsTenant returns the size of the tenant's index. Empty tenant returns the size of the whole engine.
+func (i *Index) StatsTenant(tenant string) (Stats, error) {
+	engine, err := i.scoped(tenant)
+	if err != nil {
+		return Stats{}, err
+	}
+	statsEngine, ok := engine.(StatsEngine)
+	if !ok {
+		return Stats{}, ErrNotSupported
+	}
+	return statsEngine.Stats()
+}
+
+// Stats returns the size of the MemoryIndex in thread-safe way.
+func (i *MemoryIndex) Stats() (Stats, error) {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	stats := Stats{Documents: len(i.Sources)}
+	for _, occurrences := range i.Index {
+		if len(occurrences) == 0 {
+			continue
+		}
+		stats.Tokens++
+		for _, positions := range occurrences {
+			stats.Occurrences += len(positions)
+		}
+	}
+	return stats, nil
+}
+
+// Stats merges the spilled postings to count the size of the index.
+func (i *SpillIndex) Stats() (Stats, error) {
+	stats := Stats{}
+	documents := map[string]struct{}{}
+	token := ""
+	err := i.Iterate(func(posting Posting) error {
+		if stats.Tokens == 0 || posting.Token != token {
+			token = posting.Token
+			stats.Tokens++
+		}
+		documents[posting.Document] = struct{}{}
+		stats.Occurrences += len(posting.Positions)
+		return nil
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+	stats.Documents = len(documents)
+	return stats, nil
+}
+
+// Stats sums the documents and the occurrences of all engines and counts the tokens found by any of them, so the size
+// of the sharded index is exact, while the documents held by several federated engines are counted once per engine.
+// All engines must implement StatsEngine and TokenIterator interfaces, otherwise ErrNotSupported is returned.
+func (m *MultiEngine) Stats() (Stats, error) {
+	stats := Stats{}
+	tokens := map[string]struct{}{}
+	for _, engine := range m.engines {
+		statsEngine, ok := engine.(StatsEngine)
+		if !ok {
+			return Stats{}, ErrNotSupported
+		}
+		iterator, ok := engine.(TokenIterator)
+		if !ok {
+			return Stats{}, ErrNotSupported
+		}
+		engineStats, err := statsEngine.Stats()
+		if err != nil {
+			return Stats{}, err
+		}
+		stats.Documents += engineStats.Documents
+		stats.Occurrences += engineStats.Occurrences
+		err = iterator.IterateTokens(func(token string) error {
+			tokens[token] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return Stats{}, err
+		}
+	}
+	stats.Tokens = len(tokens)
+	return stats, nil
+}
+
+// Stats returns the size of the index in the database.
+func (i *DbIndex) Stats() (Stats, error) {
+	return i.stats("")
+}
+
+func (i *DbIndex) stats(tenant string) (Stats, error) {
+	var stats struct {
+		Documents   int `pg:"documents"`
+		Tokens      int `pg:"tokens"`
+		Occurrences int `pg:"occurrences"`
+	}
+	_, err := i.pg.QueryOne(
+		&stats,
+		`SELECT (SELECT count(*) FROM documents WHERE tenant_id = ?) AS documents,
+			count(DISTINCT token_id) AS tokens, count(*) AS occurrences
+			FROM occurrences WHERE tenant_id = ?;`,
+		tenant,
+		tenant,
+	)
+	if err != nil {
+		return Stats{}, err
+	}
+	return Stats{Documents: stats.Documents, Tokens: stats.Tokens, Occurrences: stats.Occurrences}, nil
+}
+
+// Stats returns the size of the tenant's index in the database.
+func (t *TenantIndex) Stats() (Stats, error) {
+	return t.stats(t.tenant)
+}
+
+// DocumentStats is the size of the indexed document.
+type DocumentStats struct {
+	// Length is the number of the token positions in the document.
+	Length int
+	// Vocabulary is the number of the distinct tokens in the document.
+	Vocabulary int
+}
+
+// DocumentStatsEngine is the interface implemented by the engines which can report the size of the document.
+type DocumentStatsEngine interface {
+	// DocumentStats returns the size of the document. ErrUnknownDocument is returned if the document is not indexed.
+	DocumentStats(name string) (DocumentStats, error)
+}
+
+// DocumentStats returns the length and the vocabulary size of the document, e.g. to tell the content-rich documents
+// from the thin ones. The engine must implement DocumentStatsEngine interface, otherwise ErrNotSupported is returned.
+func (i *Index) DocumentStats(name string) (DocumentStats, error) {
+	return i.DocumentStatsTenant("", name)
+}
+
+// DocumentStatsTenant returns the size of the tenant's document. Empty tenant looks for the document in the whole
+// engine.
+func (i *Index) DocumentStatsTenant(tenant string, name string) (DocumentStats, error) {
+	engine, err := i.scoped(tenant)
+	if err != nil {
+		return DocumentStats{}, err
+	}
+	documentStats, ok := engine.(DocumentStatsEngine)
+	if !ok {
+		return DocumentStats{}, ErrNotSupported
+	}
+	return documentStats.DocumentStats(name)
+}
+
+// DocumentStats counts the positions and the distinct tokens of the document in thread-safe way.
+func (i *MemoryIndex) DocumentStats(name string) (DocumentStats, error) {
+	i.m.RLock()
+	defer i.m.RUnlock()
+	if _, ok := i.Sources[name]; !ok {
+		return DocumentStats{}, ErrUnknownDocument
+	}
+	stats := DocumentStats{}
+	for _, occurrences := range i.Index {
+		if positions, ok := occurrences[name]; ok && len(positions) > 0 {
+			stats.Vocabulary++
+			stats.Length += len(positions)
+		}
+	}
+	return stats, nil
+}
+
+// DocumentStats counts the occurrences and the distinct tokens of the document in the database.
+func (i *DbIndex) DocumentStats(name string) (DocumentStats, error) {
+	return i.documentStats("", name)
+}
+
+func (i *DbIndex) documentStats(tenant string, name string) (DocumentStats, error) {
+	var stats struct {
+		Documents  int `pg:"documents"`
+		Length     int `pg:"length"`
+		Vocabulary int `pg:"vocabulary"`
+	}
+	_, err := i.pg.QueryOne(
+		&stats,
+		`SELECT count(DISTINCT d.id) AS documents, count(o.document_id) AS length,
+			count(DISTINCT o.token_id) AS vocabulary
+			FROM documents AS d LEFT JOIN occurrences AS o ON o.document_id = d.id
+			WHERE d.tenant_id = ? AND d.name = ?;`,
+		tenant,
+		name,
+	)
+	if err != nil {
+		return DocumentStats{}, fmt.Errorf("error counting %s %w", name, err)
+	}
+	if stats.Documents == 0 {
+		return DocumentStats{}, ErrUnknownDocument
+	}
+	return DocumentStats{Length: stats.Length, Vocabulary: stats.Vocabulary}, nil
+}
+
+// DocumentStats counts the occurrences and the distinct tokens of the tenant's document in the database.
+func (t *TenantIndex) DocumentStats(name string) (DocumentStats, error) {
+	return t.documentStats(t.tenant, name)
+}
@@ -0,0 +1,115 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	spill, err := NewSpillIndex("", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spill.Close()
+
+	for _, engine := range []interface {
+		IndexEngine
+		StatsEngine
+	}{NewMemoryIndex(), spill} {
+		i := NewIndex(engine, nil)
+		if err := i.AddSource("file1", bytes.NewBufferString("an apple banana raspberry")); err != nil {
+			t.Error(err)
+		}
+		if err := i.AddSource("file2", bytes.NewBufferString("apple apple the banana orange")); err != nil {
+			t.Error(err)
+		}
+		i.Close()
+
+		actual, err := engine.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := Stats{Documents: 2, Tokens: 4, Occurrences: 7}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%T: %v is not equal to expected %v", engine, actual, expected)
+		}
+	}
+}
+
+func TestIndex_DocumentStats(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil)
+	for name, text := range map[string]string{
+		"rich": "apple banana raspberry orange lemon",
+		"thin": "apple apple apple apple banana",
+	} {
+		if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+
+	for _, test := range []struct {
+		name     string
+		expected DocumentStats
+	}{
+		{"rich", DocumentStats{Length: 5, Vocabulary: 5}},
+		{"thin", DocumentStats{Length: 5, Vocabulary: 2}},
+	} {
+		actual, err := i.DocumentStats(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: %+v is not equal to expected %+v", test.name, actual, test.expected)
+		}
+	}
+
+	if _, err := i.DocumentStats("file3"); !errors.Is(err, ErrUnknownDocument) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownDocument)
+	}
+	if _, err := NewIndex(&countEngine{}, nil).DocumentStats("rich"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+}
+
+func TestIndex_Stats(t *testing.T) {
+	build := func(documents map[string]string) *MemoryIndex {
+		engine := NewMemoryIndex()
+		i := NewIndex(engine, nil)
+		for name, text := range documents {
+			if err := i.AddSourceSync(name, bytes.NewBufferString(text)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		i.Close()
+		return engine
+	}
+	first := build(map[string]string{"file1": "apple banana", "file2": "banana"})
+	second := build(map[string]string{"file3": "apple cherry cherry"})
+
+	for _, test := range []struct {
+		name     string
+		engine   IndexEngine
+		expected Stats
+	}{
+		{"memory", first, Stats{Documents: 2, Tokens: 2, Occurrences: 3}},
+		{"multi", NewMultiEngine(MergeDuplicates, first, second), Stats{Documents: 3, Tokens: 3, Occurrences: 6}},
+	} {
+		actual, err := NewIndex(test.engine, nil).Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: %+v is not equal to expected %+v", test.name, actual, test.expected)
+		}
+	}
+
+	if _, err := NewIndex(&countEngine{}, nil).Stats(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("%v is not equal to expected %v", err, ErrNotSupported)
+	}
+	if _, err := NewIndex(first, nil).StatsTenant("tenant"); !errors.Is(err, ErrTenantsNotSupported) {
+		t.Errorf("%v is not equal to expected %v", err, ErrTenantsNotSupported)
+	}
+}
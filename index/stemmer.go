@@ -0,0 +1,69 @@
+package index
+
+import (
+	"strings"
+
+	"github.com/reiver/go-porterstemmer"
+)
+
+// Stemmer reduces the word to its stem. The same stemmer must be used to build and to search over the index.
+type Stemmer func(word string) string
+
+// PorterStemmer is the default stemmer implementing Porter algorithm.
+// It conflates many word forms, e.g. universe and university, favouring recall over precision.
+func PorterStemmer(word string) string {
+	return porterstemmer.StemString(word)
+}
+
+// LightStemmer lower cases the word and strips English plural suffixes only (S-stemmer).
+// It keeps more words distinct than PorterStemmer, favouring precision over recall.
+func LightStemmer(word string) string {
+	word = strings.ToLower(word)
+	switch {
+	case strings.HasSuffix(word, "ies") && !strings.HasSuffix(word, "eies") && !strings.HasSuffix(word, "aies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "es") && !strings.HasSuffix(word, "aes") &&
+		!strings.HasSuffix(word, "ees") && !strings.HasSuffix(word, "oes"):
+		return strings.TrimSuffix(word, "s")
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "ss"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// GermanStemmer lower cases the word, replaces umlauts and strips the common German inflectional suffixes.
+// It is the minimal stemmer favouring precision over recall, e.g. Häuser and Haus share the stem haus.
+func GermanStemmer(word string) string {
+	runes := []rune(strings.NewReplacer("ä", "a", "ö", "o", "ü", "u").Replace(strings.ToLower(word)))
+	n := len(runes)
+	if n < 5 {
+		return string(runes)
+	}
+	suffix := func(s string) bool {
+		return strings.HasSuffix(string(runes), s)
+	}
+	switch {
+	case n > 6 && suffix("nen"):
+		return string(runes[:n-3])
+	case n > 5 && (suffix("en") || suffix("se") || suffix("es") || suffix("er")):
+		return string(runes[:n-2])
+	case suffix("n") || suffix("e") || suffix("s") || suffix("r"):
+		return string(runes[:n-1])
+	}
+	return string(runes)
+}
+
+// Stemmers lists the available stemmers by name.
+var Stemmers = map[string]Stemmer{
+	"porter": PorterStemmer,
+	"light":  LightStemmer,
+	"german": GermanStemmer,
+}
+
+// stem reduces the word with the stemmer of the analyzer, PorterStemmer is used if it is not set.
+func (a Analyzer) stem(word string) string {
+	if a.Stemmer == nil {
+		return PorterStemmer(word)
+	}
+	return a.Stemmer(word)
+}
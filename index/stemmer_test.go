@@ -0,0 +1,75 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestLightStemmer(t *testing.T) {
+	for word, expected := range map[string]string{
+		"Apples":     "apple",
+		"ponies":     "pony",
+		"caresses":   "caresse",
+		"toes":       "toe",
+		"bus":        "bus",
+		"glass":      "glass",
+		"universe":   "universe",
+		"university": "university",
+	} {
+		if actual := LightStemmer(word); actual != expected {
+			t.Errorf("%s: %s is not equal to expected %s", word, actual, expected)
+		}
+	}
+}
+
+func TestGermanStemmer(t *testing.T) {
+	for word, expected := range map[string]string{
+		"Häuser":      "haus",
+		"Haus":        "haus",
+		"Stadt":       "stadt",
+		"Städte":      "stadt",
+		"Lehrerinnen": "lehrerin",
+		"Kindern":     "kinder",
+		"alt":         "alt",
+	} {
+		if actual := GermanStemmer(word); actual != expected {
+			t.Errorf("%s: %s is not equal to expected %s", word, actual, expected)
+		}
+	}
+}
+
+func searchNames(t *testing.T, stemmer Stemmer, query string) []string {
+	e := NewMemoryIndex()
+	i := NewIndex(e, nil, WithStemmer(stemmer))
+	if err := i.AddSource("file1", bytes.NewBufferString("the universe")); err != nil {
+		t.Error(err)
+	}
+	if err := i.AddSource("file2", bytes.NewBufferString("the university")); err != nil {
+		t.Error(err)
+	}
+	i.Close()
+
+	results, err := i.Search(query)
+	if err != nil {
+		t.Error(err)
+	}
+	names := []string{}
+	for _, result := range results {
+		names = append(names, result.Document.Name)
+	}
+	return names
+}
+
+func TestIndex_SearchStemmer(t *testing.T) {
+	actual := searchNames(t, LightStemmer, "universe")
+	expected := []string{"file1"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	actual = searchNames(t, PorterStemmer, "universe")
+	if len(actual) != 2 {
+		t.Errorf("porter stemmer must find both documents, found %v", actual)
+	}
+}
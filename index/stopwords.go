@@ -0,0 +1,125 @@
+package index
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/zoomio/stopwords"
+)
+
+// stopwordsFiles contains the stopword files of the languages named by the language code, e.g. `stopwords/de.txt`, so
+// they are found wherever the binary runs.
+//
+//go:embed stopwords
+var stopwordsFiles embed.FS
+
+// languageCode matches ISO 639 language codes.
+var languageCode = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// Stopwords is the set of the additional words ignored while indexing and searching.
+type Stopwords map[string]struct{}
+
+// WithStopwords ignores the stopwords in addition to the built-in English ones.
+// The same stopwords must be used to build and to search over the index.
+func WithStopwords(stopwords Stopwords) Option {
+	return func(i *Index) {
+		i.stopwords = stopwords
+	}
+}
+
+// WithStopwordsOnly ignores the stopwords instead of the built-in English ones, e.g. to index the documents in other
+// language or to keep the English words ignored by default. Nil stopwords keep the built-in ones as is.
+// The same stopwords must be used to build and to search over the index.
+func WithStopwordsOnly(stopwords Stopwords) Option {
+	return func(i *Index) {
+		if stopwords == nil {
+			return
+		}
+		i.stopwords = stopwords
+		i.stopwordsOnly = true
+	}
+}
+
+// NewStopwords creates the set of the words, the case of the words is ignored.
+func NewStopwords(words ...string) Stopwords {
+	s := Stopwords{}
+	for _, word := range words {
+		s[strings.ToLower(word)] = struct{}{}
+	}
+	return s
+}
+
+// ReadStopwords reads the stopwords written one per line. Empty lines and lines starting with `#` are skipped.
+func ReadStopwords(r io.Reader) (Stopwords, error) {
+	s := Stopwords{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		s[strings.ToLower(word)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadStopwords reads the stopwords from the file. The name is either the path to the file or the language code of
+// the embedded stopwords, see LanguageStopwords.
+func LoadStopwords(name string) (Stopwords, error) {
+	if _, err := os.Stat(name); os.IsNotExist(err) && languageCode.MatchString(name) {
+		s, err := LanguageStopwords(name)
+		if err == nil && s == nil {
+			return nil, fmt.Errorf("%w: no stopwords of %s", ErrUnknownLanguage, name)
+		}
+		return s, err
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("can not open stopwords %s: %w", name, err)
+	}
+	defer file.Close()
+	s, err := ReadStopwords(file)
+	if err != nil {
+		return nil, fmt.Errorf("can not read stopwords %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// LanguageStopwords returns the embedded stopwords of the language by ISO 639 language code, nil if there are no
+// stopwords of the language, e.g. of English ones built in.
+func LanguageStopwords(language string) (Stopwords, error) {
+	file, err := stopwordsFiles.Open("stopwords/" + language + ".txt")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not open stopwords %s: %w", language, err)
+	}
+	defer file.Close()
+	s, err := ReadStopwords(file)
+	if err != nil {
+		return nil, fmt.Errorf("can not read stopwords %s: %w", language, err)
+	}
+	return s, nil
+}
+
+func (s Stopwords) contains(word string) bool {
+	_, ok := s[strings.ToLower(word)]
+	return ok
+}
+
+// isStopWord checks both the original word and its stem. The built-in English stopwords are checked unless the
+// analyzer has StopwordsOnly set.
+func (a Analyzer) isStopWord(word string, token string) bool {
+	return !a.StopwordsOnly && stopwords.IsStopWord(token) || a.Stopwords.contains(word) || a.Stopwords.contains(token)
+}
@@ -0,0 +1,136 @@
+package index
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadStopwords(t *testing.T) {
+	actual, err := ReadStopwords(strings.NewReader("# comment\nUnd\n\n  der \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NewStopwords("und", "der")
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestLoadStopwords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stopwords")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "xx.txt")
+	if err := ioutil.WriteFile(path, []byte("banana\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := LoadStopwords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := NewStopwords("banana"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	// The language code is loaded from the embedded files wherever the test runs.
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	actual, err = LoadStopwords("de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.contains("und") {
+		t.Errorf("%v does not contain und", actual)
+	}
+
+	if _, err := LoadStopwords("zz"); !errors.Is(err, ErrUnknownLanguage) {
+		t.Errorf("%v is not equal to expected %v", err, ErrUnknownLanguage)
+	}
+}
+
+// tokensEngine records the added tokens in the order they are added.
+type tokensEngine struct {
+	emptyEngine
+	tokens []string
+}
+
+func (e *tokensEngine) Add(token string, position int, source Source) error {
+	e.tokens = append(e.tokens, token)
+	return nil
+}
+
+func TestIndex_Stopwords(t *testing.T) {
+	engine := &tokensEngine{}
+	i := NewIndex(engine, nil, WithStopwords(NewStopwords("bananas")))
+	if err := i.AddSource("file1", strings.NewReader("Apples, bananas and oranges")); err != nil {
+		t.Error(err)
+	}
+	i.Close()
+	actual := engine.tokens
+	expected := []string{"appl", "orang"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	tokens, _, _ := i.parseQuery("apple bananas", i.defaultAnalyzer())
+	if expected := []string{"appl"}; !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("%v is not equal to expected %v", tokens, expected)
+	}
+}
+
+func TestIndex_StopwordsOnly(t *testing.T) {
+	for _, test := range []struct {
+		stopwords Stopwords
+		expected  []string
+		query     []string
+	}{
+		{NewStopwords("bananas"), []string{"appl", "the", "and", "orang"}, []string{"the"}},
+		{nil, []string{"appl", "banana", "orang"}, []string{"banana"}},
+	} {
+		engine := &tokensEngine{}
+		i := NewIndex(engine, nil, WithStopwordsOnly(test.stopwords))
+		if err := i.AddSource("file1", strings.NewReader("Apples, the bananas and oranges")); err != nil {
+			t.Error(err)
+		}
+		i.Close()
+		actual := engine.tokens
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: %v is not equal to expected %v", test.stopwords, actual, test.expected)
+		}
+
+		tokens, _, _ := i.parseQuery("the bananas", i.defaultAnalyzer())
+		if !reflect.DeepEqual(tokens, test.query) {
+			t.Errorf("%v: %v is not equal to expected %v", test.stopwords, tokens, test.query)
+		}
+	}
+}
+
+func TestLanguageStopwords(t *testing.T) {
+	for _, language := range []string{"de", "ru"} {
+		s, err := LanguageStopwords(language)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(s) == 0 {
+			t.Errorf("%s: stopwords are empty", language)
+		}
+	}
+	// The English stopwords are built in.
+	if s, err := LanguageStopwords("en"); err != nil || s != nil {
+		t.Errorf("%v, %v is not equal to expected nil", s, err)
+	}
+}
@@ -0,0 +1,131 @@
+package index
+
+import (
+	"sort"
+)
+
+// TokenIterator is the interface implemented by the engines which can enumerate all indexed tokens.
+type TokenIterator interface {
+	// IterateTokens calls fn for every indexed token. Iteration stops on the first error returned by fn.
+	IterateTokens(fn func(token string) error) error
+}
+
+// maxSuggestionDistance is the maximal edit distance between the query token and the suggested one.
+const maxSuggestionDistance = 2
+
+// Suggest returns up to limit indexed tokens nearest to the query tokens which are not found in the index,
+// e.g. to show "did you mean" hint when the search finds nothing.
+// Suggestions are stemmed tokens ordered by the edit distance. The engine must implement TokenIterator interface,
+// otherwise no suggestions are returned.
+func (i *Index) Suggest(query string, limit int) ([]string, error) {
+	return i.suggest(i.getEngine(), query, limit)
+}
+
+// SuggestTenant returns suggestions for the query over the documents of the tenant only.
+// Empty tenant suggests over the whole engine.
+func (i *Index) SuggestTenant(tenant string, query string, limit int) ([]string, error) {
+	engine, err := i.scoped(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return i.suggest(engine, query, limit)
+}
+
+func (i *Index) suggest(engine IndexEngine, query string, limit int) ([]string, error) {
+	iterator, ok := engine.(TokenIterator)
+	if !ok || limit <= 0 {
+		return nil, nil
+	}
+
+	tokens, _, err := i.parseQuery(query, i.defaultAnalyzer())
+	if err != nil {
+		return nil, err
+	}
+	tokens, _ = splitPrefixes(tokens)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	occurrencesList, err := engine.Get(tokens)
+	if err != nil {
+		return nil, err
+	}
+	var missing [][]rune
+	for _, token := range tokens {
+		if len(occurrencesList[token]) == 0 {
+			missing = append(missing, []rune(token))
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	type suggestion struct {
+		token    string
+		distance int
+	}
+	var suggestions []suggestion
+	err = iterator.IterateTokens(func(token string) error {
+		if isExactToken(token) {
+			return nil
+		}
+		best := maxSuggestionDistance + 1
+		for _, m := range missing {
+			if d := editDistance(m, []rune(token)); d < best {
+				best = d
+			}
+		}
+		if best > 0 && best <= maxSuggestionDistance {
+			suggestions = append(suggestions, suggestion{token: token, distance: best})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].token < suggestions[j].token
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	results := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		results = append(results, s.token)
+	}
+	return results, nil
+}
+
+// editDistance returns Levenshtein distance between two words.
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
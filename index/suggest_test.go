@@ -0,0 +1,75 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"banana", "banana", 0},
+		{"banana", "bananna", 1},
+		{"appl", "apl", 1},
+		{"orang", "oragn", 2},
+		{"", "abc", 3},
+	} {
+		if actual := editDistance([]rune(c.a), []rune(c.b)); actual != c.expected {
+			t.Errorf("%s %s: %d is not equal to expected %d", c.a, c.b, actual, c.expected)
+		}
+	}
+}
+
+func TestIndex_SearchNoMatchSuggestions(t *testing.T) {
+	e := NewMemoryIndex()
+	for _, token := range []string{"appl", "banana", "bandana", "orang"} {
+		if err := e.Add(token, 0, Source{Name: "file1"}); err != nil {
+			t.Error(err)
+		}
+	}
+	i := &Index{engine: e}
+
+	results, err := i.Search("bananna")
+	if err != nil {
+		t.Error(err)
+	}
+	if results == nil || len(results) != 0 {
+		t.Errorf("%v must be empty slice", results)
+	}
+
+	suggestions, err := i.Suggest("bananna", 5)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []string{"banana", "bandana"}
+	if !reflect.DeepEqual(suggestions, expected) {
+		t.Errorf("%v is not equal to expected %v", suggestions, expected)
+	}
+
+	suggestions, err = i.Suggest("bananna", 1)
+	if err != nil {
+		t.Error(err)
+	}
+	expected = []string{"banana"}
+	if !reflect.DeepEqual(suggestions, expected) {
+		t.Errorf("%v is not equal to expected %v", suggestions, expected)
+	}
+
+	suggestions, err = i.Suggest("banana", 5)
+	if err != nil {
+		t.Error(err)
+	}
+	if suggestions != nil {
+		t.Errorf("found query must not have suggestions, got %v", suggestions)
+	}
+}
+
+func TestIndex_SuggestNotSupported(t *testing.T) {
+	i := &Index{engine: &emptyEngine{}}
+	suggestions, err := i.Suggest("bananna", 5)
+	if err != nil || suggestions != nil {
+		t.Errorf("engine without tokens iterator must not have suggestions, got %v %v", suggestions, err)
+	}
+}
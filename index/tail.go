@@ -0,0 +1,169 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// TailSource indexes the lines read from the growing stream, e.g. the output of `tail -F`, until the end of the stream.
+// Every complete line is searchable as soon as it is read, the positions continue from line to line, so the document
+// grows as the stream is read. The last line without the trailing newline is indexed at the end of the stream.
+func (i *Index) TailSource(name string, reader io.Reader) error {
+	if i.isClosed() {
+		return ErrEngineClosed
+	}
+	atomic.AddInt64(&i.documents, 1)
+	source := Source{Name: name}
+	position := 0
+	lines := bufio.NewReader(reader)
+	for {
+		line, err := lines.ReadBytes('\n')
+		if len(line) > 0 {
+			var addErr error
+			position, addErr = i.addTokensAt(source, BodyField, line, position)
+			i.commit(i.send)
+			if addErr != nil {
+				return addErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("can not read %s: %w", name, err)
+		}
+	}
+}
+
+// Tail indexes the lines appended to the growing file, e.g. the log, without reading the whole file again.
+// Create it with TailFile function, then call Poll periodically or run Follow.
+type Tail struct {
+	i      *Index
+	path   string
+	source Source
+	file   *os.File
+	// offset is the size of the file read so far.
+	offset int64
+	// position is the position of the next indexed word.
+	position int
+	// partial is the last line read without the trailing newline, it is indexed when the line is complete.
+	partial []byte
+}
+
+// TailFile opens the file to index it with Poll or Follow functions. The document is named by the path.
+func (i *Index) TailFile(path string) (*Tail, error) {
+	if i.isClosed() {
+		return nil, ErrEngineClosed
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can not open %s: %w", path, err)
+	}
+	atomic.AddInt64(&i.documents, 1)
+	return &Tail{
+		i:      i,
+		path:   path,
+		source: Source{Name: path},
+		file:   file,
+	}, nil
+}
+
+// Poll indexes the complete lines appended since the previous poll and returns the number of their bytes.
+// The file truncated below the read size is read again from the beginning. The file replaced by the new one, e.g. by
+// the log rotation, is read to the end and the new file is opened. The positions of the words continue in both cases,
+// so the document keeps growing.
+func (t *Tail) Poll() (int, error) {
+	info, err := t.file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("can not stat %s: %w", t.path, err)
+	}
+	if info.Size() < t.offset {
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return 0, fmt.Errorf("can not rewind %s: %w", t.path, err)
+		}
+		t.offset = 0
+		t.partial = nil
+	}
+
+	read, err := t.read()
+	if err != nil {
+		return read, err
+	}
+
+	current, err := os.Stat(t.path)
+	if err != nil || os.SameFile(info, current) {
+		// The rotated file is not created yet or the file is the same.
+		return read, nil
+	}
+	file, err := os.Open(t.path)
+	if err != nil {
+		return read, nil
+	}
+	// The last line of the rotated file is complete.
+	if len(t.partial) > 0 {
+		read += len(t.partial)
+		if err := t.add(t.partial); err != nil {
+			file.Close()
+			return read, err
+		}
+		t.partial = nil
+	}
+	t.file.Close()
+	t.file = file
+	t.offset = 0
+	more, err := t.read()
+	return read + more, err
+}
+
+// read indexes the complete lines from the read offset to the end of the file.
+func (t *Tail) read() (int, error) {
+	data, err := ioutil.ReadAll(t.file)
+	if err != nil {
+		return 0, fmt.Errorf("can not read %s: %w", t.path, err)
+	}
+	t.offset += int64(len(data))
+
+	data = append(t.partial, data...)
+	end := bytes.LastIndexByte(data, '\n') + 1
+	t.partial = append([]byte{}, data[end:]...)
+	if end == 0 {
+		return 0, nil
+	}
+	return end, t.add(data[:end])
+}
+
+// add indexes the lines continuing the positions of the document.
+func (t *Tail) add(lines []byte) error {
+	var err error
+	t.position, err = t.i.addTokensAt(t.source, BodyField, lines, t.position)
+	t.i.commit(t.i.send)
+	return err
+}
+
+// Follow polls the file every interval until the context is done or the poll fails.
+func (t *Tail) Follow(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := t.Poll(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the file, the indexed lines are kept.
+func (t *Tail) Close() error {
+	return t.file.Close()
+}
@@ -0,0 +1,106 @@
+package index
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// positionsOf waits until the document is found by the query and returns the positions of the token. The tokens are
+// added to the engine asynchronously, so the last added token may be not searchable right after the poll.
+func positionsOf(t *testing.T, i *Index, query string, token string) []int {
+	deadline := time.Now().Add(time.Second)
+	for {
+		results, err := i.Search(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) > 0 {
+			return results[0].Positions[token]
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTail_Poll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	log := filepath.Join(dir, "app.log")
+	write := func(flag int, text string) {
+		file, err := os.OpenFile(log, flag|os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if _, err := file.WriteString(text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	i := NewIndex(NewMemoryIndex(), nil)
+	defer i.Close()
+	write(os.O_TRUNC, "apple\n")
+	tail, err := i.TailFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tail.Close()
+
+	for _, test := range []struct {
+		name     string
+		change   func()
+		read     int
+		query    string
+		token    string
+		expected []int
+	}{
+		{"existing", func() {}, 6, "apple", "appl", []int{0}},
+		{"appended", func() { write(os.O_APPEND, "banana\ncher") }, 7, "banana", "banana", []int{1}},
+		{"partial", func() {}, 0, "cherry", "cherri", nil},
+		{"completed", func() { write(os.O_APPEND, "ry\n") }, 7, "cherry", "cherri", []int{2}},
+		{"truncated", func() { write(os.O_TRUNC, "durian\n") }, 7, "durian", "durian", []int{3}},
+		{"rotated", func() {
+			write(os.O_APPEND, "orange")
+			if err := os.Rename(log, log+".1"); err != nil {
+				t.Fatal(err)
+			}
+			write(os.O_TRUNC, "lemon\n")
+		}, 12, "lemon", "lemon", []int{5}},
+	} {
+		test.change()
+		read, err := tail.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read != test.read {
+			t.Errorf("%s: %d is not equal to expected %d", test.name, read, test.read)
+		}
+		if positions := positionsOf(t, i, test.query, test.token); !reflect.DeepEqual(positions, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.name, positions, test.expected)
+		}
+	}
+	if positions := positionsOf(t, i, "orange", "orang"); !reflect.DeepEqual(positions, []int{4}) {
+		t.Errorf("%v is not equal to expected %v", positions, []int{4})
+	}
+}
+
+func TestIndex_TailSource(t *testing.T) {
+	i := NewIndex(NewMemoryIndex(), nil)
+	defer i.Close()
+	if err := i.TailSource("stream", strings.NewReader("apple\nbanana\ncherry")); err != nil {
+		t.Fatal(err)
+	}
+	if positions := positionsOf(t, i, "cherry", "cherri"); !reflect.DeepEqual(positions, []int{2}) {
+		t.Errorf("%v is not equal to expected %v", positions, []int{2})
+	}
+}
@@ -0,0 +1,113 @@
+package index
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// TopRangeAlgorithm returns the range algorithm which keeps only k best results, so it does not sort all matched
+// documents when the number of results is limited.
+type TopRangeAlgorithm func(k int) RangeAlgorithm
+
+// TopRangeAlgorithms lists the available top-k range algorithms by name of the range algorithm in RangeAlgorithms.
+var TopRangeAlgorithms = map[string]TopRangeAlgorithm{
+	"count": ScoreByCountTop,
+}
+
+// WithTopRangeAlgorithm sets the top-k variant of the range algorithm used when the search results are limited with
+// WithLimit option and ordered by score. It must return the same results as the first k results of the range
+// algorithm, so the range algorithm changing the scores of the other one, e.g. WithTimeDecay, is added with
+// WithRescorer option instead of WithRangeAlgorithm. ScoreByCountTop is used by default if no range algorithm is set.
+func WithTopRangeAlgorithm(top TopRangeAlgorithm) Option {
+	return func(i *Index) {
+		i.topRangeAlgorithm = top
+	}
+}
+
+// Rescorer wraps the range algorithm to change the scores of its results and to order them by the new scores, e.g.
+// WithTimeDecay. It needs all results of the range algorithm, not only the best ones.
+type Rescorer func(rangeAlgorithm RangeAlgorithm) RangeAlgorithm
+
+// WithRescorer wraps the range algorithm of the index with the rescorer, the rescorers wrap it in the order of the
+// options. The top-k variant of the range algorithm is not used with the rescorers.
+func WithRescorer(rescorer Rescorer) Option {
+	return func(i *Index) {
+		i.rescorers = append(i.rescorers, rescorer)
+	}
+}
+
+// ranker returns the range algorithm of the search wrapped with the rescorers. The limited results ordered by score
+// are ranked with the top-k variant of the range algorithm unless the rescorers or the options change their scores.
+func (i *Index) ranker(options SearchOptions) RangeAlgorithm {
+	rangeAlgorithm := i.rangeAlgorithm
+	topRangeAlgorithm := i.topRangeAlgorithm
+	if rangeAlgorithm == nil {
+		rangeAlgorithm = ScoreByCount
+		if topRangeAlgorithm == nil {
+			topRangeAlgorithm = ScoreByCountTop
+		}
+	}
+	if topRangeAlgorithm != nil && i.limit > 0 && options.byScore() && !options.rescores() && len(i.rescorers) == 0 {
+		rangeAlgorithm = topRangeAlgorithm(i.limit)
+	}
+	for _, rescorer := range i.rescorers {
+		rangeAlgorithm = rescorer(rangeAlgorithm)
+	}
+	return rangeAlgorithm
+}
+
+// ScoreByCountTop returns the range algorithm which returns k best results of ScoreByCount. It keeps the results in
+// the bounded heap instead of sorting all of them.
+func ScoreByCountTop(k int) RangeAlgorithm {
+	return func(items map[*Source]*TmpResultItem, tokens []string) ([]Result, error) {
+		return topResults(scoreByCount(items, tokens), k), nil
+	}
+}
+
+// ranksBefore checks if the result a is better than b: it has the higher score or the same score and the name lower
+// alphabetically.
+func ranksBefore(a *Result, b *Result) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.Document.Name < b.Document.Name
+}
+
+// resultHeap is the heap of the results with the worst one on the top.
+type resultHeap []Result
+
+func (h resultHeap) Len() int            { return len(h) }
+func (h resultHeap) Less(i, j int) bool  { return ranksBefore(&h[j], &h[i]) }
+func (h resultHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x interface{}) { *h = append(*h, x.(Result)) }
+func (h *resultHeap) Pop() interface{} {
+	old := *h
+	result := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return result
+}
+
+// topResults returns k best results ordered by ranksBefore.
+func topResults(results []Result, k int) []Result {
+	if k <= 0 || k >= len(results) {
+		sort.Slice(results, func(i, j int) bool {
+			return ranksBefore(&results[i], &results[j])
+		})
+		return results
+	}
+	h := make(resultHeap, 0, k)
+	for _, result := range results {
+		if len(h) < k {
+			heap.Push(&h, result)
+			continue
+		}
+		if ranksBefore(&result, &h[0]) {
+			h[0] = result
+			heap.Fix(&h, 0)
+		}
+	}
+	sort.Slice(h, func(i, j int) bool {
+		return ranksBefore(&h[i], &h[j])
+	})
+	return h
+}
@@ -0,0 +1,106 @@
+package index
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// newRandomItems returns the items of n documents with the random counts of two tokens, many scores are equal.
+func newRandomItems(n int) map[*Source]*TmpResultItem {
+	r := rand.New(rand.NewSource(1))
+	items := make(map[*Source]*TmpResultItem, n)
+	for k := 0; k < n; k++ {
+		items[&Source{Name: fmt.Sprintf("file%d", k)}] = &TmpResultItem{
+			count:  2,
+			counts: map[string]int{"appl": r.Intn(10) + 1, "banana": r.Intn(10) + 1},
+		}
+	}
+	return items
+}
+
+func TestScoreByCountTop(t *testing.T) {
+	items := newRandomItems(1000)
+	tokens := []string{"appl", "banana"}
+	all, err := ScoreByCount(items, tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []int{1, 10, 999, 1000, 2000} {
+		actual, err := ScoreByCountTop(k)(items, tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := all
+		if k < len(all) {
+			expected = all[:k]
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%d: %v is not equal to expected %v", k, names(actual), names(expected))
+		}
+	}
+}
+
+func TestIndex_SearchLimitTop(t *testing.T) {
+	s1 := &Source{Name: "file1"}
+	s2 := &Source{Name: "file2"}
+	s3 := &Source{Name: "file3"}
+	engine := &emptyEngine{
+		results: map[string]Occurrences{
+			"appl": {s1: []int{0}, s2: []int{0, 1}, s3: []int{1}},
+		},
+	}
+	i := &Index{engine: engine, limit: 2}
+	results, err := i.Search("apple")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []string{"file2", "file1"}
+	if actual := names(results); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestIndex_SearchLimitRescorer(t *testing.T) {
+	s1 := &Source{Name: "file1"}
+	s2 := &Source{Name: "file2"}
+	s3 := &Source{Name: "file3"}
+	engine := &emptyEngine{
+		results: map[string]Occurrences{
+			"appl": {s1: []int{0}, s2: []int{3, 4}, s3: []int{5}},
+		},
+	}
+	// The early boost lifts file1 above file2, so it must see all results of the range algorithm.
+	i := &Index{engine: engine, limit: 1, rescorers: []Rescorer{func(rangeAlgorithm RangeAlgorithm) RangeAlgorithm {
+		return WithEarlyBoost(rangeAlgorithm, 10)
+	}}}
+	results, err := i.Search("apple")
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []string{"file1"}
+	if actual := names(results); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func BenchmarkScoreByCount(b *testing.B) {
+	items := newRandomItems(100000)
+	tokens := []string{"appl", "banana"}
+	b.Run("sort", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if _, err := ScoreByCount(items, tokens); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("top10", func(b *testing.B) {
+		top := ScoreByCountTop(10)
+		for n := 0; n < b.N; n++ {
+			if _, err := top(items, tokens); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -4,42 +4,125 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+
+	"golang.org/x/term"
 
 	"github.com/polisgo2020/search-tariel-x/index"
 )
 
+// DefaultPrompt is written before every query read from the terminal.
+const DefaultPrompt = "> "
+
+// isTerminal reports whether the file is the terminal, it is replaced in tests.
+var isTerminal = func(file *os.File) bool {
+	return term.IsTerminal(int(file.Fd()))
+}
+
 type Cli struct {
-	in  *os.File
-	out *os.File
-	i   *index.Index
+	in     *os.File
+	out    *os.File
+	i      *index.Index
+	prompt string
+	strict bool
 }
 
-func New(in *os.File, out *os.File, i *index.Index) (*Cli, error) {
+// Option configures the CLI.
+type Option func(*Cli)
+
+// WithPrompt sets the prompt written before every query read from the terminal, the empty prompt disables it.
+// DefaultPrompt is used if the option is not set.
+func WithPrompt(prompt string) Option {
+	return func(c *Cli) {
+		c.prompt = prompt
+	}
+}
+
+// WithStrict makes Run return the error of the failed search, e.g. to stop the script on the database failure. By
+// default the error is written to the output and the next query is read.
+func WithStrict() Option {
+	return func(c *Cli) {
+		c.strict = true
+	}
+}
+
+func New(in *os.File, out *os.File, i *index.Index, options ...Option) (*Cli, error) {
 	if in == nil || out == nil || i == nil {
 		return nil, errors.New("incorrect in, out interface or index obj")
 	}
-	return &Cli{
-		in:  in,
-		out: out,
-		i:   i,
-	}, nil
+	c := &Cli{
+		in:     in,
+		out:    out,
+		i:      i,
+		prompt: DefaultPrompt,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
 }
 
+// exitCommands are the inputs stopping the CLI.
+var exitCommands = map[string]bool{":quit": true, ":exit": true}
+
+// Run reads the queries line by line and writes the results. The prompt is written only if the input is the terminal,
+// so the piped queries produce the results only. The errors of the failed searches are written instead of the results
+// unless the CLI is created with WithStrict option. Run returns nil at the end of the input or on `:quit` and `:exit`
+// commands.
 func (c *Cli) Run() error {
+	reader := bufio.NewReader(c.in)
+	interactive := c.prompt != "" && isTerminal(c.in)
 	for {
-		reader := bufio.NewReader(c.in)
-		query, err := reader.ReadString('\n')
-		if err != nil {
-			return fmt.Errorf("can not read query: %w", err)
+		if interactive {
+			fmt.Fprint(c.out, c.prompt)
+		}
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("can not read query: %w", readErr)
 		}
 
-		results, err := c.i.Search(query)
-		if err != nil {
-			return err
+		query := strings.TrimSpace(line)
+		if exitCommands[query] {
+			return nil
+		}
+		if query != "" {
+			if err := c.search(query); err != nil {
+				return err
+			}
 		}
-		for i, result := range results {
-			fmt.Fprintf(c.out, "%d. %s\n", i+1, result.Document.Name)
+		if readErr == io.EOF {
+			return nil
 		}
 	}
 }
+
+// search writes the results of the query or the line telling that no documents are found. The error of the failed
+// search is written as well and nil is returned unless the CLI is created with WithStrict option.
+func (c *Cli) search(query string) error {
+	results, err := c.i.Search(query)
+	if err != nil && (!c.strict || errors.Is(err, index.ErrTooManyCandidates)) {
+		fmt.Fprintln(c.out, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(c.out, "No documents found for %s\n", query)
+		return nil
+	}
+	for i, result := range results {
+		fmt.Fprintln(c.out, formatResult(i+1, result))
+	}
+	return nil
+}
+
+// formatResult formats the result with its number and the matched query tokens, e.g. `1. file2 [appl, banana]`.
+func formatResult(n int, result index.Result) string {
+	if len(result.MatchedTokens) == 0 {
+		return fmt.Sprintf("%d. %s", n, result.Document.Name)
+	}
+	return fmt.Sprintf("%d. %s [%s]", n, result.Document.Name, strings.Join(result.MatchedTokens, ", "))
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/polisgo2020/search-tariel-x/index"
+)
+
+// failEngine fails to get the occurrences of the token durian.
+type failEngine struct {
+	*index.MemoryIndex
+}
+
+func (e failEngine) Get(tokens []string) (map[string]index.Occurrences, error) {
+	for _, token := range tokens {
+		if token == "durian" {
+			return nil, errors.New("connection refused")
+		}
+	}
+	return e.MemoryIndex.Get(tokens)
+}
+
+// newTestEngine returns the engine with file1 containing apple and file2 containing apple and banana.
+func newTestEngine(t *testing.T) *index.MemoryIndex {
+	engine := index.NewMemoryIndex()
+	for _, item := range []struct {
+		token    string
+		position int
+		document string
+	}{
+		{"appl", 0, "file1"},
+		{"appl", 0, "file2"},
+		{"banana", 1, "file2"},
+	} {
+		if err := engine.Add(item.token, item.position, index.Source{Name: item.document}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return engine
+}
+
+// run runs the CLI searching over the engine with the input and returns its output and the error of Run.
+func run(t *testing.T, engine index.IndexEngine, input string, options ...Option) (string, error) {
+	i := index.NewIndex(engine, nil, index.WithMatchedTokens())
+
+	in, err := ioutil.TempFile("", "in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(in.Name())
+	defer in.Close()
+	out, err := ioutil.TempFile("", "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if _, err := in.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(in, out, i, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := c.Run()
+
+	actual, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(actual), runErr
+}
+
+func TestCli_Run(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected string
+	}{
+		{"apple banana\nbanana\n", "1. file2 [appl, banana]\n1. file2 [banana]\n"},
+		{"apple banana  \t\r\n\n  banana", "1. file2 [appl, banana]\n1. file2 [banana]\n"},
+		{"banana\n:quit\napple\n", "1. file2 [banana]\n"},
+		{" :exit \napple\n", ""},
+		{"", ""},
+	} {
+		actual, err := run(t, newTestEngine(t), test.input)
+		if err != nil {
+			t.Errorf("%q: %v is not equal to expected nil", test.input, err)
+		}
+		if actual != test.expected {
+			t.Errorf("%q: %q is not equal to expected %q", test.input, actual, test.expected)
+		}
+	}
+}
+
+func TestCli_RunNoResults(t *testing.T) {
+	actual, err := run(t, newTestEngine(t), "durian\nbanana\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "No documents found for durian\n1. file2 [banana]\n"
+	if actual != expected {
+		t.Errorf("%q is not equal to expected %q", actual, expected)
+	}
+}
+
+func TestCli_RunPrompt(t *testing.T) {
+	defer func(original func(*os.File) bool) { isTerminal = original }(isTerminal)
+	for _, test := range []struct {
+		terminal bool
+		options  []Option
+		expected string
+	}{
+		{true, nil, "> 1. file2 [banana]\n> "},
+		{true, []Option{WithPrompt("search: ")}, "search: 1. file2 [banana]\nsearch: "},
+		{true, []Option{WithPrompt("")}, "1. file2 [banana]\n"},
+		{false, nil, "1. file2 [banana]\n"},
+	} {
+		terminal := test.terminal
+		isTerminal = func(*os.File) bool { return terminal }
+		if actual, _ := run(t, newTestEngine(t), "banana\n", test.options...); actual != test.expected {
+			t.Errorf("%q is not equal to expected %q", actual, test.expected)
+		}
+	}
+}
+
+func TestCli_RunSearchError(t *testing.T) {
+	engine := failEngine{newTestEngine(t)}
+	actual, err := run(t, engine, "durian\nbanana\n")
+	if err != nil {
+		t.Errorf("%v is not equal to expected nil", err)
+	}
+	expected := "connection refused\n1. file2 [banana]\n"
+	if actual != expected {
+		t.Errorf("%q is not equal to expected %q", actual, expected)
+	}
+
+	actual, err = run(t, engine, "durian\nbanana\n", WithStrict())
+	if err == nil {
+		t.Errorf("%v is not equal to expected search error", err)
+	}
+	if actual != "" {
+		t.Errorf("%q is not equal to expected %q", actual, "")
+	}
+}
@@ -0,0 +1,109 @@
+package ws
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/polisgo2020/search-tariel-x/index"
+)
+
+// EnableAdmin allows the admin requests, e.g. deleting the documents, authorized with the bearer token. The admin
+// requests are forbidden if the token is empty.
+func (ws *Ws) EnableAdmin(token string) {
+	ws.adminToken = token
+}
+
+// ProtectContent authorizes the requests of the raw texts of the documents with the admin token, e.g. when the index
+// of the private files retains their content. The content is public by default, as the search results are.
+func (ws *Ws) ProtectContent() {
+	ws.protectContent = true
+}
+
+// EnableReload allows to reload the index with `POST /api/admin/reload` request authorized with the bearer token.
+// The load function reads the new engine, e.g. decodes the rebuilt index file. The previous engine is closed after the
+// requests started over it are served.
+func (ws *Ws) EnableReload(token string, load func() (index.IndexEngine, error)) {
+	ws.adminToken = token
+	ws.load = load
+}
+
+// admin allows the request only with `Authorization: Bearer <token>` header matching the admin token.
+func (ws *Ws) admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ws.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(ws.adminToken)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// apiReloaded is the response of the index reload.
+type apiReloaded struct {
+	Reloaded bool `json:"reloaded"`
+}
+
+func (ws *Ws) apiReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if ws.load == nil {
+		writeError(w, http.StatusNotImplemented, "reload is not supported")
+		return
+	}
+
+	engine, err := ws.load()
+	if err != nil {
+		log.Error().Err(err).Msg("error loading index, the current index is kept")
+		writeError(w, http.StatusInternalServerError, "can not load index")
+		return
+	}
+	old, inflight := ws.swap(engine)
+	// The reload request is counted as well, so the previous engine is closed in background after it is served.
+	go func() {
+		inflight.Wait()
+		old.Close()
+	}()
+	log.Info().Msg("index is reloaded")
+	writeJSON(w, http.StatusOK, apiReloaded{Reloaded: true})
+}
+
+// track counts the request as started over the current engine of the index until it is served, so the engine replaced
+// on reload is closed only after the requests which may use it.
+func (ws *Ws) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inflight := ws.acquire()
+		defer inflight.Done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acquire counts the request started over the current engine of the index, the caller must call Done when the
+// request is served.
+func (ws *Ws) acquire() *sync.WaitGroup {
+	ws.inflightM.Lock()
+	defer ws.inflightM.Unlock()
+	if ws.inflight == nil {
+		ws.inflight = &sync.WaitGroup{}
+	}
+	ws.inflight.Add(1)
+	return ws.inflight
+}
+
+// swap replaces the engine of the index and returns the previous engine with the requests started over it.
+func (ws *Ws) swap(engine index.IndexEngine) (index.IndexEngine, *sync.WaitGroup) {
+	ws.inflightM.Lock()
+	defer ws.inflightM.Unlock()
+	inflight := ws.inflight
+	if inflight == nil {
+		inflight = &sync.WaitGroup{}
+	}
+	ws.inflight = &sync.WaitGroup{}
+	return ws.i.SwapEngine(engine), inflight
+}
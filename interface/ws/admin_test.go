@@ -0,0 +1,116 @@
+package ws
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/polisgo2020/search-tariel-x/index"
+)
+
+func adminRequest(ws *Ws, method, token string) int {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "/api/admin/reload", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	ws.admin(ws.apiReloadHandler)(w, r)
+	return w.Code
+}
+
+func TestWs_apiReloadHandler(t *testing.T) {
+	ws := newTestWs(t)
+	engine := index.NewMemoryIndex()
+	if err := engine.Add("cherri", 0, index.Source{Name: "file3"}); err != nil {
+		t.Fatal(err)
+	}
+	var loadErr error
+	ws.EnableReload("secret", func() (index.IndexEngine, error) {
+		return engine, loadErr
+	})
+
+	for _, tc := range []struct {
+		method string
+		token  string
+		code   int
+	}{
+		{http.MethodPost, "", http.StatusUnauthorized},
+		{http.MethodPost, "wrong", http.StatusUnauthorized},
+		{http.MethodGet, "secret", http.StatusMethodNotAllowed},
+	} {
+		if code := adminRequest(ws, tc.method, tc.token); code != tc.code {
+			t.Errorf("%s %q: %d is not equal to expected %d", tc.method, tc.token, code, tc.code)
+		}
+	}
+
+	loadErr = errors.New("broken index")
+	if code := adminRequest(ws, http.MethodPost, "secret"); code != http.StatusInternalServerError {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusInternalServerError)
+	}
+	var actual []apiResult
+	apiRequest(t, ws.apiSearchHandler, "/api/search?q=apple", &actual)
+	if len(actual) != 2 {
+		t.Errorf("%v is not equal to expected old results", actual)
+	}
+
+	loadErr = nil
+	if code := adminRequest(ws, http.MethodPost, "secret"); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	actual = nil
+	apiRequest(t, ws.apiSearchHandler, "/api/search?q=cherry", &actual)
+	expected := []apiResult{{Document: "file3", Score: 1}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+	actual = nil
+	apiRequest(t, ws.apiSearchHandler, "/api/search?q=apple", &actual)
+	if len(actual) != 0 {
+		t.Errorf("%v is not equal to expected empty results", actual)
+	}
+}
+
+func TestWs_apiReloadHandlerDisabled(t *testing.T) {
+	ws := newTestWs(t)
+	if code := adminRequest(ws, http.MethodPost, ""); code != http.StatusUnauthorized {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusUnauthorized)
+	}
+}
+
+// closingEngine reports when the engine is closed.
+type closingEngine struct {
+	*index.MemoryIndex
+	closed chan struct{}
+}
+
+func (e *closingEngine) Close() {
+	close(e.closed)
+}
+
+func TestWs_apiReloadHandlerInflight(t *testing.T) {
+	old := &closingEngine{MemoryIndex: index.NewMemoryIndex(), closed: make(chan struct{})}
+	ws := &Ws{i: index.NewIndex(old, nil)}
+	ws.EnableReload("secret", func() (index.IndexEngine, error) {
+		return index.NewMemoryIndex(), nil
+	})
+
+	inflight := ws.acquire()
+	if code := adminRequest(ws, http.MethodPost, "secret"); code != http.StatusOK {
+		t.Fatalf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	select {
+	case <-old.closed:
+		t.Error("engine is closed before the request is served")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	inflight.Done()
+	select {
+	case <-old.closed:
+	case <-time.After(time.Second):
+		t.Error("engine is not closed after the request is served")
+	}
+}
@@ -0,0 +1,645 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/polisgo2020/search-tariel-x/index"
+)
+
+// apiResult is the search result returned by the JSON API.
+type apiResult struct {
+	Document      string           `json:"document"`
+	Score         float64          `json:"score"`
+	Positions     map[string][]int `json:"positions,omitempty"`
+	MatchedTokens []string         `json:"matched_tokens,omitempty"`
+	Snippet       string           `json:"snippet,omitempty"`
+}
+
+// apiFacetedResults is the search response with the number of the matching documents containing every query token.
+type apiFacetedResults struct {
+	Results []apiResult    `json:"results"`
+	Facets  map[string]int `json:"facets"`
+	// Partial is true if the results are ranked over the occurrences fetched before the query timeout.
+	Partial bool `json:"partial"`
+}
+
+// apiError is the error returned by the JSON API.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("error encoding response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{Error: message})
+}
+
+// ndjsonFlushSize is the number of the lines of NDJSON response written between the flushes.
+const ndjsonFlushSize = 100
+
+// writeNDJSON writes the items one per line as newline-delimited JSON, the response is flushed every ndjsonFlushSize
+// lines, so the client can process the first items before the last ones are encoded.
+func writeNDJSON(w http.ResponseWriter, status int, count int, item func(k int) interface{}) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(status)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for k := 0; k < count; k++ {
+		if err := encoder.Encode(item(k)); err != nil {
+			log.Error().Err(err).Msg("error encoding response")
+			return
+		}
+		if flusher != nil && (k+1)%ndjsonFlushSize == 0 {
+			flusher.Flush()
+		}
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// boolParam returns the boolean query parameter, empty parameter is false.
+func boolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// timeParam returns the time query parameter in RFC 3339 format, empty parameter is zero time.
+func timeParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// searchOptions returns the tenant, the time range, the order of the results, the documents to search within, the
+// language of the query and the markers of the snippets from the request.
+func searchOptions(r *http.Request) (index.SearchOptions, error) {
+	since, err := timeParam(r, "since")
+	if err != nil {
+		return index.SearchOptions{}, errors.New("incorrect since parameter")
+	}
+	until, err := timeParam(r, "until")
+	if err != nil {
+		return index.SearchOptions{}, errors.New("incorrect until parameter")
+	}
+	return index.SearchOptions{
+		Tenant:     tenant(r),
+		Since:      since,
+		Until:      until,
+		OrderBy:    r.URL.Query().Get("sort"),
+		Order:      r.URL.Query().Get("order"),
+		RestrictTo: r.URL.Query()["document"],
+		Language:   r.URL.Query().Get("lang"),
+		Markers: index.Markers{
+			Pre:  r.URL.Query().Get("highlight_pre"),
+			Post: r.URL.Query().Get("highlight_post"),
+		},
+	}, nil
+}
+
+// Formats of the search results.
+const (
+	// formatJSON is the JSON array of the results, it is the default format.
+	formatJSON = "json"
+	// formatNDJSON is the newline-delimited JSON with one result per line.
+	formatNDJSON = "ndjson"
+)
+
+// newAPIResult returns the search result of the API, the positions are included on demand.
+func newAPIResult(result index.Result, includePositions bool) apiResult {
+	item := apiResult{
+		Document:      result.Document.Name,
+		Score:         result.Score,
+		MatchedTokens: result.MatchedTokens,
+		Snippet:       result.Snippet,
+	}
+	if includePositions {
+		item.Positions = result.Positions
+	}
+	return item
+}
+
+func (ws *Ws) apiSearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "empty query")
+		return
+	}
+	includePositions, err := boolParam(r, "include_positions")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "incorrect include_positions parameter")
+		return
+	}
+
+	withFacets, err := boolParam(r, "facets")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "incorrect facets parameter")
+		return
+	}
+
+	allowPartial, err := boolParam(r, "partial")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "incorrect partial parameter")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != formatJSON && format != formatNDJSON {
+		writeError(w, http.StatusBadRequest, "incorrect format parameter")
+		return
+	}
+	if format == formatNDJSON && withFacets {
+		writeError(w, http.StatusBadRequest, "facets are not supported with ndjson format")
+		return
+	}
+
+	options, err := searchOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var results []index.Result
+	var facets index.Facets
+	var partial bool
+	switch {
+	case withFacets && allowPartial:
+		results, facets, partial, err = ws.i.SearchPartialWithFacets(query, options)
+	case withFacets:
+		results, facets, err = ws.i.SearchWithFacets(query, options)
+	case allowPartial:
+		results, partial, err = ws.i.SearchPartial(query, options)
+	default:
+		results, err = ws.i.SearchWithOptions(query, options)
+	}
+	if errors.Is(err, index.ErrUnknownOrder) {
+		writeError(w, http.StatusBadRequest, "incorrect sort parameter")
+		return
+	}
+	if errors.Is(err, index.ErrUnknownDirection) {
+		writeError(w, http.StatusBadRequest, "incorrect order parameter")
+		return
+	}
+	if errors.Is(err, index.ErrUnknownLanguage) {
+		writeError(w, http.StatusBadRequest, "incorrect lang parameter")
+		return
+	}
+	if errors.Is(err, index.ErrUnknownField) || errors.Is(err, index.ErrTooManyCandidates) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, index.ErrNotSupported) || errors.Is(err, index.ErrTenantsNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("query", query).Msg("error search over index")
+		writeError(w, http.StatusInternalServerError, "search error")
+		return
+	}
+
+	if partial {
+		w.Header().Set(partialHeader, "true")
+	}
+	if format == formatNDJSON {
+		writeNDJSON(w, http.StatusOK, len(results), func(k int) interface{} {
+			return newAPIResult(results[k], includePositions)
+		})
+		return
+	}
+	response := make([]apiResult, 0, len(results))
+	for _, result := range results {
+		response = append(response, newAPIResult(result, includePositions))
+	}
+	if withFacets {
+		writeJSON(w, http.StatusOK, apiFacetedResults{Results: response, Facets: facets, Partial: partial})
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// apiDeleted is the response of the documents deletion.
+type apiDeleted struct {
+	Deleted int `json:"deleted"`
+}
+
+func (ws *Ws) apiDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		ws.apiListDocumentsHandler(w, r)
+	case http.MethodDelete:
+		ws.admin(ws.apiDeleteDocumentsHandler)(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// apiDocument is the listed document.
+type apiDocument struct {
+	Name     string    `json:"name"`
+	ModTime  time.Time `json:"mod_time"`
+	Language string    `json:"language,omitempty"`
+	Excluded bool      `json:"excluded"`
+}
+
+// apiDocumentList is the page of the listed documents and the number of all documents.
+type apiDocumentList struct {
+	Documents []apiDocument `json:"documents"`
+	Total     int           `json:"total"`
+}
+
+// defaultDocumentsLimit is the number of documents listed on one page.
+const defaultDocumentsLimit = 100
+
+// maxDocumentsLimit is the maximal number of documents listed on one page.
+const maxDocumentsLimit = 1000
+
+// apiListDocumentsHandler returns the page of the documents selected by `limit` and `offset` parameters and ordered by
+// `order` parameter: name (default) or id.
+func (ws *Ws) apiListDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	options := index.ListOptions{Limit: defaultDocumentsLimit, Order: r.URL.Query().Get("order")}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if options.Limit, err = strconv.Atoi(value); err != nil || options.Limit <= 0 || options.Limit > maxDocumentsLimit {
+			writeError(w, http.StatusBadRequest, "incorrect limit parameter")
+			return
+		}
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		var err error
+		if options.Offset, err = strconv.Atoi(value); err != nil || options.Offset < 0 {
+			writeError(w, http.StatusBadRequest, "incorrect offset parameter")
+			return
+		}
+	}
+
+	list, err := ws.i.ListDocumentsTenant(tenant(r), options)
+	if errors.Is(err, index.ErrUnknownOrder) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, index.ErrNotSupported) || errors.Is(err, index.ErrTenantsNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("error listing documents")
+		writeError(w, http.StatusInternalServerError, "list error")
+		return
+	}
+
+	response := apiDocumentList{Documents: make([]apiDocument, 0, len(list.Documents)), Total: list.Total}
+	for _, document := range list.Documents {
+		response.Documents = append(response.Documents, apiDocument{
+			Name:     document.Name,
+			ModTime:  document.ModTime,
+			Language: document.Language,
+			Excluded: document.Excluded,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (ws *Ws) apiDeleteDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		writeError(w, http.StatusBadRequest, "empty prefix")
+		return
+	}
+
+	deleted, err := ws.i.DeleteByPrefixTenant(tenant(r), prefix)
+	if errors.Is(err, index.ErrNotSupported) || errors.Is(err, index.ErrTenantsNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("prefix", prefix).Msg("error deleting documents")
+		writeError(w, http.StatusInternalServerError, "delete error")
+		return
+	}
+	log.Info().Str("prefix", prefix).Int("deleted", deleted).Msg("deleted documents")
+	writeJSON(w, http.StatusOK, apiDeleted{Deleted: deleted})
+}
+
+// apiExcluded is the response of the document exclusion.
+type apiExcluded struct {
+	Document string `json:"document"`
+	Excluded bool   `json:"excluded"`
+}
+
+// apiExcludedHandler excludes the document from the search with PUT request and includes it back with DELETE one.
+// The requests are authorized with the admin token.
+func (ws *Ws) apiExcludedHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut, http.MethodDelete:
+		ws.admin(ws.apiSetExcludedHandler)(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (ws *Ws) apiSetExcludedHandler(w http.ResponseWriter, r *http.Request) {
+	excluded := r.Method == http.MethodPut
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "empty name")
+		return
+	}
+
+	err := ws.i.SetExcludedTenant(tenant(r), name, excluded)
+	if errors.Is(err, index.ErrUnknownDocument) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, index.ErrNotSupported) || errors.Is(err, index.ErrTenantsNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("document", name).Msg("error excluding document")
+		writeError(w, http.StatusInternalServerError, "exclude error")
+		return
+	}
+	log.Info().Str("document", name).Bool("excluded", excluded).Msg("excluded document")
+	writeJSON(w, http.StatusOK, apiExcluded{Document: name, Excluded: excluded})
+}
+
+// apiStats is the size of the index.
+type apiStats struct {
+	Documents   int `json:"documents"`
+	Tokens      int `json:"tokens"`
+	Occurrences int `json:"occurrences"`
+}
+
+// apiStatsHandler returns the number of the documents, of the unique tokens and of the occurrences of the index.
+func (ws *Ws) apiStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := ws.i.StatsTenant(tenant(r))
+	if errors.Is(err, index.ErrNotSupported) || errors.Is(err, index.ErrTenantsNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("error counting index")
+		writeError(w, http.StatusInternalServerError, "stats error")
+		return
+	}
+	writeJSON(w, http.StatusOK, apiStats{Documents: stats.Documents, Tokens: stats.Tokens, Occurrences: stats.Occurrences})
+}
+
+// apiDocumentStats is the size of the document.
+type apiDocumentStats struct {
+	Document   string `json:"document"`
+	Length     int    `json:"length"`
+	Vocabulary int    `json:"vocabulary"`
+}
+
+// apiDocumentStatsHandler returns the length and the vocabulary size of the document.
+func (ws *Ws) apiDocumentStatsHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "empty name")
+		return
+	}
+
+	stats, err := ws.i.DocumentStatsTenant(tenant(r), name)
+	if errors.Is(err, index.ErrUnknownDocument) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, index.ErrNotSupported) || errors.Is(err, index.ErrTenantsNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("document", name).Msg("error counting document")
+		writeError(w, http.StatusInternalServerError, "document stats error")
+		return
+	}
+	writeJSON(w, http.StatusOK, apiDocumentStats{Document: name, Length: stats.Length, Vocabulary: stats.Vocabulary})
+}
+
+// contentSuffix ends the path of the document content, e.g. `/api/documents/docs/file1/content`.
+const contentSuffix = "/content"
+
+// apiDocumentContentHandler returns the raw text of the document named by the path between `/api/documents/` and
+// `/content`. The text is found only if the index retains the content of the documents. The requests are authorized
+// with the admin token if the content is protected, see ProtectContent.
+func (ws *Ws) apiDocumentContentHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/documents/")
+	if !strings.HasSuffix(name, contentSuffix) || name == contentSuffix {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if ws.protectContent {
+		ws.admin(ws.apiContentHandler)(w, r)
+		return
+	}
+	ws.apiContentHandler(w, r)
+}
+
+func (ws *Ws) apiContentHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/documents/"), contentSuffix)
+	content, err := ws.i.ContentTenant(tenant(r), name)
+	if errors.Is(err, index.ErrUnknownDocument) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, index.ErrNotSupported) || errors.Is(err, index.ErrTenantsNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("document", name).Msg("error reading document content")
+		writeError(w, http.StatusInternalServerError, "document content error")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, content)
+}
+
+// apiAnalysis is the query and the tokens it is reduced to.
+type apiAnalysis struct {
+	Query  string   `json:"query"`
+	Tokens []string `json:"tokens"`
+}
+
+// apiAnalyzeHandler returns the stemmed tokens of the query without stop words the same way as the query is searched,
+// e.g. to understand why the query finds nothing. The language of the query is set with `lang` parameter.
+func (ws *Ws) apiAnalyzeHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "empty query")
+		return
+	}
+
+	tokens, err := ws.i.AnalyzeLanguage(r.URL.Query().Get("lang"), query)
+	if errors.Is(err, index.ErrUnknownLanguage) {
+		writeError(w, http.StatusBadRequest, "incorrect lang parameter")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("query", query).Msg("error analyzing query")
+		writeError(w, http.StatusInternalServerError, "analyze error")
+		return
+	}
+	if tokens == nil {
+		tokens = []string{}
+	}
+	writeJSON(w, http.StatusOK, apiAnalysis{Query: query, Tokens: tokens})
+}
+
+// apiComparison is the top of the search results of one ranker with normalized scores.
+type apiComparison struct {
+	Ranker  string      `json:"ranker"`
+	Results []apiResult `json:"results"`
+}
+
+// defaultCompareLimit is the number of results of every ranker returned by the rankers comparison.
+const defaultCompareLimit = 10
+
+func (ws *Ws) apiDebugRankersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "empty query")
+		return
+	}
+	limit := defaultCompareLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, "incorrect limit parameter")
+			return
+		}
+	}
+
+	comparisons, err := ws.i.CompareTenant(tenant(r), query, index.RangeAlgorithms, limit)
+	if err != nil {
+		log.Error().Err(err).Str("query", query).Msg("error comparing rankers")
+		writeError(w, http.StatusInternalServerError, "search error")
+		return
+	}
+
+	response := make([]apiComparison, 0, len(comparisons))
+	for _, comparison := range comparisons {
+		results := make([]apiResult, 0, len(comparison.Results))
+		for _, result := range comparison.Results {
+			results = append(results, apiResult{Document: result.Document.Name, Score: result.Score})
+		}
+		response = append(response, apiComparison{Ranker: comparison.Ranker, Results: results})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// apiCompletion is the token completing the prefix and the number of its occurrences.
+type apiCompletion struct {
+	Token string `json:"token"`
+	Count int    `json:"count"`
+}
+
+// defaultSuggestLimit is the number of completions returned by the autocomplete.
+const defaultSuggestLimit = 10
+
+func (ws *Ws) apiSuggestHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("q")
+	if prefix == "" {
+		writeError(w, http.StatusBadRequest, "empty query")
+		return
+	}
+	limit := defaultSuggestLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, "incorrect limit parameter")
+			return
+		}
+	}
+
+	completions, err := ws.i.CompleteTenant(tenant(r), prefix, limit)
+	if errors.Is(err, index.ErrTenantsNotSupported) {
+		writeError(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("prefix", prefix).Msg("error completing prefix")
+		writeError(w, http.StatusInternalServerError, "suggest error")
+		return
+	}
+
+	response := make([]apiCompletion, 0, len(completions))
+	for _, completion := range completions {
+		response = append(response, apiCompletion{Token: completion.Token, Count: completion.Count})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// apiCapabilities lists the features supported by the engine.
+type apiCapabilities struct {
+	Engine         string `json:"engine"`
+	Positions      bool   `json:"positions"`
+	Tenants        bool   `json:"tenants"`
+	TimeRange      bool   `json:"time_range"`
+	Restrict       bool   `json:"restrict"`
+	Suggestions    bool   `json:"suggestions"`
+	Iterate        bool   `json:"iterate"`
+	Remove         bool   `json:"remove"`
+	DeleteByPrefix bool   `json:"delete_by_prefix"`
+	Stats          bool   `json:"stats"`
+	Exclude        bool   `json:"exclude"`
+	DocumentStats  bool   `json:"document_stats"`
+	ListDocuments  bool   `json:"list_documents"`
+	Content        bool   `json:"content"`
+	BM25           bool   `json:"bm25"`
+	Snippets       bool   `json:"snippets"`
+	Prefix         bool   `json:"prefix"`
+	Metadata       bool   `json:"metadata"`
+	Incremental    bool   `json:"incremental"`
+}
+
+func (ws *Ws) apiCapabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	capabilities := ws.i.Capabilities()
+	writeJSON(w, http.StatusOK, apiCapabilities{
+		Engine:         capabilities.Engine,
+		Positions:      capabilities.Positions,
+		Tenants:        capabilities.Tenants,
+		TimeRange:      capabilities.TimeRange,
+		Restrict:       capabilities.Restrict,
+		Suggestions:    capabilities.Suggestions,
+		Iterate:        capabilities.Iterate,
+		Remove:         capabilities.Remove,
+		DeleteByPrefix: capabilities.DeleteByPrefix,
+		Stats:          capabilities.Stats,
+		Exclude:        capabilities.Exclude,
+		DocumentStats:  capabilities.DocumentStats,
+		ListDocuments:  capabilities.ListDocuments,
+		Content:        capabilities.Content,
+		BM25:           capabilities.BM25,
+		Snippets:       capabilities.Snippets,
+		Prefix:         capabilities.Prefix,
+		Metadata:       capabilities.Metadata,
+		Incremental:    capabilities.Incremental,
+	})
+}
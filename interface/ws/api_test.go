@@ -0,0 +1,622 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/polisgo2020/search-tariel-x/index"
+)
+
+func newTestWs(t *testing.T) *Ws {
+	engine := index.NewMemoryIndex()
+	for _, item := range []struct {
+		token    string
+		position int
+		document string
+	}{
+		{"appl", 0, "file1"},
+		{"banana", 1, "file1"},
+		{"appl", 0, "file2"},
+		{"appl", 2, "file2"},
+	} {
+		if err := engine.Add(item.token, item.position, index.Source{Name: item.document}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Ws{i: index.NewIndex(engine, nil)}
+}
+
+func apiRequest(t *testing.T, handler http.HandlerFunc, url string, response interface{}) int {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("%s is not equal to expected application/json", contentType)
+	}
+	if err := json.NewDecoder(w.Body).Decode(response); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code
+}
+
+func TestWs_apiSearchHandlerPositions(t *testing.T) {
+	ws := newTestWs(t)
+
+	var actual []apiResult
+	if code := apiRequest(t, ws.apiSearchHandler, "/api/search?q=apple", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	expected := []apiResult{
+		{Document: "file2", Score: 2},
+		{Document: "file1", Score: 1},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	actual = nil
+	if code := apiRequest(t, ws.apiSearchHandler, "/api/search?q=apple&include_positions=true", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	expected = []apiResult{
+		{Document: "file2", Score: 2, Positions: map[string][]int{"appl": {0, 2}}},
+		{Document: "file1", Score: 1, Positions: map[string][]int{"appl": {0}}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestWs_apiSearchHandlerFacets(t *testing.T) {
+	ws := newTestWs(t)
+
+	var actual apiFacetedResults
+	if code := apiRequest(t, ws.apiSearchHandler, "/api/search?q=apple+banana&facets=true", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	expected := apiFacetedResults{
+		Results: []apiResult{{Document: "file1", Score: 2}},
+		Facets:  map[string]int{"appl": 1, "banana": 1},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+// timeoutEngine simulates the query timeout fired after the occurrences of the first token are fetched.
+type timeoutEngine struct {
+	*index.MemoryIndex
+}
+
+func (e timeoutEngine) GetPartial(tokens []string) (map[string]index.Occurrences, bool, error) {
+	occurrencesList, err := e.Get(tokens[:1])
+	return occurrencesList, len(tokens) > 1, err
+}
+
+func TestWs_apiSearchHandlerPartialFacets(t *testing.T) {
+	i := index.NewIndex(timeoutEngine{index.NewMemoryIndex()}, nil, index.WithDefaultOperator(index.OperatorOr))
+	if err := i.AddSource("file1", strings.NewReader("apple banana")); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+	ws := &Ws{i: i}
+
+	w := httptest.NewRecorder()
+	ws.apiSearchHandler(w, httptest.NewRequest(http.MethodGet, "/api/search?q=apple+banana&facets=true&partial=true", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", w.Code, http.StatusOK)
+	}
+	if header := w.Header().Get(partialHeader); header != "true" {
+		t.Errorf("%s is not equal to expected %s", header, "true")
+	}
+	var actual apiFacetedResults
+	if err := json.NewDecoder(w.Body).Decode(&actual); err != nil {
+		t.Fatal(err)
+	}
+	expected := apiFacetedResults{
+		Results: []apiResult{{Document: "file1", Score: 1}},
+		Facets:  map[string]int{"appl": 1, "banana": 0},
+		Partial: true,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestWs_apiSearchHandlerNDJSON(t *testing.T) {
+	ws := newTestWs(t)
+
+	w := httptest.NewRecorder()
+	ws.apiSearchHandler(w, httptest.NewRequest(http.MethodGet, "/api/search?q=apple&format=ndjson", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", w.Code, http.StatusOK)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("%s is not equal to expected application/x-ndjson", contentType)
+	}
+	if !w.Flushed {
+		t.Error("response is not flushed")
+	}
+
+	var actual []apiResult
+	lines := bufio.NewScanner(w.Body)
+	for lines.Scan() {
+		var result apiResult
+		if err := json.Unmarshal(lines.Bytes(), &result); err != nil {
+			t.Fatalf("line %q: %s", lines.Text(), err)
+		}
+		actual = append(actual, result)
+	}
+	expected := []apiResult{
+		{Document: "file2", Score: 2},
+		{Document: "file1", Score: 1},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestWs_apiSearchHandlerBadRequest(t *testing.T) {
+	ws := newTestWs(t)
+	for _, url := range []string{
+		"/api/search",
+		"/api/search?q=apple&include_positions=maybe",
+		"/api/search?q=apple&lang=xx",
+		"/api/search?q=apple&facets=maybe",
+		"/api/search?q=apple&format=xml",
+		"/api/search?q=apple&format=ndjson&facets=true",
+	} {
+		var actual apiError
+		if code := apiRequest(t, ws.apiSearchHandler, url, &actual); code != http.StatusBadRequest {
+			t.Errorf("%s: %d is not equal to expected %d", url, code, http.StatusBadRequest)
+		}
+		if actual.Error == "" {
+			t.Errorf("%s: error message is empty", url)
+		}
+	}
+}
+
+func TestWs_apiDocumentsHandlerDelete(t *testing.T) {
+	ws := newTestWs(t)
+	ws.EnableAdmin("secret")
+	deleteRequest := func(url string, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, url, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		ws.apiDocumentsHandler(w, r)
+		return w
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if w := deleteRequest("/api/documents?prefix=file2", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("%q: %d is not equal to expected %d", token, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	w := deleteRequest("/api/documents?prefix=file2", "secret")
+	if w.Code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", w.Code, http.StatusOK)
+	}
+	var deleted apiDeleted
+	if err := json.NewDecoder(w.Body).Decode(&deleted); err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Deleted != 1 {
+		t.Errorf("%d is not equal to expected 1", deleted.Deleted)
+	}
+
+	var actual []apiResult
+	apiRequest(t, ws.apiSearchHandler, "/api/search?q=apple", &actual)
+	expected := []apiResult{{Document: "file1", Score: 1}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	w = deleteRequest("/api/documents", "secret")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("%d is not equal to expected %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestWs_apiExcludedHandler(t *testing.T) {
+	ws := newTestWs(t)
+	ws.EnableAdmin("secret")
+
+	for _, test := range []struct {
+		method   string
+		url      string
+		token    string
+		code     int
+		expected []apiResult
+	}{
+		{http.MethodPut, "/api/documents/excluded?name=file2", "", http.StatusUnauthorized, []apiResult{
+			{Document: "file2", Score: 2},
+			{Document: "file1", Score: 1},
+		}},
+		{http.MethodPut, "/api/documents/excluded?name=file2", "secret", http.StatusOK, []apiResult{
+			{Document: "file1", Score: 1},
+		}},
+		{http.MethodDelete, "/api/documents/excluded?name=file2", "wrong", http.StatusUnauthorized, []apiResult{
+			{Document: "file1", Score: 1},
+		}},
+		{http.MethodDelete, "/api/documents/excluded?name=file2", "secret", http.StatusOK, []apiResult{
+			{Document: "file2", Score: 2},
+			{Document: "file1", Score: 1},
+		}},
+		{http.MethodPut, "/api/documents/excluded?name=file3", "secret", http.StatusNotFound, nil},
+		{http.MethodPut, "/api/documents/excluded", "secret", http.StatusBadRequest, nil},
+		{http.MethodGet, "/api/documents/excluded?name=file2", "secret", http.StatusMethodNotAllowed, nil},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.url, nil)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		ws.apiExcludedHandler(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s %s: %d is not equal to expected %d", test.method, test.url, w.Code, test.code)
+		}
+		if test.expected == nil {
+			continue
+		}
+		var actual []apiResult
+		apiRequest(t, ws.apiSearchHandler, "/api/search?q=apple", &actual)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s %s: %v is not equal to expected %v", test.method, test.url, actual, test.expected)
+		}
+	}
+}
+
+func TestWs_apiDocumentStatsHandler(t *testing.T) {
+	ws := newTestWs(t)
+
+	var actual apiDocumentStats
+	if code := apiRequest(t, ws.apiDocumentStatsHandler, "/api/documents/stats?name=file1", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	expected := apiDocumentStats{Document: "file1", Length: 2, Vocabulary: 2}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	for url, code := range map[string]int{
+		"/api/documents/stats?name=file3": http.StatusNotFound,
+		"/api/documents/stats":            http.StatusBadRequest,
+	} {
+		var response apiError
+		if actual := apiRequest(t, ws.apiDocumentStatsHandler, url, &response); actual != code {
+			t.Errorf("%s: %d is not equal to expected %d", url, actual, code)
+		}
+	}
+}
+
+func TestWs_apiStatsHandler(t *testing.T) {
+	ws := newTestWs(t)
+
+	var actual apiStats
+	if code := apiRequest(t, ws.apiStatsHandler, "/api/stats", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	expected := apiStats{Documents: 2, Tokens: 2, Occurrences: 4}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	var response apiError
+	if code := apiRequest(t, ws.apiStatsHandler, "/api/stats?tenant=a", &response); code != http.StatusNotImplemented {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusNotImplemented)
+	}
+}
+
+func TestWs_apiDocumentContentHandler(t *testing.T) {
+	i := index.NewIndex(index.NewMemoryIndex(), nil, index.WithRetainedContent())
+	for name, text := range map[string]string{
+		"docs/file1": "Apple & banana",
+		"file2":      "cherry",
+	} {
+		if err := i.AddSource(name, strings.NewReader(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+	ws := &Ws{i: i}
+
+	w := httptest.NewRecorder()
+	ws.apiDocumentContentHandler(w, httptest.NewRequest(http.MethodGet, "/api/documents/docs/file1/content", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", w.Code, http.StatusOK)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+		t.Errorf("%s is not equal to expected text/plain; charset=utf-8", contentType)
+	}
+	if actual := w.Body.String(); actual != "Apple & banana" {
+		t.Errorf("%q is not equal to expected %q", actual, "Apple & banana")
+	}
+
+	for url, code := range map[string]int{
+		"/api/documents/file3/content": http.StatusNotFound,
+		"/api/documents/file2":         http.StatusNotFound,
+		"/api/documents/content":       http.StatusNotFound,
+	} {
+		var response apiError
+		if actual := apiRequest(t, ws.apiDocumentContentHandler, url, &response); actual != code {
+			t.Errorf("%s: %d is not equal to expected %d", url, actual, code)
+		}
+	}
+
+	// The protected content is returned with the admin token only.
+	ws.EnableAdmin("secret")
+	ws.ProtectContent()
+	for token, code := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "secret": http.StatusOK} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/documents/file2/content", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		ws.apiDocumentContentHandler(w, r)
+		if w.Code != code {
+			t.Errorf("%q: %d is not equal to expected %d", token, w.Code, code)
+		}
+	}
+
+	// The content is not retained without the option.
+	var response apiError
+	if code := apiRequest(t, newTestWs(t).apiDocumentContentHandler, "/api/documents/file1/content", &response); code != http.StatusNotFound {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusNotFound)
+	}
+}
+
+func TestWs_apiListDocumentsHandler(t *testing.T) {
+	engine := index.NewMemoryIndex()
+	for _, name := range []string{"file3", "file1", "file5", "file2", "file4"} {
+		if err := engine.Add("appl", 0, index.Source{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws := &Ws{i: index.NewIndex(engine, nil)}
+
+	var pages [][]string
+	for _, url := range []string{
+		"/api/documents?limit=2",
+		"/api/documents?limit=2&offset=2",
+		"/api/documents?limit=2&offset=4&order=name",
+		"/api/documents?limit=2&offset=6",
+	} {
+		var actual apiDocumentList
+		if code := apiRequest(t, ws.apiDocumentsHandler, url, &actual); code != http.StatusOK {
+			t.Errorf("%s: %d is not equal to expected %d", url, code, http.StatusOK)
+		}
+		if actual.Total != 5 {
+			t.Errorf("%s: %d is not equal to expected %d", url, actual.Total, 5)
+		}
+		var page []string
+		for _, document := range actual.Documents {
+			page = append(page, document.Name)
+		}
+		pages = append(pages, page)
+	}
+	expected := [][]string{{"file1", "file2"}, {"file3", "file4"}, {"file5"}, nil}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Errorf("%v is not equal to expected %v", pages, expected)
+	}
+
+	for url, code := range map[string]int{
+		"/api/documents?limit=0":      http.StatusBadRequest,
+		"/api/documents?limit=100000": http.StatusBadRequest,
+		"/api/documents?offset=-1":    http.StatusBadRequest,
+		"/api/documents?order=size":   http.StatusBadRequest,
+		"/api/documents?order=id":     http.StatusNotImplemented,
+	} {
+		var response apiError
+		if actual := apiRequest(t, ws.apiDocumentsHandler, url, &response); actual != code {
+			t.Errorf("%s: %d is not equal to expected %d", url, actual, code)
+		}
+	}
+}
+
+func TestWs_apiAnalyzeHandler(t *testing.T) {
+	ws := &Ws{i: index.NewIndex(index.NewMemoryIndex(), nil, index.WithMinTokenLength(2),
+		index.WithStopwordsOnly(index.NewStopwords("the", "and")))}
+	defer ws.i.Close()
+
+	var actual apiAnalysis
+	if code := apiRequest(t, ws.apiAnalyzeHandler, "/api/analyze?q=The+Apples+and+(bananas)+x", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	expected := apiAnalysis{Query: "The Apples and (bananas) x", Tokens: []string{"appl", "banana"}}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	for url, code := range map[string]int{
+		"/api/analyze?q=the+and":       http.StatusOK,
+		"/api/analyze":                 http.StatusBadRequest,
+		"/api/analyze?q=apple&lang=xx": http.StatusBadRequest,
+	} {
+		var response apiAnalysis
+		if actual := apiRequest(t, ws.apiAnalyzeHandler, url, &response); actual != code {
+			t.Errorf("%s: %d is not equal to expected %d", url, actual, code)
+		}
+	}
+}
+
+func TestWs_apiDebugRankersHandler(t *testing.T) {
+	ws := newTestWs(t)
+
+	var actual []apiComparison
+	if code := apiRequest(t, ws.apiDebugRankersHandler, "/api/debug/rankers?q=apple&limit=1", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	if len(actual) != len(index.RangeAlgorithms) {
+		t.Errorf("%d is not equal to expected %d", len(actual), len(index.RangeAlgorithms))
+	}
+	for _, comparison := range actual {
+		if _, ok := index.RangeAlgorithms[comparison.Ranker]; !ok {
+			t.Errorf("unknown ranker %s", comparison.Ranker)
+		}
+	}
+	expected := []apiComparison{
+		{Ranker: "bm25", Results: []apiResult{{Document: "file2", Score: 1}}},
+		{Ranker: "count", Results: []apiResult{{Document: "file2", Score: 1}}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	var apiErr apiError
+	if code := apiRequest(t, ws.apiDebugRankersHandler, "/api/debug/rankers?q=apple&limit=-1", &apiErr); code != http.StatusBadRequest {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestWs_apiSearchHandlerOptions(t *testing.T) {
+	ws := newTestWs(t)
+
+	var actual []apiResult
+	if code := apiRequest(t, ws.apiSearchHandler, "/api/search?q=apple&since=2000-01-01T00:00:00Z", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	if len(actual) != 0 {
+		t.Errorf("documents without modification time are found: %v", actual)
+	}
+
+	actual = nil
+	if code := apiRequest(t, ws.apiSearchHandler, "/api/search?q=apple&document=file1", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	if expected := []apiResult{{Document: "file1", Score: 1}}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	actual = nil
+	if code := apiRequest(t, ws.apiSearchHandler, "/api/search?q=apple&sort=score&order=asc", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	if expected := []apiResult{{Document: "file1", Score: 1}, {Document: "file2", Score: 2}}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	for _, url := range []string{
+		"/api/search?q=apple&since=yesterday",
+		"/api/search?q=apple&sort=size",
+		"/api/search?q=apple&sort=name&order=up",
+	} {
+		var actual apiError
+		if code := apiRequest(t, ws.apiSearchHandler, url, &actual); code != http.StatusBadRequest {
+			t.Errorf("%s: %d is not equal to expected %d", url, code, http.StatusBadRequest)
+		}
+	}
+}
+
+// plainEngine hides the optional interfaces of the engine, e.g. the prefix search.
+type plainEngine struct {
+	index.IndexEngine
+}
+
+func TestWs_apiSearchHandlerNotImplemented(t *testing.T) {
+	i := index.NewIndex(plainEngine{index.NewMemoryIndex()}, nil)
+	if err := i.AddSource("file1", strings.NewReader("apple")); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+	ws := &Ws{i: i}
+
+	for _, url := range []string{
+		"/api/search?q=apple&tenant=a",
+		"/api/search?q=appl*",
+	} {
+		var actual apiError
+		if code := apiRequest(t, ws.apiSearchHandler, url, &actual); code != http.StatusNotImplemented {
+			t.Errorf("%s: %d is not equal to expected %d", url, code, http.StatusNotImplemented)
+		}
+	}
+}
+
+func TestWs_apiSearchHandlerMarkers(t *testing.T) {
+	i := index.NewIndex(index.NewMemoryIndex(), nil, index.WithSnippets())
+	if err := i.AddSource("file1", strings.NewReader("red apple")); err != nil {
+		t.Fatal(err)
+	}
+	i.Close()
+	ws := &Ws{i: i}
+
+	for url, expected := range map[string]string{
+		"/api/search?q=apple": "red <mark>apple</mark>",
+		"/api/search?q=apple&highlight_pre=**&highlight_post=**": "red **apple**",
+	} {
+		var actual []apiResult
+		if code := apiRequest(t, ws.apiSearchHandler, url, &actual); code != http.StatusOK {
+			t.Errorf("%s: %d is not equal to expected %d", url, code, http.StatusOK)
+		}
+		if len(actual) != 1 || actual[0].Snippet != expected {
+			t.Errorf("%s: %v is not equal to expected %s", url, actual, expected)
+		}
+	}
+}
+
+func TestWs_apiCapabilitiesHandler(t *testing.T) {
+	ws := newTestWs(t)
+
+	var actual apiCapabilities
+	if code := apiRequest(t, ws.apiCapabilitiesHandler, "/api/capabilities", &actual); code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	expected := apiCapabilities{
+		Engine:         "MemoryIndex",
+		Positions:      true,
+		Suggestions:    true,
+		Iterate:        true,
+		Remove:         true,
+		DeleteByPrefix: true,
+		Stats:          true,
+		Exclude:        true,
+		DocumentStats:  true,
+		ListDocuments:  true,
+		Content:        true,
+		BM25:           true,
+		Snippets:       true,
+		Prefix:         true,
+		Metadata:       true,
+		Incremental:    true,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
+
+func TestWs_apiSuggestHandler(t *testing.T) {
+	engine := index.NewMemoryIndex()
+	for token, positions := range map[string]int{"appl": 1, "applic": 3, "apricot": 2, "banana": 4} {
+		for position := 0; position < positions; position++ {
+			if err := engine.Add(token, position, index.Source{Name: "file1"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	ws := &Ws{i: index.NewIndex(engine, nil)}
+
+	for url, expected := range map[string][]apiCompletion{
+		"/api/suggest?q=ap":          {{"applic", 3}, {"apricot", 2}, {"appl", 1}},
+		"/api/suggest?q=App&limit=1": {{"applic", 3}},
+		"/api/suggest?q=cherry":      {},
+	} {
+		var actual []apiCompletion
+		if code := apiRequest(t, ws.apiSuggestHandler, url, &actual); code != http.StatusOK {
+			t.Errorf("%s: %d is not equal to expected %d", url, code, http.StatusOK)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: %v is not equal to expected %v", url, actual, expected)
+		}
+	}
+
+	var actual apiError
+	if code := apiRequest(t, ws.apiSuggestHandler, "/api/suggest", &actual); code != http.StatusBadRequest {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusBadRequest)
+	}
+}
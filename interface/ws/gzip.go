@@ -0,0 +1,140 @@
+package ws
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EnableCompression makes the server gzip the responses of the clients accepting gzip encoding. The responses shorter
+// than minSize bytes are sent as is.
+func (ws *Ws) EnableCompression(minSize int) {
+	ws.compress = true
+	ws.compressMinSize = minSize
+}
+
+func (ws *Ws) gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ws.compress {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipWriter{ResponseWriter: w, minSize: ws.compressMinSize, status: http.StatusOK}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip checks the Accept-Encoding header of the request, e.g. `gzip, deflate` or `gzip;q=0.5`.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(encoding, ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err != nil || q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipWriter buffers the response until it reaches the minimal size, then compresses it. The shorter responses are
+// written as is on close.
+type gzipWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+	// started is true when the headers are sent.
+	started bool
+	buf     []byte
+	gz      *gzip.Writer
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	if w.started {
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) < w.minSize {
+		return len(p), nil
+	}
+	w.started = true
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		w.ResponseWriter.WriteHeader(w.status)
+		return len(p), w.flushBuffer(w.ResponseWriter)
+	}
+	// The server sniffs the content type of the written data, so it is detected from the data before compressing.
+	if _, ok := header["Content-Type"]; !ok {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", "gzip")
+	w.ResponseWriter.WriteHeader(w.status)
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	return len(p), w.flushBuffer(w.gz)
+}
+
+// Flush sends the compressed data written so far to the client, e.g. the lines of the streamed response. The response
+// shorter than the minimal size is kept in the buffer.
+func (w *gzipWriter) Flush() {
+	if !w.started {
+		return
+	}
+	if w.gz != nil {
+		if err := w.gz.Flush(); err != nil {
+			log.Error().Err(err).Msg("error compressing response")
+			return
+		}
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *gzipWriter) flushBuffer(out io.Writer) error {
+	_, err := out.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// close finishes the compressed stream or writes the short response as is.
+func (w *gzipWriter) close() {
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			log.Error().Err(err).Msg("error compressing response")
+		}
+		return
+	}
+	if w.started {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if err := w.flushBuffer(w.ResponseWriter); err != nil {
+		log.Error().Err(err).Msg("error writing response")
+	}
+}
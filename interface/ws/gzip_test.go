@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWs_gzipMiddleware(t *testing.T) {
+	ws := &Ws{}
+	ws.EnableCompression(100)
+	body := strings.Repeat("apple banana ", 100)
+	handler := ws.gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("short") != "" {
+			w.Write([]byte("short"))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	}))
+
+	for _, tc := range []struct {
+		url            string
+		acceptEncoding string
+		encoding       string
+		status         int
+		body           string
+	}{
+		{"/", "gzip, deflate", "gzip", http.StatusCreated, body},
+		{"/", "deflate, gzip;q=0", "", http.StatusCreated, body},
+		{"/", "", "", http.StatusCreated, body},
+		{"/?short=1", "gzip", "", http.StatusOK, "short"},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+		r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		handler.ServeHTTP(w, r)
+
+		if encoding := w.Header().Get("Content-Encoding"); encoding != tc.encoding {
+			t.Errorf("%s %q: %q is not equal to expected %q", tc.url, tc.acceptEncoding, encoding, tc.encoding)
+		}
+		if w.Code != tc.status {
+			t.Errorf("%s %q: %d is not equal to expected %d", tc.url, tc.acceptEncoding, w.Code, tc.status)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("%q is not equal to expected Accept-Encoding", vary)
+		}
+		var actual []byte
+		if tc.encoding == "gzip" {
+			// The content type is detected from the uncompressed body.
+			if contentType := w.Header().Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+				t.Errorf("%s %q: %q is not equal to expected text/plain", tc.url, tc.acceptEncoding, contentType)
+			}
+			if w.Body.Len() >= len(body) {
+				t.Errorf("%d is not less than %d", w.Body.Len(), len(body))
+			}
+			reader, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual, err = ioutil.ReadAll(reader); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			actual = w.Body.Bytes()
+		}
+		if string(actual) != tc.body {
+			t.Errorf("%s %q: %q is not equal to expected %q", tc.url, tc.acceptEncoding, actual, tc.body)
+		}
+	}
+}
+
+func TestWs_gzipMiddlewareFlush(t *testing.T) {
+	ws := &Ws{}
+	ws.EnableCompression(10)
+	var flushed int
+	handler := ws.gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("apple banana cherry\n"))
+		w.(http.Flusher).Flush()
+		flushed = w.(*gzipWriter).ResponseWriter.(*httptest.ResponseRecorder).Body.Len()
+		w.Write([]byte("orange\n"))
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(w, r)
+
+	if flushed == 0 || !w.Flushed {
+		t.Error("compressed response is not flushed")
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "apple banana cherry\norange\n"; string(actual) != expected {
+		t.Errorf("%q is not equal to expected %q", actual, expected)
+	}
+}
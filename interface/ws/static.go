@@ -0,0 +1,47 @@
+package ws
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// assets contains the templates and the static files of the web UI, so the binary does not depend on the working
+// directory.
+//
+//go:embed templates static
+var assets embed.FS
+
+// OverrideStatic serves the static files from the directory, e.g. the customized style.css, falling back to the
+// embedded ones for the files missing in the directory.
+func (ws *Ws) OverrideStatic(dir string) {
+	ws.staticDir = dir
+}
+
+// overlayFS opens the files from the override file system first and from the embedded one otherwise.
+type overlayFS struct {
+	override http.FileSystem
+	embedded http.FileSystem
+}
+
+func (o overlayFS) Open(name string) (http.File, error) {
+	if o.override != nil {
+		if f, err := o.override.Open(name); err == nil {
+			return f, nil
+		}
+	}
+	return o.embedded.Open(name)
+}
+
+func (ws *Ws) staticHandler(w http.ResponseWriter, r *http.Request) {
+	static, err := fs.Sub(assets, "static")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	files := overlayFS{embedded: http.FS(static)}
+	if ws.staticDir != "" {
+		files.override = http.Dir(ws.staticDir)
+	}
+	http.StripPrefix("/static/", http.FileServer(files)).ServeHTTP(w, r)
+}
@@ -0,0 +1,8 @@
+// Focus the query input and put the cursor at the end of the query.
+document.addEventListener("DOMContentLoaded", function () {
+    var input = document.querySelector("input[name=q]");
+    if (input) {
+        input.focus();
+        input.setSelectionRange(input.value.length, input.value.length);
+    }
+});
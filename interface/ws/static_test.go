@@ -0,0 +1,51 @@
+package ws
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func staticRequest(ws *Ws, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ws.staticHandler(w, httptest.NewRequest(http.MethodGet, url, nil))
+	return w
+}
+
+func TestWs_staticHandler(t *testing.T) {
+	ws := &Ws{}
+	embedded, err := assets.ReadFile("static/style.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := staticRequest(ws, "/static/style.css")
+	if w.Code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", w.Code, http.StatusOK)
+	}
+	if w.Body.String() != string(embedded) {
+		t.Errorf("%q is not equal to expected %q", w.Body.String(), embedded)
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/css") {
+		t.Errorf("%s is not equal to expected text/css", contentType)
+	}
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "style.css"), []byte("body {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ws.OverrideStatic(dir)
+
+	if w := staticRequest(ws, "/static/style.css"); w.Body.String() != "body {}" {
+		t.Errorf("%q is not equal to expected %q", w.Body.String(), "body {}")
+	}
+	if w := staticRequest(ws, "/static/search.js"); w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("embedded search.js is not served when it is missing in the override directory: %d", w.Code)
+	}
+	if w := staticRequest(ws, "/static/missing.css"); w.Code != http.StatusNotFound {
+		t.Errorf("%d is not equal to expected %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -1,10 +1,18 @@
 package ws
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/polisgo2020/search-tariel-x/index"
@@ -12,11 +20,28 @@ import (
 )
 
 type Ws struct {
+	network   string
 	listen    string
 	i         *index.Index
 	server    http.Server
 	indexTpl  *template.Template
 	searchTpl *template.Template
+	// warming is 1 while the query cache is warmed up.
+	warming int32
+	// adminToken authorizes the admin requests, they are forbidden if it is empty.
+	adminToken string
+	// protectContent authorizes the requests of the raw texts of the documents with the admin token.
+	protectContent bool
+	// load reads the new engine of the index on reload.
+	load func() (index.IndexEngine, error)
+	// compress enables gzip encoding of the responses not shorter than compressMinSize bytes.
+	compress        bool
+	compressMinSize int
+	// staticDir overrides the embedded static files if it is not empty.
+	staticDir string
+	// inflightM guards inflight, the requests started over the current engine of the index, see track.
+	inflightM sync.Mutex
+	inflight  *sync.WaitGroup
 }
 
 func New(listen string, timeout time.Duration, i *index.Index) (*Ws, error) {
@@ -24,20 +49,22 @@ func New(listen string, timeout time.Duration, i *index.Index) (*Ws, error) {
 		return nil, errors.New("incorrect index obj")
 	}
 
-	if listen == "" {
-		return nil, errors.New("incorrect listen interface")
+	network, listen, err := parseListen(listen)
+	if err != nil {
+		return nil, err
 	}
 
-	indexTpl, err := template.ParseFiles("interface/ws/templates/index.html")
+	indexTpl, err := template.ParseFS(assets, "templates/index.html")
 	if err != nil {
 		return nil, fmt.Errorf("can not read index template %w", err)
 	}
-	searchTpl, err := template.ParseFiles("interface/ws/templates/search.html")
+	searchTpl, err := template.ParseFS(assets, "templates/search.html")
 	if err != nil {
 		return nil, fmt.Errorf("can not read search template %w", err)
 	}
 
 	ws := &Ws{
+		network:   network,
 		listen:    listen,
 		i:         i,
 		indexTpl:  indexTpl,
@@ -47,8 +74,22 @@ func New(listen string, timeout time.Duration, i *index.Index) (*Ws, error) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", ws.indexHandler)
 	mux.HandleFunc("/search", ws.searchHandler)
+	mux.HandleFunc("/static/", ws.staticHandler)
+	mux.HandleFunc("/api/search", ws.apiSearchHandler)
+	mux.HandleFunc("/api/suggest", ws.apiSuggestHandler)
+	mux.HandleFunc("/api/analyze", ws.apiAnalyzeHandler)
+	mux.HandleFunc("/api/documents", ws.apiDocumentsHandler)
+	mux.HandleFunc("/api/documents/excluded", ws.apiExcludedHandler)
+	mux.HandleFunc("/api/documents/stats", ws.apiDocumentStatsHandler)
+	mux.HandleFunc("/api/documents/", ws.apiDocumentContentHandler)
+	mux.HandleFunc("/api/debug/rankers", ws.apiDebugRankersHandler)
+	mux.HandleFunc("/api/capabilities", ws.apiCapabilitiesHandler)
+	mux.HandleFunc("/api/stats", ws.apiStatsHandler)
+	mux.HandleFunc("/readyz", ws.readyHandler)
+	mux.HandleFunc("/healthz", ws.healthHandler)
+	mux.HandleFunc("/api/admin/reload", ws.admin(ws.apiReloadHandler))
 
-	logMw := logMiddleware(mux)
+	logMw := logMiddleware(recoverMiddleware(ws.gzipMiddleware(ws.track(mux))))
 
 	ws.server = http.Server{
 		Addr:         listen,
@@ -60,6 +101,39 @@ func New(listen string, timeout time.Duration, i *index.Index) (*Ws, error) {
 	return ws, nil
 }
 
+// unixPrefix is the prefix of the listen address for unix sockets, e.g. unix:/var/run/search.sock.
+const unixPrefix = "unix:"
+
+// parseListen validates the listen address and returns the network and the address to listen.
+// The address can be host:port, :port or port to listen all interfaces, or unix:/path/to/socket.
+func parseListen(listen string) (string, string, error) {
+	if listen == "" {
+		return "", "", errors.New("incorrect listen interface: empty address")
+	}
+
+	if strings.HasPrefix(listen, unixPrefix) {
+		path := strings.TrimPrefix(listen, unixPrefix)
+		if path == "" {
+			return "", "", fmt.Errorf("incorrect listen interface %q: empty socket path", listen)
+		}
+		return "unix", path, nil
+	}
+
+	if _, err := strconv.Atoi(listen); err == nil {
+		listen = ":" + listen
+	}
+
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", "", fmt.Errorf("incorrect listen interface %q, expected host:port, :port or unix:/path: %w", listen, err)
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil || portNum < 0 || portNum > 65535 {
+		return "", "", fmt.Errorf("incorrect listen interface %q: invalid port %q", listen, port)
+	}
+	return "tcp", net.JoinHostPort(host, port), nil
+}
+
 func logMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -74,36 +148,205 @@ func logMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// recoverMiddleware logs the panic of the handler with the stack and responds with 500, so the server keeps running.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			log.Error().
+				Interface("panic", err).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Bytes("stack", debug.Stack()).
+				Msg("handler panic")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (ws *Ws) indexHandler(w http.ResponseWriter, r *http.Request) {
 	if err := ws.indexTpl.Execute(w, nil); err != nil {
 		log.Error().Err(err).Msg("error rendering template")
 	}
 }
 
+// tenantHeader is the request header with the tenant to search over.
+// The tenant can be passed with `tenant` query parameter as well.
+const tenantHeader = "X-Tenant"
+
+// partialHeader is the response header set to true if the search results requested with `partial` query parameter
+// are incomplete because the query timed out.
+const partialHeader = "X-Partial-Results"
+
+func tenant(r *http.Request) string {
+	if tenant := r.Header.Get(tenantHeader); tenant != "" {
+		return tenant
+	}
+	return r.URL.Query().Get("tenant")
+}
+
+func (ws *Ws) search(r *http.Request, query string, limit, offset int) ([]index.Result, int, error) {
+	options := index.SearchOptions{Tenant: tenant(r), Language: r.URL.Query().Get("lang")}
+	return ws.i.SearchPagedWithOptions(query, options, limit, offset)
+}
+
+// pageParameters returns `limit` and `offset` query parameters of the results page, 0 limit shows all results.
+func pageParameters(r *http.Request) (limit int, offset int, err error) {
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			return 0, 0, errors.New("incorrect limit parameter")
+		}
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return 0, 0, errors.New("incorrect offset parameter")
+		}
+	}
+	return limit, offset, nil
+}
+
+// pageURL returns the URL of the results page at the offset keeping all other query parameters of the request, e.g.
+// the tenant and the language.
+func pageURL(r *http.Request, offset int) template.URL {
+	query := r.URL.Query()
+	query.Set("offset", strconv.Itoa(offset))
+	return template.URL(r.URL.Path + "?" + query.Encode())
+}
+
+// maxSuggestions is the maximal number of suggestions shown when the search finds nothing.
+const maxSuggestions = 5
+
+func (ws *Ws) suggest(r *http.Request, query string) ([]string, error) {
+	return ws.i.SuggestTenant(tenant(r), query, maxSuggestions)
+}
+
 func (ws *Ws) searchHandler(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
+	limit, offset, err := pageParameters(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Error search %q over index: %s.", query, err)
+		return
+	}
 
 	var results []index.Result
-	var err error
+	var suggestions []string
+	var total int
 	if query != "" {
-		results, err = ws.i.Search(query)
-		if err != nil {
+		results, total, err = ws.search(r, query, limit, offset)
+		if errors.Is(err, index.ErrTooManyCandidates) || errors.Is(err, index.ErrUnknownLanguage) {
+			fmt.Fprintf(w, "Error search %q over index: %s.", query, err)
+		} else if err != nil {
 			log.Printf("Error search %q over index: %q", query, err)
 			fmt.Fprintf(w, "Error search %q over index.", query)
 		}
 	}
+	// The empty state is shown only if the search succeeded, the failed search shows the error.
+	empty := query != "" && err == nil && total == 0
+	if empty {
+		if suggestions, err = ws.suggest(r, query); err != nil {
+			log.Error().Err(err).Str("query", query).Msg("error getting suggestions")
+		}
+	}
+	// The links to the previous and the next pages are shown if the limit is set, the empty link is hidden.
+	var previous, next template.URL
+	if limit > 0 && offset > 0 {
+		previousOffset := offset - limit
+		if previousOffset < 0 {
+			previousOffset = 0
+		}
+		previous = pageURL(r, previousOffset)
+	}
+	if limit > 0 && offset+limit < total {
+		next = pageURL(r, offset+limit)
+	}
 	if err := ws.searchTpl.Execute(w, struct {
-		Results []index.Result
-		Query   string
+		Results     []index.Result
+		Query       string
+		Suggestions []string
+		Empty       bool
+		Total       int
+		Limit       int
+		Previous    template.URL
+		Next        template.URL
 	}{
-		Results: results,
-		Query:   query,
+		Results:     results,
+		Query:       query,
+		Suggestions: suggestions,
+		Empty:       empty,
+		Total:       total,
+		Limit:       limit,
+		Previous:    previous,
+		Next:        next,
 	}); err != nil {
 		log.Error().Err(err).Msg("error rendering template")
 	}
 }
 
+// Warmup runs the queries to populate the query cache in background. The server is not ready until the warmup is
+// finished, but it serves the searches meanwhile. The queries are closed after the warmup.
+func (ws *Ws) Warmup(queries io.ReadCloser) {
+	atomic.StoreInt32(&ws.warming, 1)
+	inflight := ws.acquire()
+	go func() {
+		defer inflight.Done()
+		defer atomic.StoreInt32(&ws.warming, 0)
+		defer queries.Close()
+		start := time.Now()
+		count, err := ws.i.Warmup(queries)
+		if err != nil {
+			log.Error().Err(err).Int("queries", count).Msg("error warming up query cache")
+			return
+		}
+		log.Info().Int("queries", count).Dur("duration", time.Since(start)).Msg("query cache is warmed up")
+	}()
+}
+
+// readyHandler responds with 503 status until the warmup is finished.
+func (ws *Ws) readyHandler(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&ws.warming) == 1 {
+		http.Error(w, "warming up", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(w, "ok")
+}
+
+// healthHandler responds with 503 status when the engine of the index is not reachable, e.g. the database is down.
+func (ws *Ws) healthHandler(w http.ResponseWriter, r *http.Request) {
+	if err := ws.i.Ping(); err != nil {
+		log.Error().Err(err).Msg("engine is not reachable")
+		http.Error(w, "engine is not reachable", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(w, "ok")
+}
+
+// Shutdown stops accepting new requests, waits for the in-flight searches and closes the index.
+// The engine of the index must be closed by the caller after Shutdown returns.
+func (ws *Ws) Shutdown(ctx context.Context) error {
+	if err := ws.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("can not shutdown server: %w", err)
+	}
+	ws.i.Close()
+	return nil
+}
+
+// Run serves the requests until Shutdown is called. It returns nil after Shutdown.
 func (ws *Ws) Run() error {
+	listener, err := net.Listen(ws.network, ws.listen)
+	if err != nil {
+		return fmt.Errorf("can not listen %s %s: %w", ws.network, ws.listen, err)
+	}
 	log.Info().Str("interface", ws.listen).Msg("started to listen")
-	return ws.server.ListenAndServe()
+	if err := ws.server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,336 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/polisgo2020/search-tariel-x/index"
+)
+
+func TestParseListen(t *testing.T) {
+	for listen, expected := range map[string][2]string{
+		"0.0.0.0:8080":         {"tcp", "0.0.0.0:8080"},
+		"localhost:80":         {"tcp", "localhost:80"},
+		"[::1]:8080":           {"tcp", "[::1]:8080"},
+		":8080":                {"tcp", ":8080"},
+		"8080":                 {"tcp", ":8080"},
+		"unix:/tmp/search.soc": {"unix", "/tmp/search.soc"},
+	} {
+		network, address, err := parseListen(listen)
+		if err != nil {
+			t.Errorf("%s: %v", listen, err)
+			continue
+		}
+		if actual := [2]string{network, address}; actual != expected {
+			t.Errorf("%s: %v is not equal to expected %v", listen, actual, expected)
+		}
+	}
+}
+
+func TestParseListenInvalid(t *testing.T) {
+	for _, listen := range []string{
+		"",
+		"localhost",
+		"localhost:",
+		"localhost:http",
+		"localhost:99999",
+		"0.0.0.0:8080:80",
+		"unix:",
+	} {
+		if _, _, err := parseListen(listen); err == nil {
+			t.Errorf("%q must be invalid", listen)
+		}
+	}
+}
+
+// blockingEngine blocks the search until it is released and panics if it is searched after Close.
+type blockingEngine struct {
+	*index.MemoryIndex
+	started chan struct{}
+	release chan struct{}
+	m       sync.Mutex
+	closed  bool
+}
+
+func (e *blockingEngine) Get(tokens []string) (map[string]index.Occurrences, error) {
+	close(e.started)
+	<-e.release
+	e.m.Lock()
+	defer e.m.Unlock()
+	if e.closed {
+		panic("search over closed engine")
+	}
+	return e.MemoryIndex.Get(tokens)
+}
+
+func (e *blockingEngine) Close() {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.closed = true
+}
+
+// freeAddress returns the local address with the random free port.
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestWs_ShutdownDuringSearch(t *testing.T) {
+	engine := &blockingEngine{
+		MemoryIndex: index.NewMemoryIndex(),
+		started:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+	if err := engine.Add("appl", 0, index.Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+	listen := freeAddress(t)
+	ws := &Ws{network: "tcp", listen: listen, i: index.NewIndex(engine, nil)}
+	ws.server = http.Server{Handler: http.HandlerFunc(ws.apiSearchHandler)}
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- ws.Run()
+	}()
+
+	status := make(chan int, 1)
+	go func() {
+		var response *http.Response
+		var err error
+		for attempt := 0; attempt < 50; attempt++ {
+			if response, err = http.Get("http://" + listen + "/api/search?q=apple"); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Error(err)
+			status <- 0
+			return
+		}
+		response.Body.Close()
+		status <- response.StatusCode
+	}()
+	<-engine.started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		err := ws.Shutdown(context.Background())
+		engine.Close()
+		shutdownErr <- err
+	}()
+
+	select {
+	case <-shutdownErr:
+		t.Fatal("shutdown does not wait for the in-flight search")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(engine.release)
+
+	if code := <-status; code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", code, http.StatusOK)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Error(err)
+	}
+	if err := <-runErr; err != nil {
+		t.Error(err)
+	}
+}
+
+// blockingReader blocks the reading until it is released.
+type blockingReader struct {
+	*strings.Reader
+	release chan struct{}
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	<-r.release
+	return r.Reader.Read(p)
+}
+
+func (r *blockingReader) Close() error {
+	return nil
+}
+
+func TestWs_Warmup(t *testing.T) {
+	ws := newTestWs(t)
+	queries := &blockingReader{Reader: strings.NewReader("apple\n"), release: make(chan struct{})}
+	ws.Warmup(queries)
+
+	w := httptest.NewRecorder()
+	ws.readyHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("%d is not equal to expected %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	close(queries.release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		w = httptest.NewRecorder()
+		ws.readyHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if w.Code == http.StatusOK || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", w.Code, http.StatusOK)
+	}
+}
+
+// pingEngine simulates the engine with the external storage.
+type pingEngine struct {
+	*index.MemoryIndex
+	err error
+}
+
+func (e pingEngine) Ping() error {
+	return e.err
+}
+
+func TestWs_healthHandler(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		err  error
+		code int
+		body string
+	}{
+		{"healthy", nil, http.StatusOK, "ok"},
+		{"unhealthy", errors.New("connection refused"), http.StatusServiceUnavailable, "engine is not reachable\n"},
+	} {
+		ws := &Ws{i: index.NewIndex(pingEngine{index.NewMemoryIndex(), test.err}, nil)}
+		w := httptest.NewRecorder()
+		ws.healthHandler(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if w.Code != test.code {
+			t.Errorf("%s: %d is not equal to expected %d", test.name, w.Code, test.code)
+		}
+		if w.Body.String() != test.body {
+			t.Errorf("%s: %q is not equal to expected %q", test.name, w.Body.String(), test.body)
+		}
+		ws.i.Close()
+	}
+}
+
+func TestNew_WorkingDirectory(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	// The templates are embedded, so the server starts out of the repository root.
+	ws, err := New("127.0.0.1:0", time.Second, index.NewIndex(index.NewMemoryIndex(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, handler := range []http.HandlerFunc{ws.indexHandler, ws.searchHandler} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/search?q=apple", nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<html") {
+			t.Errorf("%d %q is not the rendered template", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestWs_searchHandlerPaged(t *testing.T) {
+	ws, err := New("127.0.0.1:0", time.Second, newTestWs(t).i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		url      string
+		code     int
+		contains []string
+		excludes []string
+	}{
+		{"/search?q=apple", http.StatusOK, []string{"Found 2 documents", "file2", "file1"}, []string{"Next"}},
+		{"/search?q=apple&limit=1", http.StatusOK, []string{"file2", "offset=1&amp;q=apple\">Next"}, []string{"file1", "Previous"}},
+		{"/search?q=apple&limit=1&offset=1", http.StatusOK, []string{"file1", "offset=0&amp;q=apple\">Previous"}, []string{"file2", "Next"}},
+		// The links keep the other parameters of the search.
+		{"/search?q=apple&limit=1&lang=", http.StatusOK, []string{"/search?lang=&amp;limit=1&amp;offset=1&amp;q=apple"}, nil},
+		{"/search?q=apple&limit=5&offset=10", http.StatusOK, []string{"Found 2 documents"}, []string{"file1", "No documents found"}},
+		{"/search?q=durian", http.StatusOK, []string{"No documents found for \"durian\""}, []string{"Found"}},
+		{"/search?q=apple&limit=-1", http.StatusBadRequest, []string{"incorrect limit parameter"}, nil},
+		{"/search?q=apple&offset=x", http.StatusBadRequest, []string{"incorrect offset parameter"}, nil},
+	} {
+		w := httptest.NewRecorder()
+		ws.searchHandler(w, httptest.NewRequest(http.MethodGet, test.url, nil))
+		if w.Code != test.code {
+			t.Errorf("%s: %d is not equal to expected %d", test.url, w.Code, test.code)
+		}
+		body := w.Body.String()
+		for _, expected := range test.contains {
+			if !strings.Contains(body, expected) {
+				t.Errorf("%s: %q does not contain %q", test.url, body, expected)
+			}
+		}
+		for _, unexpected := range test.excludes {
+			if strings.Contains(body, unexpected) {
+				t.Errorf("%s: %q contains %q", test.url, body, unexpected)
+			}
+		}
+	}
+}
+
+// failingEngine fails to get the occurrences.
+type failingEngine struct {
+	*index.MemoryIndex
+}
+
+func (e failingEngine) Get(tokens []string) (map[string]index.Occurrences, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestWs_searchHandlerError(t *testing.T) {
+	ws, err := New("127.0.0.1:0", time.Second, index.NewIndex(failingEngine{index.NewMemoryIndex()}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	ws.searchHandler(w, httptest.NewRequest(http.MethodGet, "/search?q=apple", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "Error search") {
+		t.Errorf("%q does not contain %q", body, "Error search")
+	}
+	// The failed search is not reported as the empty result set.
+	if strings.Contains(body, "No documents found") {
+		t.Errorf("%q contains %q", body, "No documents found")
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	handler := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m["broken"]++
+	}))
+
+	for n := 0; n < 2; n++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%d is not equal to expected %d", w.Code, http.StatusInternalServerError)
+		}
+	}
+}
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	stdLog "log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-pg/pg/v9"
@@ -16,6 +27,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 
+	"github.com/polisgo2020/search-tariel-x/config"
 	"github.com/polisgo2020/search-tariel-x/index"
 	ifaceCli "github.com/polisgo2020/search-tariel-x/interface/cli"
 	"github.com/polisgo2020/search-tariel-x/interface/ws"
@@ -25,7 +37,8 @@ func main() {
 	app := cli.NewApp()
 	app.Name = "Search index"
 	app.Usage = "generate index from text files and search over them"
-	app.Before = initLogger
+
+	defaults := config.Default()
 
 	indexFileFlag := &cli.StringFlag{
 		Name:     "index",
@@ -34,12 +47,17 @@ func main() {
 		Required: true,
 	}
 
+	configFlag := &cli.StringFlag{
+		Name:    "config",
+		Aliases: []string{"c"},
+		Usage:   "JSON configuration file, environment variables and flags override its values",
+		EnvVars: []string{"CONFIG"},
+	}
+
 	pgFlag := &cli.StringFlag{
-		Name:     "postgresql",
-		Aliases:  []string{"pg"},
-		Usage:    "Postgresql connection strings",
-		EnvVars:  []string{"PGSQL"},
-		Required: true,
+		Name:    "postgresql",
+		Aliases: []string{"pg"},
+		Usage:   "Postgresql connection strings, env PGSQL",
 	}
 
 	jsonFlag := &cli.BoolFlag{
@@ -47,11 +65,127 @@ func main() {
 		Usage: "Use json-encoded index",
 	}
 
+	streamFlag := &cli.BoolFlag{
+		Name:  "stream",
+		Usage: "Use streamed index format",
+	}
+
+	nameCollisionFlag := &cli.StringFlag{
+		Name:  "nameCollision",
+		Usage: "Handling of files indexed with the same document name: serialize (default) appends the later file to the document, reject skips it with an error, env NAME_COLLISION",
+	}
+
+	dedupFlag := &cli.BoolFlag{
+		Name:  "dedup",
+		Usage: "Skip files with the same content as already indexed ones, env DEDUP",
+	}
+
+	incrementalFlag := &cli.BoolFlag{
+		Name:  "incremental",
+		Usage: "Skip files with the same content as already indexed ones and reindex the changed ones, env INCREMENTAL",
+	}
+
+	maxFilesFlag := &cli.IntFlag{
+		Name:  "maxFiles",
+		Usage: "Maximal number of files indexed in the order of their names, 0 means no limit, env MAX_FILES",
+	}
+
+	workersFlag := &cli.IntFlag{
+		Name:  "workers",
+		Usage: "Number of the files read in parallel, default the number of CPUs, env WORKERS",
+	}
+
+	readErrorsFlag := &cli.StringFlag{
+		Name:  "readErrors",
+		Usage: "Handling of the files which can not be read: skip, fail on the first one or strict to fail after reading all files, default skip, env READ_ERRORS",
+	}
+
+	recordsFlag := &cli.StringFlag{
+		Name:  "records",
+		Usage: "Format of the files whose records are indexed as separate documents: csv or jsonl, empty indexes every file as one document, env RECORDS",
+	}
+
+	retainContentFlag := &cli.BoolFlag{
+		Name:  "retainContent",
+		Usage: "Keep the raw texts of the files in the index file to return them with the API, env RETAIN_CONTENT",
+	}
+
+	snippetsFlag := &cli.BoolFlag{
+		Name:  "snippets",
+		Usage: "Keep the words of the files in the index file and return the snippets with the results, env SNIPPETS",
+	}
+
+	recordNameFlag := &cli.StringFlag{
+		Name:  "recordName",
+		Usage: "Field of the record used as the document name, default name, env RECORD_NAME",
+	}
+
+	recordContentFlag := &cli.StringFlag{
+		Name:  "recordContent",
+		Usage: "Field of the record used as the document content, default content, env RECORD_CONTENT",
+	}
+
+	recordMetadataFlag := &cli.StringFlag{
+		Name:  "recordMetadata",
+		Usage: "Comma-separated fields of the record kept as the document metadata, e.g. to order the results by them, env RECORD_METADATA",
+	}
+
+	maxWordSizeFlag := &cli.IntFlag{
+		Name:  "maxWordSize",
+		Usage: "Maximal size of the indexed word in bytes, longer words are skipped, 0 means 64KB, env MAX_WORD_SIZE",
+	}
+
+	progressFlag := &cli.DurationFlag{
+		Name:  "progress",
+		Usage: "Interval of the build progress messages, default 10s, 0 disables them, env PROGRESS",
+	}
+
+	quietFlag := &cli.BoolFlag{
+		Name:  "quiet",
+		Usage: "Do not print the summary of the built index",
+	}
+
+	checksumFlag := &cli.BoolFlag{
+		Name:  "checksum",
+		Usage: "Write the SHA-256 checksum of the index to the file with .sha256 suffix, it is verified on load",
+	}
+
+	federateFlag := &cli.StringSliceFlag{
+		Name:  "federate",
+		Usage: "Additional index files searched together with the index, may be repeated",
+	}
+
+	duplicatesFlag := &cli.StringFlag{
+		Name:  "duplicates",
+		Usage: "Handling of the documents with the same name in the federated index files: merge or suffix",
+		Value: "merge",
+	}
+
+	spillFlag := &cli.IntFlag{
+		Name:  "spill",
+		Usage: "Spill postings to temporary files after this number of positions and write streamed index",
+	}
+
+	shardsFlag := &cli.IntFlag{
+		Name:  "shards",
+		Usage: "Split the index into this number of files listed in the manifest with .manifest suffix",
+	}
+
+	shardWorkersFlag := &cli.IntFlag{
+		Name:  "shardWorkers",
+		Usage: "Number of the shards of the index decoded in parallel, 0 decodes all shards at once",
+	}
+
 	logLevelFlag := &cli.StringFlag{
-		Name:    "logLevel",
-		Usage:   "Log level",
-		Value:   "debug",
-		EnvVars: []string{"LOG_LEVEL"},
+		Name:  "logLevel",
+		Usage: "Log level, env LOG_LEVEL",
+		Value: defaults.LogLevel,
+	}
+
+	logFormatFlag := &cli.StringFlag{
+		Name:  "logFormat",
+		Usage: "Log format: json or console, env LOG_FORMAT",
+		Value: defaults.LogFormat,
 	}
 
 	sourceFlag := &cli.StringFlag{
@@ -61,11 +195,188 @@ func main() {
 		Required: true,
 	}
 
+	tenantFlag := &cli.StringFlag{
+		Name:  "tenant",
+		Usage: "Tenant of the documents, env TENANT",
+	}
+
+	flushWorkersFlag := &cli.IntFlag{
+		Name:  "flushWorkers",
+		Usage: "Number of the workers inserting the occurrences to the database in parallel, default 1, env FLUSH_WORKERS",
+	}
+
+	flushMemoryFlag := &cli.IntFlag{
+		Name:  "flushMemory",
+		Usage: "Estimated size in bytes of the batch of the occurrences inserted to the database at once, env FLUSH_MEMORY",
+	}
+
+	occurrenceConflictFlag := &cli.StringFlag{
+		Name:  "occurrenceConflict",
+		Usage: "Handling of the occurrences already stored in the database: ignore skips them, fail reports them as the insert error, default ignore, env OCCURRENCE_CONFLICT",
+	}
+
+	stemmerFlag := &cli.StringFlag{
+		Name:  "stemmer",
+		Usage: "Stemmer: porter or light. Use the same stemmer to build and to search, env STEMMER",
+		Value: defaults.Stemmer,
+	}
+
+	stopwordsOnlyFlag := &cli.BoolFlag{
+		Name:  "stopwordsOnly",
+		Usage: "Ignore only the stopwords set with --stopwords instead of the built-in English ones, env STOPWORDS_ONLY",
+	}
+
+	splitIdentifiersFlag := &cli.BoolFlag{
+		Name:  "splitIdentifiers",
+		Usage: "Index whole words joined by underscores, dots and slashes in addition to their parts. Use the same setting to build and to search, env SPLIT_IDENTIFIERS",
+	}
+
+	apostrophesFlag := &cli.StringFlag{
+		Name:  "apostrophes",
+		Usage: "Handling of apostrophes inside words: split (default) or strip, e.g. don't into dont. Use the same setting to build and to search, env APOSTROPHES",
+	}
+
+	minTokenLengthFlag := &cli.IntFlag{
+		Name:  "minTokenLength",
+		Usage: "Minimal number of letters of the indexed and searched words, the shorter words are dropped, default 2, 0 keeps all words. Use the same setting to build and to search, env MIN_TOKEN_LENGTH",
+	}
+
+	exactBoostFlag := &cli.Float64Flag{
+		Name:  "exactBoost",
+		Usage: "Multiplier of the score of the documents containing the original form of the query term, 0 disables it. Use the same setting to build and to search, env EXACT_BOOST",
+	}
+
+	languageFlag := &cli.StringFlag{
+		Name:  "language",
+		Usage: "Language code of the indexed documents, e.g. de, searched with lang parameter, env LANGUAGE",
+	}
+
+	stopwordsFlag := &cli.StringFlag{
+		Name:  "stopwords",
+		Usage: "Additional stopwords file or language code, e.g. de. Use the same stopwords to build and to search, env STOPWORDS",
+	}
+
+	countsFlag := &cli.BoolFlag{
+		Name:  "counts",
+		Usage: "Fetch only the number of occurrences from the database, positions are not returned, env COUNTS",
+	}
+
+	promptFlag := &cli.StringFlag{
+		Name:  "prompt",
+		Usage: "Prompt of the interactive CLI written if the input is the terminal, default \"> \", env PROMPT",
+	}
+
+	strictFlag := &cli.BoolFlag{
+		Name:  "strict",
+		Usage: "Stop the interactive CLI on the first failed search instead of printing the error, env STRICT",
+	}
+
 	listenFlag := &cli.StringFlag{
 		Name:    "listen",
 		Aliases: []string{"l"},
-		Usage:   "Interface to listen",
-		EnvVars: []string{"LISTEN"},
+		Usage:   "Interface to listen, env LISTEN",
+	}
+
+	timeoutFlag := &cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Web server read and write timeout, env TIMEOUT",
+		Value: defaults.Timeout,
+	}
+
+	rankerFlag := &cli.StringFlag{
+		Name:  "ranker",
+		Usage: "Range algorithm: count or bm25, env RANKER",
+		Value: defaults.Ranker,
+	}
+
+	queryCacheFlag := &cli.IntFlag{
+		Name:  "queryCache",
+		Usage: "Number of recent queries with cached results, 0 disables the cache, env QUERY_CACHE",
+	}
+
+	queryCacheFileFlag := &cli.StringFlag{
+		Name:  "queryCacheFile",
+		Usage: "File the query cache is saved to on shutdown and loaded from on start, env QUERY_CACHE_FILE",
+	}
+
+	warmupFlag := &cli.StringFlag{
+		Name:  "warmup",
+		Usage: "File with popular queries one per line run on start to populate the query cache, env WARMUP",
+	}
+
+	staticFlag := &cli.StringFlag{
+		Name:  "static",
+		Usage: "Directory with static files of the web UI overriding the embedded ones, e.g. style.css, env STATIC",
+	}
+
+	gzipFlag := &cli.BoolFlag{
+		Name:  "gzip",
+		Usage: "Compress the web server responses with gzip, env GZIP",
+	}
+
+	gzipMinSizeFlag := &cli.IntFlag{
+		Name:  "gzipMinSize",
+		Usage: "Minimal size of the compressed response in bytes, default 1024, env GZIP_MIN_SIZE",
+	}
+
+	adminTokenFlag := &cli.StringFlag{
+		Name:  "adminToken",
+		Usage: "Bearer token of the admin API, empty disables it, env ADMIN_TOKEN",
+	}
+
+	protectContentFlag := &cli.BoolFlag{
+		Name:  "protectContent",
+		Usage: "Authorize the requests of the raw texts of the documents with the admin token, env PROTECT_CONTENT",
+	}
+
+	operatorFlag := &cli.StringFlag{
+		Name:  "operator",
+		Usage: "Operator between the query terms: AND finds documents with all terms, OR with any term, default AND, env OPERATOR",
+	}
+
+	halfLifeFlag := &cli.DurationFlag{
+		Name:  "halfLife",
+		Usage: "Age of the document halving its score, e.g. 168h, 0 means no decay, env HALF_LIFE",
+	}
+
+	earlyBoostFlag := &cli.Float64Flag{
+		Name:  "earlyBoost",
+		Usage: "Multiplier of the score of the documents starting with the query terms, fading with the position, 1 disables it, env EARLY_BOOST",
+	}
+
+	normalizeLengthFlag := &cli.BoolFlag{
+		Name:  "normalizeLength",
+		Usage: "Divide the scores by the length of the document relative to the average length, env NORMALIZE_LENGTH",
+	}
+
+	phraseBoostFlag := &cli.Float64Flag{
+		Name:  "phraseBoost",
+		Usage: "Multiplier of the score of the documents containing the exact quoted phrase, default 2, env PHRASE_BOOST",
+	}
+
+	strictPhrasesFlag := &cli.BoolFlag{
+		Name:  "strictPhrases",
+		Usage: "Find only the documents containing the quoted phrases of the query, env STRICT_PHRASES",
+	}
+
+	queryTimeoutFlag := &cli.DurationFlag{
+		Name:  "queryTimeout",
+		Usage: "Timeout of the database queries of the search, 0 means no timeout, env QUERY_TIMEOUT",
+	}
+
+	maxTokenCountFlag := &cli.IntFlag{
+		Name:  "maxTokenCount",
+		Usage: "Maximal number of occurrences of every token counted by the ranker, 0 means no cap, env MAX_TOKEN_COUNT",
+	}
+
+	maxCandidatesFlag := &cli.IntFlag{
+		Name:  "maxCandidates",
+		Usage: "Maximal number of documents matching the query before ranking, broader queries fail, 0 means no limit, env MAX_CANDIDATES",
+	}
+
+	limitFlag := &cli.IntFlag{
+		Name:  "limit",
+		Usage: "Maximal number of search results, 0 means no limit, env LIMIT",
 	}
 
 	app.Commands = []*cli.Command{
@@ -76,19 +387,73 @@ func main() {
 				{
 					Name: "file",
 					Flags: []cli.Flag{
+						configFlag,
 						logLevelFlag,
+						logFormatFlag,
 						indexFileFlag,
 						sourceFlag,
 						jsonFlag,
+						spillFlag,
+						shardsFlag,
+						checksumFlag,
+						quietFlag,
+						progressFlag,
+						dedupFlag,
+						incrementalFlag,
+						nameCollisionFlag,
+						readErrorsFlag,
+						recordsFlag,
+						recordNameFlag,
+						recordContentFlag,
+						recordMetadataFlag,
+						retainContentFlag,
+						snippetsFlag,
+						maxFilesFlag,
+						workersFlag,
+						maxWordSizeFlag,
+						stemmerFlag,
+						stopwordsFlag,
+						stopwordsOnlyFlag,
+						splitIdentifiersFlag,
+						apostrophesFlag,
+						minTokenLengthFlag,
+						exactBoostFlag,
+						languageFlag,
 					},
 					Action: buildFile,
 				},
 				{
 					Name: "db",
 					Flags: []cli.Flag{
+						configFlag,
 						logLevelFlag,
+						logFormatFlag,
 						sourceFlag,
 						pgFlag,
+						tenantFlag,
+						flushWorkersFlag,
+						flushMemoryFlag,
+						occurrenceConflictFlag,
+						quietFlag,
+						progressFlag,
+						dedupFlag,
+						incrementalFlag,
+						nameCollisionFlag,
+						readErrorsFlag,
+						recordsFlag,
+						recordNameFlag,
+						recordContentFlag,
+						maxFilesFlag,
+						workersFlag,
+						maxWordSizeFlag,
+						stemmerFlag,
+						stopwordsFlag,
+						stopwordsOnlyFlag,
+						splitIdentifiersFlag,
+						apostrophesFlag,
+						minTokenLengthFlag,
+						exactBoostFlag,
+						languageFlag,
 					},
 					Action: buildDb,
 				},
@@ -101,24 +466,184 @@ func main() {
 				{
 					Name: "file",
 					Flags: []cli.Flag{
+						configFlag,
 						logLevelFlag,
+						logFormatFlag,
 						indexFileFlag,
+						federateFlag,
+						duplicatesFlag,
 						jsonFlag,
+						streamFlag,
+						shardWorkersFlag,
 						listenFlag,
+						promptFlag,
+						strictFlag,
+						staticFlag,
+						gzipFlag,
+						gzipMinSizeFlag,
+						stemmerFlag,
+						stopwordsFlag,
+						stopwordsOnlyFlag,
+						splitIdentifiersFlag,
+						apostrophesFlag,
+						minTokenLengthFlag,
+						exactBoostFlag,
+						timeoutFlag,
+						rankerFlag,
+						limitFlag,
+						maxCandidatesFlag,
+						operatorFlag,
+						maxTokenCountFlag,
+						halfLifeFlag,
+						earlyBoostFlag,
+						normalizeLengthFlag,
+						phraseBoostFlag,
+						strictPhrasesFlag,
+						snippetsFlag,
+						queryCacheFlag,
+						queryCacheFileFlag,
+						warmupFlag,
+						adminTokenFlag,
+						protectContentFlag,
 					},
 					Action: searchFile,
 				},
 				{
 					Name: "db",
 					Flags: []cli.Flag{
+						configFlag,
 						logLevelFlag,
+						logFormatFlag,
 						pgFlag,
+						tenantFlag,
 						listenFlag,
+						promptFlag,
+						strictFlag,
+						staticFlag,
+						gzipFlag,
+						gzipMinSizeFlag,
+						stemmerFlag,
+						stopwordsFlag,
+						stopwordsOnlyFlag,
+						splitIdentifiersFlag,
+						apostrophesFlag,
+						minTokenLengthFlag,
+						exactBoostFlag,
+						countsFlag,
+						timeoutFlag,
+						queryTimeoutFlag,
+						rankerFlag,
+						limitFlag,
+						maxCandidatesFlag,
+						operatorFlag,
+						maxTokenCountFlag,
+						halfLifeFlag,
+						earlyBoostFlag,
+						normalizeLengthFlag,
+						phraseBoostFlag,
+						strictPhrasesFlag,
+						queryCacheFlag,
+						warmupFlag,
+						adminTokenFlag,
 					},
 					Action: searchDb,
 				},
 			},
 		},
+		{
+			Name:  "dump",
+			Usage: "Dump search index to the file in streamed format",
+			Subcommands: []*cli.Command{
+				{
+					Name: "db",
+					Flags: []cli.Flag{
+						configFlag,
+						logLevelFlag,
+						logFormatFlag,
+						indexFileFlag,
+						jsonFlag,
+						checksumFlag,
+						pgFlag,
+						tenantFlag,
+					},
+					Action: dumpDb,
+				},
+			},
+		},
+		{
+			Name:  "vacuum",
+			Usage: "Remove the tokens not found in any document from the search index, no other process may use it",
+			Subcommands: []*cli.Command{
+				{
+					Name: "db",
+					Flags: []cli.Flag{
+						configFlag,
+						logLevelFlag,
+						logFormatFlag,
+						pgFlag,
+					},
+					Action: vacuumDb,
+				},
+			},
+		},
+		{
+			Name:  "stats",
+			Usage: "Print the number of the documents, of the unique tokens and of the occurrences of the search index",
+			Subcommands: []*cli.Command{
+				{
+					Name: "file",
+					Flags: []cli.Flag{
+						configFlag,
+						logLevelFlag,
+						logFormatFlag,
+						indexFileFlag,
+						federateFlag,
+						duplicatesFlag,
+						jsonFlag,
+						streamFlag,
+						shardWorkersFlag,
+					},
+					Action: statsFile,
+				},
+				{
+					Name: "db",
+					Flags: []cli.Flag{
+						configFlag,
+						logLevelFlag,
+						logFormatFlag,
+						pgFlag,
+						tenantFlag,
+					},
+					Action: statsDb,
+				},
+			},
+		},
+		{
+			Name:  "debug",
+			Usage: "Debug the analysis of the documents",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "tokenize",
+					Usage:     "Print the tokens of the file or of stdin with the positions of their words without indexing them",
+					ArgsUsage: "[file]",
+					Flags: []cli.Flag{
+						configFlag,
+						logLevelFlag,
+						logFormatFlag,
+						stemmerFlag,
+						stopwordsFlag,
+						stopwordsOnlyFlag,
+						splitIdentifiersFlag,
+						apostrophesFlag,
+						minTokenLengthFlag,
+						exactBoostFlag,
+						maxWordSizeFlag,
+						languageFlag,
+					},
+					Action: tokenizeFile,
+				},
+			},
+		},
 	}
 
 	err := app.Run(os.Args)
@@ -127,156 +652,975 @@ func main() {
 	}
 }
 
-func initLogger(c *cli.Context) error {
-	logLevel, err := zerolog.ParseLevel(c.String("logLevel"))
+// tokenizeFile prints the tokens of the file passed as the argument or of stdin if the argument is empty or `-`.
+func tokenizeFile(c *cli.Context) error {
+	cfg, err := setup(c)
 	if err != nil {
-		stdLog.Print(err)
 		return err
 	}
-	zerolog.SetGlobalLevel(logLevel)
-	return nil
+	input := io.Reader(os.Stdin)
+	if name := c.Args().First(); name != "" && name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("can not open %s: %w", name, err)
+		}
+		defer file.Close()
+		input = file
+	}
+	return tokenize(cfg, input, os.Stdout)
 }
 
-func buildFile(c *cli.Context) error {
-	if err := initLogger(c); err != nil {
-		return err
-	}
-	engine := index.NewMemoryIndex()
-	if err := build(c, engine); err != nil {
+// tokenize writes the tokens of the input analyzed as the built documents one per line with the position of the word
+// separated by the tab, e.g. `0\tappl`.
+func tokenize(cfg *config.Config, input io.Reader, output io.Writer) error {
+	options, err := indexOptions(cfg)
+	if err != nil {
 		return err
 	}
-	defer engine.Close()
-	indexFile := c.String("index")
-	output, err := os.Create(indexFile)
+	i := index.NewIndex(index.NewMemoryIndex(), nil, options...)
+	defer i.Close()
+	tokens, err := i.Tokenize(cfg.Language, input)
 	if err != nil {
-		return fmt.Errorf("can not create output file %s: %w", indexFile, err)
-	}
-	defer output.Close()
-
-	var encoder index.Encoder
-	if c.Bool("json") {
-		encoder = json.NewEncoder(output)
-	} else {
-		encoder = gob.NewEncoder(output)
+		return err
 	}
-
-	if err := engine.Encode(encoder); err != nil {
-		return fmt.Errorf("can not write index: %w", err)
+	writer := bufio.NewWriter(output)
+	for _, token := range tokens {
+		fmt.Fprintf(writer, "%d\t%s\n", token.Position, token.Token)
 	}
-	return nil
+	return writer.Flush()
 }
 
-func buildDb(c *cli.Context) error {
-	if err := initLogger(c); err != nil {
-		return err
-	}
-	engine, err := getDbEngine(c)
+// setup loads the configuration and initializes the logger.
+func setup(c *cli.Context) (*config.Config, error) {
+	cfg, err := config.Load(c.String("config"), c)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer engine.Close()
-	return build(c, engine)
+	if err := initLogger(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
-func build(c *cli.Context, engine index.IndexEngine) error {
-	sourcesDir := c.String("sources")
-	files, err := ioutil.ReadDir(sourcesDir)
+func initLogger(cfg *config.Config) error {
+	logLevel, err := zerolog.ParseLevel(cfg.LogLevel)
 	if err != nil {
+		stdLog.Print(err)
 		return err
 	}
+	zerolog.SetGlobalLevel(logLevel)
 
-	i := index.NewIndex(engine, nil)
-
-	wg := &sync.WaitGroup{}
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		wg.Add(1)
-		go func(fileName string) {
-			defer wg.Done()
-			if err := readFile(fileName, i); err != nil {
-				log.Error().Err(err).Msgf("cannot read file %s", fileName)
-			}
-		}(filepath.Join(sourcesDir, file.Name()))
+	switch cfg.LogFormat {
+	case "json":
+	case "console":
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	default:
+		return fmt.Errorf("unknown log format %s", cfg.LogFormat)
 	}
-	wg.Wait()
 	return nil
 }
 
-func readFile(name string, i *index.Index) error {
-	input, err := os.Open(name)
+func buildFile(c *cli.Context) error {
+	cfg, err := setup(c)
 	if err != nil {
 		return err
 	}
-	defer input.Close()
-
-	return i.AddSource(name, input)
-}
-
-func searchFile(c *cli.Context) error {
-	var err error
-	if err = initLogger(c); err != nil {
-		return err
+	start := time.Now()
+	shards := c.Int("shards")
+	if spill := c.Int("spill"); spill > 0 {
+		if shards > 0 {
+			return errors.New("sharded index can not be built with spill")
+		}
+		if cfg.Incremental {
+			return errors.New("incremental index can not be built with spill")
+		}
+		return buildSpill(c, cfg, spill, start)
 	}
-	indexFile := c.String("index")
-	file, err := os.Open(indexFile)
+	engine, err := buildEngine(c, cfg, shards)
 	if err != nil {
-		return fmt.Errorf("can not open index file %s: %w", indexFile, err)
+		return err
 	}
-
-	var decoder index.Decoder
-	if c.Bool("json") {
-		decoder = json.NewDecoder(file)
+	if err := build(c, cfg, engine); err != nil {
+		return err
+	}
+	defer engine.Close()
+	if shards > 0 {
+		err = writeShards(c, engine, shards)
 	} else {
-		decoder = gob.NewDecoder(file)
+		err = writeIndex(c, engine.Encode)
 	}
-	engine, err := index.Decode(decoder)
 	if err != nil {
 		return err
 	}
-	defer engine.Close()
+	return printSummary(c, engine, start)
+}
 
-	return search(c, engine)
+// buildEngine returns the empty engine to build the index file or the existing index file to update by the
+// incremental build. The incremental build starts from scratch if the index file does not exist yet.
+func buildEngine(c *cli.Context, cfg *config.Config, shards int) (*index.MemoryIndex, error) {
+	if !cfg.Incremental {
+		return index.NewMemoryIndex(), nil
+	}
+	if shards > 0 {
+		return nil, errors.New("sharded index can not be built incrementally")
+	}
+	if _, err := os.Stat(c.String("index")); os.IsNotExist(err) {
+		return index.NewMemoryIndex(), nil
+	}
+	return decodeIndex(c)
 }
 
-func searchDb(c *cli.Context) error {
-	if err := initLogger(c); err != nil {
+func buildSpill(c *cli.Context, cfg *config.Config, spill int, start time.Time) error {
+	engine, err := index.NewSpillIndex("", spill)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+	if err := build(c, cfg, engine); err != nil {
 		return err
 	}
-	engine, err := getDbEngine(c)
+	err = writeIndex(c, func(encoder index.Encoder) error {
+		return index.EncodeStream(engine, encoder)
+	})
 	if err != nil {
 		return err
 	}
-	defer engine.Close()
-
-	return search(c, engine)
+	return printSummary(c, engine, start)
 }
 
-func search(c *cli.Context, engine index.IndexEngine) error {
-	index := index.NewIndex(engine, nil)
+// writeIndex writes the index file set by the flags with the encode function. The stale manifest of the sharded index
+// built to the same file is removed.
+func writeIndex(c *cli.Context, encode func(encoder index.Encoder) error) error {
+	indexFile := c.String("index")
+	if err := os.Remove(manifestFile(indexFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can not remove stale manifest file: %w", err)
+	}
+	return writeIndexFile(c, indexFile, encode)
+}
 
-	if c.String("listen") == "" {
-		iface, err := ifaceCli.New(os.Stdin, os.Stdout, index)
-		if err != nil {
+// writeShards splits the index into the shard files named by the index file with the number suffix, e.g.
+// `index.data.0`, and writes the manifest listing them. The shards are written in the format set by the flags.
+func writeShards(c *cli.Context, engine *index.MemoryIndex, shards int) error {
+	indexFile := c.String("index")
+	var manifest index.Manifest
+	for k, shard := range engine.Split(shards) {
+		shardFile := fmt.Sprintf("%s.%d", indexFile, k)
+		if err := writeIndexFile(c, shardFile, shard.Encode); err != nil {
 			return err
 		}
-		return iface.Run()
+		manifest.Shards = append(manifest.Shards, filepath.Base(shardFile))
 	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("can not encode manifest: %w", err)
+	}
+	if err := ioutil.WriteFile(manifestFile(indexFile), data, 0644); err != nil {
+		return fmt.Errorf("can not write manifest file: %w", err)
+	}
+	return nil
+}
+
+// manifestFile returns the name of the file listing the shards of the index file.
+func manifestFile(indexFile string) string {
+	return indexFile + ".manifest"
+}
+
+// writeIndexFile creates the index file and writes the index with the encode function. With the checksum flag the
+// SHA-256 checksum of the written file is stored next to it, otherwise the stale checksum file is removed.
+func writeIndexFile(c *cli.Context, indexFile string, encode func(encoder index.Encoder) error) error {
+	output, err := os.Create(indexFile)
+	if err != nil {
+		return fmt.Errorf("can not create output file %s: %w", indexFile, err)
+	}
+	defer output.Close()
+
+	hash := sha256.New()
+	if err := encode(encoder(c, io.MultiWriter(output, hash))); err != nil {
+		return fmt.Errorf("can not write index: %w", err)
+	}
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("can not write index: %w", err)
+	}
+
+	if !c.Bool("checksum") {
+		if err := os.Remove(checksumFile(indexFile)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("can not remove stale checksum file: %w", err)
+		}
+		return nil
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil)) + "\n"
+	if err := ioutil.WriteFile(checksumFile(indexFile), []byte(checksum), 0644); err != nil {
+		return fmt.Errorf("can not write checksum file: %w", err)
+	}
+	return nil
+}
+
+// checksumFile returns the name of the file with the checksum of the index file.
+func checksumFile(indexFile string) string {
+	return indexFile + ".sha256"
+}
+
+// errChecksumMismatch is returned when the index file does not match the checksum written on build.
+var errChecksumMismatch = errors.New("index checksum mismatch, the file is corrupted, rebuild the index")
+
+// verifyChecksum compares the index file with the checksum written on build. The files without checksum are not
+// verified.
+func verifyChecksum(indexFile string) error {
+	expected, err := ioutil.ReadFile(checksumFile(indexFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can not read checksum file: %w", err)
+	}
+
+	file, err := os.Open(indexFile)
+	if err != nil {
+		return fmt.Errorf("can not open index file %s: %w", indexFile, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("can not read index file %s: %w", indexFile, err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != strings.TrimSpace(string(expected)) {
+		return fmt.Errorf("%w: %s", errChecksumMismatch, indexFile)
+	}
+	return nil
+}
+
+func encoder(c *cli.Context, output io.Writer) index.Encoder {
+	if c.Bool("json") {
+		return json.NewEncoder(output)
+	}
+	return gob.NewEncoder(output)
+}
+
+func dumpDb(c *cli.Context) error {
+	cfg, err := setup(c)
+	if err != nil {
+		return err
+	}
+	engine, err := getDbEngine(cfg)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	var iterator index.Iterator = engine
+	if cfg.Tenant != "" {
+		iterator = engine.Tenant(cfg.Tenant).(index.Iterator)
+	}
+
+	return writeIndex(c, func(encoder index.Encoder) error {
+		return index.EncodeStream(iterator, encoder)
+	})
+}
+
+func vacuumDb(c *cli.Context) error {
+	cfg, err := setup(c)
+	if err != nil {
+		return err
+	}
+	engine, err := getDbEngine(cfg)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	removed, err := engine.CompactTokens()
+	if err != nil {
+		return err
+	}
+	log.Info().Int("tokens", removed).Msg("removed orphaned tokens")
+	return nil
+}
+
+func buildDb(c *cli.Context) error {
+	cfg, err := setup(c)
+	if err != nil {
+		return err
+	}
+	engine, err := getDbEngine(cfg)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+	start := time.Now()
+	var target index.IndexEngine = engine
+	if cfg.Tenant != "" {
+		target = engine.Tenant(cfg.Tenant)
+	}
+	if err := build(c, cfg, target); err != nil {
+		return err
+	}
+	if err := engine.Flush(); err != nil {
+		return err
+	}
+	return printSummary(c, target.(index.StatsEngine), start)
+}
+
+// printSummary prints the size of the built index and the build time unless --quiet flag is set.
+func printSummary(c *cli.Context, engine index.StatsEngine, start time.Time) error {
+	if c.Bool("quiet") {
+		return nil
+	}
+	stats, err := engine.Stats()
+	if err != nil {
+		return fmt.Errorf("can not get index stats: %w", err)
+	}
+	fmt.Fprintln(os.Stdout, summary(stats, time.Since(start)))
+	return nil
+}
+
+// summary formats the size of the built index and the build time.
+func summary(stats index.Stats, elapsed time.Duration) string {
+	return fmt.Sprintf("indexed %s in %s", formatStats(stats), elapsed.Round(time.Millisecond))
+}
+
+// formatStats formats the size of the index.
+func formatStats(stats index.Stats) string {
+	return fmt.Sprintf("%d documents, %d unique tokens, %d occurrences", stats.Documents, stats.Tokens, stats.Occurrences)
+}
+
+func statsFile(c *cli.Context) error {
+	if _, err := setup(c); err != nil {
+		return err
+	}
+	engine, err := decodeIndexes(c)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+	return printStats(os.Stdout, engine)
+}
+
+func statsDb(c *cli.Context) error {
+	cfg, err := setup(c)
+	if err != nil {
+		return err
+	}
+	engine, err := getDbEngine(cfg)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+	if cfg.Tenant != "" {
+		return printStats(os.Stdout, engine.Tenant(cfg.Tenant))
+	}
+	return printStats(os.Stdout, engine)
+}
+
+// printStats writes the size of the index.
+func printStats(output io.Writer, engine index.IndexEngine) error {
+	i := index.NewIndex(engine, nil)
+	defer i.Close()
+	stats, err := i.Stats()
+	if err != nil {
+		return fmt.Errorf("can not get index stats: %w", err)
+	}
+	_, err = fmt.Fprintln(output, formatStats(stats))
+	return err
+}
+
+// Read error modes of the build set with the readErrors flag.
+const (
+	// readErrorsSkip logs the files which can not be read and indexes the others.
+	readErrorsSkip = "skip"
+	// readErrorsFail stops the build on the first file which can not be read.
+	readErrorsFail = "fail"
+	// readErrorsStrict indexes all readable files and fails the build if some files can not be read.
+	readErrorsStrict = "strict"
+)
+
+// recordFields returns the comma-separated fields of the records, the empty ones are skipped.
+func recordFields(value string) []string {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// errFilesNotRead is returned by the strict build if some files can not be read.
+var errFilesNotRead = errors.New("some files can not be read")
+
+func build(c *cli.Context, cfg *config.Config, engine index.IndexEngine) error {
+	switch cfg.ReadErrors {
+	case readErrorsSkip, readErrorsFail, readErrorsStrict:
+	default:
+		return fmt.Errorf("unknown read errors mode %s", cfg.ReadErrors)
+	}
+	sourcesDir := c.String("sources")
+	files, err := ioutil.ReadDir(sourcesDir)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := index.LanguageStemmers[cfg.Language]; cfg.Language != "" && !ok {
+		return fmt.Errorf("unknown language %s", cfg.Language)
+	}
+	options, err := indexOptions(cfg)
+	if err != nil {
+		return err
+	}
+	var records *index.RecordOptions
+	if cfg.Records != "" {
+		format, err := index.ParseRecordFormat(cfg.Records)
+		if err != nil {
+			return err
+		}
+		records = &index.RecordOptions{
+			Format:         format,
+			NameField:      cfg.RecordName,
+			ContentField:   cfg.RecordContent,
+			MetadataFields: recordFields(cfg.RecordMetadata),
+		}
+	}
+	if cfg.Incremental {
+		options = append(options, index.WithIncrementalUpdates())
+	}
+	i := index.NewIndex(engine, nil, options...)
+
+	// The files are listed sorted by name, so the limited build indexes the same files every time.
+	var names []string
+	for _, file := range files {
+		if cfg.MaxFiles > 0 && len(names) >= cfg.MaxFiles {
+			break
+		}
+		if !file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+	total := len(names)
+	stop := make(chan struct{})
+	if cfg.Progress > 0 && !c.Bool("quiet") {
+		go reportProgress(i, total, cfg.Progress, stop)
+	}
+
+	var (
+		failed   int64
+		stopped  int32
+		firstErr error
+		errOnce  sync.Once
+	)
+	runWorkers(names, cfg.Workers, func(name string) {
+		if atomic.LoadInt32(&stopped) == 1 {
+			return
+		}
+		fileName := filepath.Join(sourcesDir, name)
+		if err := readFile(fileName, cfg.Language, records, i); err != nil {
+			atomic.AddInt64(&failed, 1)
+			log.Error().Err(err).Msgf("cannot read file %s", fileName)
+			if cfg.ReadErrors == readErrorsFail {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("can not read file %s: %w", fileName, err)
+					atomic.StoreInt32(&stopped, 1)
+				})
+			}
+		}
+	})
+	i.Close()
+	close(stop)
+
+	if failed > 0 {
+		log.Warn().Int64("failed", failed).Int("total", total).Msg("some files are not indexed")
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	if failed > 0 && cfg.ReadErrors == readErrorsStrict {
+		return fmt.Errorf("%w: %d of %d files", errFilesNotRead, failed, total)
+	}
+	return nil
+}
+
+// reportProgress logs the build progress every interval until stop is closed.
+func reportProgress(i *index.Index, total int, interval time.Duration, stop <-chan struct{}) {
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			progress := i.Progress()
+			event := log.Info().
+				Int64("documents", progress.Documents).
+				Int64("skipped", progress.Skipped).
+				Int64("failed", progress.Failed).
+				Int("total", total).
+				Int64("tokens", progress.Tokens)
+			if progress.Stored >= 0 {
+				event = event.Int64("stored", progress.Stored)
+			}
+			event.Msg(progressMessage(progress, total, time.Since(start)))
+		}
+	}
+}
+
+// progressMessage formats the build progress and the indexing rate. The processed files are the indexed, the skipped
+// and the failed ones.
+func progressMessage(progress index.Progress, total int, elapsed time.Duration) string {
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(progress.Tokens) / elapsed.Seconds()
+	}
+	processed := progress.Documents + progress.Skipped + progress.Failed
+	message := fmt.Sprintf("processed %d/%d files (%d indexed, %d skipped, %d failed), %d tokens, %.0f tokens/s",
+		processed, total, progress.Documents, progress.Skipped, progress.Failed, progress.Tokens, rate)
+	if progress.Stored >= 0 {
+		message += fmt.Sprintf(", %d occurrences stored", progress.Stored)
+	}
+	return message
+}
+
+// runWorkers calls fn for every name in at most workers goroutines at once and returns when all names are processed,
+// so the build does not open more files than the workers. Non-positive workers means the number of CPUs.
+func runWorkers(names []string, workers int, fn func(name string)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(names) {
+		workers = len(names)
+	}
+	queue := make(chan string)
+	wg := &sync.WaitGroup{}
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				fn(name)
+			}
+		}()
+	}
+	for _, name := range names {
+		queue <- name
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// readFile adds the file to the index as one document or its records as separate documents if records options are not
+// nil. The language is the code of the language of the file or empty for the default analyzer.
+func readFile(name string, language string, records *index.RecordOptions, i *index.Index) error {
+	input, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	info, err := input.Stat()
+	if err != nil {
+		return err
+	}
+	source := index.Source{Name: name, ModTime: info.ModTime(), Language: language}
+	if records != nil {
+		_, err := i.AddRecords(source, input, *records)
+		return err
+	}
+	return i.AddDocument(source, input)
+}
+
+func searchFile(c *cli.Context) error {
+	cfg, err := setup(c)
+	if err != nil {
+		return err
+	}
+	engine, err := decodeIndexes(c)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	return search(cfg, engine, func() (index.IndexEngine, error) {
+		return decodeIndexes(c)
+	})
+}
+
+// decodeIndexes reads the index file and the federated index files set by the flags.
+func decodeIndexes(c *cli.Context) (index.IndexEngine, error) {
+	federated := c.StringSlice("federate")
+	if len(federated) == 0 {
+		return decodeShards(c, c.String("index"))
+	}
+	duplicates, err := index.ParseDuplicatePolicy(c.String("duplicates"))
+	if err != nil {
+		return nil, err
+	}
+	engine, err := decodeShards(c, c.String("index"))
+	if err != nil {
+		return nil, err
+	}
+	engines := []index.IndexEngine{engine}
+	for _, indexFile := range federated {
+		engine, err := decodeShards(c, indexFile)
+		if err != nil {
+			return nil, err
+		}
+		engines = append(engines, engine)
+	}
+	return index.NewMultiEngine(duplicates, engines...), nil
+}
+
+// decodeShards reads the shards listed in the manifest of the index file in parallel. The index file is read as is if
+// it has no manifest.
+func decodeShards(c *cli.Context, indexFile string) (index.IndexEngine, error) {
+	data, err := ioutil.ReadFile(manifestFile(indexFile))
+	if os.IsNotExist(err) {
+		engine, err := decodeIndexFile(c, indexFile)
+		if err != nil {
+			return nil, err
+		}
+		return engine, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not read manifest file: %w", err)
+	}
+	var manifest index.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("can not decode manifest file %s: %w", manifestFile(indexFile), err)
+	}
+	shards := make([]string, 0, len(manifest.Shards))
+	for _, shard := range manifest.Shards {
+		shards = append(shards, filepath.Join(filepath.Dir(indexFile), shard))
+	}
+	return index.DecodeShards(shards, c.Int("shardWorkers"), func(shard string) (index.IndexEngine, error) {
+		engine, err := decodeIndexFile(c, shard)
+		if err != nil {
+			return nil, err
+		}
+		return engine, nil
+	})
+}
+
+// decodeIndex reads the index file set by the flags.
+func decodeIndex(c *cli.Context) (*index.MemoryIndex, error) {
+	return decodeIndexFile(c, c.String("index"))
+}
+
+// decodeIndexFile reads the index file in the format set by the flags.
+func decodeIndexFile(c *cli.Context, indexFile string) (*index.MemoryIndex, error) {
+	if err := verifyChecksum(indexFile); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(indexFile)
+	if err != nil {
+		return nil, fmt.Errorf("can not open index file %s: %w", indexFile, err)
+	}
+	defer file.Close()
+
+	var decoder index.Decoder
+	if c.Bool("json") {
+		decoder = json.NewDecoder(file)
+	} else {
+		decoder = gob.NewDecoder(file)
+	}
+	decode := index.Decode
+	if c.Bool("stream") {
+		decode = index.DecodeStream
+	}
+	engine, err := decode(decoder)
+	if errors.Is(err, index.ErrIndexFormat) {
+		return nil, fmt.Errorf("can not decode index file %s, check the json and stream flags: %w", indexFile, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not decode index file %s: %w", indexFile, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("can not stat index file %s: %w", indexFile, err)
+	}
+	engine.SetGeneration(fmt.Sprintf("%s:%d:%d", indexFile, info.Size(), info.ModTime().UnixNano()))
+	return engine, nil
+}
 
-	iface, err := ws.New(c.String("listen"), 10*time.Second, index)
+func searchDb(c *cli.Context) error {
+	cfg, err := setup(c)
+	if err != nil {
+		return err
+	}
+	if cfg.QueryCacheFile != "" {
+		return errors.New("query cache file is not supported by the database index")
+	}
+	engine, err := getDbEngine(cfg)
 	if err != nil {
 		return err
 	}
-	return iface.Run()
+	defer engine.Close()
+
+	if cfg.Tenant != "" {
+		return search(cfg, engine.Tenant(cfg.Tenant), nil)
+	}
+	return search(cfg, engine, nil)
 }
 
-func getDbEngine(c *cli.Context) (index.IndexEngine, error) {
-	pgOpt, err := pg.ParseURL(c.String("postgresql"))
+// search runs the interactive CLI or the web server. The load function reads the new engine on reload, nil disables
+// the reload.
+func search(cfg *config.Config, engine index.IndexEngine, load func() (index.IndexEngine, error)) error {
+	options, err := indexOptions(cfg)
+	if err != nil {
+		return err
+	}
+	index := index.NewIndex(engine, nil, options...)
+	defer index.Close()
+
+	if cfg.Listen == "" {
+		cliOptions := []ifaceCli.Option{ifaceCli.WithPrompt(cfg.Prompt)}
+		if cfg.Strict {
+			cliOptions = append(cliOptions, ifaceCli.WithStrict())
+		}
+		iface, err := ifaceCli.New(os.Stdin, os.Stdout, index, cliOptions...)
+		if err != nil {
+			return err
+		}
+		return iface.Run()
+	}
+
+	iface, err := ws.New(cfg.Listen, cfg.Timeout, index)
+	if err != nil {
+		return err
+	}
+	if cfg.QueryCacheFile != "" {
+		if cfg.QueryCache == 0 {
+			return errors.New("query cache file requires the query cache, set --queryCache")
+		}
+		if err := loadQueryCache(index, cfg.QueryCacheFile); err != nil {
+			return err
+		}
+		defer saveQueryCache(index, cfg.QueryCacheFile)
+	}
+	if cfg.Warmup != "" {
+		if cfg.QueryCache == 0 {
+			return errors.New("warmup requires the query cache, set --queryCache")
+		}
+		queries, err := os.Open(cfg.Warmup)
+		if err != nil {
+			return fmt.Errorf("can not open warmup file %s: %w", cfg.Warmup, err)
+		}
+		iface.Warmup(queries)
+	}
+	if cfg.Static != "" {
+		iface.OverrideStatic(cfg.Static)
+	}
+	if cfg.Gzip {
+		iface.EnableCompression(cfg.GzipMinSize)
+	}
+	iface.EnableAdmin(cfg.AdminToken)
+	if cfg.ProtectContent {
+		iface.ProtectContent()
+	}
+	if load != nil {
+		iface.EnableReload(cfg.AdminToken, load)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	return serve(iface, signals, cfg.Timeout)
+}
+
+// loadQueryCache populates the query cache from the file saved on the previous shutdown. The missing file is skipped.
+func loadQueryCache(i *index.Index, cacheFile string) error {
+	file, err := os.Open(cacheFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can not open query cache file %s: %w", cacheFile, err)
+	}
+	defer file.Close()
+
+	loaded, err := i.LoadQueryCache(file)
+	if err != nil {
+		log.Warn().Err(err).Str("file", cacheFile).Msg("skip broken query cache file")
+		return nil
+	}
+	log.Info().Int("queries", loaded).Str("file", cacheFile).Msg("query cache loaded")
+	return nil
+}
+
+// saveQueryCache writes the query cache to the file to load it on the next start.
+func saveQueryCache(i *index.Index, cacheFile string) {
+	file, err := os.Create(cacheFile)
+	if err != nil {
+		log.Error().Err(err).Str("file", cacheFile).Msg("can not create query cache file")
+		return
+	}
+	defer file.Close()
+
+	if err := i.SaveQueryCache(file); err != nil {
+		log.Error().Err(err).Str("file", cacheFile).Msg("can not save query cache")
+		return
+	}
+	log.Info().Str("file", cacheFile).Msg("query cache saved")
+}
+
+// serve runs the web server until the signal is received, then stops accepting requests, waits for the in-flight
+// searches and closes the index. The engine is closed by the caller after serve returns.
+func serve(iface *ws.Ws, signals <-chan os.Signal, timeout time.Duration) error {
+	errC := make(chan error, 1)
+	go func() {
+		errC <- iface.Run()
+	}()
+
+	select {
+	case err := <-errC:
+		return err
+	case sig := <-signals:
+		log.Info().Str("signal", sig.String()).Msg("shutting down")
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		if err := iface.Shutdown(ctx); err != nil {
+			return err
+		}
+		return <-errC
+	}
+}
+
+func indexOptions(cfg *config.Config) ([]index.Option, error) {
+	stemmer, ok := index.Stemmers[cfg.Stemmer]
+	if !ok {
+		return nil, fmt.Errorf("unknown stemmer %s", cfg.Stemmer)
+	}
+	rangeAlgorithm, ok := index.RangeAlgorithms[cfg.Ranker]
+	if !ok {
+		return nil, fmt.Errorf("unknown ranker %s", cfg.Ranker)
+	}
+	operator, err := index.ParseOperator(cfg.Operator)
+	if err != nil {
+		return nil, err
+	}
+	apostrophes, err := index.ParseApostrophes(cfg.Apostrophes)
+	if err != nil {
+		return nil, err
+	}
+	options := []index.Option{
+		index.WithStemmer(stemmer),
+		index.WithRangeAlgorithm(rangeAlgorithm),
+		index.WithMatchedTokens(),
+		index.WithLimit(cfg.Limit),
+		index.WithMaxCandidates(cfg.MaxCandidates),
+		index.WithMaxTokenCount(cfg.MaxTokenCount),
+		index.WithMaxWordSize(cfg.MaxWordSize),
+		index.WithDefaultOperator(operator),
+		index.WithPhraseBoost(cfg.PhraseBoost),
+		index.WithApostrophes(apostrophes),
+		index.WithMinTokenLength(cfg.MinTokenLength),
+		index.WithExactBoost(cfg.ExactBoost),
+	}
+	if top, ok := index.TopRangeAlgorithms[cfg.Ranker]; ok {
+		options = append(options, index.WithTopRangeAlgorithm(top))
+	}
+	if cfg.HalfLife > 0 {
+		options = append(options, index.WithRescorer(func(rangeAlgorithm index.RangeAlgorithm) index.RangeAlgorithm {
+			return index.WithTimeDecay(rangeAlgorithm, cfg.HalfLife)
+		}))
+	}
+	if cfg.EarlyBoost > 1 {
+		options = append(options, index.WithRescorer(func(rangeAlgorithm index.RangeAlgorithm) index.RangeAlgorithm {
+			return index.WithEarlyBoost(rangeAlgorithm, cfg.EarlyBoost)
+		}))
+	}
+	if cfg.NormalizeLength {
+		options = append(options, index.WithRescorer(index.WithLengthNormalization))
+	}
+	if cfg.RetainContent {
+		options = append(options, index.WithRetainedContent())
+	}
+	if cfg.Snippets {
+		options = append(options, index.WithSnippets())
+	}
+	if cfg.StrictPhrases {
+		options = append(options, index.WithStrictPhrases())
+	}
+	if cfg.Counts {
+		options = append(options, index.WithCountsOnly())
+	}
+	if cfg.QueryCache > 0 {
+		options = append(options, index.WithQueryCache(cfg.QueryCache), index.WithQueryCacheSettings(fmt.Sprintf(
+			"%s:%v:%v:%v:%s:%s", cfg.Ranker, cfg.HalfLife, cfg.EarlyBoost, cfg.NormalizeLength, cfg.Stemmer, cfg.Stopwords,
+		)))
+	}
+	if cfg.Dedup {
+		options = append(options, index.WithDeduplication())
+	}
+	if cfg.NameCollision != "" {
+		collision, err := index.ParseNameCollision(cfg.NameCollision)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, index.WithNameCollision(collision))
+	}
+	if cfg.SplitIdentifiers {
+		options = append(options, index.WithIdentifierSplitting())
+	}
+	if cfg.Stopwords != "" {
+		stopwords, err := index.LoadStopwords(cfg.Stopwords)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.StopwordsOnly {
+			options = append(options, index.WithStopwordsOnly(stopwords))
+		} else {
+			options = append(options, index.WithStopwords(stopwords))
+		}
+	}
+	languages, err := languageOptions(cfg.SplitIdentifiers, apostrophes, cfg.MinTokenLength)
+	if err != nil {
+		return nil, err
+	}
+	return append(options, languages...), nil
+}
+
+// languageOptions registers the analyzers of the languages with the known stemmers and the embedded stopwords of the
+// language if there are any.
+func languageOptions(splitIdentifiers bool, apostrophes index.Apostrophes, minTokenLength int) ([]index.Option, error) {
+	var options []index.Option
+	for language, stemmer := range index.LanguageStemmers {
+		analyzer := index.Analyzer{
+			Stemmer:          stemmer,
+			SplitIdentifiers: splitIdentifiers,
+			Apostrophes:      apostrophes,
+			MinTokenLength:   minTokenLength,
+		}
+		stopwords, err := index.LanguageStopwords(language)
+		if err != nil {
+			return nil, err
+		}
+		analyzer.Stopwords = stopwords
+		options = append(options, index.WithLanguage(language, analyzer))
+	}
+	return options, nil
+}
+
+func getDbEngine(cfg *config.Config) (*index.DbIndex, error) {
+	if cfg.Postgresql == "" {
+		return nil, errors.New("postgresql connection string is not set, use --postgresql flag or PGSQL variable")
+	}
+	pgOpt, err := config.PostgresqlOptions(cfg.Postgresql)
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := index.ParseConflictPolicy(cfg.OccurrenceConflict)
 	if err != nil {
 		return nil, err
 	}
 	pgdb := pg.Connect(pgOpt)
 	log.Info().Msg("connected to db")
-	return index.NewDbIndex(pgdb), nil
+	return index.NewDbIndex(
+		pgdb,
+		index.WithFlushWorkers(cfg.FlushWorkers),
+		index.WithFlushMemory(cfg.FlushMemory),
+		index.WithQueryTimeout(cfg.QueryTimeout),
+		index.WithConflictPolicy(conflicts),
+	), nil
 }
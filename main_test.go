@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/polisgo2020/search-tariel-x/config"
+	"github.com/polisgo2020/search-tariel-x/index"
+	"github.com/polisgo2020/search-tariel-x/interface/ws"
+)
+
+func TestSummary(t *testing.T) {
+	engine := index.NewMemoryIndex()
+	i := index.NewIndex(engine, nil)
+	if err := i.AddSource("file1", bytes.NewBufferString("an apple banana raspberry")); err != nil {
+		t.Error(err)
+	}
+	if err := i.AddSource("file2", bytes.NewBufferString("apple apple the banana orange")); err != nil {
+		t.Error(err)
+	}
+	i.Close()
+
+	stats, err := engine.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := summary(stats, 1500*time.Millisecond+300*time.Microsecond)
+	expected := "indexed 2 documents, 4 unique tokens, 7 occurrences in 1.5s"
+	if actual != expected {
+		t.Errorf("%s is not equal to expected %s", actual, expected)
+	}
+}
+
+func TestPrintStats(t *testing.T) {
+	engine := index.NewMemoryIndex()
+	i := index.NewIndex(engine, nil)
+	if err := i.AddSource("file1", bytes.NewBufferString("an apple banana raspberry")); err != nil {
+		t.Error(err)
+	}
+	if err := i.AddSource("file2", bytes.NewBufferString("apple apple the banana orange")); err != nil {
+		t.Error(err)
+	}
+	i.Close()
+
+	var output bytes.Buffer
+	if err := printStats(&output, engine); err != nil {
+		t.Fatal(err)
+	}
+	expected := "2 documents, 4 unique tokens, 7 occurrences\n"
+	if output.String() != expected {
+		t.Errorf("%q is not equal to expected %q", output.String(), expected)
+	}
+}
+
+func TestProgressMessage(t *testing.T) {
+	for _, test := range []struct {
+		progress index.Progress
+		expected string
+	}{
+		{index.Progress{Documents: 3, Tokens: 500, Stored: -1}, "processed 3/10 files (3 indexed, 0 skipped, 0 failed), 500 tokens, 250 tokens/s"},
+		{index.Progress{Documents: 3, Skipped: 2, Failed: 1, Tokens: 500, Stored: 200}, "processed 6/10 files (3 indexed, 2 skipped, 1 failed), 500 tokens, 250 tokens/s, 200 occurrences stored"},
+	} {
+		if actual := progressMessage(test.progress, 10, 2*time.Second); actual != test.expected {
+			t.Errorf("%s is not equal to expected %s", actual, test.expected)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checksum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	indexFile := filepath.Join(dir, "index")
+
+	context := func(checksum bool) *cli.Context {
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		set.String("index", indexFile, "")
+		set.Bool("checksum", checksum, "")
+		return cli.NewContext(cli.NewApp(), set, nil)
+	}
+	engine := index.NewMemoryIndex()
+	if err := engine.Add("appl", 0, index.Source{Name: "file1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeIndex(context(true), engine.Encode); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeIndex(context(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Index, engine.Index) {
+		t.Errorf("%v is not equal to expected %v", decoded.Index, engine.Index)
+	}
+
+	data, err := ioutil.ReadFile(indexFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := ioutil.WriteFile(indexFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeIndex(context(true)); !errors.Is(err, errChecksumMismatch) {
+		t.Errorf("%v is not equal to expected %v", err, errChecksumMismatch)
+	}
+
+	// Build without checksum removes the stale checksum file.
+	if err := writeIndex(context(false), engine.Encode); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(checksumFile(indexFile)); !os.IsNotExist(err) {
+		t.Errorf("%v is not equal to expected %v", err, os.ErrNotExist)
+	}
+	if err := ioutil.WriteFile(indexFile, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeIndex(context(false)); !errors.Is(err, index.ErrIndexTruncated) {
+		t.Errorf("%v is not equal to expected %v", err, index.ErrIndexTruncated)
+	}
+}
+
+func TestShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	indexFile := filepath.Join(dir, "index")
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("index", indexFile, "")
+	set.Int("shardWorkers", 2, "")
+	c := cli.NewContext(cli.NewApp(), set, nil)
+
+	engine := index.NewMemoryIndex()
+	i := index.NewIndex(engine, nil)
+	for name, text := range map[string]string{
+		"file1": "apple banana",
+		"file2": "banana cherry",
+		"file3": "apple apple cherry",
+		"file4": "banana",
+	} {
+		if err := i.AddSource(name, bytes.NewBufferString(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i.Close()
+	if err := writeShards(c, engine, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := decodeIndexes(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoded.Close()
+	expected, err := i.Search("apple cherry banana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := index.NewIndex(decoded, nil).Search("apple cherry banana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	// The index written without shards replaces the sharded one.
+	if err := writeIndex(c, index.NewMemoryIndex().Encode); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(manifestFile(indexFile)); !os.IsNotExist(err) {
+		t.Errorf("%v is not equal to expected %v", err, os.ErrNotExist)
+	}
+}
+
+func TestRunWorkers(t *testing.T) {
+	var names []string
+	for n := 0; n < 20; n++ {
+		names = append(names, fmt.Sprintf("file%d", n))
+	}
+	for _, workers := range []int{1, 3, 50} {
+		var (
+			m         sync.Mutex
+			running   int
+			maxSeen   int
+			processed []string
+		)
+		runWorkers(names, workers, func(name string) {
+			m.Lock()
+			running++
+			if running > maxSeen {
+				maxSeen = running
+			}
+			m.Unlock()
+			// The reader keeps the file open for a while, so the workers overlap.
+			time.Sleep(time.Millisecond)
+			m.Lock()
+			running--
+			processed = append(processed, name)
+			m.Unlock()
+		})
+		if maxSeen > workers {
+			t.Errorf("%d: %d readers run at once", workers, maxSeen)
+		}
+		sort.Strings(processed)
+		expected := append([]string{}, names...)
+		sort.Strings(expected)
+		if !reflect.DeepEqual(processed, expected) {
+			t.Errorf("%d: %v is not equal to expected %v", workers, processed, expected)
+		}
+	}
+}
+
+func TestBuild_ReadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "file1"), []byte("apple banana"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The link to the missing file is listed but can not be opened.
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "file2")); err != nil {
+		t.Fatal(err)
+	}
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("sources", dir, "")
+	set.Bool("quiet", true, "")
+	c := cli.NewContext(cli.NewApp(), set, nil)
+
+	for _, test := range []struct {
+		mode     string
+		expected error
+	}{
+		{readErrorsSkip, nil},
+		{readErrorsFail, os.ErrNotExist},
+		{readErrorsStrict, errFilesNotRead},
+	} {
+		cfg := config.Default()
+		cfg.ReadErrors = test.mode
+		engine := index.NewMemoryIndex()
+		err := build(c, &cfg, engine)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: %v is not equal to expected %v", test.mode, err, test.expected)
+		}
+		if test.mode != readErrorsFail && len(engine.Sources) != 1 {
+			t.Errorf("%s: %d is not equal to expected 1", test.mode, len(engine.Sources))
+		}
+	}
+
+	cfg := config.Default()
+	cfg.ReadErrors = "ignore"
+	if err := build(c, &cfg, index.NewMemoryIndex()); err == nil {
+		t.Error("unknown read errors mode is accepted")
+	}
+}
+
+func TestBuild_MaxFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"file3", "file1", "file2"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("apple"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "dir0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("sources", dir, "")
+	set.Bool("quiet", true, "")
+	c := cli.NewContext(cli.NewApp(), set, nil)
+
+	for maxFiles, expected := range map[int][]string{
+		0: {"file1", "file2", "file3"},
+		2: {"file1", "file2"},
+		5: {"file1", "file2", "file3"},
+	} {
+		cfg := config.Default()
+		cfg.MaxFiles = maxFiles
+		engine := index.NewMemoryIndex()
+		if err := build(c, &cfg, engine); err != nil {
+			t.Fatal(err)
+		}
+		var actual []string
+		for name := range engine.Sources {
+			actual = append(actual, filepath.Base(name))
+		}
+		sort.Strings(actual)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%d: %v is not equal to expected %v", maxFiles, actual, expected)
+		}
+	}
+}
+
+func TestBuild_Records(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	records := "name,content,price,color\napple,red apple,3,red\nbanana,yellow banana,1,yellow\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "fruits.csv"), []byte(records), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("sources", dir, "")
+	set.Bool("quiet", true, "")
+	c := cli.NewContext(cli.NewApp(), set, nil)
+
+	cfg := config.Default()
+	cfg.Records = "csv"
+	cfg.RecordMetadata = "price, ,color"
+	engine := index.NewMemoryIndex()
+	if err := build(c, &cfg, engine); err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for name := range engine.Sources {
+		actual = append(actual, name)
+	}
+	sort.Strings(actual)
+	expected := []string{"apple", "banana"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+	metadata := map[string]string{"price": "1", "color": "yellow"}
+	if actual := engine.Sources["banana"].Metadata; !reflect.DeepEqual(actual, metadata) {
+		t.Errorf("%v is not equal to expected %v", actual, metadata)
+	}
+}
+
+func TestBuild_Incremental(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sources := filepath.Join(dir, "sources")
+	if err := os.Mkdir(sources, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, text string) {
+		if err := ioutil.WriteFile(filepath.Join(sources, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("sources", sources, "")
+	set.String("index", filepath.Join(dir, "index.data"), "")
+	set.Bool("quiet", true, "")
+	c := cli.NewContext(cli.NewApp(), set, nil)
+	cfg := config.Default()
+	cfg.Incremental = true
+	rebuild := func() *index.MemoryIndex {
+		engine, err := buildEngine(c, &cfg, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := build(c, &cfg, engine); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeIndex(c, engine.Encode); err != nil {
+			t.Fatal(err)
+		}
+		return engine
+	}
+
+	write("unchanged", "apple banana")
+	write("modified", "apple kiwi")
+	built := rebuild()
+	unchanged, modified := *built.Sources[filepath.Join(sources, "unchanged")], *built.Sources[filepath.Join(sources, "modified")]
+
+	// The touched file keeps the modification time of the indexed document.
+	touched := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(sources, "unchanged"), touched, touched); err != nil {
+		t.Fatal(err)
+	}
+	write("modified", "apple cherry")
+	write("new", "cherry")
+	rebuilt := rebuild()
+	if actual := *rebuilt.Sources[filepath.Join(sources, "unchanged")]; !reflect.DeepEqual(actual, unchanged) {
+		t.Errorf("%+v is not equal to expected %+v", actual, unchanged)
+	}
+	if actual := rebuilt.Sources[filepath.Join(sources, "modified")]; actual.Hash == modified.Hash {
+		t.Errorf("%s is not reindexed", actual.Name)
+	}
+	var actual []string
+	for name := range rebuilt.Sources {
+		actual = append(actual, filepath.Base(name))
+	}
+	sort.Strings(actual)
+	expected := []string{"modified", "new", "unchanged"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+
+	if _, err := buildEngine(c, &cfg, 2); err == nil {
+		t.Error("sharded index is built incrementally")
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	input, err := os.Open(filepath.Join("testdata", "tokenize.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+
+	cfg := config.Default()
+	cfg.SplitIdentifiers = true
+	var output bytes.Buffer
+	if err := tokenize(&cfg, input, &output); err != nil {
+		t.Fatal(err)
+	}
+	expected := "0\tappl\n1\tbanana\n2\tconfig.json\n2\tconfig\n2\tjson\n3\tcherri\n"
+	if output.String() != expected {
+		t.Errorf("%q is not equal to expected %q", output.String(), expected)
+	}
+
+	cfg.Language = "xx"
+	if err := tokenize(&cfg, bytes.NewBufferString("apple"), &output); !errors.Is(err, index.ErrUnknownLanguage) {
+		t.Errorf("%v is not equal to expected %v", err, index.ErrUnknownLanguage)
+	}
+}
+
+func TestServe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listen := listener.Addr().String()
+	listener.Close()
+
+	i := index.NewIndex(index.NewMemoryIndex(), nil)
+	if err := i.AddSource("apple", bytes.NewBufferString("apple")); err != nil {
+		t.Fatal(err)
+	}
+	iface, err := ws.New(listen, time.Second, i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(iface, signals, time.Second)
+	}()
+
+	var response *http.Response
+	for attempt := 0; attempt < 50; attempt++ {
+		if response, err = http.Get("http://" + listen + "/api/search?q=apple"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("%d is not equal to expected %d", response.StatusCode, http.StatusOK)
+	}
+
+	signals <- syscall.SIGTERM
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve does not return after the signal")
+	}
+}
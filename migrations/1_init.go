@@ -38,15 +38,19 @@ func init() {
 			);`)
 		return err
 	}, func(db migrations.DB) error {
-		if _, err := db.Exec(`DROP TABLE public.words;`); err != nil {
-			return err
-		}
-		if _, err := db.Exec(`DROP TABLE public.documents;`); err != nil {
-			return err
-		}
-		if _, err := db.Exec(`DROP TABLE public.occurrences;`); err != nil {
-			return err
+		for _, statement := range initDown {
+			if _, err := db.Exec(statement); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
 }
+
+// initDown drops the tables of the initial schema, the occurrences referencing the others are dropped first. The
+// missing tables are skipped, so the reset can be run again after the failure.
+var initDown = []string{
+	`DROP TABLE IF EXISTS public.occurrences;`,
+	`DROP TABLE IF EXISTS public.documents;`,
+	`DROP TABLE IF EXISTS public.tokens;`,
+}
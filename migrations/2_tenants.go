@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		if _, err := db.Exec(`ALTER TABLE public.documents
+			ADD COLUMN tenant_id text NOT NULL DEFAULT '';`); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`ALTER TABLE public.occurrences
+			ADD COLUMN tenant_id text NOT NULL DEFAULT '';`); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`CREATE INDEX documents_tenant_name_idx
+			ON public.documents (tenant_id, name);`); err != nil {
+			return err
+		}
+		_, err := db.Exec(`CREATE INDEX occurrences_tenant_token_idx
+			ON public.occurrences (tenant_id, token_id);`)
+		return err
+	}, func(db migrations.DB) error {
+		if _, err := db.Exec(`DROP INDEX public.occurrences_tenant_token_idx;`); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`DROP INDEX public.documents_tenant_name_idx;`); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`ALTER TABLE public.occurrences DROP COLUMN tenant_id;`); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE public.documents DROP COLUMN tenant_id;`)
+		return err
+	})
+}
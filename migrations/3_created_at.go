@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		if _, err := db.Exec(`ALTER TABLE public.documents
+			ADD COLUMN created_at timestamptz;`); err != nil {
+			return err
+		}
+		_, err := db.Exec(`CREATE INDEX documents_tenant_created_at_idx
+			ON public.documents (tenant_id, created_at);`)
+		return err
+	}, func(db migrations.DB) error {
+		if _, err := db.Exec(`DROP INDEX public.documents_tenant_created_at_idx;`); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE public.documents DROP COLUMN created_at;`)
+		return err
+	})
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`ALTER TABLE public.documents
+			ADD COLUMN language text;`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`ALTER TABLE public.documents DROP COLUMN language;`)
+		return err
+	})
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		if _, err := db.Exec(`DELETE FROM public.occurrences AS a
+			USING public.occurrences AS b
+			WHERE a.id > b.id AND a.token_id = b.token_id AND a.document_id = b.document_id
+				AND a.position = b.position;`); err != nil {
+			return err
+		}
+		_, err := db.Exec(`ALTER TABLE public.occurrences
+			ADD CONSTRAINT occurrences_token_document_position_key UNIQUE (token_id, document_id, position);`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`ALTER TABLE public.occurrences
+			DROP CONSTRAINT occurrences_token_document_position_key;`)
+		return err
+	})
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/go-pg/migrations/v7"
+)
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`CREATE INDEX tokens_token_prefix_idx
+			ON public.tokens (token text_pattern_ops);`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`DROP INDEX public.tokens_token_prefix_idx;`)
+		return err
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultConnectAttempts is the number of the connection attempts if CONNECT_ATTEMPTS is not set.
+const defaultConnectAttempts = 10
+
+// defaultConnectDelay is the delay before the second connection attempt if CONNECT_DELAY is not set.
+const defaultConnectDelay = time.Second
+
+// maxConnectDelay caps the delay between the connection attempts.
+const maxConnectDelay = 30 * time.Second
+
+// connectOptions returns the number of the connection attempts and the delay before the second one from
+// CONNECT_ATTEMPTS and CONNECT_DELAY environment variables, e.g. `CONNECT_ATTEMPTS=20 CONNECT_DELAY=500ms`.
+func connectOptions() (int, time.Duration, error) {
+	attempts := defaultConnectAttempts
+	if value := os.Getenv("CONNECT_ATTEMPTS"); value != "" {
+		var err error
+		if attempts, err = strconv.Atoi(value); err != nil || attempts < 1 {
+			return 0, 0, fmt.Errorf("incorrect CONNECT_ATTEMPTS %s", value)
+		}
+	}
+	delay := defaultConnectDelay
+	if value := os.Getenv("CONNECT_DELAY"); value != "" {
+		var err error
+		if delay, err = time.ParseDuration(value); err != nil || delay < 0 {
+			return 0, 0, fmt.Errorf("incorrect CONNECT_DELAY %s", value)
+		}
+	}
+	return attempts, delay, nil
+}
+
+// waitConnection calls ping until it succeeds or the attempts are exhausted, e.g. until the database started in the
+// next container accepts the connections. The delay between the attempts doubles up to maxConnectDelay. The error of
+// the last attempt is returned.
+func waitConnection(ping func() error, attempts int, delay time.Duration, sleep func(time.Duration)) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ping(); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		log.Printf("database is not reachable, attempt %d of %d: %s", attempt, attempts, err)
+		sleep(delay)
+		if delay *= 2; delay > maxConnectDelay {
+			delay = maxConnectDelay
+		}
+	}
+	return fmt.Errorf("database is not reachable after %d attempts: %w", attempts, err)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWaitConnection(t *testing.T) {
+	errRefused := errors.New("connection refused")
+	for _, test := range []struct {
+		failures int
+		attempts int
+		fails    bool
+		delays   []time.Duration
+	}{
+		{0, 3, false, nil},
+		{2, 3, false, []time.Duration{time.Second, 2 * time.Second}},
+		{3, 3, true, []time.Duration{time.Second, 2 * time.Second}},
+		{6, 7, false, []time.Duration{
+			time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second,
+		}},
+	} {
+		pings := 0
+		ping := func() error {
+			pings++
+			if pings <= test.failures {
+				return errRefused
+			}
+			return nil
+		}
+		var delays []time.Duration
+		sleep := func(delay time.Duration) {
+			delays = append(delays, delay)
+		}
+
+		err := waitConnection(ping, test.attempts, time.Second, sleep)
+		if fails := err != nil; fails != test.fails {
+			t.Errorf("%d failures: %v is not equal to expected %v", test.failures, err, test.fails)
+		}
+		if err != nil && !errors.Is(err, errRefused) {
+			t.Errorf("%v is not equal to expected %v", err, errRefused)
+		}
+		if !reflect.DeepEqual(delays, test.delays) {
+			t.Errorf("%d failures: %v is not equal to expected %v", test.failures, delays, test.delays)
+		}
+	}
+}
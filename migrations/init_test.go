@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestInitDown(t *testing.T) {
+	drop := regexp.MustCompile(`^DROP TABLE IF EXISTS public\.(\w+);$`)
+	var actual []string
+	for _, statement := range initDown {
+		match := drop.FindStringSubmatch(strings.TrimSpace(statement))
+		if match == nil {
+			t.Fatalf("%q is not the idempotent drop of the table", statement)
+		}
+		actual = append(actual, match[1])
+	}
+	// The occurrences reference the documents and the tokens, so they are dropped first.
+	expected := []string{"occurrences", "documents", "tokens"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%v is not equal to expected %v", actual, expected)
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/go-pg/migrations/v7"
 	"github.com/go-pg/pg/v9"
+
+	"github.com/polisgo2020/search-tariel-x/config"
 )
 
 const usageText = `This program runs command on the db. Supported commands are:
@@ -19,18 +22,29 @@ const usageText = `This program runs command on the db. Supported commands are:
   - version - prints current db version.
   - set_version [version] - sets db version without running migrations.
 Usage:
-  go run *.go <command> [args]
+  go run . <command> [args]
 `
 
 func main() {
 	flag.Usage = usage
 	flag.Parse()
 
-	opts, err := pg.ParseURL(os.Getenv("PGSQL"))
+	opts, err := config.PostgresqlOptions(os.Getenv("PGSQL"))
 	if err != nil {
 		log.Fatal(err)
 	}
 	db := pg.Connect(opts)
+	attempts, delay, err := connectOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	ping := func() error {
+		_, err := db.Exec("SELECT 1")
+		return err
+	}
+	if err := waitConnection(ping, attempts, delay, time.Sleep); err != nil {
+		log.Fatal(err)
+	}
 
 	oldVersion, newVersion, err := migrations.Run(db, flag.Args()...)
 	if err != nil {